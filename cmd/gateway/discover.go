@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/lei/simple-ci/internal/config"
+	"github.com/lei/simple-ci/internal/discovery"
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+// runDiscover implements "gateway discover": it walks Concourse, appends jobs
+// without a definition to the jobs file and reports stale definitions
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	teams := fs.String("teams", "", "comma-separated teams to walk (default: every visible team)")
+	pipelines := fs.String("pipelines", "", "comma-separated pipeline name globs (default: all)")
+	jobID := fs.String("job-id", discovery.DefaultJobIDTemplate, "job_id template")
+	project := fs.String("project", discovery.DefaultProjectTemplate, "project template")
+	environment := fs.String("environment", "", "environment template")
+	jobsFile := fs.String("jobs-file", "", "jobs file to merge into; a missing file starts a new one (default: from configuration)")
+	output := fs.String("o", "-", "where to write the merged jobs file (- for stdout)")
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *jobsFile == "" {
+		*jobsFile = cfg.JobsFile
+	}
+
+	adapter, err := newAdapter(cfg)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*jobsFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read jobs file: %w", err)
+	}
+	var existing []*models.Job
+	if len(data) > 0 {
+		if existing, err = config.LoadJobs(*jobsFile); err != nil {
+			return err
+		}
+	}
+
+	result, err := discovery.Import(context.Background(), adapter, existing, discovery.Options{
+		Teams:     splitList(*teams),
+		Pipelines: splitList(*pipelines),
+		Templates: discovery.Templates{
+			JobID:       *jobID,
+			Project:     *project,
+			Environment: *environment,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, job := range result.Added {
+		fmt.Fprintf(os.Stderr, "added     %s\n", job.JobID)
+	}
+	for _, c := range result.Conflicts {
+		fmt.Fprintf(os.Stderr, "conflict  %s: job_id already used, skipped %s/%s/%s\n", c.JobID, c.Team, c.Pipeline, c.Job)
+	}
+	for _, st := range result.Stale {
+		fmt.Fprintf(os.Stderr, "stale     %s: %s/%s/%s no longer exists\n", st.JobID, st.Team, st.Pipeline, st.Job)
+	}
+	fmt.Fprintf(os.Stderr, "%d added, %d unchanged, %d stale, %d conflicts\n",
+		len(result.Added), len(result.Unchanged), len(result.Stale), len(result.Conflicts))
+
+	if *dryRun {
+		return nil
+	}
+
+	merged, err := config.AppendJobs(data, result.Added)
+	if err != nil {
+		return err
+	}
+	if *output == "-" {
+		_, err = os.Stdout.Write(merged)
+		return err
+	}
+	return os.WriteFile(*output, merged, 0o644)
+}
+
+// newAdapter creates a Concourse adapter for command line use. Logs go to
+// stderr so results can be written to stdout.
+func newAdapter(cfg *config.Config) (*concourse.Adapter, error) {
+	adapter, err := concourse.NewAdapter(&concourse.Config{
+		URL:                cfg.Concourse.URL,
+		Team:               cfg.Concourse.Team,
+		Username:           cfg.Concourse.Username,
+		Password:           cfg.Concourse.Password,
+		BearerToken:        cfg.Concourse.BearerToken,
+		TokenRefreshMargin: cfg.Concourse.TokenRefreshMargin,
+	}, stderrLogger())
+	if err != nil {
+		return nil, fmt.Errorf("initialize concourse provider: %w", err)
+	}
+	return adapter, nil
+}
+
+// stderrLogger logs warnings and errors to stderr
+func stderrLogger() *logger.Logger {
+	return &logger.Logger{Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))}
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
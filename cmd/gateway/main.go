@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -21,14 +22,20 @@ func run() error {
 	// Load .env file (ignore error if file doesn't exist - env vars might be set externally)
 	_ = godotenv.Load()
 
-	// Determine jobs file path from environment or use default
-	jobsFile := os.Getenv("JOBS_FILE")
-	if jobsFile == "" {
-		jobsFile = "configs/jobs.yaml"
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "discover":
+			return runDiscover(os.Args[2:])
+		case "validate":
+			return runValidate(os.Args[2:])
+		default:
+			return fmt.Errorf("unknown command %q (available: discover, validate)", os.Args[1])
+		}
 	}
 
-	// Create gateway from environment configuration
-	gw, err := gateway.NewFromEnv(jobsFile)
+	// Create gateway from CONFIG_FILE and environment configuration
+	gw, err := gateway.NewFromEnv("")
 	if err != nil {
 		return err
 	}
@@ -37,6 +44,15 @@ func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// Reload jobs file on SIGHUP; failures are logged and reported in /health?detailed=true
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_ = gw.Reload(ctx)
+		}
+	}()
+
 	// Start the gateway (blocks until shutdown)
 	return gw.Start(ctx)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lei/simple-ci/internal/config"
+	"github.com/lei/simple-ci/internal/service"
+)
+
+// runValidate implements "gateway validate": it loads the jobs file and checks
+// every job against the provider, failing if any job is invalid or unavailable
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	jobsFile := fs.String("jobs-file", "", "jobs file to validate (default: from configuration)")
+	timeout := fs.Duration("timeout", time.Minute, "how long to wait for the provider")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *jobsFile == "" {
+		*jobsFile = cfg.JobsFile
+	}
+
+	jobsConfig, err := config.LoadJobsFile(*jobsFile)
+	if err != nil {
+		return err
+	}
+	adapter, err := newAdapter(cfg)
+	if err != nil {
+		return err
+	}
+
+	svc, err := service.NewService(jobsConfig.Jobs, adapter, stderrLogger(), service.Options{
+		Workflows:  jobsConfig.Workflows,
+		Freezes:    jobsConfig.Freezes,
+		Approvals:  jobsConfig.Approvals,
+		Validation: service.ValidationStrict,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", *jobsFile, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	results, err := svc.ValidateJobs(ctx)
+
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if avail := results[id]; avail.Available {
+			fmt.Printf("ok           %s\n", id)
+		} else {
+			fmt.Printf("unavailable  %s: %s\n", id, avail.Reason)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d jobs valid\n", *jobsFile, len(results))
+	return nil
+}
@@ -93,6 +93,9 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
+	// Start gateway background processing (queued runs, run tracking)
+	gw.StartBackground(ctx)
+
 	// Start server in goroutine
 	go func() {
 		log.Println("starting server on :8080")
@@ -8,9 +8,10 @@ import (
 
 // Context key constants - using plain strings for cross-package compatibility
 const (
-	contextKeyRequestID  = "request_id"
-	contextKeyLogger     = "logger"
-	contextKeyAPIKeyName = "api_key_name"
+	contextKeyRequestID    = "request_id"
+	contextKeyLogger       = "logger"
+	contextKeyAPIKeyName   = "api_key_name"
+	contextKeyAPIKeyScopes = "api_key_scopes"
 )
 
 // GetRequestID retrieves the request ID from context
@@ -36,3 +37,11 @@ func GetAPIKeyName(ctx context.Context) string {
 	}
 	return ""
 }
+
+// GetAPIKeyScopes retrieves the scopes granted to the caller's API key from context
+func GetAPIKeyScopes(ctx context.Context) []string {
+	if scopes, ok := ctx.Value(contextKeyAPIKeyScopes).([]string); ok {
+		return scopes
+	}
+	return nil
+}
@@ -1,11 +1,247 @@
 package api
 
 import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/lei/simple-ci/internal/models"
 	"github.com/lei/simple-ci/internal/provider/concourse"
 )
 
+// JobQuery filters, sorts and pages configured jobs
+type JobQuery struct {
+	Projects     []string           // Any of these projects; empty matches all
+	Environments []string           // Any of these environments; empty matches all
+	Labels       []LabelRequirement // All must match
+	Search       string             // Case-insensitive substring of job_id, display_name or project
+	Sort         string             // job_id (default), project, environment or display_name; "-" prefix sorts descending
+	Offset       int
+	Limit        int // 0 returns all matching jobs
+}
+
+// LabelRequirement is one term of a label selector
+type LabelRequirement struct {
+	Key      string
+	Value    string
+	Exists   bool // Matches on presence of Key only
+	Negative bool // "!=" or "!key"
+}
+
+// jobSortKeys are the fields jobs can be sorted by
+var jobSortKeys = map[string]func(*models.Job) string{
+	"job_id":       func(j *models.Job) string { return j.JobID },
+	"project":      func(j *models.Job) string { return j.Project },
+	"environment":  func(j *models.Job) string { return j.Environment },
+	"display_name": func(j *models.Job) string { return j.DisplayName },
+}
+
+// ParseLabelSelector parses a comma-separated label selector. Terms are
+// "key=value" (or "key==value"), "key!=value", "key" (label set) and "!key"
+// (label not set).
+func ParseLabelSelector(selector string) ([]LabelRequirement, error) {
+	var reqs []LabelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			req.Key, req.Value, _ = strings.Cut(term, "!=")
+			req.Negative = true
+		case strings.Contains(term, "=="):
+			req.Key, req.Value, _ = strings.Cut(term, "==")
+		case strings.Contains(term, "="):
+			req.Key, req.Value, _ = strings.Cut(term, "=")
+		case strings.HasPrefix(term, "!"):
+			req.Key, req.Exists, req.Negative = term[1:], true, true
+		default:
+			req.Key, req.Exists = term, true
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if req.Key == "" || strings.ContainsAny(req.Key, "=!") || strings.ContainsAny(req.Value, "=!") {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// Matches reports whether labels satisfy the requirement. "key!=value"
+// matches jobs without the label, as in Kubernetes.
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	if r.Exists {
+		return ok != r.Negative
+	}
+	return (ok && value == r.Value) != r.Negative
+}
+
+// parseJobQuery parses GET /v1/jobs query parameters. Repeated project,
+// environment and label parameters are combined.
+func parseJobQuery(values url.Values) (JobQuery, error) {
+	q := JobQuery{
+		Projects:     splitParams(values["project"]),
+		Environments: splitParams(values["environment"]),
+		Search:       values.Get("search"),
+		Sort:         values.Get("sort"),
+	}
+
+	for _, selector := range values["label"] {
+		reqs, err := ParseLabelSelector(selector)
+		if err != nil {
+			return JobQuery{}, err
+		}
+		q.Labels = append(q.Labels, reqs...)
+	}
+
+	if _, ok := jobSortKeys[strings.TrimPrefix(q.Sort, "-")]; q.Sort != "" && !ok {
+		return JobQuery{}, fmt.Errorf("invalid sort %q (expected job_id, project, environment or display_name)", q.Sort)
+	}
+
+	for name, dst := range map[string]*int{"offset": &q.Offset, "limit": &q.Limit} {
+		if raw := values.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return JobQuery{}, fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	return q, nil
+}
+
+// parseInclude parses the include query parameter of GET /v1/jobs and
+// reports whether latest runs were requested
+func parseInclude(value string) (bool, error) {
+	latestRun := false
+	for _, item := range strings.Split(value, ",") {
+		switch strings.TrimSpace(item) {
+		case "":
+		case "latest_run":
+			latestRun = true
+		default:
+			return false, fmt.Errorf("invalid include %q (expected latest_run)", item)
+		}
+	}
+	return latestRun, nil
+}
+
+// maxVersionPageLimit caps the page size of resource version listings
+const maxVersionPageLimit = 100
+
+// parseVersionPage parses the limit, from and to query parameters of resource
+// version listings. Limit defaults to 20.
+func parseVersionPage(values url.Values) (concourse.Page, error) {
+	page := concourse.Page{Limit: 20}
+	for name, dst := range map[string]*int{"limit": &page.Limit, "from": &page.From, "to": &page.To} {
+		if raw := values.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				return concourse.Page{}, fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	if page.From > 0 && page.To > 0 {
+		return concourse.Page{}, fmt.Errorf("from and to are mutually exclusive")
+	}
+	if page.Limit > maxVersionPageLimit {
+		page.Limit = maxVersionPageLimit
+	}
+	return page, nil
+}
+
+// splitParams flattens repeated and comma-separated query parameter values
+func splitParams(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// QueryJobs filters and sorts jobs and returns the requested page along with
+// the number of matching jobs. Ties are ordered by job_id so pages are stable.
+func QueryJobs(jobs []*models.Job, q JobQuery) ([]*models.Job, int) {
+	searchLower := strings.ToLower(q.Search)
+
+	matched := make([]*models.Job, 0, len(jobs))
+	for _, j := range jobs {
+		if len(q.Projects) > 0 && !containsString(q.Projects, j.Project) {
+			continue
+		}
+		if len(q.Environments) > 0 && !containsString(q.Environments, j.Environment) {
+			continue
+		}
+		if q.Search != "" &&
+			!strings.Contains(strings.ToLower(j.JobID), searchLower) &&
+			!strings.Contains(strings.ToLower(j.DisplayName), searchLower) &&
+			!strings.Contains(strings.ToLower(j.Project), searchLower) {
+			continue
+		}
+		if !matchesLabels(q.Labels, j.Labels) {
+			continue
+		}
+		matched = append(matched, j)
+	}
+
+	field := strings.TrimPrefix(q.Sort, "-")
+	desc := strings.HasPrefix(q.Sort, "-")
+	key, ok := jobSortKeys[field]
+	if !ok {
+		key = jobSortKeys["job_id"]
+	}
+	sort.SliceStable(matched, func(a, b int) bool {
+		ka, kb := key(matched[a]), key(matched[b])
+		if ka != kb {
+			return (ka < kb) != desc
+		}
+		return matched[a].JobID < matched[b].JobID
+	})
+
+	total := len(matched)
+	if q.Offset >= total {
+		return []*models.Job{}, total
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
+
+// matchesLabels reports whether labels satisfy every requirement
+func matchesLabels(reqs []LabelRequirement, labels map[string]string) bool {
+	for _, req := range reqs {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // FilterPipelines filters pipelines based on query parameters
 func FilterPipelines(pipelines []concourse.Pipeline, search string, paused, archived *bool) []concourse.Pipeline {
 	if search == "" && paused == nil && archived == nil {
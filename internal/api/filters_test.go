@@ -1,8 +1,11 @@
 package api
 
 import (
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/lei/simple-ci/internal/models"
 	"github.com/lei/simple-ci/internal/provider/concourse"
 )
 
@@ -71,6 +74,62 @@ func TestFilterJobs(t *testing.T) {
 	}
 }
 
+func TestQueryJobs(t *testing.T) {
+	jobs := []*models.Job{
+		{JobID: "pay-deploy", Project: "payments", Environment: "prod", Labels: map[string]string{"team": "payments", "tier": "web"}},
+		{JobID: "pay-batch", Project: "payments", Environment: "prod", Labels: map[string]string{"team": "payments", "tier": "batch"}},
+		{JobID: "pay-build", Project: "payments", Environment: "dev", DisplayName: "Build", Labels: map[string]string{"team": "payments"}},
+		{JobID: "shop-deploy", Project: "shop", Environment: "prod", Labels: map[string]string{"team": "shop"}},
+		{JobID: "docs", Project: "docs", Environment: "dev"},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string // Job IDs of the page in order
+		total int
+	}{
+		{"no filters sorted by job_id", "", "docs,pay-batch,pay-build,pay-deploy,shop-deploy", 5},
+		{"project", "project=payments", "pay-batch,pay-build,pay-deploy", 3},
+		{"projects", "project=shop&project=docs", "docs,shop-deploy", 2},
+		{"environment", "environment=dev", "docs,pay-build", 2},
+		{"label selector", "label=team=payments,tier!=batch", "pay-build,pay-deploy", 2},
+		{"label exists", "label=tier", "pay-batch,pay-deploy", 2},
+		{"label missing", "label=!team", "docs", 1},
+		{"search", "search=BUILD", "pay-build", 1},
+		{"sort descending", "sort=-project&environment=prod", "shop-deploy,pay-batch,pay-deploy", 3},
+		{"page", "limit=2&offset=1", "pay-batch,pay-build", 5},
+		{"offset past end", "offset=10", "", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			q, err := parseJobQuery(values)
+			if err != nil {
+				t.Fatalf("parseJobQuery() error = %v", err)
+			}
+			page, total := QueryJobs(jobs, q)
+			ids := make([]string, len(page))
+			for i, j := range page {
+				ids[i] = j.JobID
+			}
+			if got := strings.Join(ids, ","); got != tt.want || total != tt.total {
+				t.Errorf("QueryJobs() = %q (total %d), want %q (total %d)", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestParseJobQuery_Invalid(t *testing.T) {
+	for _, query := range []string{"label=team=a=b", "label=!=x", "sort=created", "limit=-1", "offset=x"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseJobQuery(values); err == nil {
+			t.Errorf("parseJobQuery(%q) error = nil, want error", query)
+		}
+	}
+}
+
 func TestParseBoolParam(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -102,3 +161,21 @@ func TestParseBoolParam(t *testing.T) {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func TestParseVersionPage(t *testing.T) {
+	values, _ := url.ParseQuery("limit=500&to=42")
+	page, err := parseVersionPage(values)
+	if err != nil {
+		t.Fatalf("parseVersionPage() error = %v", err)
+	}
+	if page != (concourse.Page{Limit: maxVersionPageLimit, To: 42}) {
+		t.Errorf("parseVersionPage() = %+v, want limit capped and to 42", page)
+	}
+
+	for _, query := range []string{"limit=0", "from=x", "from=1&to=2"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseVersionPage(values); err == nil {
+			t.Errorf("parseVersionPage(%q) error = nil, want error", query)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/lei/simple-ci/internal/config"
+	"github.com/lei/simple-ci/internal/discovery"
+	"github.com/lei/simple-ci/internal/models"
 	"github.com/lei/simple-ci/internal/provider"
 	"github.com/lei/simple-ci/internal/service"
 )
@@ -51,14 +58,164 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 
 // ListJobs handles GET /v1/jobs
 func (h *Handlers) ListJobs(w http.ResponseWriter, r *http.Request) {
-	jobs := h.service.ListJobs(r.Context())
+	query, err := parseJobQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	withRuns, err := parseInclude(r.URL.Query().Get("include"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jobs, total := QueryJobs(h.service.ListJobs(r.Context()), query)
+	if withRuns {
+		jobs = h.service.WithLatestRuns(r.Context(), jobs)
+	}
+
+	resp := map[string]interface{}{
+		"jobs":  jobs,
+		"total": total,
+	}
+	if next := query.Offset + len(jobs); query.Limit > 0 && next < total {
+		resp["next_offset"] = next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetJob handles GET /v1/jobs/{job_id}
+func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.service.GetJob(r.Context(), chi.URLParam(r, "job_id"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"jobs": jobs,
+		"job": job,
 	})
 }
 
+// maxJobDefinitionSize bounds job definition request bodies
+const maxJobDefinitionSize = 1 << 20
+
+// CreateJob handles POST /v1/jobs
+func (h *Handlers) CreateJob(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	job, ok := decodeJobDefinition(w, r, "")
+	if !ok {
+		return
+	}
+
+	saved, err := h.service.CreateJob(r.Context(), job)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("job created", "job_id", saved.JobID, "revision", saved.Revision)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job": saved,
+	})
+}
+
+// PutJob handles PUT /v1/jobs/{job_id}
+func (h *Handlers) PutJob(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	jobID := chi.URLParam(r, "job_id")
+
+	job, ok := decodeJobDefinition(w, r, jobID)
+	if !ok {
+		return
+	}
+
+	saved, created, err := h.service.PutJob(r.Context(), job)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("job saved", "job_id", saved.JobID, "revision", saved.Revision, "created", created)
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job": saved,
+	})
+}
+
+// DeleteJob handles DELETE /v1/jobs/{job_id}
+func (h *Handlers) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	jobID := chi.URLParam(r, "job_id")
+
+	if err := h.service.DeleteJob(r.Context(), jobID); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("job deleted", "job_id", jobID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListJobRevisions handles GET /v1/jobs/{job_id}/revisions
+func (h *Handlers) ListJobRevisions(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	revisions, err := h.service.ListJobRevisions(r.Context(), jobID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revisions": revisions,
+	})
+}
+
+// decodeJobDefinition parses a job definition body (JSON, or YAML as in
+// jobs.yaml), writing a 400 response if it is invalid
+func decodeJobDefinition(w http.ResponseWriter, r *http.Request, jobID string) (*models.Job, bool) {
+	logger := GetLogger(r.Context())
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJobDefinitionSize))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+
+	job, err := config.ParseJobDefinition(body, jobID)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("invalid job definition", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return job, true
+}
+
 // TriggerRun handles POST /v1/jobs/{job_id}/runs
 func (h *Handlers) TriggerRun(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
@@ -69,8 +226,9 @@ func (h *Handlers) TriggerRun(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Parameters     map[string]interface{} `json:"parameters"`
-		IdempotencyKey string                 `json:"idempotency_key"`
+		Parameters     map[string]interface{}       `json:"parameters"`
+		IdempotencyKey string                       `json:"idempotency_key"`
+		Versions       map[string]map[string]string `json:"versions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -85,10 +243,11 @@ func (h *Handlers) TriggerRun(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("decoded trigger request",
 			"job_id", jobID,
 			"has_parameters", len(req.Parameters) > 0,
+			"has_versions", len(req.Versions) > 0,
 			"has_idempotency_key", req.IdempotencyKey != "")
 	}
 
-	run, err := h.service.TriggerRun(r.Context(), jobID, req.Parameters, req.IdempotencyKey)
+	run, err := h.service.TriggerRunAt(r.Context(), jobID, req.Parameters, req.IdempotencyKey, req.Versions)
 	if err != nil {
 		handleServiceError(w, r, err)
 		return
@@ -101,8 +260,14 @@ func (h *Handlers) TriggerRun(w http.ResponseWriter, r *http.Request) {
 			"status", run.Status)
 	}
 
+	// Runs held by the gateway are accepted but not yet created in the provider
+	status := http.StatusCreated
+	if run.GatewayStatus.IsHeld() || run.GatewayStatus == models.GatewayStatusPendingApproval {
+		status = http.StatusAccepted
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"run": run,
 	})
@@ -117,93 +282,526 @@ func (h *Handlers) GetRun(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("fetching run status", "run_id", runID)
 	}
 
-	run, err := h.service.GetRun(r.Context(), runID)
+	run, err := h.service.GetRun(r.Context(), runID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Debug("run status retrieved",
+			"run_id", runID,
+			"status", run.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run": run,
+	})
+}
+
+// GetRunSteps handles GET /v1/runs/{run_id}/steps
+func (h *Handlers) GetRunSteps(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	if logger != nil {
+		logger.Debug("fetching run steps", "run_id", runID)
+	}
+
+	steps, err := h.service.GetRunSteps(r.Context(), runID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"run_id": runID,
+		"steps":  steps,
+	}
+	if failing := steps.Failing(); failing != nil {
+		response["failing_step"] = failing
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRunResources handles GET /v1/runs/{run_id}/resources
+func (h *Handlers) GetRunResources(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	if logger != nil {
+		logger.Debug("fetching run resources", "run_id", runID)
+	}
+
+	resources, err := h.service.GetRunResources(r.Context(), runID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run_id":  runID,
+		"inputs":  resources.Inputs,
+		"outputs": resources.Outputs,
+	})
+}
+
+// StreamEvents handles GET /v1/runs/{run_id}/events
+func (h *Handlers) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	if logger != nil {
+		logger.Info("starting event stream", "run_id", runID)
+	}
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		if logger != nil {
+			logger.Error("streaming not supported by response writer")
+		}
+		respondError(w, r, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// Send initial connection success event
+	requestID := GetRequestID(r.Context())
+	fmt.Fprintf(w, "event: connected\ndata: {\"request_id\":\"%s\"}\n\n", requestID)
+	flusher.Flush()
+
+	if err := h.service.StreamRunEvents(r.Context(), runID, w); err != nil {
+		// Cannot change headers after streaming starts, but MUST log
+		if logger != nil {
+			logger.Error("streaming error occurred",
+				"run_id", runID,
+				"error", err,
+				"error_type", fmt.Sprintf("%T", err))
+		}
+
+		// Send error event if possible (best effort)
+		fmt.Fprintf(w, "event: error\ndata: {\"message\":\"stream error\",\"request_id\":\"%s\"}\n\n", requestID)
+		flusher.Flush()
+		return
+	}
+
+	if logger != nil {
+		logger.Info("event stream completed successfully", "run_id", runID)
+	}
+	flusher.Flush()
+}
+
+// CancelRun handles POST /v1/runs/{run_id}/cancel
+func (h *Handlers) CancelRun(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	if logger != nil {
+		logger.Info("canceling run", "run_id", runID)
+	}
+
+	if err := h.service.CancelRun(r.Context(), runID); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("run canceled successfully", "run_id", runID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RerunRun handles POST /v1/runs/{run_id}/rerun
+func (h *Handlers) RerunRun(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	if logger != nil {
+		logger.Info("rerunning run", "run_id", runID)
+	}
+
+	run, err := h.service.RerunRun(r.Context(), runID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("run rerun successfully",
+			"run_id", run.RunID,
+			"rerun_of", run.RerunOf,
+			"status", run.Status)
+	}
+
+	status := http.StatusCreated
+	if run.GatewayStatus.IsHeld() || run.GatewayStatus == models.GatewayStatusPendingApproval {
+		status = http.StatusAccepted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run": run,
+	})
+}
+
+// ApproveRun handles POST /v1/runs/{run_id}/approve
+func (h *Handlers) ApproveRun(w http.ResponseWriter, r *http.Request) {
+	h.decideRun(w, r, "approve", h.service.ApproveRun)
+}
+
+// RejectRun handles POST /v1/runs/{run_id}/reject
+func (h *Handlers) RejectRun(w http.ResponseWriter, r *http.Request) {
+	h.decideRun(w, r, "reject", h.service.RejectRun)
+}
+
+// decideRun applies an approval decision with an optional {"comment": "..."} body
+func (h *Handlers) decideRun(w http.ResponseWriter, r *http.Request, decision string, decide func(context.Context, string, string) (*models.Run, error)) {
+	logger := GetLogger(r.Context())
+	runID := chi.URLParam(r, "run_id")
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	run, err := decide(r.Context(), runID, req.Comment)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("run approval decision recorded",
+			"run_id", runID,
+			"decision", decision,
+			"gateway_status", run.GatewayStatus)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run": run,
+	})
+}
+
+// PauseJob handles POST /v1/jobs/{job_id}/pause
+func (h *Handlers) PauseJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// UnpauseJob handles POST /v1/jobs/{job_id}/unpause
+func (h *Handlers) UnpauseJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// setPaused pauses or unpauses a job or its pipeline with an optional
+// {"target": "job|pipeline", "reason": "..."} body
+func (h *Handlers) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	logger := GetLogger(r.Context())
+	jobID := chi.URLParam(r, "job_id")
+
+	var req struct {
+		Target models.PauseTarget `json:"target"`
+		Reason string             `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	job, err := h.service.SetPaused(r.Context(), jobID, req.Target, paused, req.Reason)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("pause state changed",
+			"job_id", jobID,
+			"target", req.Target,
+			"paused", paused)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job": job,
+	})
+}
+
+// ListPauses handles GET /v1/pauses
+func (h *Handlers) ListPauses(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	jobs, events := h.service.ListPauses(r.Context())
+
+	if logger != nil {
+		logger.Debug("pauses listed", "count", len(jobs))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":   jobs,
+		"events": events,
+	})
+}
+
+// ListFreezes handles GET /v1/freezes
+func (h *Handlers) ListFreezes(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	freezes, overrides := h.service.ListFreezes(r.Context())
+
+	if logger != nil {
+		logger.Debug("freezes listed", "count", len(freezes))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"freezes":   freezes,
+		"overrides": overrides,
+	})
+}
+
+// CreateFreeze handles POST /v1/freezes
+func (h *Handlers) CreateFreeze(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	var req struct {
+		Reason       string     `json:"reason"`
+		Projects     []string   `json:"projects"`
+		Environments []string   `json:"environments"`
+		Start        *time.Time `json:"start"`
+		End          *time.Time `json:"end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	f, err := h.service.CreateFreeze(r.Context(), service.FreezeRequest{
+		Reason:       req.Reason,
+		Projects:     req.Projects,
+		Environments: req.Environments,
+		Start:        req.Start,
+		End:          req.End,
+	})
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("freeze created", "freeze_id", f.FreezeID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"freeze": f,
+	})
+}
+
+// DeleteFreeze handles DELETE /v1/freezes/{freeze_id}
+func (h *Handlers) DeleteFreeze(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	freezeID := chi.URLParam(r, "freeze_id")
+
+	if err := h.service.DeleteFreeze(r.Context(), freezeID); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("freeze lifted", "freeze_id", freezeID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSchedules handles GET /v1/schedules
+func (h *Handlers) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	schedules, err := h.service.ListSchedules(r.Context())
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Debug("schedules listed", "count", len(schedules))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schedules": schedules,
+	})
+}
+
+// TriggerBatch handles POST /v1/jobs/{job_id}/runs:batch
+func (h *Handlers) TriggerBatch(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	jobID := chi.URLParam(r, "job_id")
+
+	var req struct {
+		Parameters    map[string]interface{}   `json:"parameters"`
+		Matrix        map[string][]interface{} `json:"matrix"`
+		ParameterSets []map[string]interface{} `json:"parameter_sets"`
+		MaxParallel   int                      `json:"max_parallel"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	batch, err := h.service.TriggerBatch(r.Context(), jobID, service.BatchRequest{
+		Parameters:    req.Parameters,
+		Matrix:        req.Matrix,
+		ParameterSets: req.ParameterSets,
+		MaxParallel:   req.MaxParallel,
+	})
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("batch triggered",
+			"job_id", jobID,
+			"batch_id", batch.BatchID,
+			"runs", len(batch.Runs))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch": batch,
+	})
+}
+
+// GetBatch handles GET /v1/batches/{batch_id}
+func (h *Handlers) GetBatch(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batch_id")
+
+	batch, err := h.service.GetBatch(r.Context(), batchID)
 	if err != nil {
 		handleServiceError(w, r, err)
 		return
 	}
 
-	if logger != nil {
-		logger.Debug("run status retrieved",
-			"run_id", runID,
-			"status", run.Status)
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch": batch,
+	})
+}
+
+// ListWorkflows handles GET /v1/workflows
+func (h *Handlers) ListWorkflows(w http.ResponseWriter, r *http.Request) {
+	workflows := h.service.ListWorkflows(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"run": run,
+		"workflows": workflows,
 	})
 }
 
-// StreamEvents handles GET /v1/runs/{run_id}/events
-func (h *Handlers) StreamEvents(w http.ResponseWriter, r *http.Request) {
+// StartWorkflow handles POST /v1/workflows/{workflow_id}/runs
+func (h *Handlers) StartWorkflow(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
-	runID := chi.URLParam(r, "run_id")
+	workflowID := chi.URLParam(r, "workflow_id")
 
-	if logger != nil {
-		logger.Info("starting event stream", "run_id", runID)
+	var req struct {
+		Parameters map[string]interface{} `json:"parameters"`
 	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		if logger != nil {
-			logger.Error("streaming not supported by response writer")
+			logger.Warn("invalid request body", "error", err)
 		}
-		respondError(w, r, http.StatusInternalServerError, "streaming not supported")
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	// Send initial connection success event
-	requestID := GetRequestID(r.Context())
-	fmt.Fprintf(w, "event: connected\ndata: {\"request_id\":\"%s\"}\n\n", requestID)
-	flusher.Flush()
-
-	if err := h.service.StreamRunEvents(r.Context(), runID, w); err != nil {
-		// Cannot change headers after streaming starts, but MUST log
-		if logger != nil {
-			logger.Error("streaming error occurred",
-				"run_id", runID,
-				"error", err,
-				"error_type", fmt.Sprintf("%T", err))
-		}
-
-		// Send error event if possible (best effort)
-		fmt.Fprintf(w, "event: error\ndata: {\"message\":\"stream error\",\"request_id\":\"%s\"}\n\n", requestID)
-		flusher.Flush()
+	run, err := h.service.StartWorkflow(r.Context(), workflowID, req.Parameters)
+	if err != nil {
+		handleServiceError(w, r, err)
 		return
 	}
 
 	if logger != nil {
-		logger.Info("event stream completed successfully", "run_id", runID)
+		logger.Info("workflow started",
+			"workflow_id", workflowID,
+			"workflow_run_id", run.WorkflowRunID)
 	}
-	flusher.Flush()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_run": run,
+	})
 }
 
-// CancelRun handles POST /v1/runs/{run_id}/cancel
-func (h *Handlers) CancelRun(w http.ResponseWriter, r *http.Request) {
-	logger := GetLogger(r.Context())
-	runID := chi.URLParam(r, "run_id")
+// GetWorkflowRun handles GET /v1/workflow-runs/{workflow_run_id}
+func (h *Handlers) GetWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	workflowRunID := chi.URLParam(r, "workflow_run_id")
 
-	if logger != nil {
-		logger.Info("canceling run", "run_id", runID)
+	run, err := h.service.GetWorkflowRun(r.Context(), workflowRunID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
 	}
 
-	if err := h.service.CancelRun(r.Context(), runID); err != nil {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_run": run,
+	})
+}
+
+// CancelWorkflowRun handles POST /v1/workflow-runs/{workflow_run_id}/cancel
+func (h *Handlers) CancelWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	workflowRunID := chi.URLParam(r, "workflow_run_id")
+
+	run, err := h.service.CancelWorkflowRun(r.Context(), workflowRunID)
+	if err != nil {
 		handleServiceError(w, r, err)
 		return
 	}
 
 	if logger != nil {
-		logger.Info("run canceled successfully", "run_id", runID)
+		logger.Info("workflow canceled", "workflow_run_id", workflowRunID)
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_run": run,
+	})
 }
 
 // respondError writes a JSON error response with logging
@@ -231,6 +829,23 @@ func respondError(w http.ResponseWriter, r *http.Request, status int, message st
 	})
 }
 
+// respondValidationError writes a 422 response listing the rejected fields
+func respondValidationError(w http.ResponseWriter, r *http.Request, err *service.ValidationError) {
+	requestID := GetRequestID(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-ID", requestID)
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":    "invalid parameters",
+			"code":       http.StatusUnprocessableEntity,
+			"request_id": requestID,
+			"fields":     err.Fields,
+		},
+	})
+}
+
 // ListPipelines handles GET /v1/discovery/pipelines
 func (h *Handlers) ListPipelines(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
@@ -367,6 +982,178 @@ func (h *Handlers) ListJobBuilds(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ListPipelineResources handles GET /v1/discovery/pipelines/{pipeline}/resources
+func (h *Handlers) ListPipelineResources(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+
+	if logger != nil {
+		logger.Debug("listing resources", "pipeline", pipeline)
+	}
+
+	resources, err := h.service.ListPipelineResources(r.Context(), pipeline)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resources listed", "pipeline", pipeline, "count", len(resources))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resources": resources,
+	})
+}
+
+// ListPipelineResourceTypes handles GET /v1/discovery/pipelines/{pipeline}/resource-types
+func (h *Handlers) ListPipelineResourceTypes(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+
+	if logger != nil {
+		logger.Debug("listing resource types", "pipeline", pipeline)
+	}
+
+	types, err := h.service.ListPipelineResourceTypes(r.Context(), pipeline)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resource types listed", "pipeline", pipeline, "count", len(types))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resource_types": types,
+	})
+}
+
+// ListResourceVersions handles GET /v1/discovery/pipelines/{pipeline}/resources/{resource}/versions
+func (h *Handlers) ListResourceVersions(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+	resource := chi.URLParam(r, "resource")
+
+	page, err := parseVersionPage(r.URL.Query())
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if logger != nil {
+		logger.Debug("listing resource versions", "pipeline", pipeline, "resource", resource, "limit", page.Limit)
+	}
+
+	versions, pagination, err := h.service.ListResourceVersions(r.Context(), pipeline, resource, page)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resource versions listed", "pipeline", pipeline, "resource", resource, "count", len(versions))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"versions":   versions,
+		"pagination": pagination,
+	})
+}
+
+// CheckResource handles POST /v1/discovery/pipelines/{pipeline}/resources/{resource}/check
+// with an optional {"from": {...}} body
+func (h *Handlers) CheckResource(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+	resource := chi.URLParam(r, "resource")
+
+	var req struct {
+		From map[string]string `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	build, err := h.service.CheckResource(r.Context(), pipeline, resource, req.From)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resource check started", "pipeline", pipeline, "resource", resource, "build_id", build.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"build": build,
+	})
+}
+
+// PinResource handles POST /v1/discovery/pipelines/{pipeline}/resources/{resource}/pin
+func (h *Handlers) PinResource(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+	resource := chi.URLParam(r, "resource")
+
+	var req struct {
+		Version   map[string]string `json:"version"`
+		VersionID int               `json:"version_id"`
+		Comment   string            `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	err := h.service.PinResource(r.Context(), pipeline, resource, service.PinRequest{
+		Version:   req.Version,
+		VersionID: req.VersionID,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resource pinned", "pipeline", pipeline, "resource", resource)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinResource handles POST /v1/discovery/pipelines/{pipeline}/resources/{resource}/unpin
+func (h *Handlers) UnpinResource(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	pipeline := chi.URLParam(r, "pipeline")
+	resource := chi.URLParam(r, "resource")
+
+	if err := h.service.UnpinResource(r.Context(), pipeline, resource); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("resource unpinned", "pipeline", pipeline, "resource", resource)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ListTeams handles GET /v1/discovery/teams
 func (h *Handlers) ListTeams(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
@@ -416,6 +1203,57 @@ func (h *Handlers) ListTeamPipelines(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ImportJobs handles POST /v1/discovery/import
+func (h *Handlers) ImportJobs(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+
+	var req struct {
+		Teams     []string            `json:"teams"`
+		Pipelines []string            `json:"pipelines"`
+		Templates discovery.Templates `json:"templates"`
+		DryRun    *bool               `json:"dry_run"` // Defaults to true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if logger != nil {
+			logger.Warn("invalid request body", "error", err)
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	result, err := h.service.ImportJobs(r.Context(), discovery.Options{
+		Teams:     req.Teams,
+		Pipelines: req.Pipelines,
+		Templates: req.Templates,
+	}, dryRun)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	// Ready to paste into jobs.yaml
+	jobsYAML, err := config.AppendJobs(nil, result.Added)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if logger != nil {
+		logger.Info("jobs imported", "added", len(result.Added), "stale", len(result.Stale), "dry_run", dryRun)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run":   dryRun,
+		"added":     result.Added,
+		"unchanged": result.Unchanged,
+		"stale":     result.Stale,
+		"conflicts": result.Conflicts,
+		"jobs_yaml": string(jobsYAML),
+	})
+}
+
 // handleServiceError maps service errors to HTTP responses with detailed logging
 func handleServiceError(w http.ResponseWriter, r *http.Request, err error) {
 	logger := GetLogger(r.Context())
@@ -429,11 +1267,100 @@ func handleServiceError(w http.ResponseWriter, r *http.Request, err error) {
 			"request_id", requestID)
 	}
 
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		respondValidationError(w, r, validationErr)
+		return
+	}
+
+	var freezeErr *service.FreezeError
+	if errors.As(err, &freezeErr) {
+		respondError(w, r, http.StatusLocked, freezeErr.Error())
+		return
+	}
+
+	var rateErr *service.RateLimitError
+	if errors.As(err, &rateErr) {
+		if rateErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+		}
+		respondError(w, r, http.StatusTooManyRequests, rateErr.Error())
+		return
+	}
+
 	switch {
 	case errors.Is(err, service.ErrJobNotFound):
 		respondError(w, r, http.StatusNotFound, "job not found")
 	case errors.Is(err, service.ErrRunNotFound):
 		respondError(w, r, http.StatusNotFound, "run not found")
+	case errors.Is(err, service.ErrConcurrencyLimit):
+		respondError(w, r, http.StatusConflict, "job concurrency limit reached")
+	case errors.Is(err, service.ErrRunNotDispatched):
+		respondError(w, r, http.StatusConflict, "run has not been dispatched to the provider yet")
+	case errors.Is(err, service.ErrApproverScopeRequired):
+		respondError(w, r, http.StatusForbidden, "approver scope required")
+	case errors.Is(err, service.ErrSelfApproval):
+		respondError(w, r, http.StatusForbidden, "runs cannot be approved by the caller who triggered them")
+	case errors.Is(err, service.ErrRunNotPendingApproval):
+		respondError(w, r, http.StatusConflict, "run is not pending approval")
+	case errors.Is(err, service.ErrAlreadyApproved):
+		respondError(w, r, http.StatusConflict, "run already approved by this caller")
+	case errors.Is(err, service.ErrFreezeNotFound):
+		respondError(w, r, http.StatusNotFound, "freeze not found")
+	case errors.Is(err, service.ErrFreezeReadOnly):
+		respondError(w, r, http.StatusConflict, "freeze is defined in the jobs configuration")
+	case errors.Is(err, service.ErrFreezeOverrideScopeRequired):
+		respondError(w, r, http.StatusForbidden, "freeze_override scope required")
+	case errors.Is(err, service.ErrInvalidFreeze):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrJobExists):
+		respondError(w, r, http.StatusConflict, "job already exists")
+	case errors.Is(err, service.ErrJobReadOnly):
+		respondError(w, r, http.StatusConflict, "job is defined in the jobs configuration")
+	case errors.Is(err, service.ErrJobInUse):
+		respondError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidJob):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrJobAdminScopeRequired):
+		respondError(w, r, http.StatusForbidden, "job_admin scope required")
+	case errors.Is(err, service.ErrInvalidImport):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrVersionsUnsupported):
+		respondError(w, r, http.StatusBadRequest, "provider does not support triggering at specific versions")
+	case errors.Is(err, service.ErrOperatorScopeRequired):
+		respondError(w, r, http.StatusForbidden, "operator scope required")
+	case errors.Is(err, service.ErrPauseUnsupported):
+		respondError(w, r, http.StatusBadRequest, "provider does not support pausing jobs")
+	case errors.Is(err, service.ErrInvalidPauseTarget):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrInvalidPin):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, provider.ErrResourceNotFound):
+		respondError(w, r, http.StatusNotFound, "resource not found in provider")
+	case errors.Is(err, provider.ErrJobRefUnresolved):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrStepsUnsupported):
+		respondError(w, r, http.StatusBadRequest, "provider does not support run steps")
+	case errors.Is(err, service.ErrRunResourcesUnsupported):
+		respondError(w, r, http.StatusBadRequest, "provider does not support run resources")
+	case errors.Is(err, service.ErrRerunUnsupported):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrParametersNotRetained):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrVersionPinBusy):
+		respondError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, provider.ErrVersionNotFound):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrBatchNotFound):
+		respondError(w, r, http.StatusNotFound, "batch not found")
+	case errors.Is(err, service.ErrInvalidBatch):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrWorkflowNotFound):
+		respondError(w, r, http.StatusNotFound, "workflow not found")
+	case errors.Is(err, service.ErrWorkflowRunNotFound):
+		respondError(w, r, http.StatusNotFound, "workflow run not found")
+	case errors.Is(err, service.ErrWorkflowRunFinished):
+		respondError(w, r, http.StatusConflict, "workflow run already finished")
 	case errors.Is(err, provider.ErrJobNotFound):
 		respondError(w, r, http.StatusNotFound, "job not found in provider")
 	case errors.Is(err, provider.ErrRunNotFound):
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/internal/service"
+	"github.com/lei/simple-ci/pkg/logger"
 )
 
 func TestHealth_Simple(t *testing.T) {
@@ -28,3 +35,115 @@ func TestHealth_Simple(t *testing.T) {
 		t.Errorf("Health() body = %s, want %s", w.Body.String(), want)
 	}
 }
+
+// newResourceRouter serves the resource check and pin endpoints backed by a
+// fake Concourse API; concourseStatus is the status its check and pin
+// endpoints answer with for resource "repo"
+func newResourceRouter(t *testing.T, concourseStatus int) *chi.Mux {
+	t.Helper()
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/pipelines/p/resources/repo/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(concourseStatus)
+		switch {
+		case concourseStatus >= 400:
+			w.Write([]byte(`{"error":"concourse says no"}`))
+		case strings.HasSuffix(r.URL.Path, "/check"):
+			w.Write([]byte(`{"id":7,"name":"check","status":"started"}`))
+		}
+	}))
+	t.Cleanup(fake.Close)
+
+	log := logger.New("error", "text")
+	adapter, err := concourse.NewAdapter(&concourse.Config{URL: fake.URL, Team: "main", BearerToken: "token"}, log)
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	svc, err := service.NewService(nil, adapter, log, service.Options{})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	h := NewHandlers(svc)
+	r := chi.NewRouter()
+	r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/check", h.CheckResource)
+	r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/pin", h.PinResource)
+	r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/unpin", h.UnpinResource)
+	return r
+}
+
+// sendAs sends a request with the given API key scopes and returns the response
+func sendAs(r http.Handler, method, path, body string, scopes ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), contextKeyAPIKeyName, "ci-bot")
+	req = req.WithContext(context.WithValue(ctx, contextKeyAPIKeyScopes, scopes))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCheckResource_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		concourseStatus int
+		resource        string
+		body            string
+		want            int
+	}{
+		{"started", http.StatusCreated, "repo", `{"from":{"ref":"abc"}}`, http.StatusAccepted},
+		{"no body", http.StatusCreated, "repo", "", http.StatusAccepted},
+		{"invalid body", http.StatusCreated, "repo", `{"from":`, http.StatusBadRequest},
+		{"unknown resource", http.StatusCreated, "missing", "", http.StatusNotFound},
+		{"rejected by concourse", http.StatusBadRequest, "repo", "", http.StatusBadRequest},
+		{"concourse failure", http.StatusInternalServerError, "repo", "", http.StatusBadGateway},
+		{"concourse unavailable", http.StatusServiceUnavailable, "repo", "", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResourceRouter(t, tt.concourseStatus)
+			w := sendAs(r, "POST", "/discovery/pipelines/p/resources/"+tt.resource+"/check", tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPinResource_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		concourseStatus int
+		resource        string
+		body            string
+		scopes          []string
+		want            int
+	}{
+		{"pinned", http.StatusOK, "repo", `{"version_id":3}`, []string{"operator"}, http.StatusNoContent},
+		{"without operator scope", http.StatusOK, "repo", `{"version_id":3}`, nil, http.StatusForbidden},
+		{"invalid body", http.StatusOK, "repo", `{"version_id":"3"}`, []string{"operator"}, http.StatusBadRequest},
+		{"no version", http.StatusOK, "repo", `{}`, []string{"operator"}, http.StatusBadRequest},
+		{"version and id", http.StatusOK, "repo", `{"version":{"ref":"a"},"version_id":3}`, []string{"operator"}, http.StatusBadRequest},
+		{"negative id", http.StatusOK, "repo", `{"version_id":-1}`, []string{"operator"}, http.StatusBadRequest},
+		{"unknown resource", http.StatusOK, "missing", `{"version_id":3}`, []string{"operator"}, http.StatusNotFound},
+		{"concourse failure", http.StatusInternalServerError, "repo", `{"version_id":3}`, []string{"operator"}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResourceRouter(t, tt.concourseStatus)
+			w := sendAs(r, "POST", "/discovery/pipelines/p/resources/"+tt.resource+"/pin", tt.body, tt.scopes...)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	r := newResourceRouter(t, http.StatusOK)
+	if w := sendAs(r, "POST", "/discovery/pipelines/p/resources/repo/unpin", ""); w.Code != http.StatusForbidden {
+		t.Errorf("unpin without scope status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := sendAs(r, "POST", "/discovery/pipelines/p/resources/missing/unpin", "", "operator"); w.Code != http.StatusNotFound {
+		t.Errorf("unpin unknown resource status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -13,16 +13,21 @@ import (
 
 // AuthMiddleware handles API key authentication
 type AuthMiddleware struct {
-	apiKeys map[string]string // key -> name
+	apiKeys map[string]string   // key -> name
+	scopes  map[string][]string // name -> scopes
 }
 
 // NewAuthMiddleware creates a new auth middleware
 func NewAuthMiddleware(keys []config.APIKey) *AuthMiddleware {
 	keyMap := make(map[string]string)
+	scopes := make(map[string][]string)
 	for _, k := range keys {
 		keyMap[k.Key] = k.Name
+		if len(k.Scopes) > 0 {
+			scopes[k.Name] = k.Scopes
+		}
 	}
-	return &AuthMiddleware{apiKeys: keyMap}
+	return &AuthMiddleware{apiKeys: keyMap, scopes: scopes}
 }
 
 // Authenticate validates the API key from the Authorization header
@@ -73,6 +78,7 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		// Add key name to context for logging/audit
 		ctx := context.WithValue(r.Context(), contextKeyAPIKeyName, name)
+		ctx = context.WithValue(ctx, contextKeyAPIKeyScopes, m.scopes[name])
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
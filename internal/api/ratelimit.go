@@ -0,0 +1,91 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lei/simple-ci/internal/ratelimit"
+)
+
+// RateLimitMiddleware enforces per-API-key and per-job token bucket budgets
+type RateLimitMiddleware struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewRateLimitMiddleware creates a new rate limit middleware.
+// A nil limiter disables rate limiting.
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter) *RateLimitMiddleware {
+	return &RateLimitMiddleware{limiter: limiter}
+}
+
+// Triggers applies the trigger budget (mutating requests)
+func (m *RateLimitMiddleware) Triggers(next http.Handler) http.Handler {
+	return m.handler(ratelimit.ClassTrigger, next)
+}
+
+// Reads applies the read budget
+func (m *RateLimitMiddleware) Reads(next http.Handler) http.Handler {
+	return m.handler(ratelimit.ClassRead, next)
+}
+
+// handler charges the API key bucket and, when the route has a job_id, the job bucket.
+// Must run after routing (r.Group/r.With) so URL parameters are available.
+func (m *RateLimitMiddleware) handler(class ratelimit.Class, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := GetLogger(r.Context())
+
+		targets := []ratelimit.Target{{
+			Scope:   ratelimit.ScopeKey,
+			Subject: GetAPIKeyName(r.Context()),
+			Class:   class,
+		}}
+		jobID := chi.URLParam(r, "job_id")
+		if jobID != "" {
+			targets = append(targets, ratelimit.Target{
+				Scope:   ratelimit.ScopeJob,
+				Subject: jobID,
+				Class:   class,
+			})
+		}
+
+		allowed, retryAfter := m.limiter.Allow(targets...)
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			if logger != nil {
+				logger.Warn("rate limit exceeded",
+					"api_key_name", GetAPIKeyName(r.Context()),
+					"job_id", jobID,
+					"class", class,
+					"retry_after_seconds", seconds)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			respondError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Metrics handles GET /metrics with rate limiter state in Prometheus text format
+func (m *RateLimitMiddleware) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if m.limiter == nil {
+		return
+	}
+	if err := m.limiter.WriteMetrics(w); err != nil {
+		if logger := GetLogger(r.Context()); logger != nil {
+			logger.Error("failed to write metrics", "error", err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lei/simple-ci/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware_Triggers(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{
+		JobTrigger: ratelimit.Rate{Limit: 1, Per: time.Minute},
+		Jobs:       map[string]bool{"job_tests": true, "job_hello": true},
+	})
+	m := NewRateLimitMiddleware(limiter)
+
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(m.Triggers)
+		r.Post("/jobs/{job_id}/runs", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+	})
+
+	send := func(jobID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/jobs/"+jobID+"/runs", nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextKeyAPIKeyName, "ci-bot"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("job_tests"); w.Code != http.StatusCreated {
+		t.Fatalf("first trigger status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	w := send("job_tests")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second trigger status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want %q", got, "60")
+	}
+
+	// Budgets are per job
+	if w := send("job_hello"); w.Code != http.StatusCreated {
+		t.Errorf("other job status = %d, want %d", w.Code, http.StatusCreated)
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // NewRouter creates and configures the HTTP router
-func NewRouter(handlers *Handlers, authMiddleware *AuthMiddleware, loggingMiddleware *LoggingMiddleware) *chi.Mux {
+func NewRouter(handlers *Handlers, authMiddleware *AuthMiddleware, loggingMiddleware *LoggingMiddleware, rateLimitMiddleware *RateLimitMiddleware) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Global middleware - ORDER MATTERS!
@@ -24,7 +24,7 @@ func NewRouter(handlers *Handlers, authMiddleware *AuthMiddleware, loggingMiddle
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link", "X-Request-ID"}, // Expose request ID
+		ExposedHeaders:   []string{"Link", "X-Request-ID", "Retry-After"}, // Expose request ID
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -32,28 +32,74 @@ func NewRouter(handlers *Handlers, authMiddleware *AuthMiddleware, loggingMiddle
 	// Health check endpoint (no auth required)
 	r.Get("/health", handlers.Health)
 
+	// Metrics endpoint - names API keys and jobs, so it requires authentication
+	r.With(authMiddleware.Authenticate).Get("/metrics", rateLimitMiddleware.Metrics)
+
 	// API v1 routes (with authentication)
 	r.Route("/v1", func(r chi.Router) {
 		r.Use(authMiddleware.Authenticate)
 
-		// Jobs
-		r.Get("/jobs", handlers.ListJobs)
-		r.Post("/jobs/{job_id}/runs", handlers.TriggerRun)
+		// Mutating requests - charged against the trigger budget
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimitMiddleware.Triggers)
+
+			r.Post("/jobs", handlers.CreateJob)
+			r.Put("/jobs/{job_id}", handlers.PutJob)
+			r.Delete("/jobs/{job_id}", handlers.DeleteJob)
+			r.Post("/jobs/{job_id}/runs", handlers.TriggerRun)
+			r.Post("/jobs/{job_id}/runs:batch", handlers.TriggerBatch)
+			r.Post("/jobs/{job_id}/pause", handlers.PauseJob)
+			r.Post("/jobs/{job_id}/unpause", handlers.UnpauseJob)
+			r.Post("/runs/{run_id}/cancel", handlers.CancelRun)
+			r.Post("/runs/{run_id}/rerun", handlers.RerunRun)
+			r.Post("/runs/{run_id}/approve", handlers.ApproveRun)
+			r.Post("/runs/{run_id}/reject", handlers.RejectRun)
+			r.Post("/workflows/{workflow_id}/runs", handlers.StartWorkflow)
+			r.Post("/workflow-runs/{workflow_run_id}/cancel", handlers.CancelWorkflowRun)
+			r.Post("/freezes", handlers.CreateFreeze)
+			r.Delete("/freezes/{freeze_id}", handlers.DeleteFreeze)
+			r.Post("/discovery/import", handlers.ImportJobs)
+			r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/check", handlers.CheckResource)
+			r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/pin", handlers.PinResource)
+			r.Post("/discovery/pipelines/{pipeline}/resources/{resource}/unpin", handlers.UnpinResource)
+		})
+
+		// Read requests - charged against the read budget
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimitMiddleware.Reads)
+
+			// Jobs
+			r.Get("/jobs", handlers.ListJobs)
+			r.Get("/jobs/{job_id}", handlers.GetJob)
+			r.Get("/jobs/{job_id}/revisions", handlers.ListJobRevisions)
+			r.Get("/schedules", handlers.ListSchedules)
+			r.Get("/freezes", handlers.ListFreezes)
+			r.Get("/pauses", handlers.ListPauses)
+
+			// Runs
+			r.Get("/runs/{run_id}", handlers.GetRun)
+			r.Get("/runs/{run_id}/events", handlers.StreamEvents)
+			r.Get("/runs/{run_id}/steps", handlers.GetRunSteps)
+			r.Get("/runs/{run_id}/resources", handlers.GetRunResources)
+			r.Get("/batches/{batch_id}", handlers.GetBatch)
 
-		// Runs
-		r.Get("/runs/{run_id}", handlers.GetRun)
-		r.Get("/runs/{run_id}/events", handlers.StreamEvents)
-		r.Post("/runs/{run_id}/cancel", handlers.CancelRun)
+			// Workflows
+			r.Get("/workflows", handlers.ListWorkflows)
+			r.Get("/workflow-runs/{workflow_run_id}", handlers.GetWorkflowRun)
 
-		// Builds - detailed build information
-		r.Get("/builds/{build_id}", handlers.GetBuildDetails)
+			// Builds - detailed build information
+			r.Get("/builds/{build_id}", handlers.GetBuildDetails)
 
-		// Discovery - list teams, pipelines and jobs from provider
-		r.Get("/discovery/teams", handlers.ListTeams)
-		r.Get("/discovery/teams/{team}/pipelines", handlers.ListTeamPipelines)
-		r.Get("/discovery/pipelines", handlers.ListPipelines)
-		r.Get("/discovery/pipelines/{pipeline}/jobs", handlers.ListPipelineJobs)
-		r.Get("/discovery/pipelines/{pipeline}/jobs/{job}/builds", handlers.ListJobBuilds)
+			// Discovery - list teams, pipelines and jobs from provider
+			r.Get("/discovery/teams", handlers.ListTeams)
+			r.Get("/discovery/teams/{team}/pipelines", handlers.ListTeamPipelines)
+			r.Get("/discovery/pipelines", handlers.ListPipelines)
+			r.Get("/discovery/pipelines/{pipeline}/jobs", handlers.ListPipelineJobs)
+			r.Get("/discovery/pipelines/{pipeline}/jobs/{job}/builds", handlers.ListJobBuilds)
+			r.Get("/discovery/pipelines/{pipeline}/resources", handlers.ListPipelineResources)
+			r.Get("/discovery/pipelines/{pipeline}/resource-types", handlers.ListPipelineResourceTypes)
+			r.Get("/discovery/pipelines/{pipeline}/resources/{resource}/versions", handlers.ListResourceVersions)
+		})
 	})
 
 	return r
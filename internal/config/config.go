@@ -3,9 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/ratelimit"
 )
 
 // Config represents the gateway configuration
@@ -14,7 +16,24 @@ type Config struct {
 	Auth      AuthConfig
 	Concourse ConcourseConfig
 	Logging   LoggingConfig
+	RateLimit RateLimitConfig
+	State     StateConfig
 	JobsFile  string
+
+	// ConfigFile is the config file settings were read from; empty if none
+	ConfigFile string
+
+	// JobsReloadInterval is how often JobsFile is checked for changes; 0 disables watching
+	JobsReloadInterval time.Duration
+
+	// JobsValidation is how jobs are checked against the provider: off, lenient or strict
+	JobsValidation string
+}
+
+// StateConfig contains settings for gateway-held state (queued runs etc.)
+type StateConfig struct {
+	Dir          string        // Directory for persisted state; empty keeps state in memory
+	PollInterval time.Duration // How often tracked runs are refreshed from the provider
 }
 
 // ServerConfig contains HTTP server settings
@@ -31,8 +50,9 @@ type AuthConfig struct {
 
 // APIKey represents an API key for authentication
 type APIKey struct {
-	Name string
-	Key  string
+	Name   string
+	Key    string
+	Scopes []string // Extra permissions, e.g. "approver"
 }
 
 // ConcourseConfig contains Concourse connection settings
@@ -41,7 +61,7 @@ type ConcourseConfig struct {
 	Team               string
 	Username           string
 	Password           string
-	BearerToken        string        // Optional: Use pre-configured token
+	BearerToken        string // Optional: Use pre-configured token
 	TokenRefreshMargin time.Duration
 }
 
@@ -51,96 +71,151 @@ type LoggingConfig struct {
 	Format string // json or text
 }
 
-// Load reads configuration from environment variables
+// RateLimitConfig contains default rate limit budgets (e.g. "60/m").
+// Empty values mean unlimited.
+type RateLimitConfig struct {
+	KeyTrigger   string
+	KeyRead      string
+	JobTrigger   string
+	JobRead      string
+	KeyOverrides map[string]RateLimits // API key name -> budgets
+}
+
+// RateLimits contains trigger and read budgets for a single API key or job.
+// Empty values inherit the default budget.
+type RateLimits struct {
+	Trigger string
+	Read    string
+}
+
+// Load reads configuration from the config file named by CONFIG_FILE, if
+// set, and environment variables. Environment variables take precedence over
+// the config file.
 func Load() (*Config, error) {
-	cfg := &Config{}
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile reads configuration from a YAML or JSON config file and
+// environment variables, which take precedence. An empty path reads
+// environment variables only. Problems in the file are reported as a
+// *FileError naming the line and field.
+func LoadFile(path string) (*Config, error) {
+	s := &settings{}
+	if path != "" {
+		var err error
+		if s, err = readSettings(path); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{ConfigFile: path}
 
 	// Server configuration
-	port, err := getEnvInt("SERVER_PORT", 8081)
+	port, err := s.int("SERVER_PORT", 8081)
 	if err != nil {
-		return nil, fmt.Errorf("parse SERVER_PORT: %w", err)
+		return nil, err
 	}
 	cfg.Server.Port = port
 
-	readTimeout, err := getEnvDuration("SERVER_READ_TIMEOUT", "30s")
+	readTimeout, err := s.duration("SERVER_READ_TIMEOUT", "30s")
 	if err != nil {
-		return nil, fmt.Errorf("parse SERVER_READ_TIMEOUT: %w", err)
+		return nil, err
 	}
 	cfg.Server.ReadTimeout = readTimeout
 
-	writeTimeout, err := getEnvDuration("SERVER_WRITE_TIMEOUT", "30s")
+	writeTimeout, err := s.duration("SERVER_WRITE_TIMEOUT", "30s")
 	if err != nil {
-		return nil, fmt.Errorf("parse SERVER_WRITE_TIMEOUT: %w", err)
+		return nil, err
 	}
 	cfg.Server.WriteTimeout = writeTimeout
 
 	// Authentication configuration
-	apiKeys, err := parseAPIKeys(os.Getenv("API_KEYS"))
+	apiKeys, err := s.apiKeys()
 	if err != nil {
-		return nil, fmt.Errorf("parse API_KEYS: %w", err)
+		return nil, err
 	}
 	cfg.Auth.APIKeys = apiKeys
 
 	// Concourse configuration
-	cfg.Concourse.URL = getEnv("CONCOURSE_URL", "")
+	cfg.Concourse.URL = s.get("CONCOURSE_URL", "")
 	if cfg.Concourse.URL == "" {
-		return nil, fmt.Errorf("CONCOURSE_URL is required")
+		return nil, fmt.Errorf("%s is required", s.name("CONCOURSE_URL"))
 	}
 
-	cfg.Concourse.Team = getEnv("CONCOURSE_TEAM", "main")
-	cfg.Concourse.Username = getEnv("CONCOURSE_USERNAME", "")
-	cfg.Concourse.Password = getEnv("CONCOURSE_PASSWORD", "")
-	cfg.Concourse.BearerToken = getEnv("CONCOURSE_BEARER_TOKEN", "")
+	cfg.Concourse.Team = s.get("CONCOURSE_TEAM", "main")
+	cfg.Concourse.Username = s.get("CONCOURSE_USERNAME", "")
+	cfg.Concourse.Password = s.get("CONCOURSE_PASSWORD", "")
+	cfg.Concourse.BearerToken = s.get("CONCOURSE_BEARER_TOKEN", "")
 
 	// Validate authentication configuration
 	if cfg.Concourse.BearerToken == "" {
 		// If no bearer token, username and password are required
 		if cfg.Concourse.Username == "" || cfg.Concourse.Password == "" {
-			return nil, fmt.Errorf("either CONCOURSE_BEARER_TOKEN or both CONCOURSE_USERNAME and CONCOURSE_PASSWORD must be provided")
+			return nil, fmt.Errorf("either %s or both %s and %s must be provided",
+				s.name("CONCOURSE_BEARER_TOKEN"), s.name("CONCOURSE_USERNAME"), s.name("CONCOURSE_PASSWORD"))
 		}
 	}
 
-	refreshMargin, err := getEnvDuration("CONCOURSE_TOKEN_REFRESH_MARGIN", "5m")
+	refreshMargin, err := s.duration("CONCOURSE_TOKEN_REFRESH_MARGIN", "5m")
 	if err != nil {
-		return nil, fmt.Errorf("parse CONCOURSE_TOKEN_REFRESH_MARGIN: %w", err)
+		return nil, err
 	}
 	cfg.Concourse.TokenRefreshMargin = refreshMargin
 
 	// Logging configuration
-	cfg.Logging.Level = getEnv("LOG_LEVEL", "info")
-	cfg.Logging.Format = getEnv("LOG_FORMAT", "json")
+	cfg.Logging.Level = s.get("LOG_LEVEL", "info")
+	cfg.Logging.Format = s.get("LOG_FORMAT", "json")
 
-	// Jobs file
-	cfg.JobsFile = getEnv("JOBS_FILE", "configs/jobs.yaml")
+	// Rate limit configuration
+	if cfg.RateLimit.KeyTrigger, err = s.rate("RATE_LIMIT_KEY_TRIGGER"); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.KeyRead, err = s.rate("RATE_LIMIT_KEY_READ"); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.JobTrigger, err = s.rate("RATE_LIMIT_JOB_TRIGGER"); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.JobRead, err = s.rate("RATE_LIMIT_JOB_READ"); err != nil {
+		return nil, err
+	}
 
-	return cfg, nil
-}
+	keyOverrides, err := s.keyOverrides()
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateLimit.KeyOverrides = keyOverrides
 
-// getEnv gets an environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+	// State configuration
+	cfg.State.Dir = s.get("STATE_DIR", "data")
+	pollInterval, err := s.duration("RUN_POLL_INTERVAL", "10s")
+	if err != nil {
+		return nil, err
 	}
-	return value
-}
+	cfg.State.PollInterval = pollInterval
 
-// getEnvInt gets an integer environment variable with a default value
-func getEnvInt(key string, defaultValue int) (int, error) {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue, nil
+	// Jobs come from JOBS_FILE, else the config file if it defines any
+	cfg.JobsFile = os.Getenv("JOBS_FILE")
+	if cfg.JobsFile == "" && s.definesJobs() {
+		cfg.JobsFile = path
 	}
-	return strconv.Atoi(value)
-}
+	if cfg.JobsFile == "" {
+		cfg.JobsFile = "configs/jobs.yaml"
+	}
+	reloadInterval, err := s.duration("JOBS_RELOAD_INTERVAL", "5s")
+	if err != nil {
+		return nil, err
+	}
+	cfg.JobsReloadInterval = reloadInterval
 
-// getEnvDuration gets a duration environment variable with a default value
-func getEnvDuration(key, defaultValue string) (time.Duration, error) {
-	value := os.Getenv(key)
-	if value == "" {
-		value = defaultValue
+	cfg.JobsValidation = s.get("JOBS_VALIDATION", "lenient")
+	switch cfg.JobsValidation {
+	case "off", "lenient", "strict":
+	default:
+		return nil, s.invalid("JOBS_VALIDATION", fmt.Errorf("expected off, lenient or strict, got %q", cfg.JobsValidation))
 	}
-	return time.ParseDuration(value)
+
+	return cfg, nil
 }
 
 // parseAPIKeys parses comma-separated API keys in format "name:key,name:key"
@@ -164,3 +239,90 @@ func parseAPIKeys(value string) ([]APIKey, error) {
 
 	return keys, nil
 }
+
+// applyAPIKeyScopes grants scopes to API keys by name in format
+// "name:scope;scope2,name2:scope"
+func applyAPIKeyScopes(keys []APIKey, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid scope format: %s (expected name:scope;...)", entry)
+		}
+		name := strings.TrimSpace(parts[0])
+
+		var scopes []string
+		for _, scope := range strings.Split(parts[1], ";") {
+			scope = strings.TrimSpace(scope)
+			if err := checkScope(scope); err != nil {
+				return err
+			}
+			scopes = append(scopes, scope)
+		}
+
+		found := false
+		for i := range keys {
+			if keys[i].Name == name {
+				keys[i].Scopes = append(keys[i].Scopes, scopes...)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown API key name: %s", name)
+		}
+	}
+
+	return nil
+}
+
+// checkScope rejects scopes the gateway doesn't know
+func checkScope(scope string) error {
+	switch models.Scope(scope) {
+	case models.ScopeApprover, models.ScopeFreezeOverride, models.ScopeJobAdmin, models.ScopeOperator:
+		return nil
+	default:
+		return fmt.Errorf("unknown scope: %s (expected approver, freeze_override, job_admin or operator)", scope)
+	}
+}
+
+// parseRateLimitOverrides parses per-key budgets in format
+// "name:trigger=5/m;read=100/m,name2:read=unlimited"
+func parseRateLimitOverrides(value string) (map[string]RateLimits, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	overrides := make(map[string]RateLimits)
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid override format: %s (expected name:class=rate;...)", entry)
+		}
+
+		var limits RateLimits
+		for _, budget := range strings.Split(parts[1], ";") {
+			kv := strings.SplitN(strings.TrimSpace(budget), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid budget format: %s (expected class=rate)", budget)
+			}
+			rate := strings.TrimSpace(kv[1])
+			if _, err := ratelimit.ParseRate(rate); err != nil {
+				return nil, err
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "trigger":
+				limits.Trigger = rate
+			case "read":
+				limits.Read = rate
+			default:
+				return nil, fmt.Errorf("unknown rate limit class: %s (expected trigger or read)", kv[0])
+			}
+		}
+		overrides[strings.TrimSpace(parts[0])] = limits
+	}
+
+	return overrides, nil
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches "${NAME}" and "${NAME:-default}". Trigger parameter
+// references such as "${params.branch}" don't match and are left alone.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// yamlLine extracts the line number from yaml.v3 error messages
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Problem is an error at a line and field of a configuration file
+type Problem struct {
+	Line    int    // 1-based; 0 when unknown
+	Field   string // Dotted path, e.g. "server.port" or "jobs[2].provider"
+	Message string
+}
+
+// String formats the problem as "line: field: message"
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		b.WriteString(strconv.Itoa(p.Line) + ": ")
+	}
+	if p.Field != "" {
+		b.WriteString(p.Field + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// FileError reports every problem found in a configuration file
+type FileError struct {
+	Path     string
+	Problems []Problem
+}
+
+// Error implements the error interface
+func (e *FileError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Path + ":" + e.Problems[0].String()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + e.Path + ":" + p.String()
+	}
+	return fmt.Sprintf("%s: %d problems:\n%s", e.Path, len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// document is a parsed configuration file with environment references
+// expanded and every field indexed by its dotted path
+type document struct {
+	path     string
+	data     []byte
+	root     *yaml.Node            // Top-level mapping
+	keys     map[string]*yaml.Node // Field path -> key node
+	values   map[string]*yaml.Node // Field path -> value node
+	order    []string              // Field paths in document order
+	problems []Problem
+}
+
+// readDocument reads a YAML or JSON configuration file. Syntax errors and
+// references to unset environment variables are returned as a *FileError.
+func readDocument(path string) (*document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(path, data)
+}
+
+// parseDocument parses configuration file contents; see readDocument
+func parseDocument(path string, data []byte) (*document, error) {
+	doc := &document{
+		path:   path,
+		data:   data,
+		keys:   make(map[string]*yaml.Node),
+		values: make(map[string]*yaml.Node),
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, &FileError{Path: path, Problems: yamlProblems(err, nil)}
+	}
+	switch {
+	case node.Kind == 0:
+		doc.root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case node.Content[0].Kind == yaml.MappingNode:
+		doc.root = node.Content[0]
+	default:
+		return nil, &FileError{Path: path, Problems: []Problem{{Line: node.Content[0].Line, Message: "top level must be a mapping"}}}
+	}
+
+	doc.walk(doc.root, "")
+	if err := doc.err(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// walk indexes fields below node and expands environment references in
+// scalar values
+func (d *document) walk(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			field := node.Content[i].Value
+			if path != "" {
+				field = path + "." + field
+			}
+			d.keys[field] = node.Content[i]
+			d.values[field] = node.Content[i+1]
+			d.order = append(d.order, field)
+			d.walk(node.Content[i+1], field)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			field := fmt.Sprintf("%s[%d]", path, i)
+			d.values[field] = item
+			d.walk(item, field)
+		}
+	case yaml.ScalarNode:
+		d.expand(node, path)
+	}
+}
+
+// expand replaces environment references in a scalar value. Plain scalars
+// are re-resolved afterwards, so "port: ${PORT}" decodes as an integer.
+func (d *document) expand(node *yaml.Node, path string) {
+	if !strings.Contains(node.Value, "${") {
+		return
+	}
+	node.Value = envReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		m := envReference.FindStringSubmatch(ref)
+		value, set := os.LookupEnv(m[1])
+		hasDefault := strings.Contains(ref, ":-")
+		switch {
+		case value != "" || (set && !hasDefault):
+			return value
+		case hasDefault:
+			return m[2]
+		}
+		d.problems = append(d.problems, Problem{Line: node.Line, Field: path, Message: fmt.Sprintf("environment variable %s is not set", m[1])})
+		return ""
+	})
+	if node.Style == 0 {
+		node.Tag = ""
+	}
+}
+
+// has reports whether the document sets a field
+func (d *document) has(field string) bool {
+	_, ok := d.values[field]
+	return ok
+}
+
+// scalar returns a field's value, or false if the field isn't set or is null
+func (d *document) scalar(field string) (string, bool) {
+	node, ok := d.values[field]
+	if !ok || node.Kind != yaml.ScalarNode || node.ShortTag() == "!!null" {
+		return "", false
+	}
+	return node.Value, true
+}
+
+// line returns the line a field is defined on
+func (d *document) line(field string) int {
+	if node, ok := d.keys[field]; ok {
+		return node.Line
+	}
+	if node, ok := d.values[field]; ok {
+		return node.Line
+	}
+	return 0
+}
+
+// addProblem records a problem with a field
+func (d *document) addProblem(field, format string, args ...interface{}) {
+	d.problems = append(d.problems, Problem{Line: d.line(field), Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// fieldError returns an error for a single field
+func (d *document) fieldError(field string, err error) error {
+	return &FileError{Path: d.path, Problems: []Problem{{Line: d.line(field), Field: field, Message: err.Error()}}}
+}
+
+// err returns the recorded problems, or nil if there are none
+func (d *document) err() error {
+	if len(d.problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(d.problems, func(i, j int) bool { return d.problems[i].Line < d.problems[j].Line })
+	return &FileError{Path: d.path, Problems: d.problems}
+}
+
+// decode decodes the document (or a field of it, when field is set) into
+// out. Type errors are reported with the line and field they occurred at.
+func (d *document) decode(field string, out interface{}) error {
+	node := d.root
+	if field != "" {
+		var ok bool
+		if node, ok = d.values[field]; !ok {
+			return nil
+		}
+	}
+	if err := node.Decode(out); err != nil {
+		return &FileError{Path: d.path, Problems: yamlProblems(err, d.fieldsByLine())}
+	}
+	return nil
+}
+
+// fieldsByLine maps lines to the innermost field whose key is on them, or
+// else the list item starting on them
+func (d *document) fieldsByLine() map[int]string {
+	fields := make(map[int]string, len(d.values))
+	for field, node := range d.keys {
+		if cur, ok := fields[node.Line]; !ok || len(field) > len(cur) {
+			fields[node.Line] = field
+		}
+	}
+	for field, node := range d.values {
+		if _, ok := fields[node.Line]; !ok {
+			fields[node.Line] = field
+		}
+	}
+	return fields
+}
+
+// yamlProblems converts yaml.v3 errors into problems, naming the field
+// defined on the reported line when known
+func yamlProblems(err error, fields map[int]string) []Problem {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+
+	problems := make([]Problem, 0, len(msgs))
+	for _, msg := range msgs {
+		m := yamlLine.FindStringSubmatch(msg)
+		if m == nil {
+			problems = append(problems, Problem{Message: strings.TrimPrefix(msg, "yaml: ")})
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		problems = append(problems, Problem{Line: line, Field: fields[line], Message: m[2]})
+	}
+	return problems
+}
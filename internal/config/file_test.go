@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDocument_Interpolation(t *testing.T) {
+	t.Setenv("GW_HOST", "ci.example.com")
+	t.Setenv("GW_PORT", "9000")
+	t.Setenv("GW_EMPTY", "")
+
+	data := []byte(`url: https://${GW_HOST}/api
+port: ${GW_PORT}
+quoted: "${GW_PORT}"
+team: ${GW_TEAM:-main}
+empty_default: ${GW_EMPTY:-fallback}
+empty: ${GW_EMPTY}
+param: ${params.branch}
+`)
+	doc, err := parseDocument("gateway.yaml", data)
+	if err != nil {
+		t.Fatalf("parseDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"url", "https://ci.example.com/api"},
+		{"port", "9000"},
+		{"team", "main"},
+		{"empty_default", "fallback"},
+		{"empty", ""},
+		{"param", "${params.branch}"},
+	}
+	for _, tt := range tests {
+		if got, _ := doc.scalar(tt.field); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+
+	// Plain scalars decode by their expanded value, quoted ones stay strings
+	var out struct {
+		Port   int         `yaml:"port"`
+		Quoted interface{} `yaml:"quoted"`
+	}
+	if err := doc.decode("", &out); err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+	if out.Port != 9000 || out.Quoted != "9000" {
+		t.Errorf("decoded port = %d, quoted = %#v; want 9000 and \"9000\"", out.Port, out.Quoted)
+	}
+}
+
+func TestParseDocument_UnsetVariables(t *testing.T) {
+	data := []byte(`server:
+  port: 8080
+providers:
+  concourse:
+    url: ${GW_UNSET_URL}
+    password: ${GW_UNSET_PASSWORD}
+`)
+	_, err := parseDocument("gateway.yaml", data)
+
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || len(fileErr.Problems) != 2 {
+		t.Fatalf("parseDocument() error = %v, want two problems", err)
+	}
+	want := `gateway.yaml: 2 problems:
+  gateway.yaml:5: providers.concourse.url: environment variable GW_UNSET_URL is not set
+  gateway.yaml:6: providers.concourse.password: environment variable GW_UNSET_PASSWORD is not set`
+	if err.Error() != want {
+		t.Errorf("error =\n%s\nwant\n%s", err, want)
+	}
+}
+
+func TestParseDocument_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"syntax", "server:\n\tport: 8080\n", "gateway.yaml:2: found character that cannot start any token"},
+		{"top level", "- server\n", "gateway.yaml:1: top level must be a mapping"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseDocument("gateway.yaml", []byte(tt.data))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("parseDocument() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parseDocument() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocument_DecodeNamesField(t *testing.T) {
+	data := []byte(`auth:
+  api_keys:
+    - name: ci
+      key: secret
+      scopes: approver
+`)
+	doc, err := parseDocument("gateway.yaml", data)
+	if err != nil {
+		t.Fatalf("parseDocument() error = %v", err)
+	}
+
+	var keys []struct {
+		Scopes []string `yaml:"scopes"`
+	}
+	err = doc.decode("auth.api_keys", &keys)
+	want := "gateway.yaml:5: auth.api_keys[0].scopes: cannot unmarshal !!str `approver` into []string"
+	if err == nil || err.Error() != want {
+		t.Errorf("decode() error = %v, want %q", err, want)
+	}
+}
+
+func TestProblem_String(t *testing.T) {
+	tests := []struct {
+		problem Problem
+		want    string
+	}{
+		{Problem{Line: 3, Field: "server.port", Message: "unknown field"}, "3: server.port: unknown field"},
+		{Problem{Field: "server.port", Message: "unknown field"}, "server.port: unknown field"},
+		{Problem{Message: "unexpected end of file"}, "unexpected end of file"},
+	}
+	for _, tt := range tests {
+		if got := tt.problem.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -1,25 +1,131 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/lei/simple-ci/internal/cron"
+	"github.com/lei/simple-ci/internal/freeze"
 	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+	"github.com/lei/simple-ci/internal/ratelimit"
 	"gopkg.in/yaml.v3"
 )
 
+// labelKey and labelValue match valid job label keys and non-empty values
+var (
+	labelKey   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+	labelValue = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+)
+
 // JobsConfig represents the jobs configuration file structure
 type JobsConfig struct {
-	Jobs []JobDefinition `yaml:"jobs"`
+	Include   []string                      `yaml:"include"` // Further jobs files, relative to this one; globs allowed
+	Jobs      []JobDefinition               `yaml:"jobs"`
+	Workflows []WorkflowDefinition          `yaml:"workflows"`
+	Approvals map[string]ApprovalDefinition `yaml:"approvals"` // Default approval policy by environment
+	Freezes   []FreezeDefinition            `yaml:"freezes"`
+}
+
+// FreezeDefinition blocks triggers during a calendar range or recurring window
+type FreezeDefinition struct {
+	ID           string   `yaml:"id"`
+	Reason       string   `yaml:"reason"`
+	Projects     []string `yaml:"projects"`
+	Environments []string `yaml:"environments"`
+	Start        string   `yaml:"start"` // RFC 3339
+	End          string   `yaml:"end"`   // RFC 3339
+	Cron         string   `yaml:"cron"`  // Recurring window start
+	Duration     string   `yaml:"duration"`
+	Timezone     string   `yaml:"timezone"`
+}
+
+// ApprovalDefinition requires approvers to sign off on runs before dispatch
+type ApprovalDefinition struct {
+	RequiredApprovals *int   `yaml:"required_approvals"` // Defaults to 1; 0 disables approvals for a job
+	ExpiresAfter      string `yaml:"expires_after"`      // Defaults to 24h
+}
+
+// WorkflowDefinition chains jobs into a DAG
+type WorkflowDefinition struct {
+	WorkflowID  string                   `yaml:"workflow_id"`
+	DisplayName string                   `yaml:"display_name"`
+	Nodes       []WorkflowNodeDefinition `yaml:"nodes"`
+}
+
+// WorkflowNodeDefinition runs a job within a workflow
+type WorkflowNodeDefinition struct {
+	ID         string                 `yaml:"id"`
+	JobID      string                 `yaml:"job_id"`
+	DependsOn  []string               `yaml:"depends_on"`
+	When       string                 `yaml:"when"` // success (default), failure, always
+	Parameters map[string]interface{} `yaml:"parameters"`
 }
 
 // JobDefinition represents a job definition in the config file
 type JobDefinition struct {
 	JobID       string                 `yaml:"job_id"`
-	Project     string                 `yaml:"project"`
-	DisplayName string                 `yaml:"display_name"`
-	Environment string                 `yaml:"environment"`
+	Project     string                 `yaml:"project,omitempty"`
+	DisplayName string                 `yaml:"display_name,omitempty"`
+	Environment string                 `yaml:"environment,omitempty"`
+	Labels      map[string]string      `yaml:"labels,omitempty"`
 	Provider    ProviderConfig         `yaml:"provider"`
+	RateLimit   *RateLimitDefinition   `yaml:"rate_limit,omitempty"`
+	Concurrency *ConcurrencyDefinition `yaml:"concurrency,omitempty"`
+	Schedule    *ScheduleDefinition    `yaml:"schedule,omitempty"`
+	Retry       *RetryDefinition       `yaml:"retry,omitempty"`
+	Timeout     string                 `yaml:"timeout,omitempty"` // e.g. "1h"; runs exceeding it are aborted
+	Parameters  []ParameterDefinition  `yaml:"parameters,omitempty"`
+	Approval    *ApprovalDefinition    `yaml:"approval,omitempty"` // Overrides the environment's approval policy
+}
+
+// ParameterDefinition declares a trigger parameter accepted by a job
+type ParameterDefinition struct {
+	Name        string        `yaml:"name"`
+	Type        string        `yaml:"type"` // string (default), integer, number, boolean
+	Description string        `yaml:"description"`
+	Required    bool          `yaml:"required"`
+	Default     interface{}   `yaml:"default"`
+	Enum        []interface{} `yaml:"enum"`
+	Pattern     string        `yaml:"pattern"`
+	Sensitive   bool          `yaml:"sensitive"`
+}
+
+// RetryDefinition re-triggers runs that end in a retryable status
+type RetryDefinition struct {
+	MaxAttempts int      `yaml:"max_attempts"`
+	On          []string `yaml:"on"`          // Defaults to [errored]
+	Backoff     string   `yaml:"backoff"`     // e.g. "30s"
+	MaxBackoff  string   `yaml:"max_backoff"` // e.g. "10m"
+	Multiplier  float64  `yaml:"multiplier"`  // Defaults to 2
+}
+
+// ScheduleDefinition triggers a job on a cron schedule
+type ScheduleDefinition struct {
+	Cron       string                 `yaml:"cron"`
+	Timezone   string                 `yaml:"timezone"`
+	Parameters map[string]interface{} `yaml:"parameters"`
+	Jitter     string                 `yaml:"jitter"`
+}
+
+// ConcurrencyDefinition limits active runs of a job
+type ConcurrencyDefinition struct {
+	MaxConcurrent int    `yaml:"max_concurrent"`
+	Policy        string `yaml:"policy"` // queue (default), reject, cancel_previous, supersede_pending
+}
+
+// RateLimitDefinition overrides the default per-job rate limit budgets
+type RateLimitDefinition struct {
+	Trigger string `yaml:"trigger"`
+	Read    string `yaml:"read"`
 }
 
 // ProviderConfig represents provider-specific configuration
@@ -28,39 +134,710 @@ type ProviderConfig struct {
 	Ref  map[string]interface{} `yaml:"ref"`
 }
 
+// JobsFile is everything defined in a jobs configuration file
+type JobsFile struct {
+	Jobs      []*models.Job
+	Workflows []*models.Workflow
+	Freezes   []*models.Freeze
+	Approvals map[string]*models.JobApproval // Default approval policy by environment
+	Hash      string                         // SHA-256 of the contents of the file and its includes
+}
+
+// LoadJobsFile reads the jobs configuration file once and parses jobs,
+// workflows and freezes from it
+func LoadJobsFile(path string) (*JobsFile, error) {
+	cfg, data, err := readJobsConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := buildJobs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	workflows, err := buildWorkflows(cfg)
+	if err != nil {
+		return nil, err
+	}
+	freezes, err := buildFreezes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := buildApprovals(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobsFile{
+		Jobs:      jobs,
+		Workflows: workflows,
+		Freezes:   freezes,
+		Approvals: approvals,
+		Hash:      HashJobsFile(data),
+	}, nil
+}
+
+// AppendJobs adds generated job definitions to the jobs list of a jobs
+// configuration file, keeping the rest of the document, including comments.
+// Empty data yields a new document. Only the identifying fields and the
+// provider ref of each job are written.
+func AppendJobs(data []byte, jobs []*models.Job) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse jobs config: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parse jobs config: top level is not a mapping")
+	}
+
+	var list *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "jobs" {
+			list = root.Content[i+1]
+		}
+	}
+	if list == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "jobs"}, list)
+	}
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		*list = yaml.Node{Kind: yaml.SequenceNode}
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("parse jobs config: jobs is not a list")
+	}
+
+	for _, job := range jobs {
+		var node yaml.Node
+		if err := node.Encode(JobDefinition{
+			JobID:       job.JobID,
+			Project:     job.Project,
+			DisplayName: job.DisplayName,
+			Environment: job.Environment,
+			Provider: ProviderConfig{
+				Kind: job.Provider.Kind,
+				Ref:  job.Provider.Ref,
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("encode job %s: %w", job.JobID, err)
+		}
+		quoteStrings(&node)
+		list.Content = append(list.Content, &node)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encode jobs config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode jobs config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// quoteStrings double-quotes string values, matching the style of jobs.yaml
+func quoteStrings(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 1; i < len(node.Content); i += 2 {
+			if v := node.Content[i]; v.Kind == yaml.ScalarNode && v.Tag == "!!str" {
+				v.Style = yaml.DoubleQuotedStyle
+			}
+		}
+	}
+	for _, child := range node.Content {
+		quoteStrings(child)
+	}
+}
+
+// HashJobsFile returns the hash reported for jobs configuration contents
+func HashJobsFile(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readJobsConfig reads and decodes a jobs configuration file and the files it
+// includes. The returned data is the contents of every file read, in order.
+func readJobsConfig(path string) (*JobsConfig, []byte, error) {
+	cfg, doc, err := decodeJobsConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := doc.data
+
+	for i, pattern := range cfg.Include {
+		paths, err := includePaths(path, pattern)
+		if err != nil {
+			return nil, nil, doc.fieldError(fmt.Sprintf("include[%d]", i), err)
+		}
+		for _, incPath := range paths {
+			inc, incDoc, err := decodeJobsConfig(incPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(inc.Include) > 0 {
+				return nil, nil, incDoc.fieldError("include", fmt.Errorf("included files can't include other files"))
+			}
+			for env, ad := range inc.Approvals {
+				if _, dup := cfg.Approvals[env]; dup {
+					return nil, nil, incDoc.fieldError("approvals."+env, fmt.Errorf("approval policy for %s already defined", env))
+				}
+				if cfg.Approvals == nil {
+					cfg.Approvals = make(map[string]ApprovalDefinition)
+				}
+				cfg.Approvals[env] = ad
+			}
+			cfg.Jobs = append(cfg.Jobs, inc.Jobs...)
+			cfg.Workflows = append(cfg.Workflows, inc.Workflows...)
+			cfg.Freezes = append(cfg.Freezes, inc.Freezes...)
+			data = append(data, incDoc.data...)
+		}
+	}
+	return cfg, data, nil
+}
+
+// decodeJobsConfig reads and decodes a single jobs configuration file
+func decodeJobsConfig(path string) (*JobsConfig, *document, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		var fileErr *FileError
+		if errors.As(err, &fileErr) {
+			return nil, nil, fmt.Errorf("parse jobs config: %w", err)
+		}
+		return nil, nil, fmt.Errorf("read jobs config file: %w", err)
+	}
+
+	var cfg JobsConfig
+	if err := doc.decode("", &cfg); err != nil {
+		return nil, nil, fmt.Errorf("parse jobs config: %w", err)
+	}
+	return &cfg, doc, nil
+}
+
+// includePaths resolves an include pattern relative to the including file.
+// Glob patterns may match no files.
+func includePaths(base, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(base), pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	return paths, nil
+}
+
+// JobsFileHash returns the hash of a jobs configuration file and the files
+// it includes, matching JobsFile.Hash. A file that can't be parsed is hashed
+// on its own so that changes to it are still noticed.
+func JobsFileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var cfg struct {
+		Include []string `yaml:"include"`
+	}
+	doc, err := parseDocument(path, data)
+	if err != nil || doc.decode("", &cfg) != nil {
+		return HashJobsFile(data), nil
+	}
+	for _, pattern := range cfg.Include {
+		paths, _ := includePaths(path, pattern)
+		for _, incPath := range paths {
+			if incData, err := os.ReadFile(incPath); err == nil {
+				data = append(data, incData...)
+			}
+		}
+	}
+	return HashJobsFile(data), nil
+}
+
 // LoadJobs reads and parses the jobs configuration file
 func LoadJobs(path string) ([]*models.Job, error) {
-	data, err := os.ReadFile(path)
+	cfg, _, err := readJobsConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("read jobs config file: %w", err)
+		return nil, err
 	}
+	return buildJobs(cfg)
+}
 
-	var cfg JobsConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse jobs config: %w", err)
+// buildJobs validates job definitions and converts them to models
+func buildJobs(cfg *JobsConfig) ([]*models.Job, error) {
+	if _, err := buildApprovals(cfg); err != nil {
+		return nil, err
 	}
 
 	// Validate and convert to models
 	jobs := make([]*models.Job, 0, len(cfg.Jobs))
+	seen := make(map[string]int, len(cfg.Jobs))
 	for i, jd := range cfg.Jobs {
 		if jd.JobID == "" {
 			return nil, fmt.Errorf("job at index %d missing job_id", i)
 		}
-		if jd.Provider.Kind == "" {
-			return nil, fmt.Errorf("job %s missing provider kind", jd.JobID)
+		if first, dup := seen[jd.JobID]; dup {
+			return nil, fmt.Errorf("job at index %d: duplicate job_id %s (first defined at index %d)", i, jd.JobID, first)
 		}
+		seen[jd.JobID] = i
 
-		jobs = append(jobs, &models.Job{
-			JobID:       jd.JobID,
-			Project:     jd.Project,
-			DisplayName: jd.DisplayName,
-			Environment: jd.Environment,
-			Provider: models.JobProviderConfig{
-				Kind: jd.Provider.Kind,
-				Ref:  jd.Provider.Ref,
-			},
-		})
+		approvalDef := jd.Approval
+		if approvalDef == nil {
+			if ad, ok := cfg.Approvals[jd.Environment]; ok && jd.Environment != "" {
+				approvalDef = &ad
+			}
+		}
+
+		job, err := buildJob(jd, approvalDef)
+		if err != nil {
+			return nil, err
+		}
+		job.Source = models.JobSourceConfig
+		jobs = append(jobs, job)
 	}
 
 	return jobs, nil
 }
+
+// buildApprovals validates the default approval policies by environment.
+// Environments whose policy requires no approvals map to nil.
+func buildApprovals(cfg *JobsConfig) (map[string]*models.JobApproval, error) {
+	approvals := make(map[string]*models.JobApproval, len(cfg.Approvals))
+	for env, ad := range cfg.Approvals {
+		approval, err := parseApproval(&ad)
+		if err != nil {
+			return nil, fmt.Errorf("approvals %s: %w", env, err)
+		}
+		approvals[env] = approval
+	}
+	return approvals, nil
+}
+
+// ParseJobDefinition parses a single job definition in YAML or JSON, as
+// accepted by the job management API, with the same validation as jobs.yaml.
+// A non-empty jobID fills in a missing job_id and must match a given one.
+// Environment approval policies are applied by the service.
+func ParseJobDefinition(data []byte, jobID string) (*models.Job, error) {
+	var jd JobDefinition
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&jd); err != nil {
+		return nil, fmt.Errorf("parse job definition: %w", err)
+	}
+
+	if jd.JobID == "" {
+		jd.JobID = jobID
+	}
+	if jd.JobID == "" {
+		return nil, fmt.Errorf("job definition missing job_id")
+	}
+	if jobID != "" && jd.JobID != jobID {
+		return nil, fmt.Errorf("job_id %q does not match %q", jd.JobID, jobID)
+	}
+
+	return buildJob(jd, jd.Approval)
+}
+
+// buildJob validates one job definition and converts it to a model
+func buildJob(jd JobDefinition, approvalDef *ApprovalDefinition) (*models.Job, error) {
+	if jd.Provider.Kind == "" {
+		return nil, fmt.Errorf("job %s missing provider kind", jd.JobID)
+	}
+	if err := checkProviderRef(jd.Provider); err != nil {
+		return nil, fmt.Errorf("job %s provider: %w", jd.JobID, err)
+	}
+	if err := checkLabels(jd.Labels); err != nil {
+		return nil, fmt.Errorf("job %s labels: %w", jd.JobID, err)
+	}
+
+	var rateLimit *models.JobRateLimit
+	if jd.RateLimit != nil {
+		if _, err := ratelimit.ParseRate(jd.RateLimit.Trigger); err != nil {
+			return nil, fmt.Errorf("job %s rate_limit.trigger: %w", jd.JobID, err)
+		}
+		if _, err := ratelimit.ParseRate(jd.RateLimit.Read); err != nil {
+			return nil, fmt.Errorf("job %s rate_limit.read: %w", jd.JobID, err)
+		}
+		rateLimit = &models.JobRateLimit{
+			Trigger: jd.RateLimit.Trigger,
+			Read:    jd.RateLimit.Read,
+		}
+	}
+
+	concurrency, err := parseConcurrency(jd.Concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("job %s concurrency: %w", jd.JobID, err)
+	}
+
+	schedule, err := parseSchedule(jd.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("job %s schedule: %w", jd.JobID, err)
+	}
+
+	retry, err := parseRetry(jd.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("job %s retry: %w", jd.JobID, err)
+	}
+
+	if jd.Timeout != "" {
+		if d, err := time.ParseDuration(jd.Timeout); err != nil || d <= 0 {
+			return nil, fmt.Errorf("job %s: invalid timeout %q", jd.JobID, jd.Timeout)
+		}
+	}
+
+	schema, err := parseParameters(jd.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("job %s parameters: %w", jd.JobID, err)
+	}
+	if schedule != nil {
+		if _, fieldErrs := params.Validate(schema, schedule.Parameters); len(fieldErrs) > 0 {
+			return nil, fmt.Errorf("job %s schedule parameters: %s %s", jd.JobID, fieldErrs[0].Field, fieldErrs[0].Message)
+		}
+	}
+
+	approval, err := parseApproval(approvalDef)
+	if err != nil {
+		return nil, fmt.Errorf("job %s approval: %w", jd.JobID, err)
+	}
+
+	return &models.Job{
+		JobID:       jd.JobID,
+		Project:     jd.Project,
+		DisplayName: jd.DisplayName,
+		Environment: jd.Environment,
+		Labels:      jd.Labels,
+		Provider: models.JobProviderConfig{
+			Kind: jd.Provider.Kind,
+			Ref:  jd.Provider.Ref,
+		},
+		RateLimit:   rateLimit,
+		Concurrency: concurrency,
+		Schedule:    schedule,
+		Retry:       retry,
+		Timeout:     jd.Timeout,
+		Parameters:  schema,
+		Approval:    approval,
+	}, nil
+}
+
+// checkLabels validates label keys and values so they can be matched by
+// label selectors such as "team=payments,tier!=batch"
+func checkLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKey.MatchString(key) {
+			return fmt.Errorf("invalid key %q: use letters, digits, '.', '_', '-' and '/', starting and ending with a letter or digit", key)
+		}
+		if value != "" && !labelValue.MatchString(value) {
+			return fmt.Errorf("invalid value %q for %s: use letters, digits, '.', '_' and '-', starting and ending with a letter or digit", value, key)
+		}
+	}
+	return nil
+}
+
+// checkProviderRef validates the ref fields each provider kind requires.
+// Whether the referenced job exists is checked against the provider later.
+func checkProviderRef(pc ProviderConfig) error {
+	switch pc.Kind {
+	case "concourse":
+		for _, field := range []string{"team", "pipeline", "job"} {
+			if v, ok := pc.Ref[field].(string); !ok || v == "" {
+				return fmt.Errorf("ref.%s is required", field)
+			}
+		}
+		if raw, ok := pc.Ref["instance_vars"]; ok {
+			if _, ok := raw.(map[string]interface{}); !ok {
+				return fmt.Errorf("ref.instance_vars must be a map")
+			}
+		}
+		if raw, ok := pc.Ref["pin"]; ok {
+			pins, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("ref.pin must be a map of resources")
+			}
+			for resource, version := range pins {
+				if _, ok := version.(map[string]interface{}); !ok {
+					return fmt.Errorf("ref.pin.%s must be a version map", resource)
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported kind %q", pc.Kind)
+	}
+}
+
+// parseConcurrency validates a concurrency block and applies the default policy
+func parseConcurrency(cd *ConcurrencyDefinition) (*models.JobConcurrency, error) {
+	if cd == nil {
+		return nil, nil
+	}
+	if cd.MaxConcurrent < 1 {
+		return nil, fmt.Errorf("max_concurrent must be at least 1")
+	}
+
+	policy := models.ConcurrencyPolicy(cd.Policy)
+	switch policy {
+	case "":
+		policy = models.PolicyQueue
+	case models.PolicyQueue, models.PolicyReject, models.PolicyCancelPrevious, models.PolicySupersedePending:
+	default:
+		return nil, fmt.Errorf("unknown policy %q (expected queue, reject, cancel_previous or supersede_pending)", cd.Policy)
+	}
+
+	return &models.JobConcurrency{
+		MaxConcurrent: cd.MaxConcurrent,
+		Policy:        policy,
+	}, nil
+}
+
+// parseSchedule validates a schedule block
+func parseSchedule(sd *ScheduleDefinition) (*models.JobSchedule, error) {
+	if sd == nil {
+		return nil, nil
+	}
+	if _, err := cron.Parse(sd.Cron); err != nil {
+		return nil, err
+	}
+	if sd.Timezone != "" {
+		if _, err := time.LoadLocation(sd.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", sd.Timezone, err)
+		}
+	}
+	if sd.Jitter != "" {
+		if d, err := time.ParseDuration(sd.Jitter); err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid jitter %q", sd.Jitter)
+		}
+	}
+
+	return &models.JobSchedule{
+		Cron:       sd.Cron,
+		Timezone:   sd.Timezone,
+		Parameters: sd.Parameters,
+		Jitter:     sd.Jitter,
+	}, nil
+}
+
+// parseParameters converts and validates a job's parameter schema
+func parseParameters(defs []ParameterDefinition) ([]models.JobParameter, error) {
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	schema := make([]models.JobParameter, 0, len(defs))
+	for _, d := range defs {
+		paramType := models.ParameterType(d.Type)
+		if paramType == "" {
+			paramType = models.ParamString
+		}
+		schema = append(schema, models.JobParameter{
+			Name:        d.Name,
+			Type:        paramType,
+			Description: d.Description,
+			Required:    d.Required,
+			Default:     d.Default,
+			Enum:        d.Enum,
+			Pattern:     d.Pattern,
+			Sensitive:   d.Sensitive,
+		})
+	}
+
+	if err := params.CheckSchema(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// parseApproval validates an approval block and applies defaults.
+// A policy requiring zero approvals disables approvals.
+func parseApproval(ad *ApprovalDefinition) (*models.JobApproval, error) {
+	if ad == nil {
+		return nil, nil
+	}
+
+	required := 1
+	if ad.RequiredApprovals != nil {
+		required = *ad.RequiredApprovals
+	}
+	if required < 0 {
+		return nil, fmt.Errorf("required_approvals must not be negative")
+	}
+
+	expiresAfter := ad.ExpiresAfter
+	if expiresAfter == "" {
+		expiresAfter = "24h"
+	}
+	if d, err := time.ParseDuration(expiresAfter); err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid expires_after %q", ad.ExpiresAfter)
+	}
+
+	if required == 0 {
+		return nil, nil
+	}
+	return &models.JobApproval{
+		RequiredApprovals: required,
+		ExpiresAfter:      expiresAfter,
+	}, nil
+}
+
+// parseRetry validates a retry block and applies defaults
+func parseRetry(rd *RetryDefinition) (*models.JobRetry, error) {
+	if rd == nil {
+		return nil, nil
+	}
+	if rd.MaxAttempts < 2 {
+		return nil, fmt.Errorf("max_attempts must be at least 2")
+	}
+
+	retry := &models.JobRetry{
+		MaxAttempts: rd.MaxAttempts,
+		Backoff:     rd.Backoff,
+		MaxBackoff:  rd.MaxBackoff,
+		Multiplier:  rd.Multiplier,
+	}
+
+	for _, status := range rd.On {
+		switch models.RunStatus(status) {
+		case models.StatusFailed, models.StatusErrored, models.StatusCanceled:
+			retry.On = append(retry.On, models.RunStatus(status))
+		default:
+			return nil, fmt.Errorf("cannot retry on status %q (expected failed, errored or canceled)", status)
+		}
+	}
+	if len(retry.On) == 0 {
+		retry.On = []models.RunStatus{models.StatusErrored}
+	}
+
+	for name, value := range map[string]string{"backoff": rd.Backoff, "max_backoff": rd.MaxBackoff} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+	}
+
+	if retry.Multiplier == 0 {
+		retry.Multiplier = 2
+	}
+	if retry.Multiplier < 1 {
+		return nil, fmt.Errorf("multiplier must be at least 1")
+	}
+
+	return retry, nil
+}
+
+// LoadWorkflows reads workflow definitions from the jobs configuration file.
+// References to jobs and dependencies are validated by the service.
+func LoadWorkflows(path string) ([]*models.Workflow, error) {
+	cfg, _, err := readJobsConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return buildWorkflows(cfg)
+}
+
+// buildWorkflows converts workflow definitions to models
+func buildWorkflows(cfg *JobsConfig) ([]*models.Workflow, error) {
+
+	workflows := make([]*models.Workflow, 0, len(cfg.Workflows))
+	for _, wd := range cfg.Workflows {
+		if wd.WorkflowID == "" {
+			return nil, fmt.Errorf("workflow_id is required")
+		}
+
+		wf := &models.Workflow{
+			WorkflowID:  wd.WorkflowID,
+			DisplayName: wd.DisplayName,
+		}
+		for _, nd := range wd.Nodes {
+			when := models.WorkflowCondition(nd.When)
+			switch when {
+			case "":
+				when = models.ConditionSuccess
+			case models.ConditionSuccess, models.ConditionFailure, models.ConditionAlways:
+			default:
+				return nil, fmt.Errorf("workflow %s node %s: unknown condition %q (expected success, failure or always)", wd.WorkflowID, nd.ID, nd.When)
+			}
+
+			wf.Nodes = append(wf.Nodes, models.WorkflowNode{
+				ID:         nd.ID,
+				JobID:      nd.JobID,
+				DependsOn:  nd.DependsOn,
+				When:       when,
+				Parameters: nd.Parameters,
+			})
+		}
+		workflows = append(workflows, wf)
+	}
+
+	return workflows, nil
+}
+
+// LoadFreezes reads freeze windows from the jobs configuration file
+func LoadFreezes(path string) ([]*models.Freeze, error) {
+	cfg, _, err := readJobsConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return buildFreezes(cfg)
+}
+
+// buildFreezes validates freeze definitions and converts them to models
+func buildFreezes(cfg *JobsConfig) ([]*models.Freeze, error) {
+
+	freezes := make([]*models.Freeze, 0, len(cfg.Freezes))
+	seen := make(map[string]bool)
+	for i, fd := range cfg.Freezes {
+		if fd.ID == "" {
+			return nil, fmt.Errorf("freeze at index %d missing id", i)
+		}
+		if seen[fd.ID] {
+			return nil, fmt.Errorf("duplicate freeze %s", fd.ID)
+		}
+		seen[fd.ID] = true
+
+		f := &models.Freeze{
+			FreezeID:     fd.ID,
+			Reason:       fd.Reason,
+			Projects:     fd.Projects,
+			Environments: fd.Environments,
+			Cron:         fd.Cron,
+			Duration:     fd.Duration,
+			Timezone:     fd.Timezone,
+			Source:       models.FreezeSourceConfig,
+		}
+		for name, value := range map[string]string{"start": fd.Start, "end": fd.End} {
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("freeze %s: invalid %s %q (expected RFC 3339)", fd.ID, name, value)
+			}
+			if name == "start" {
+				f.Start = &t
+			} else {
+				f.End = &t
+			}
+		}
+		if fd.Cron == "" && f.Start == nil && f.End == nil {
+			return nil, fmt.Errorf("freeze %s: needs start/end or cron", fd.ID)
+		}
+		if err := freeze.Check(f); err != nil {
+			return nil, fmt.Errorf("freeze %s: %w", fd.ID, err)
+		}
+
+		freezes = append(freezes, f)
+	}
+
+	return freezes, nil
+}
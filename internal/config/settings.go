@@ -0,0 +1,311 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lei/simple-ci/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// settingFields maps environment variables to the config file fields they
+// override. This, together with auth.api_keys, rate_limits.key_overrides and
+// the jobs file sections, is the config file schema.
+var settingFields = map[string]string{
+	"SERVER_PORT":                    "server.port",
+	"SERVER_READ_TIMEOUT":            "server.read_timeout",
+	"SERVER_WRITE_TIMEOUT":           "server.write_timeout",
+	"CONCOURSE_URL":                  "providers.concourse.url",
+	"CONCOURSE_TEAM":                 "providers.concourse.team",
+	"CONCOURSE_USERNAME":             "providers.concourse.username",
+	"CONCOURSE_PASSWORD":             "providers.concourse.password",
+	"CONCOURSE_BEARER_TOKEN":         "providers.concourse.bearer_token",
+	"CONCOURSE_TOKEN_REFRESH_MARGIN": "providers.concourse.token_refresh_margin",
+	"LOG_LEVEL":                      "logging.level",
+	"LOG_FORMAT":                     "logging.format",
+	"RATE_LIMIT_KEY_TRIGGER":         "rate_limits.key_trigger",
+	"RATE_LIMIT_KEY_READ":            "rate_limits.key_read",
+	"RATE_LIMIT_JOB_TRIGGER":         "rate_limits.job_trigger",
+	"RATE_LIMIT_JOB_READ":            "rate_limits.job_read",
+	"STATE_DIR":                      "state.dir",
+	"RUN_POLL_INTERVAL":              "state.poll_interval",
+	"JOBS_RELOAD_INTERVAL":           "jobs_reload_interval",
+	"JOBS_VALIDATION":                "jobs_validation",
+}
+
+// structuredFields are config file fields without an environment variable
+// equivalent of the same shape; "[]" stands for a list index and "*" for a
+// map key
+var structuredFields = []string{
+	"auth.api_keys[].name",
+	"auth.api_keys[].key",
+	"auth.api_keys[].scopes",
+	"rate_limits.key_overrides.*.trigger",
+	"rate_limits.key_overrides.*.read",
+}
+
+// jobsSections are top-level config file fields holding job definitions,
+// validated when the jobs are loaded
+var jobsSections = []string{"jobs", "workflows", "approvals", "freezes", "include"}
+
+// listIndex matches list indexes in field paths
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// settings resolves gateway settings from environment variables, then the
+// config file, then defaults
+type settings struct {
+	doc *document // nil without a config file
+}
+
+// readSettings reads a gateway config file and checks it against the schema.
+// Every unknown field is reported.
+func readSettings(path string) (*settings, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	sections := make(map[string]bool)
+	addPrefixes := func(field string) {
+		for i := range field {
+			if field[i] == '.' && field[i-1] != ']' {
+				sections[field[:i]] = true
+			}
+		}
+		known[field] = true
+	}
+	for _, field := range settingFields {
+		addPrefixes(field)
+	}
+	for _, field := range structuredFields {
+		addPrefixes(field)
+	}
+
+	for _, field := range doc.order {
+		if isJobsField(field) {
+			continue
+		}
+		switch path := schemaPath(field); {
+		case sections[path]:
+			if doc.values[field].Kind != yaml.MappingNode {
+				doc.addProblem(field, "must be a mapping")
+			}
+		case !known[path] && path != "auth.api_keys":
+			doc.addProblem(field, "unknown field")
+		}
+	}
+	for env, field := range settingFields {
+		if node, ok := doc.values[field]; ok && node.Kind != yaml.ScalarNode {
+			doc.addProblem(field, "must be a single value (overridden by %s)", env)
+		}
+	}
+	if err := doc.err(); err != nil {
+		return nil, err
+	}
+	return &settings{doc: doc}, nil
+}
+
+// isJobsField reports whether a field belongs to a jobs file section
+func isJobsField(field string) bool {
+	for _, section := range jobsSections {
+		if field == section || strings.HasPrefix(field, section+".") || strings.HasPrefix(field, section+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaPath normalizes list indexes and key override names in a field path
+func schemaPath(field string) string {
+	field = listIndex.ReplaceAllString(field, "[]")
+	if rest, ok := strings.CutPrefix(field, "rate_limits.key_overrides."); ok {
+		if _, attr, found := strings.Cut(rest, "."); found {
+			return "rate_limits.key_overrides.*." + attr
+		}
+		return "rate_limits.key_overrides.*"
+	}
+	return field
+}
+
+// definesJobs reports whether the config file holds job definitions
+func (s *settings) definesJobs() bool {
+	if s.doc == nil {
+		return false
+	}
+	for _, section := range jobsSections {
+		if s.doc.has(section) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns a setting and the config file field it came from; the field
+// is empty if the value came from the environment or isn't set
+func (s *settings) lookup(env string) (value, field string) {
+	if value := os.Getenv(env); value != "" {
+		return value, ""
+	}
+	if s.doc != nil {
+		if value, ok := s.doc.scalar(settingFields[env]); ok {
+			return value, settingFields[env]
+		}
+	}
+	return "", ""
+}
+
+// get returns a setting or its default
+func (s *settings) get(env, defaultValue string) string {
+	if value, _ := s.lookup(env); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// int returns an integer setting or its default
+func (s *settings) int(env string, defaultValue int) (int, error) {
+	value, _ := s.lookup(env)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, s.invalid(env, err)
+	}
+	return n, nil
+}
+
+// duration returns a duration setting or its default
+func (s *settings) duration(env, defaultValue string) (time.Duration, error) {
+	d, err := time.ParseDuration(s.get(env, defaultValue))
+	if err != nil {
+		return 0, s.invalid(env, err)
+	}
+	return d, nil
+}
+
+// rate returns a rate limit budget, empty for unlimited
+func (s *settings) rate(env string) (string, error) {
+	value := s.get(env, "")
+	if _, err := ratelimit.ParseRate(value); err != nil {
+		return "", s.invalid(env, err)
+	}
+	return value, nil
+}
+
+// invalid reports an invalid setting at the config file field or
+// environment variable it came from
+func (s *settings) invalid(env string, err error) error {
+	if _, field := s.lookup(env); field != "" {
+		return s.doc.fieldError(field, err)
+	}
+	return fmt.Errorf("parse %s: %w", env, err)
+}
+
+// name describes where a setting can be provided, for "is required" errors
+func (s *settings) name(env string) string {
+	if s.doc == nil {
+		return env
+	}
+	return fmt.Sprintf("%s (or %s in %s)", env, settingFields[env], s.doc.path)
+}
+
+// apiKeys returns API keys from API_KEYS, or from auth.api_keys in the
+// config file. API_KEY_SCOPES grants extra scopes either way.
+func (s *settings) apiKeys() ([]APIKey, error) {
+	var keys []APIKey
+	var err error
+	if os.Getenv("API_KEYS") == "" && s.doc != nil && s.doc.has("auth.api_keys") {
+		keys, err = s.fileAPIKeys()
+		if err != nil {
+			return nil, err
+		}
+	} else if os.Getenv("API_KEYS") == "" && s.doc != nil {
+		return nil, fmt.Errorf("API_KEYS (or auth.api_keys in %s) is required", s.doc.path)
+	} else if keys, err = parseAPIKeys(os.Getenv("API_KEYS")); err != nil {
+		return nil, fmt.Errorf("parse API_KEYS: %w", err)
+	}
+
+	if err := applyAPIKeyScopes(keys, os.Getenv("API_KEY_SCOPES")); err != nil {
+		return nil, fmt.Errorf("parse API_KEY_SCOPES: %w", err)
+	}
+	return keys, nil
+}
+
+// fileAPIKeys decodes auth.api_keys from the config file
+func (s *settings) fileAPIKeys() ([]APIKey, error) {
+	var defs []struct {
+		Name   string   `yaml:"name"`
+		Key    string   `yaml:"key"`
+		Scopes []string `yaml:"scopes"`
+	}
+	if err := s.doc.decode("auth.api_keys", &defs); err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		s.doc.addProblem("auth.api_keys", "at least one API key is required")
+	}
+
+	keys := make([]APIKey, 0, len(defs))
+	for i, def := range defs {
+		field := fmt.Sprintf("auth.api_keys[%d]", i)
+		if def.Name == "" {
+			s.doc.addProblem(field, "name is required")
+		}
+		if def.Key == "" {
+			s.doc.addProblem(field, "key is required")
+		}
+		for j, scope := range def.Scopes {
+			if err := checkScope(scope); err != nil {
+				s.doc.addProblem(fmt.Sprintf("%s.scopes[%d]", field, j), "%v", err)
+			}
+		}
+		keys = append(keys, APIKey{Name: def.Name, Key: def.Key, Scopes: def.Scopes})
+	}
+	if err := s.doc.err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// keyOverrides returns per-key rate limits from RATE_LIMIT_KEY_OVERRIDES, or
+// from rate_limits.key_overrides in the config file
+func (s *settings) keyOverrides() (map[string]RateLimits, error) {
+	if value := os.Getenv("RATE_LIMIT_KEY_OVERRIDES"); value != "" || s.doc == nil {
+		overrides, err := parseRateLimitOverrides(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse RATE_LIMIT_KEY_OVERRIDES: %w", err)
+		}
+		return overrides, nil
+	}
+
+	var defs map[string]struct {
+		Trigger string `yaml:"trigger"`
+		Read    string `yaml:"read"`
+	}
+	if err := s.doc.decode("rate_limits.key_overrides", &defs); err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	overrides := make(map[string]RateLimits, len(defs))
+	for name, def := range defs {
+		for attr, rate := range map[string]string{"trigger": def.Trigger, "read": def.Read} {
+			if _, err := ratelimit.ParseRate(rate); err != nil {
+				s.doc.addProblem("rate_limits.key_overrides."+name+"."+attr, "%v", err)
+			}
+		}
+		overrides[name] = RateLimits{Trigger: def.Trigger, Read: def.Read}
+	}
+	if err := s.doc.err(); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
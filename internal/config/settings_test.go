@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearSettingsEnv unsets every environment variable LoadFile reads
+func clearSettingsEnv(t *testing.T) {
+	t.Helper()
+	for env := range settingFields {
+		t.Setenv(env, "")
+	}
+	for _, env := range []string{"API_KEYS", "API_KEY_SCOPES", "RATE_LIMIT_KEY_OVERRIDES", "JOBS_FILE"} {
+		t.Setenv(env, "")
+	}
+}
+
+// writeConfig writes a config file to a temporary directory
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile_Precedence(t *testing.T) {
+	clearSettingsEnv(t)
+	path := writeConfig(t, `server:
+  port: 9000
+  read_timeout: 10s
+auth:
+  api_keys:
+    - name: file
+      key: file-secret
+      scopes: [approver]
+providers:
+  concourse:
+    url: https://file.example.com
+    team: ${GW_TEAM:-platform}
+    bearer_token: token
+rate_limits:
+  key_overrides:
+    file:
+      trigger: 5/m
+`)
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("API_KEY_SCOPES", "file:operator")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	// Environment variables win over the file, the file over defaults
+	if cfg.Server.Port != 9100 {
+		t.Errorf("port = %d, want 9100 from SERVER_PORT", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeout != 10*time.Second {
+		t.Errorf("read timeout = %v, want 10s from the file", cfg.Server.ReadTimeout)
+	}
+	if cfg.Server.WriteTimeout != 30*time.Second {
+		t.Errorf("write timeout = %v, want the 30s default", cfg.Server.WriteTimeout)
+	}
+	if cfg.Concourse.URL != "https://file.example.com" || cfg.Concourse.Team != "platform" {
+		t.Errorf("concourse = %s team %s, want file values", cfg.Concourse.URL, cfg.Concourse.Team)
+	}
+	if keys := cfg.Auth.APIKeys; len(keys) != 1 || keys[0].Name != "file" || strings.Join(keys[0].Scopes, ",") != "approver,operator" {
+		t.Errorf("api keys = %+v, want file key with file and API_KEY_SCOPES scopes", keys)
+	}
+	if cfg.RateLimit.KeyOverrides["file"].Trigger != "5/m" {
+		t.Errorf("key overrides = %+v, want file override", cfg.RateLimit.KeyOverrides)
+	}
+	if cfg.JobsFile != "configs/jobs.yaml" {
+		t.Errorf("jobs file = %q, want default without a jobs section", cfg.JobsFile)
+	}
+
+	// Structured settings from the environment replace the file's entirely
+	t.Setenv("API_KEYS", "env:env-secret")
+	t.Setenv("API_KEY_SCOPES", "")
+	t.Setenv("RATE_LIMIT_KEY_OVERRIDES", "env:read=10/m")
+	t.Setenv("CONCOURSE_TEAM", "env-team")
+	cfg, err = LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if keys := cfg.Auth.APIKeys; len(keys) != 1 || keys[0].Name != "env" {
+		t.Errorf("api keys = %+v, want API_KEYS only", keys)
+	}
+	if _, ok := cfg.RateLimit.KeyOverrides["file"]; ok || cfg.RateLimit.KeyOverrides["env"].Read != "10/m" {
+		t.Errorf("key overrides = %+v, want RATE_LIMIT_KEY_OVERRIDES only", cfg.RateLimit.KeyOverrides)
+	}
+	if cfg.Concourse.Team != "env-team" {
+		t.Errorf("team = %q, want CONCOURSE_TEAM over the file", cfg.Concourse.Team)
+	}
+}
+
+func TestLoadFile_JobsFile(t *testing.T) {
+	clearSettingsEnv(t)
+	t.Setenv("API_KEYS", "ci:secret")
+	t.Setenv("CONCOURSE_URL", "https://ci.example.com")
+	t.Setenv("CONCOURSE_BEARER_TOKEN", "token")
+	path := writeConfig(t, `jobs:
+  - job_id: build
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if cfg.JobsFile != path {
+		t.Errorf("jobs file = %q, want the config file defining jobs", cfg.JobsFile)
+	}
+
+	t.Setenv("JOBS_FILE", "other.yaml")
+	if cfg, err = LoadFile(path); err != nil || cfg.JobsFile != "other.yaml" {
+		t.Errorf("jobs file = %q (error %v), want JOBS_FILE", cfg.JobsFile, err)
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    string // {path} stands for the config file
+	}{
+		{
+			name: "unknown fields",
+			content: `server:
+  port: 8080
+  host: localhost
+logging:
+  colour: true
+`,
+			want: `{path}: 2 problems:
+  {path}:3: server.host: unknown field
+  {path}:5: logging.colour: unknown field`,
+		},
+		{
+			name: "section not a mapping",
+			content: `server: 8080
+`,
+			want: "{path}:1: server: must be a mapping",
+		},
+		{
+			name: "list for a single value",
+			content: `logging:
+  level: [debug]
+`,
+			want: "{path}:2: logging.level: must be a single value (overridden by LOG_LEVEL)",
+		},
+		{
+			name: "invalid value in file",
+			content: `server:
+  read_timeout: soon
+`,
+			env:  map[string]string{"API_KEYS": "ci:secret"},
+			want: `{path}:2: server.read_timeout: time: invalid duration "soon"`,
+		},
+		{
+			name: "invalid value in environment",
+			content: `server:
+  read_timeout: soon
+`,
+			env:  map[string]string{"API_KEYS": "ci:secret", "SERVER_READ_TIMEOUT": "later"},
+			want: `parse SERVER_READ_TIMEOUT: time: invalid duration "later"`,
+		},
+		{
+			name: "missing api key field",
+			content: `auth:
+  api_keys:
+    - name: ci
+`,
+			want: "{path}:3: auth.api_keys[0]: key is required",
+		},
+		{
+			name: "required setting",
+			content: `auth:
+  api_keys:
+    - name: ci
+      key: secret
+`,
+			want: "CONCOURSE_URL (or providers.concourse.url in {path}) is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearSettingsEnv(t)
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			path := writeConfig(t, tt.content)
+
+			_, err := LoadFile(path)
+			want := strings.ReplaceAll(tt.want, "{path}", path)
+			if err == nil || err.Error() != want {
+				t.Errorf("LoadFile() error =\n%v\nwant\n%s", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	base := time.Date(2026, 1, 8, 18, 22, 11, 0, time.UTC) // Thursday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 8, 18, 23, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 8, 18, 30, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 1, 9, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 8, 19, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2026, 1, 9, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// Day of month OR day of week when both are restricted
+		{"0 0 15 * fri", time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := s.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	s, _ := Parse("0 2 * * *")
+	got := s.Next(time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC).In(loc))
+	want := time.Date(2026, 1, 9, 7, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got.UTC(), want)
+	}
+}
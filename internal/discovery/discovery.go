@@ -0,0 +1,334 @@
+// Package discovery generates job definitions from the jobs a Concourse
+// installation exposes.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// Default naming templates
+const (
+	DefaultJobIDTemplate   = "{team}-{pipeline}-{instance}-{job}"
+	DefaultProjectTemplate = "{pipeline}"
+)
+
+// placeholder matches "{team}", "{pipeline}", "{instance}", "{job}" and "{var.<name>}"
+var placeholder = regexp.MustCompile(`\{([a-z]+)(?:\.([A-Za-z0-9_-]+))?\}`)
+
+// unsafeJobID matches runs of characters not allowed in generated job IDs
+var unsafeJobID = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// Lister walks a Concourse installation; implemented by *concourse.Adapter
+type Lister interface {
+	ListTeams(ctx context.Context) ([]concourse.Team, error)
+	ListTeamPipelines(ctx context.Context, team string) ([]concourse.Pipeline, error)
+	ListTeamJobs(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]concourse.Job, error)
+}
+
+// Templates name generated jobs. Placeholders: {team}, {pipeline}, {job},
+// {instance} (instance var values joined by "-", empty for regular
+// pipelines) and {var.<name>} for a single instance var. Generated job IDs
+// are lowercased with other characters replaced by "-".
+type Templates struct {
+	JobID       string `json:"job_id"`      // Defaults to DefaultJobIDTemplate
+	Project     string `json:"project"`     // Defaults to DefaultProjectTemplate
+	Environment string `json:"environment"` // Empty leaves environment unset
+}
+
+// Options selects what to walk and how to name the result
+type Options struct {
+	Teams     []string  // Teams to walk; empty walks every team the gateway can see
+	Pipelines []string  // Pipeline name globs (path.Match); empty matches all
+	Templates Templates // Naming templates
+}
+
+// Result compares discovered jobs with existing job definitions
+type Result struct {
+	Added     []*models.Job `json:"added"`     // Discovered jobs without a definition
+	Unchanged []string      `json:"unchanged"` // IDs of definitions matching a discovered job
+	Stale     []StaleJob    `json:"stale"`     // Definitions whose Concourse job no longer exists
+	Conflicts []Conflict    `json:"conflicts"` // Discovered jobs whose generated job_id is taken
+}
+
+// StaleJob is an existing definition referencing a job that wasn't found in
+// a walked team and pipeline
+type StaleJob struct {
+	JobID    string `json:"job_id"`
+	Team     string `json:"team"`
+	Pipeline string `json:"pipeline"`
+	Job      string `json:"job"`
+}
+
+// Conflict is a discovered job whose generated job_id is already used by a
+// definition of a different Concourse job
+type Conflict struct {
+	JobID    string `json:"job_id"`
+	Team     string `json:"team"`
+	Pipeline string `json:"pipeline"`
+	Job      string `json:"job"`
+}
+
+// discovered is a Concourse job found while walking
+type discovered struct {
+	team         string
+	pipeline     string
+	instanceVars map[string]interface{}
+	job          string
+}
+
+// Check validates options, e.g. before walking on behalf of an API request
+func (o Options) Check() error {
+	for _, glob := range o.Pipelines {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid pipeline glob %q", glob)
+		}
+	}
+	for name, tmpl := range map[string]string{
+		"job_id":      o.Templates.JobID,
+		"project":     o.Templates.Project,
+		"environment": o.Templates.Environment,
+	} {
+		for _, m := range placeholder.FindAllStringSubmatch(tmpl, -1) {
+			switch {
+			case m[1] == "var" && m[2] != "":
+			case m[2] == "" && (m[1] == "team" || m[1] == "pipeline" || m[1] == "instance" || m[1] == "job"):
+			default:
+				return fmt.Errorf("%s template: unknown placeholder %s", name, m[0])
+			}
+		}
+	}
+	return nil
+}
+
+// Import walks the selected teams and pipelines and compares what it finds
+// with the existing job definitions. Archived pipelines are skipped; their
+// jobs are reported as stale.
+func Import(ctx context.Context, lister Lister, existing []*models.Job, opts Options) (*Result, error) {
+	if err := opts.Check(); err != nil {
+		return nil, err
+	}
+
+	teams := opts.Teams
+	if len(teams) == 0 {
+		all, err := lister.ListTeams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range all {
+			teams = append(teams, t.Name)
+		}
+	}
+
+	var found []discovered
+	for _, team := range teams {
+		pipelines, err := lister.ListTeamPipelines(ctx, team)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pipelines {
+			if p.Archived || !opts.matchesPipeline(p.Name) {
+				continue
+			}
+			jobs, err := lister.ListTeamJobs(ctx, team, p.Name, p.InstanceVars)
+			if err != nil {
+				return nil, err
+			}
+			for _, j := range jobs {
+				found = append(found, discovered{team: team, pipeline: p.Name, instanceVars: p.InstanceVars, job: j.Name})
+			}
+		}
+	}
+
+	return compare(found, existing, teams, opts), nil
+}
+
+// compare matches discovered jobs against existing definitions
+func compare(found []discovered, existing []*models.Job, teams []string, opts Options) *Result {
+	result := &Result{}
+
+	walked := make(map[string]bool, len(teams))
+	for _, t := range teams {
+		walked[t] = true
+	}
+
+	discoveredKeys := make(map[string]bool, len(found))
+	for _, d := range found {
+		discoveredKeys[refKey(d.team, d.pipeline, d.job, instanceKey(d.instanceVars))] = true
+		discoveredKeys[refKey(d.team, d.pipeline, d.job, "*")] = true
+	}
+
+	usedIDs := make(map[string]bool, len(existing))
+	existingKeys := make(map[string]bool, len(existing))
+	for _, job := range existing {
+		usedIDs[job.JobID] = true
+
+		ref, ok := concourseRef(job)
+		if !ok {
+			continue
+		}
+		existingKeys[refKey(ref.team, ref.pipeline, ref.job, ref.instance)] = true
+
+		if !walked[ref.team] || !opts.matchesPipeline(ref.pipeline) {
+			continue
+		}
+		if discoveredKeys[refKey(ref.team, ref.pipeline, ref.job, ref.instance)] {
+			result.Unchanged = append(result.Unchanged, job.JobID)
+			continue
+		}
+		result.Stale = append(result.Stale, StaleJob{JobID: job.JobID, Team: ref.team, Pipeline: ref.pipeline, Job: ref.job})
+	}
+
+	for _, d := range found {
+		instance := instanceKey(d.instanceVars)
+		if existingKeys[refKey(d.team, d.pipeline, d.job, instance)] || existingKeys[refKey(d.team, d.pipeline, d.job, "*")] {
+			continue
+		}
+
+		job := d.toJob(opts.Templates)
+		if usedIDs[job.JobID] {
+			result.Conflicts = append(result.Conflicts, Conflict{JobID: job.JobID, Team: d.team, Pipeline: d.pipeline, Job: d.job})
+			continue
+		}
+		usedIDs[job.JobID] = true
+		result.Added = append(result.Added, job)
+	}
+
+	sort.Strings(result.Unchanged)
+	sort.Slice(result.Stale, func(i, j int) bool { return result.Stale[i].JobID < result.Stale[j].JobID })
+	return result
+}
+
+// matchesPipeline reports whether a pipeline is selected by the globs
+func (o Options) matchesPipeline(name string) bool {
+	if len(o.Pipelines) == 0 {
+		return true
+	}
+	for _, glob := range o.Pipelines {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toJob renders a job definition for a discovered job
+func (d discovered) toJob(t Templates) *models.Job {
+	jobIDTemplate := t.JobID
+	if jobIDTemplate == "" {
+		jobIDTemplate = DefaultJobIDTemplate
+	}
+	projectTemplate := t.Project
+	if projectTemplate == "" {
+		projectTemplate = DefaultProjectTemplate
+	}
+
+	jobID := unsafeJobID.ReplaceAllString(strings.ToLower(d.render(jobIDTemplate)), "-")
+	for strings.Contains(jobID, "--") {
+		jobID = strings.ReplaceAll(jobID, "--", "-")
+	}
+
+	ref := map[string]interface{}{
+		"team":     d.team,
+		"pipeline": d.pipeline,
+		"job":      d.job,
+	}
+	if len(d.instanceVars) > 0 {
+		ref["instance_vars"] = d.instanceVars
+	}
+
+	return &models.Job{
+		JobID:       strings.Trim(jobID, "-"),
+		Project:     d.render(projectTemplate),
+		DisplayName: d.job,
+		Environment: d.render(t.Environment),
+		Provider: models.JobProviderConfig{
+			Kind: "concourse",
+			Ref:  ref,
+		},
+	}
+}
+
+// render substitutes placeholders in a naming template
+func (d discovered) render(tmpl string) string {
+	return placeholder.ReplaceAllStringFunc(tmpl, func(match string) string {
+		m := placeholder.FindStringSubmatch(match)
+		switch m[1] {
+		case "team":
+			return d.team
+		case "pipeline":
+			return d.pipeline
+		case "job":
+			return d.job
+		case "instance":
+			names := make([]string, 0, len(d.instanceVars))
+			for name := range d.instanceVars {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			values := make([]string, 0, len(names))
+			for _, name := range names {
+				values = append(values, fmt.Sprint(d.instanceVars[name]))
+			}
+			return strings.Join(values, "-")
+		case "var":
+			if v, ok := d.instanceVars[m[2]]; ok {
+				return fmt.Sprint(v)
+			}
+			return ""
+		}
+		return match
+	})
+}
+
+// jobRef is the part of a Concourse job ref that identifies the job
+type jobRef struct {
+	team, pipeline, job string
+	instance            string // instanceKey of static instance vars, "*" when they depend on trigger parameters
+}
+
+// concourseRef extracts the identifying fields of a Concourse job definition
+func concourseRef(job *models.Job) (jobRef, bool) {
+	if job.Provider.Kind != "concourse" {
+		return jobRef{}, false
+	}
+	team, _ := job.Provider.Ref["team"].(string)
+	pipeline, _ := job.Provider.Ref["pipeline"].(string)
+	name, _ := job.Provider.Ref["job"].(string)
+	if team == "" || pipeline == "" || name == "" {
+		return jobRef{}, false
+	}
+
+	vars, _ := job.Provider.Ref["instance_vars"].(map[string]interface{})
+	instance := instanceKey(vars)
+	for _, v := range vars {
+		if s, ok := v.(string); ok && strings.Contains(s, "${params.") {
+			instance = "*"
+			break
+		}
+	}
+	return jobRef{team: team, pipeline: pipeline, job: name, instance: instance}, true
+}
+
+// instanceKey canonicalizes instance vars for comparison
+func instanceKey(vars map[string]interface{}) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	// encoding/json sorts map keys
+	data, _ := json.Marshal(vars)
+	return string(data)
+}
+
+// refKey identifies a Concourse job
+func refKey(team, pipeline, job, instance string) string {
+	return team + "/" + pipeline + "/" + job + "/" + instance
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// fakeLister serves a fixed Concourse installation
+type fakeLister struct {
+	pipelines map[string][]concourse.Pipeline
+	jobs      map[string][]string // "team/pipeline" -> job names
+}
+
+func (f *fakeLister) ListTeams(ctx context.Context) ([]concourse.Team, error) {
+	var teams []concourse.Team
+	for name := range f.pipelines {
+		teams = append(teams, concourse.Team{Name: name})
+	}
+	return teams, nil
+}
+
+func (f *fakeLister) ListTeamPipelines(ctx context.Context, team string) ([]concourse.Pipeline, error) {
+	return f.pipelines[team], nil
+}
+
+func (f *fakeLister) ListTeamJobs(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]concourse.Job, error) {
+	var jobs []concourse.Job
+	for _, name := range f.jobs[team+"/"+pipeline] {
+		jobs = append(jobs, concourse.Job{Name: name})
+	}
+	return jobs, nil
+}
+
+func concourseJob(id, team, pipeline, job string) *models.Job {
+	return &models.Job{
+		JobID: id,
+		Provider: models.JobProviderConfig{
+			Kind: "concourse",
+			Ref:  map[string]interface{}{"team": team, "pipeline": pipeline, "job": job},
+		},
+	}
+}
+
+func TestImport(t *testing.T) {
+	lister := &fakeLister{
+		pipelines: map[string][]concourse.Pipeline{
+			"main": {
+				{Name: "payments"},
+				{Name: "Shop", InstanceVars: map[string]interface{}{"branch": "release/1.2"}},
+				{Name: "legacy", Archived: true},
+			},
+		},
+		jobs: map[string][]string{
+			"main/payments": {"build", "deploy"},
+			"main/Shop":     {"build"},
+			"main/legacy":   {"build"},
+		},
+	}
+	existing := []*models.Job{
+		concourseJob("payments-build", "main", "payments", "build"),
+		concourseJob("legacy-build", "main", "legacy", "build"),
+		concourseJob("main-payments-deploy", "other", "x", "y"), // Takes the generated ID
+		concourseJob("ops-backup", "ops", "backup", "run"),      // Team not walked
+	}
+
+	result, err := Import(context.Background(), lister, existing, Options{
+		Teams:     []string{"main"},
+		Templates: Templates{Environment: "{var.branch}"},
+	})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if len(result.Unchanged) != 1 || result.Unchanged[0] != "payments-build" {
+		t.Errorf("unchanged = %v, want [payments-build]", result.Unchanged)
+	}
+	if len(result.Stale) != 1 || result.Stale[0].JobID != "legacy-build" {
+		t.Errorf("stale = %+v, want legacy-build", result.Stale)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].JobID != "main-payments-deploy" {
+		t.Errorf("conflicts = %+v, want main-payments-deploy", result.Conflicts)
+	}
+
+	if len(result.Added) != 1 {
+		t.Fatalf("added = %d jobs, want 1", len(result.Added))
+	}
+	added := result.Added[0]
+	if added.JobID != "main-shop-release-1.2-build" || added.Project != "Shop" || added.Environment != "release/1.2" {
+		t.Errorf("added = %q project %q environment %q, want main-shop-release-1.2-build, Shop, release/1.2",
+			added.JobID, added.Project, added.Environment)
+	}
+	if _, ok := added.Provider.Ref["instance_vars"]; !ok {
+		t.Errorf("ref = %v, want instance_vars", added.Provider.Ref)
+	}
+}
+
+func TestOptionsCheck(t *testing.T) {
+	if err := (Options{Templates: Templates{JobID: "{team}-{jobname}"}}).Check(); err == nil {
+		t.Error("Check() error = nil, want unknown placeholder")
+	}
+	if err := (Options{Pipelines: []string{"[a-"}}).Check(); err == nil {
+		t.Error("Check() error = nil, want invalid glob")
+	}
+	if err := (Options{Templates: Templates{JobID: "{team}.{var.env}.{job}"}}).Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package freeze evaluates deployment freeze windows.
+package freeze
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/cron"
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// Schedule is a freeze window compiled by Compile, so evaluating it doesn't
+// parse the cron expression or load the timezone again
+type Schedule struct {
+	Freeze *models.Freeze
+
+	cron     *cron.Schedule // nil for calendar ranges
+	duration time.Duration
+	loc      *time.Location
+}
+
+// Compile validates a freeze window: either a calendar range (start/end, end
+// optional for open-ended freezes) or a recurring cron window with a duration
+func Compile(f *models.Freeze) (*Schedule, error) {
+	if f.Cron != "" {
+		if f.Start != nil || f.End != nil {
+			return nil, fmt.Errorf("cron windows cannot also set start or end")
+		}
+		schedule, err := cron.Parse(f.Cron)
+		if err != nil {
+			return nil, err
+		}
+		d, err := time.ParseDuration(f.Duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("cron windows need a positive duration, got %q", f.Duration)
+		}
+		loc := time.UTC
+		if f.Timezone != "" {
+			if loc, err = time.LoadLocation(f.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid timezone %q: %w", f.Timezone, err)
+			}
+		}
+		return &Schedule{Freeze: f, cron: schedule, duration: d, loc: loc}, nil
+	}
+
+	if f.Duration != "" || f.Timezone != "" {
+		return nil, fmt.Errorf("duration and timezone require cron")
+	}
+	if f.Start != nil && f.End != nil && !f.End.After(*f.Start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	return &Schedule{Freeze: f}, nil
+}
+
+// Check validates a freeze window without keeping the compiled schedule
+func Check(f *models.Freeze) error {
+	_, err := Compile(f)
+	return err
+}
+
+// Window reports whether the freeze is active at now and, if so, when the
+// current window ends (nil for open-ended freezes)
+func (s *Schedule) Window(now time.Time) (bool, *time.Time) {
+	f := s.Freeze
+	if s.cron == nil {
+		if f.Start != nil && now.Before(*f.Start) {
+			return false, nil
+		}
+		if f.End != nil && !now.Before(*f.End) {
+			return false, nil
+		}
+		return true, f.End
+	}
+
+	// The earliest window still open at now started within the last duration
+	start := s.cron.Next(now.Add(-s.duration).In(s.loc))
+	if start.IsZero() || start.After(now) {
+		return false, nil
+	}
+	end := start.Add(s.duration)
+	return true, &end
+}
+
+// Expired reports whether the freeze will never be active again
+func Expired(f *models.Freeze, now time.Time) bool {
+	return f.Cron == "" && f.End != nil && !now.Before(*f.End)
+}
+
+// Matches reports whether the freeze applies to the job
+func Matches(f *models.Freeze, job *models.Job) bool {
+	return matchesAny(f.Projects, job.Project) && matchesAny(f.Environments, job.Environment)
+}
+
+// matchesAny reports whether value is in values; empty values match everything
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestWindow_Calendar(t *testing.T) {
+	start := time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	f, err := Compile(&models.Freeze{Start: &start, End: &end})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"before", start.Add(-time.Minute), false},
+		{"at start", start, true},
+		{"inside", start.Add(72 * time.Hour), true},
+		{"at end", end, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, until := f.Window(tt.now)
+			if active != tt.want {
+				t.Errorf("Window() active = %v, want %v", active, tt.want)
+			}
+			if active && !until.Equal(end) {
+				t.Errorf("Window() until = %v, want %v", until, end)
+			}
+		})
+	}
+
+	open, _ := Compile(&models.Freeze{Start: &start})
+	if active, until := open.Window(end); !active || until != nil {
+		t.Errorf("open-ended freeze: active = %v, until = %v", active, until)
+	}
+}
+
+func TestWindow_Cron(t *testing.T) {
+	// Fridays from 16:00 for 64 hours, i.e. until Monday 08:00
+	f, err := Compile(&models.Freeze{Cron: "0 16 * * 5", Duration: "64h", Timezone: "Europe/Berlin"})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"friday before", time.Date(2025, 6, 6, 15, 59, 0, 0, berlin), false},
+		{"friday evening", time.Date(2025, 6, 6, 18, 0, 0, 0, berlin), true},
+		{"sunday", time.Date(2025, 6, 8, 12, 0, 0, 0, berlin), true},
+		{"monday morning", time.Date(2025, 6, 9, 8, 0, 0, 0, berlin), false},
+		{"wednesday", time.Date(2025, 6, 11, 12, 0, 0, 0, berlin), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, until := f.Window(tt.now)
+			if active != tt.want {
+				t.Errorf("Window() active = %v, want %v", active, tt.want)
+			}
+			if want := time.Date(2025, 6, 9, 8, 0, 0, 0, berlin); active && !until.Equal(want) {
+				t.Errorf("Window() until = %v, want %v", until, want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		freeze models.Freeze
+	}{
+		{"end before start", models.Freeze{Start: &now, End: &earlier}},
+		{"cron without duration", models.Freeze{Cron: "0 16 * * 5"}},
+		{"cron with range", models.Freeze{Cron: "0 16 * * 5", Duration: "1h", End: &now}},
+		{"duration without cron", models.Freeze{Duration: "1h"}},
+		{"bad timezone", models.Freeze{Cron: "0 16 * * 5", Duration: "1h", Timezone: "Mars/Olympus"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(&tt.freeze); err == nil {
+				t.Error("Check() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	job := &models.Job{Project: "shop", Environment: "prod"}
+
+	if !Matches(&models.Freeze{}, job) {
+		t.Error("unscoped freeze should match every job")
+	}
+	if !Matches(&models.Freeze{Environments: []string{"prod"}}, job) {
+		t.Error("environment freeze should match")
+	}
+	if Matches(&models.Freeze{Projects: []string{"billing"}, Environments: []string{"prod"}}, job) {
+		t.Error("freeze for another project should not match")
+	}
+}
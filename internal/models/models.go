@@ -8,23 +8,260 @@ type Job struct {
 	Project     string            `json:"project"`
 	DisplayName string            `json:"display_name"`
 	Environment string            `json:"environment"`
+	Labels      map[string]string `json:"labels,omitempty"` // Arbitrary key/value tags, e.g. team=payments
 	Provider    JobProviderConfig `json:"provider"`
+	RateLimit   *JobRateLimit     `json:"rate_limit,omitempty"`
+	Concurrency *JobConcurrency   `json:"concurrency,omitempty"`
+	Schedule    *JobSchedule      `json:"schedule,omitempty"`
+	Retry       *JobRetry         `json:"retry,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"` // Max run duration from dispatch, e.g. "1h"
+	Parameters  []JobParameter    `json:"parameters,omitempty"`
+	Approval    *JobApproval      `json:"approval,omitempty"`
+	Source      JobSource         `json:"source,omitempty"`
+	Revision    int               `json:"revision,omitempty"` // Revision of an API-managed definition
+
+	Availability *JobAvailability `json:"availability,omitempty"` // Result of the last provider check
+	Pause        *JobPause        `json:"pause,omitempty"`        // Pause state last read from the provider
+	LatestRun    *JobRuns         `json:"latest_run,omitempty"`   // Filled on request
+}
+
+// JobRuns is the latest activity of a job
+type JobRuns struct {
+	Finished *Run `json:"finished,omitempty"` // Most recent finished run
+	Running  *Run `json:"running,omitempty"`  // Run in progress
+	Queued   *Run `json:"queued,omitempty"`   // Next run waiting to start, in the provider or held by the gateway
+}
+
+// JobAvailability records whether the provider knows a job's ref
+type JobAvailability struct {
+	Available bool      `json:"available"`
+	Reason    string    `json:"reason,omitempty"` // Why the job is unavailable
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// JobPause is the pause state of a job in the provider
+type JobPause struct {
+	Job       bool      `json:"job"`      // The job itself is paused
+	Pipeline  bool      `json:"pipeline"` // The pipeline it belongs to is paused
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Paused reports whether the job can't start new runs
+func (p JobPause) Paused() bool {
+	return p.Job || p.Pipeline
+}
+
+// PauseTarget selects what a pause applies to
+type PauseTarget string
+
+const (
+	PauseTargetJob      PauseTarget = "job"      // Only the job
+	PauseTargetPipeline PauseTarget = "pipeline" // Every job in the job's pipeline
+)
+
+// PauseEvent records a pause or unpause through the gateway
+type PauseEvent struct {
+	JobID  string      `json:"job_id"`
+	Target PauseTarget `json:"target"`
+	Paused bool        `json:"paused"` // False for an unpause
+	Reason string      `json:"reason,omitempty"`
+	By     string      `json:"by"`
+	At     time.Time   `json:"at"`
+}
+
+// JobSource records where a job was defined
+type JobSource string
+
+const (
+	JobSourceConfig JobSource = "config" // Defined in jobs.yaml
+	JobSourceAPI    JobSource = "api"    // Registered through the API, possibly overriding jobs.yaml
+)
+
+// JobRevision is one change to an API-managed job definition
+type JobRevision struct {
+	JobID    string    `json:"job_id"`
+	Revision int       `json:"revision"`
+	Action   JobChange `json:"action"`
+	Job      *Job      `json:"job,omitempty"` // Definition after the change; unset for deletions
+	By       string    `json:"by,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// JobChange is the kind of change recorded in a JobRevision
+type JobChange string
+
+const (
+	JobCreated JobChange = "created"
+	JobUpdated JobChange = "updated"
+	JobDeleted JobChange = "deleted"
+)
+
+// JobApproval requires other callers to approve a run before it is dispatched
+type JobApproval struct {
+	RequiredApprovals int    `json:"required_approvals"`
+	ExpiresAfter      string `json:"expires_after"` // Pending runs are dropped after this long, e.g. "24h"
+}
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	ScopeApprover       Scope = "approver"        // May approve or reject runs of jobs with an approval policy
+	ScopeFreezeOverride Scope = "freeze_override" // May trigger during freeze windows and create or lift ad-hoc freezes
+	ScopeJobAdmin       Scope = "job_admin"       // May register, change and delete jobs through the API
+	ScopeOperator       Scope = "operator"        // May pause and unpause jobs and pipelines in the provider
+)
+
+// JobParameter describes one accepted trigger parameter. Jobs that declare
+// parameters reject unknown ones.
+type JobParameter struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`   // Regular expression for string values
+	Sensitive   bool          `json:"sensitive,omitempty"` // Value is redacted wherever the gateway echoes it
 }
 
+// ParameterType is the JSON type a parameter value must have
+type ParameterType string
+
+const (
+	ParamString  ParameterType = "string"
+	ParamInteger ParameterType = "integer"
+	ParamNumber  ParameterType = "number"
+	ParamBoolean ParameterType = "boolean"
+)
+
 // JobProviderConfig contains provider-specific configuration
 type JobProviderConfig struct {
 	Kind string                 `json:"kind"` // "concourse", "github", etc.
 	Ref  map[string]interface{} `json:"ref"`  // Provider-specific configuration
 }
 
+// JobRateLimit overrides the gateway's default per-job rate limits
+type JobRateLimit struct {
+	Trigger string `json:"trigger,omitempty"` // e.g. "5/m"
+	Read    string `json:"read,omitempty"`
+}
+
+// JobConcurrency limits how many runs of a job may be active at once
+type JobConcurrency struct {
+	MaxConcurrent int               `json:"max_concurrent"`
+	Policy        ConcurrencyPolicy `json:"policy"`
+}
+
+// ConcurrencyPolicy decides what happens to a trigger when all slots are taken
+type ConcurrencyPolicy string
+
+const (
+	PolicyQueue            ConcurrencyPolicy = "queue"             // Hold the trigger until a slot frees up
+	PolicyReject           ConcurrencyPolicy = "reject"            // Refuse the trigger
+	PolicyCancelPrevious   ConcurrencyPolicy = "cancel_previous"   // Cancel the oldest active run(s) and start now
+	PolicySupersedePending ConcurrencyPolicy = "supersede_pending" // Keep only the newest held trigger
+)
+
+// JobSchedule triggers a job periodically from the gateway
+type JobSchedule struct {
+	Cron       string                 `json:"cron"`                 // Five-field cron expression or @daily etc.
+	Timezone   string                 `json:"timezone,omitempty"`   // IANA name, defaults to UTC
+	Parameters map[string]interface{} `json:"parameters,omitempty"` // Parameters for scheduled runs
+	Jitter     string                 `json:"jitter,omitempty"`     // Max random delay, e.g. "5m"
+}
+
+// JobRetry re-triggers runs that end in a retryable status
+type JobRetry struct {
+	MaxAttempts int         `json:"max_attempts"`          // Total attempts including the first
+	On          []RunStatus `json:"on"`                    // Statuses that trigger a retry
+	Backoff     string      `json:"backoff,omitempty"`     // Delay before the first retry, e.g. "30s"
+	MaxBackoff  string      `json:"max_backoff,omitempty"` // Upper bound for the delay
+	Multiplier  float64     `json:"multiplier,omitempty"`  // Delay growth per attempt
+}
+
+// Schedule describes the state of a job schedule
+type Schedule struct {
+	JobID       string     `json:"job_id"`
+	Cron        string     `json:"cron"`
+	Timezone    string     `json:"timezone"`
+	Jitter      string     `json:"jitter,omitempty"`
+	NextFireAt  *time.Time `json:"next_fire_at,omitempty"`
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+	LastRunID   string     `json:"last_run_id,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
 // Run represents a single execution of a job
 type Run struct {
-	RunID      string     `json:"run_id"`
-	JobID      string     `json:"job_id,omitempty"`
-	Status     RunStatus  `json:"status"`
-	CreatedAt  time.Time  `json:"created_at"`
-	StartedAt  *time.Time `json:"started_at,omitempty"`
-	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	RunID             string                       `json:"run_id"`
+	JobID             string                       `json:"job_id,omitempty"`
+	Status            RunStatus                    `json:"status"`
+	GatewayStatus     GatewayStatus                `json:"gateway_status,omitempty"`
+	ProviderRunID     string                       `json:"provider_run_id,omitempty"`
+	QueuePosition     int                          `json:"queue_position,omitempty"`
+	Reason            string                       `json:"reason,omitempty"`
+	CancelReason      CancelReason                 `json:"cancel_reason,omitempty"`
+	Attempt           int                          `json:"attempt,omitempty"`
+	RerunOf           string                       `json:"rerun_of,omitempty"` // Run this one was rerun from
+	TriggeredBy       string                       `json:"triggered_by,omitempty"`
+	Parameters        map[string]interface{}       `json:"parameters,omitempty"` // Sensitive values redacted
+	NotBefore         *time.Time                   `json:"not_before,omitempty"`
+	Versions          map[string]map[string]string `json:"versions,omitempty"`       // Input versions requested at trigger time
+	InputVersions     map[string]map[string]string `json:"input_versions,omitempty"` // Input versions the provider resolved
+	FailingStep       string                       `json:"failing_step,omitempty"`   // Step that failed or errored the run
+	URL               string                       `json:"url,omitempty"`            // Run page in the provider UI
+	Approvals         []RunApproval                `json:"approvals,omitempty"`
+	ApprovalExpiresAt *time.Time                   `json:"approval_expires_at,omitempty"`
+	FreezeOverride    *FreezeOverride              `json:"freeze_override,omitempty"`
+	CreatedAt         time.Time                    `json:"created_at"`
+	StartedAt         *time.Time                   `json:"started_at,omitempty"`
+	FinishedAt        *time.Time                   `json:"finished_at,omitempty"`
+	DurationSeconds   int64                        `json:"duration_seconds,omitempty"` // Run time so far while running
+	Attempts          []RunAttempt                 `json:"attempts,omitempty"`
+	FinalStatus       RunStatus                    `json:"final_status,omitempty"`
+}
+
+// CancelReason records why a canceled run was stopped
+type CancelReason string
+
+const (
+	CancelReasonUser       CancelReason = "user"             // Canceled through the API
+	CancelReasonTimeout    CancelReason = "timeout"          // Aborted by the gateway after exceeding the job timeout
+	CancelReasonSuperseded CancelReason = "superseded"       // Canceled in favor of a newer trigger
+	CancelReasonRejected   CancelReason = "rejected"         // Rejected by an approver
+	CancelReasonExpired    CancelReason = "approval_expired" // Not approved in time
+)
+
+// RunApproval records one approver's sign-off on a run
+type RunApproval struct {
+	By      string    `json:"by"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// RunAttempt summarizes one attempt in a run's retry chain
+type RunAttempt struct {
+	Attempt       int           `json:"attempt"`
+	RunID         string        `json:"run_id"`
+	Status        RunStatus     `json:"status"`
+	GatewayStatus GatewayStatus `json:"gateway_status,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+}
+
+// RunResources lists the resource versions a run consumed and produced
+type RunResources struct {
+	Inputs  []RunResource `json:"inputs"`
+	Outputs []RunResource `json:"outputs"`
+}
+
+// RunResource is one resource version a run used or produced
+type RunResource struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type,omitempty"` // Resource type, e.g. git
+	Version  map[string]string `json:"version"`
+	Metadata map[string]string `json:"metadata,omitempty"` // Provider metadata of the version, e.g. commit author
 }
 
 // RunStatus represents the state of a run
@@ -40,6 +277,35 @@ const (
 	StatusUnknown   RunStatus = "unknown"
 )
 
+// IsTerminal reports whether the status is final
+func (s RunStatus) IsTerminal() bool {
+	switch s {
+	case StatusSucceeded, StatusFailed, StatusCanceled, StatusErrored:
+		return true
+	default:
+		return false
+	}
+}
+
+// GatewayStatus is the gateway's own view of a run, independent of the provider
+type GatewayStatus string
+
+const (
+	GatewayStatusQueued          GatewayStatus = "queued"           // Held by the gateway waiting for a free slot
+	GatewayStatusDispatched      GatewayStatus = "dispatched"       // Handed to the provider
+	GatewayStatusSuperseded      GatewayStatus = "superseded"       // Dropped in favor of a newer trigger
+	GatewayStatusCanceled        GatewayStatus = "canceled"         // Canceled before it was dispatched
+	GatewayStatusDispatchFailed  GatewayStatus = "dispatch_failed"  // Provider refused the trigger
+	GatewayStatusPendingApproval GatewayStatus = "pending_approval" // Waiting for approvers
+	GatewayStatusRejected        GatewayStatus = "rejected"         // Rejected by an approver
+	GatewayStatusExpired         GatewayStatus = "expired"          // Approval window passed
+)
+
+// IsHeld reports whether the run is waiting inside the gateway
+func (s GatewayStatus) IsHeld() bool {
+	return s == GatewayStatusQueued
+}
+
 // Event represents a streaming event from a run
 type Event struct {
 	Type      EventType              `json:"-"`
@@ -55,3 +321,223 @@ const (
 	EventTypeLog    EventType = "log"
 	EventTypeError  EventType = "error"
 )
+
+// Step is a node of a run's step tree. Leaf steps (get, put, task, ...) carry
+// the status and timing reported by the provider; composite steps (do,
+// in_parallel, try, hooks, ...) summarize their children.
+type Step struct {
+	ID         string     `json:"id"`
+	Type       StepType   `json:"type"`
+	Name       string     `json:"name,omitempty"`
+	Resource   string     `json:"resource,omitempty"` // Resource of get and put steps
+	Status     StepStatus `json:"status"`
+	ExitStatus *int       `json:"exit_status,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Children   []*Step    `json:"children,omitempty"`
+	Hook       *Step      `json:"hook,omitempty"` // Step run by on_success, on_failure, on_abort, on_error and ensure
+}
+
+// StepType is the kind of a step
+type StepType string
+
+const (
+	StepGet         StepType = "get"
+	StepPut         StepType = "put"
+	StepTask        StepType = "task"
+	StepCheck       StepType = "check"
+	StepSetPipeline StepType = "set_pipeline"
+	StepLoadVar     StepType = "load_var"
+	StepDo          StepType = "do"
+	StepInParallel  StepType = "in_parallel"
+	StepAcross      StepType = "across"
+	StepTry         StepType = "try"
+	StepRetry       StepType = "retry"
+	StepTimeout     StepType = "timeout"
+	StepOnSuccess   StepType = "on_success"
+	StepOnFailure   StepType = "on_failure"
+	StepOnAbort     StepType = "on_abort"
+	StepOnError     StepType = "on_error"
+	StepEnsure      StepType = "ensure"
+	StepUnknown     StepType = "unknown"
+)
+
+// StepStatus represents the state of a step
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"   // Not started, or skipped
+	StepRunning   StepStatus = "running"   // Started and not finished
+	StepSucceeded StepStatus = "succeeded" // Finished successfully
+	StepFailed    StepStatus = "failed"    // Finished with a non-zero exit status
+	StepErrored   StepStatus = "errored"   // Could not run to completion
+)
+
+// IsDone reports whether the step has finished
+func (s StepStatus) IsDone() bool {
+	return s == StepSucceeded || s == StepFailed || s == StepErrored
+}
+
+// Failing returns the innermost step under s that failed or errored, or nil.
+// Failures inside try steps are ignored.
+func (s *Step) Failing() *Step {
+	if s == nil || s.Type == StepTry || (s.Status != StepFailed && s.Status != StepErrored) {
+		return nil
+	}
+	for _, child := range s.Children {
+		if failing := child.Failing(); failing != nil {
+			return failing
+		}
+	}
+	if failing := s.Hook.Failing(); failing != nil {
+		return failing
+	}
+	return s
+}
+
+// Freeze blocks triggers of matching jobs during a window. A window is either a
+// calendar range (Start/End) or recurs at each Cron match for Duration.
+type Freeze struct {
+	FreezeID     string       `json:"freeze_id"`
+	Reason       string       `json:"reason,omitempty"`
+	Projects     []string     `json:"projects,omitempty"`     // Empty matches every project
+	Environments []string     `json:"environments,omitempty"` // Empty matches every environment
+	Start        *time.Time   `json:"start,omitempty"`
+	End          *time.Time   `json:"end,omitempty"` // Open-ended when unset
+	Cron         string       `json:"cron,omitempty"`
+	Duration     string       `json:"duration,omitempty"`
+	Timezone     string       `json:"timezone,omitempty"`
+	Source       FreezeSource `json:"source"`
+	CreatedBy    string       `json:"created_by,omitempty"`
+	Active       bool         `json:"active"`
+	ActiveUntil  *time.Time   `json:"active_until,omitempty"` // End of the current window
+}
+
+// FreezeSource records where a freeze was defined
+type FreezeSource string
+
+const (
+	FreezeSourceConfig FreezeSource = "config" // Defined in jobs.yaml
+	FreezeSourceAPI    FreezeSource = "api"    // Created through the API
+)
+
+// FreezeOverride records a trigger let through an active freeze
+type FreezeOverride struct {
+	FreezeID string    `json:"freeze_id"`
+	RunID    string    `json:"run_id,omitempty"`
+	JobID    string    `json:"job_id"`
+	By       string    `json:"by"`
+	At       time.Time `json:"at"`
+}
+
+// Workflow chains jobs into a DAG orchestrated by the gateway
+type Workflow struct {
+	WorkflowID  string         `json:"workflow_id"`
+	DisplayName string         `json:"display_name,omitempty"`
+	Nodes       []WorkflowNode `json:"nodes"`
+}
+
+// WorkflowNode runs a job once its dependencies have finished.
+// Parameter values may reference "${params.<name>}" (workflow run parameters)
+// and "${nodes.<node_id>.run_id}" / "${nodes.<node_id>.status}".
+type WorkflowNode struct {
+	ID         string                 `json:"id"`
+	JobID      string                 `json:"job_id"`
+	DependsOn  []string               `json:"depends_on,omitempty"`
+	When       WorkflowCondition      `json:"when,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// WorkflowCondition decides whether a node runs given its dependencies' outcomes
+type WorkflowCondition string
+
+const (
+	ConditionSuccess WorkflowCondition = "success" // All dependencies succeeded (default)
+	ConditionFailure WorkflowCondition = "failure" // At least one dependency did not succeed
+	ConditionAlways  WorkflowCondition = "always"  // Run once all dependencies have finished
+)
+
+// WorkflowRun is one execution of a workflow
+type WorkflowRun struct {
+	WorkflowRunID string                 `json:"workflow_run_id"`
+	WorkflowID    string                 `json:"workflow_id"`
+	Status        WorkflowStatus         `json:"status"`
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+	TriggeredBy   string                 `json:"triggered_by,omitempty"`
+	Nodes         []WorkflowNodeRun      `json:"nodes"`
+	Sensitive     []string               `json:"sensitive_parameters"` // Parameters masked because they feed sensitive node parameters
+	CreatedAt     time.Time              `json:"created_at"`
+	FinishedAt    *time.Time             `json:"finished_at,omitempty"`
+}
+
+// WorkflowNodeRun is the state of one node within a workflow run
+type WorkflowNodeRun struct {
+	NodeID     string     `json:"node_id"`
+	JobID      string     `json:"job_id"`
+	Status     NodeStatus `json:"status"`
+	RunID      string     `json:"run_id,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// WorkflowStatus represents the overall state of a workflow run
+type WorkflowStatus string
+
+const (
+	WorkflowRunning   WorkflowStatus = "running"
+	WorkflowSucceeded WorkflowStatus = "succeeded"
+	WorkflowFailed    WorkflowStatus = "failed"
+	WorkflowCanceled  WorkflowStatus = "canceled"
+)
+
+// NodeStatus represents the state of a workflow node
+type NodeStatus string
+
+const (
+	NodePending   NodeStatus = "pending"   // Waiting for dependencies
+	NodeRunning   NodeStatus = "running"   // Run triggered and not finished
+	NodeSucceeded NodeStatus = "succeeded" // Run succeeded
+	NodeFailed    NodeStatus = "failed"    // Run failed, errored or could not be triggered
+	NodeCanceled  NodeStatus = "canceled"  // Run or workflow canceled
+	NodeSkipped   NodeStatus = "skipped"   // Condition not met
+)
+
+// IsDone reports whether the node will not change anymore
+func (s NodeStatus) IsDone() bool {
+	return s != NodePending && s != NodeRunning
+}
+
+// Batch triggers a job once per parameter set and aggregates the child runs
+type Batch struct {
+	BatchID     string            `json:"batch_id"`
+	JobID       string            `json:"job_id"`
+	Status      BatchStatus       `json:"status"`
+	MaxParallel int               `json:"max_parallel,omitempty"` // 0 means all at once
+	TriggeredBy string            `json:"triggered_by,omitempty"`
+	Counts      map[RunStatus]int `json:"counts"`
+	Runs        []BatchRun        `json:"runs"`
+	Sensitive   []string          `json:"sensitive_parameters"` // Parameters masked in runs
+	CreatedAt   time.Time         `json:"created_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// BatchRun is one child run of a batch. Children waiting for a parallelism
+// slot are queued and have no run_id yet.
+type BatchRun struct {
+	Index      int                    `json:"index"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	RunID      string                 `json:"run_id,omitempty"`
+	Status     RunStatus              `json:"status"`
+	Reason     string                 `json:"reason,omitempty"`
+}
+
+// BatchStatus represents the aggregate state of a batch
+type BatchStatus string
+
+const (
+	BatchRunning   BatchStatus = "running"   // Some children have not finished
+	BatchSucceeded BatchStatus = "succeeded" // Every child succeeded
+	BatchFailed    BatchStatus = "failed"    // Every child finished and at least one did not succeed
+)
@@ -0,0 +1,235 @@
+// Package params validates trigger parameters against a job's parameter schema.
+package params
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// Redacted replaces sensitive parameter values in responses and persisted state
+const Redacted = "***"
+
+// patterns caches compiled parameter patterns by expression. Patterns are
+// compiled when the schema is checked, not on every validation.
+var patterns sync.Map
+
+// FieldError describes why a single parameter was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// CheckSchema validates parameter definitions, including their defaults and enums
+func CheckSchema(schema []models.JobParameter) error {
+	seen := make(map[string]bool, len(schema))
+	for _, p := range schema {
+		if p.Name == "" {
+			return fmt.Errorf("parameter name is required")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate parameter %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		switch p.Type {
+		case models.ParamString, models.ParamInteger, models.ParamNumber, models.ParamBoolean:
+		default:
+			return fmt.Errorf("parameter %s: unknown type %q (expected string, integer, number or boolean)", p.Name, p.Type)
+		}
+
+		if p.Pattern != "" {
+			if p.Type != models.ParamString {
+				return fmt.Errorf("parameter %s: pattern requires type string", p.Name)
+			}
+			if _, err := compilePattern(p.Pattern); err != nil {
+				return fmt.Errorf("parameter %s: invalid pattern: %w", p.Name, err)
+			}
+		}
+
+		for _, v := range p.Enum {
+			if _, err := coerce(p.Type, v); err != nil {
+				return fmt.Errorf("parameter %s: enum value %v: %w", p.Name, v, err)
+			}
+		}
+
+		if p.Default != nil {
+			if _, err := check(p, p.Default); err != nil {
+				return fmt.Errorf("parameter %s: default: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks values against the schema and returns them with defaults
+// applied. An empty schema accepts any values unchanged.
+func Validate(schema []models.JobParameter, values map[string]interface{}) (map[string]interface{}, []FieldError) {
+	if len(schema) == 0 {
+		return values, nil
+	}
+
+	var errs []FieldError
+	out := make(map[string]interface{}, len(schema))
+	known := make(map[string]bool, len(schema))
+
+	for _, p := range schema {
+		known[p.Name] = true
+
+		value, present := values[p.Name]
+		if !present || value == nil {
+			switch {
+			case p.Default != nil:
+				value = p.Default
+			case p.Required:
+				errs = append(errs, FieldError{Field: p.Name, Message: "is required"})
+				continue
+			default:
+				continue
+			}
+		}
+
+		normalized, err := check(p, value)
+		if err != nil {
+			errs = append(errs, FieldError{Field: p.Name, Message: err.Error()})
+			continue
+		}
+		out[p.Name] = normalized
+	}
+
+	for name := range values {
+		if !known[name] {
+			errs = append(errs, FieldError{Field: name, Message: "is not a parameter of this job"})
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// Redact returns a copy of values with sensitive parameters masked
+func Redact(schema []models.JobParameter, values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	for _, p := range schema {
+		if _, ok := out[p.Name]; ok && p.Sensitive {
+			out[p.Name] = Redacted
+		}
+	}
+	return out
+}
+
+// HasRedacted reports whether any sensitive parameter in values holds the
+// mask instead of its value, e.g. after values were read back from state
+func HasRedacted(schema []models.JobParameter, values map[string]interface{}) bool {
+	for _, p := range schema {
+		if p.Sensitive && values[p.Name] == Redacted {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePattern returns the compiled expression, compiling it on first use
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// check validates a single value and returns it in its canonical Go type
+func check(p models.JobParameter, value interface{}) (interface{}, error) {
+	v, err := coerce(p.Type, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.Enum) > 0 {
+		allowed := false
+		for _, e := range p.Enum {
+			if ev, _ := coerce(p.Type, e); ev == v {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("must be one of %v", p.Enum)
+		}
+	}
+
+	if p.Pattern != "" {
+		// Pattern validity is checked when the schema is loaded
+		if re, err := compilePattern(p.Pattern); err == nil && !re.MatchString(v.(string)) {
+			return nil, fmt.Errorf("must match pattern %s", p.Pattern)
+		}
+	}
+
+	// The mask marks values that were not persisted, so it can't be a value itself
+	if p.Sensitive && v == Redacted {
+		return nil, fmt.Errorf("must not be %s", Redacted)
+	}
+
+	return v, nil
+}
+
+// coerce converts JSON- or YAML-decoded values to string, int64, float64 or bool
+func coerce(t models.ParameterType, value interface{}) (interface{}, error) {
+	switch t {
+	case models.ParamString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("must be a string")
+
+	case models.ParamBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("must be a boolean")
+
+	case models.ParamInteger:
+		switch n := value.(type) {
+		case int:
+			return int64(n), nil
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && !math.IsInf(n, 0) {
+				return int64(n), nil
+			}
+		}
+		return nil, fmt.Errorf("must be an integer")
+
+	case models.ParamNumber:
+		switch n := value.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+		return nil, fmt.Errorf("must be a number")
+	}
+
+	return nil, fmt.Errorf("unknown type %q", t)
+}
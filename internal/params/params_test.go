@@ -0,0 +1,117 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+var testSchema = []models.JobParameter{
+	{Name: "ref", Type: models.ParamString, Required: true, Pattern: `^(main|v\d+\.\d+\.\d+)$`},
+	{Name: "region", Type: models.ParamString, Default: "eu", Enum: []interface{}{"eu", "us"}},
+	{Name: "replicas", Type: models.ParamInteger, Default: 2},
+	{Name: "dry_run", Type: models.ParamBoolean},
+	{Name: "token", Type: models.ParamString, Sensitive: true},
+}
+
+func TestValidate_AppliesDefaults(t *testing.T) {
+	got, errs := Validate(testSchema, map[string]interface{}{"ref": "v1.2.3", "replicas": float64(3)})
+	if len(errs) > 0 {
+		t.Fatalf("Validate() errors = %v", errs)
+	}
+	if got["region"] != "eu" || got["replicas"] != int64(3) {
+		t.Errorf("Validate() = %v, want default region and integer replicas", got)
+	}
+	if _, ok := got["dry_run"]; ok {
+		t.Errorf("optional parameter without default should stay unset")
+	}
+}
+
+func TestValidate_FieldErrors(t *testing.T) {
+	_, errs := Validate(testSchema, map[string]interface{}{
+		"region":   "ap",
+		"replicas": 1.5,
+		"dry_run":  "yes",
+		"extra":    1,
+	})
+
+	want := map[string]string{
+		"dry_run":  "must be a boolean",
+		"extra":    "is not a parameter of this job",
+		"ref":      "is required",
+		"region":   "must be one of [eu us]",
+		"replicas": "must be an integer",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() errors = %v, want %d", errs, len(want))
+	}
+	for _, e := range errs {
+		if want[e.Field] != e.Message {
+			t.Errorf("field %s: message = %q, want %q", e.Field, e.Message, want[e.Field])
+		}
+	}
+
+	_, errs = Validate(testSchema, map[string]interface{}{"ref": "feature/x"})
+	if len(errs) != 1 || errs[0].Field != "ref" {
+		t.Errorf("Validate() errors = %v, want pattern error on ref", errs)
+	}
+}
+
+func TestValidate_NoSchema(t *testing.T) {
+	values := map[string]interface{}{"anything": true}
+	if got, errs := Validate(nil, values); len(errs) > 0 || got["anything"] != true {
+		t.Errorf("Validate() = %v, %v; want values unchanged", got, errs)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		param models.JobParameter
+	}{
+		{"unknown type", models.JobParameter{Name: "a", Type: "date"}},
+		{"bad pattern", models.JobParameter{Name: "a", Type: models.ParamString, Pattern: "("}},
+		{"pattern on integer", models.JobParameter{Name: "a", Type: models.ParamInteger, Pattern: "1"}},
+		{"default outside enum", models.JobParameter{Name: "a", Type: models.ParamString, Enum: []interface{}{"x"}, Default: "y"}},
+		{"enum of wrong type", models.JobParameter{Name: "a", Type: models.ParamInteger, Enum: []interface{}{"x"}}},
+	}
+	for _, tt := range tests {
+		if err := CheckSchema([]models.JobParameter{tt.param}); err == nil {
+			t.Errorf("%s: CheckSchema() error = nil, want error", tt.name)
+		}
+	}
+	if err := CheckSchema(testSchema); err != nil {
+		t.Errorf("CheckSchema(testSchema) error = %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := Redact(testSchema, map[string]interface{}{"ref": "main", "token": "s3cret"})
+	if got["token"] != Redacted || got["ref"] != "main" {
+		t.Errorf("Redact() = %v, want token masked", got)
+	}
+	if !HasRedacted(testSchema, got) {
+		t.Error("HasRedacted() = false for redacted values, want true")
+	}
+	if HasRedacted(testSchema, map[string]interface{}{"ref": Redacted, "token": "s3cret"}) {
+		t.Error("HasRedacted() = true for a non-sensitive mask, want false")
+	}
+
+	// The mask itself is not an acceptable sensitive value
+	if _, errs := Validate(testSchema, map[string]interface{}{"ref": "main", "token": Redacted}); len(errs) != 1 || errs[0].Field != "token" {
+		t.Errorf("Validate() errors = %v, want token rejected", errs)
+	}
+}
+
+func TestCompilePattern_Cached(t *testing.T) {
+	if err := CheckSchema(testSchema); err != nil {
+		t.Fatalf("CheckSchema() error = %v", err)
+	}
+	first, err := compilePattern(testSchema[0].Pattern)
+	if err != nil {
+		t.Fatalf("compilePattern() error = %v", err)
+	}
+	if again, _ := compilePattern(testSchema[0].Pattern); again != first {
+		t.Error("compilePattern() compiled a checked pattern again")
+	}
+}
@@ -2,6 +2,7 @@ package concourse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -50,11 +51,19 @@ func NewAdapter(cfg *Config, log *logger.Logger) (*Adapter, error) {
 	}, nil
 }
 
-// ConcourseJobRef represents a Concourse job reference
+// ConcourseJobRef represents a Concourse job reference.
+// InstanceVars and Pins may reference trigger parameters as "${params.<name>}".
 type ConcourseJobRef struct {
 	Team     string
 	Pipeline string
 	Job      string
+
+	// InstanceVars selects an instanced pipeline
+	InstanceVars map[string]interface{}
+
+	// Pins maps resource names to versions pinned around the trigger and
+	// restored once the build has picked up its inputs (see MapVersions)
+	Pins map[string]map[string]interface{}
 }
 
 func (c *ConcourseJobRef) Kind() string {
@@ -125,8 +134,14 @@ func (a *Adapter) Trigger(ctx context.Context, jobRef provider.JobRef, params pr
 		"job", ref.Job,
 		"param_count", len(params.Parameters))
 
+	// Concourse builds don't take parameters; they select the pipeline instance and input versions
+	instanceVars, err := resolveInstanceVars(ref.InstanceVars, params.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("map parameters: %w", err)
+	}
+
 	// Trigger build via Concourse API
-	build, err := a.client.CreateBuild(ctx, ref.Team, ref.Pipeline, ref.Job, params.Parameters)
+	build, err := a.client.CreateBuild(ctx, ref.Team, ref.Pipeline, ref.Job, instanceVars)
 	if err != nil {
 		logger.Error("provider: failed to create build",
 			"team", ref.Team,
@@ -180,7 +195,34 @@ func (a *Adapter) GetRun(ctx context.Context, runRef provider.RunRef) (*models.R
 		"build_id", ref.BuildID,
 		"status", build.Status)
 
-	return mapBuildToRun(build, ref), nil
+	return mapBuildToRun(build, ref, a.buildWebURL(ref, build)), nil
+}
+
+// buildWebURL returns the Concourse UI page of a build
+func (a *Adapter) buildWebURL(ref *ConcourseRunRef, build *Build) string {
+	return a.client.BuildWebURL(ref.Team, ref.Pipeline, ref.Job, build.Name, build.PipelineInstanceVars)
+}
+
+// InputVersions implements provider.InputVersionReader
+func (a *Adapter) InputVersions(ctx context.Context, runRef provider.RunRef) (map[string]map[string]string, error) {
+	logger := a.getLogger(ctx)
+
+	ref, ok := runRef.(*ConcourseRunRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid run ref type: expected ConcourseRunRef")
+	}
+
+	resources, err := a.client.GetBuildResources(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build resources", "build_id", ref.BuildID, "error", err)
+		return nil, err
+	}
+
+	versions := make(map[string]map[string]string, len(resources.Inputs))
+	for _, input := range resources.Inputs {
+		versions[input.Name] = input.Version
+	}
+	return versions, nil
 }
 
 // StreamEvents implements Provider.StreamEvents
@@ -241,6 +283,58 @@ func (a *Adapter) Cancel(ctx context.Context, runRef provider.RunRef) error {
 	return nil
 }
 
+// Rerun implements provider.Rerunner using Concourse's rerun-build API, which
+// creates a build of the same job with the inputs of the earlier build
+func (a *Adapter) Rerun(ctx context.Context, runRef provider.RunRef) (provider.RunRef, error) {
+	logger := a.getLogger(ctx)
+
+	ref, ok := runRef.(*ConcourseRunRef)
+	if !ok {
+		logger.Error("provider: invalid run ref type for rerun", "expected", "ConcourseRunRef")
+		return nil, fmt.Errorf("invalid run ref type: expected ConcourseRunRef")
+	}
+
+	// Reruns are addressed by build name within the job's pipeline instance
+	origin, err := a.client.GetBuild(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build to rerun",
+			"build_id", ref.BuildID,
+			"error", err)
+		return nil, err
+	}
+
+	logger.Debug("provider: rerunning concourse build",
+		"team", ref.Team,
+		"pipeline", ref.Pipeline,
+		"job", ref.Job,
+		"build_id", ref.BuildID,
+		"build_name", origin.Name)
+
+	build, err := a.client.RerunBuild(ctx, ref.Team, ref.Pipeline, ref.Job, origin.Name, origin.PipelineInstanceVars)
+	if err != nil {
+		logger.Error("provider: failed to rerun build",
+			"build_id", ref.BuildID,
+			"error", err)
+		return nil, fmt.Errorf("rerun build: %w", err)
+	}
+
+	logger.Info("provider: build rerun",
+		"team", ref.Team,
+		"pipeline", ref.Pipeline,
+		"job", ref.Job,
+		"rerun_of", ref.BuildID,
+		"build_id", build.ID,
+		"build_name", build.Name)
+
+	return &ConcourseRunRef{
+		Team:      ref.Team,
+		Pipeline:  ref.Pipeline,
+		Job:       ref.Job,
+		BuildID:   build.ID,
+		BuildName: build.Name,
+	}, nil
+}
+
 // ListPipelines lists all pipelines for the configured team
 func (a *Adapter) ListPipelines(ctx context.Context) ([]Pipeline, error) {
 	logger := a.getLogger(ctx)
@@ -271,7 +365,7 @@ func (a *Adapter) ListJobs(ctx context.Context, pipeline string) ([]Job, error)
 		"team", a.config.Team,
 		"pipeline", pipeline)
 
-	jobs, err := a.client.ListJobs(ctx, a.config.Team, pipeline)
+	jobs, err := a.client.ListJobs(ctx, a.config.Team, pipeline, nil)
 	if err != nil {
 		logger.Error("provider: failed to list jobs",
 			"team", a.config.Team,
@@ -288,6 +382,186 @@ func (a *Adapter) ListJobs(ctx context.Context, pipeline string) ([]Job, error)
 	return jobs, nil
 }
 
+// ListTeamJobs lists all jobs in a pipeline of a specific team. Non-empty
+// instanceVars select an instanced pipeline.
+func (a *Adapter) ListTeamJobs(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]Job, error) {
+	logger := a.getLogger(ctx)
+
+	logger.Debug("provider: listing team jobs", "team", team, "pipeline", pipeline)
+
+	jobs, err := a.client.ListJobs(ctx, team, pipeline, instanceVars)
+	if err != nil {
+		logger.Error("provider: failed to list team jobs", "team", team, "pipeline", pipeline, "error", err)
+		return nil, fmt.Errorf("list team jobs: %w", err)
+	}
+
+	logger.Info("provider: team jobs listed", "team", team, "pipeline", pipeline, "count", len(jobs))
+	return jobs, nil
+}
+
+// ValidateJob implements provider.JobValidator by looking the job up in its
+// pipeline. Jobs whose instance vars reference trigger parameters can't be
+// resolved ahead of a trigger and are accepted as-is.
+func (a *Adapter) ValidateJob(ctx context.Context, jobRef provider.JobRef) error {
+	logger := a.getLogger(ctx)
+
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+
+	if name, ok := paramInstanceVar(ref.InstanceVars); ok {
+		logger.Debug("provider: skipping job validation, instance vars depend on trigger parameters",
+			"pipeline", ref.Pipeline,
+			"job", ref.Job,
+			"instance_var", name)
+		return nil
+	}
+
+	jobs, err := a.client.ListJobs(ctx, ref.Team, ref.Pipeline, ref.InstanceVars)
+	if errors.Is(err, provider.ErrRunNotFound) {
+		return fmt.Errorf("%w: pipeline %s not found in team %s", provider.ErrJobNotFound, ref.Pipeline, ref.Team)
+	}
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+
+	for _, j := range jobs {
+		if j.Name == ref.Job {
+			logger.Debug("provider: job validated",
+				"team", ref.Team,
+				"pipeline", ref.Pipeline,
+				"job", ref.Job)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: pipeline %s has no job %s", provider.ErrJobNotFound, ref.Pipeline, ref.Job)
+}
+
+// LatestRuns implements provider.LatestRunsReader using the finished and
+// next builds Concourse reports for the job. Jobs whose instance vars
+// reference trigger parameters have no single pipeline to look at and report
+// no runs.
+func (a *Adapter) LatestRuns(ctx context.Context, jobRef provider.JobRef) (finished, next *models.Run, err error) {
+	logger := a.getLogger(ctx)
+
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	if _, ok := paramInstanceVar(ref.InstanceVars); ok {
+		return nil, nil, nil
+	}
+
+	logger.Debug("provider: getting job", "team", ref.Team, "pipeline", ref.Pipeline, "job", ref.Job)
+
+	job, err := a.client.GetJob(ctx, ref.Team, ref.Pipeline, ref.Job, ref.InstanceVars)
+	if errors.Is(err, provider.ErrRunNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s/%s/%s", provider.ErrJobNotFound, ref.Team, ref.Pipeline, ref.Job)
+	}
+	if err != nil {
+		logger.Error("provider: failed to get job", "pipeline", ref.Pipeline, "job", ref.Job, "error", err)
+		return nil, nil, fmt.Errorf("get job: %w", err)
+	}
+
+	toRun := func(build *Build) *models.Run {
+		if build == nil {
+			return nil
+		}
+		runRef := &ConcourseRunRef{
+			Team:      ref.Team,
+			Pipeline:  ref.Pipeline,
+			Job:       ref.Job,
+			BuildID:   build.ID,
+			BuildName: build.Name,
+		}
+		return mapBuildToRun(build, runRef, a.buildWebURL(runRef, build))
+	}
+	return toRun(job.FinishedBuild), toRun(job.NextBuild), nil
+}
+
+// SetPaused implements provider.Pauser. Jobs whose instance vars reference
+// trigger parameters have no single pipeline to pause.
+func (a *Adapter) SetPaused(ctx context.Context, jobRef provider.JobRef, target models.PauseTarget, paused bool) error {
+	logger := a.getLogger(ctx)
+
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	if name, ok := paramInstanceVar(ref.InstanceVars); ok {
+		return fmt.Errorf("%w: instance var %q", provider.ErrJobRefUnresolved, name)
+	}
+
+	var err error
+	if target == models.PauseTargetPipeline {
+		err = a.client.SetPipelinePaused(ctx, ref.Team, ref.Pipeline, ref.InstanceVars, paused)
+	} else {
+		err = a.client.SetJobPaused(ctx, ref.Team, ref.Pipeline, ref.Job, ref.InstanceVars, paused)
+	}
+	if errors.Is(err, provider.ErrRunNotFound) {
+		return fmt.Errorf("%w: %s/%s/%s", provider.ErrJobNotFound, ref.Team, ref.Pipeline, ref.Job)
+	}
+	if err != nil {
+		logger.Error("provider: failed to set paused",
+			"team", ref.Team,
+			"pipeline", ref.Pipeline,
+			"job", ref.Job,
+			"target", target,
+			"paused", paused,
+			"error", err)
+		return fmt.Errorf("%s %s: %w", pauseAction(paused), target, err)
+	}
+
+	logger.Info("provider: pause state changed",
+		"team", ref.Team,
+		"pipeline", ref.Pipeline,
+		"job", ref.Job,
+		"target", target,
+		"paused", paused)
+	return nil
+}
+
+// PauseState implements provider.Pauser from the job and its pipeline. Jobs
+// whose instance vars reference trigger parameters report no state.
+func (a *Adapter) PauseState(ctx context.Context, jobRef provider.JobRef) (*models.JobPause, error) {
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	if _, ok := paramInstanceVar(ref.InstanceVars); ok {
+		return nil, nil
+	}
+
+	lookupErr := func(what string, err error) error {
+		if errors.Is(err, provider.ErrRunNotFound) {
+			return fmt.Errorf("%w: %s/%s/%s", provider.ErrJobNotFound, ref.Team, ref.Pipeline, ref.Job)
+		}
+		return fmt.Errorf("get %s: %w", what, err)
+	}
+
+	pipeline, err := a.client.GetPipeline(ctx, ref.Team, ref.Pipeline, ref.InstanceVars)
+	if err != nil {
+		return nil, lookupErr("pipeline", err)
+	}
+	job, err := a.client.GetJob(ctx, ref.Team, ref.Pipeline, ref.Job, ref.InstanceVars)
+	if err != nil {
+		return nil, lookupErr("job", err)
+	}
+	return &models.JobPause{Job: job.Paused, Pipeline: pipeline.Paused, CheckedAt: time.Now()}, nil
+}
+
+// paramInstanceVar returns the name of an instance var that references a
+// trigger parameter, if any
+func paramInstanceVar(instanceVars map[string]interface{}) (string, bool) {
+	for name, value := range instanceVars {
+		if s, ok := value.(string); ok && paramRef.MatchString(s) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // ListJobBuilds lists recent builds for a job
 func (a *Adapter) ListJobBuilds(ctx context.Context, pipeline, job string, limit int) ([]Build, error) {
 	logger := a.getLogger(ctx)
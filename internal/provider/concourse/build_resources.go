@@ -0,0 +1,141 @@
+package concourse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// planResource is a get or put step of a build plan
+type planResource struct {
+	id       string
+	step     string // get or put
+	name     string
+	resource string
+	typ      string
+}
+
+// RunResources implements provider.RunResourceReader. Versions come from the
+// build's resources; resource types from the get and put steps of the build
+// plan; metadata from the finish-get and finish-put events.
+func (a *Adapter) RunResources(ctx context.Context, runRef provider.RunRef) (*models.RunResources, error) {
+	logger := a.getLogger(ctx)
+
+	ref, ok := runRef.(*ConcourseRunRef)
+	if !ok {
+		logger.Error("provider: invalid run ref type for resources", "expected", "ConcourseRunRef")
+		return nil, fmt.Errorf("invalid run ref type: expected ConcourseRunRef")
+	}
+
+	build, err := a.client.GetBuild(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build", "build_id", ref.BuildID, "error", err)
+		return nil, err
+	}
+
+	resources, err := a.client.GetBuildResources(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build resources", "build_id", ref.BuildID, "error", err)
+		return nil, fmt.Errorf("get build resources: %w", err)
+	}
+
+	// Types and metadata are best effort: versions alone still answer which
+	// commit a run used
+	var steps []planResource
+	if plan, err := a.client.GetBuildPlan(ctx, ref.BuildID); err != nil {
+		logger.Warn("provider: failed to get build plan", "build_id", ref.BuildID, "error", err)
+	} else {
+		steps = collectPlanResources(plan, nil)
+	}
+	metadata := make(map[string]map[string]string)
+	if events, err := a.buildEvents(ctx, build); err != nil {
+		logger.Warn("provider: failed to read build events", "build_id", ref.BuildID, "error", err)
+	} else {
+		for _, event := range events {
+			if (event.Event == "finish-get" || event.Event == "finish-put") && len(event.Data.Metadata) > 0 {
+				metadata[event.Data.Origin.ID] = mapMetadata(event.Data.Metadata)
+			}
+		}
+	}
+
+	// Inputs are named after their get step, outputs after their resource.
+	// Steps that reported metadata win over others of the same name, such as
+	// the implicit get after a put.
+	find := func(step string, match func(planResource) bool) (typ string, meta map[string]string) {
+		for _, p := range steps {
+			if p.step != step || !match(p) {
+				continue
+			}
+			if typ == "" {
+				typ = p.typ
+			}
+			if meta == nil && metadata[p.id] != nil {
+				typ, meta = p.typ, metadata[p.id]
+			}
+		}
+		return typ, meta
+	}
+
+	result := &models.RunResources{
+		Inputs:  make([]models.RunResource, 0, len(resources.Inputs)),
+		Outputs: make([]models.RunResource, 0, len(resources.Outputs)),
+	}
+	for _, input := range resources.Inputs {
+		typ, meta := find("get", func(p planResource) bool { return p.name == input.Name })
+		result.Inputs = append(result.Inputs, models.RunResource{Name: input.Name, Type: typ, Version: input.Version, Metadata: meta})
+	}
+	for _, output := range resources.Outputs {
+		typ, meta := find("put", func(p planResource) bool { return p.resource == output.Name || p.name == output.Name })
+		result.Outputs = append(result.Outputs, models.RunResource{Name: output.Name, Type: typ, Version: output.Version, Metadata: meta})
+	}
+
+	logger.Debug("provider: build resources retrieved",
+		"build_id", ref.BuildID,
+		"inputs", len(result.Inputs),
+		"outputs", len(result.Outputs))
+	return result, nil
+}
+
+// collectPlanResources walks a build plan and appends its get and put steps
+func collectPlanResources(node interface{}, out []planResource) []planResource {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		id, _ := n["id"].(string)
+		for key, value := range n {
+			if key != "get" && key != "put" {
+				out = collectPlanResources(value, out)
+				continue
+			}
+			fields, _ := value.(map[string]interface{})
+			p := planResource{id: id, step: key}
+			p.name, _ = fields["name"].(string)
+			p.resource, _ = fields["resource"].(string)
+			p.typ, _ = fields["type"].(string)
+			if p.resource == "" {
+				p.resource = p.name
+			}
+			out = append(out, p)
+		}
+	case []interface{}:
+		for _, child := range n {
+			out = collectPlanResources(child, out)
+		}
+	}
+	return out
+}
+
+// mapMetadata converts Concourse version metadata to a map. Repeated names
+// are joined with newlines.
+func mapMetadata(fields []MetadataField) map[string]string {
+	out := make(map[string]string, len(fields))
+	for _, f := range fields {
+		if prev, ok := out[f.Name]; ok {
+			out[f.Name] = prev + "\n" + f.Value
+			continue
+		}
+		out[f.Name] = f.Value
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package concourse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// buildPlan gets a commit, tags it with a get named after its resource and
+// pushes an image; the put is followed by its implicit get
+const buildPlan = `{"id": "root", "do": [
+	{"id": "in", "in_parallel": {"steps": [
+		{"id": "get-source", "get": {"name": "source", "resource": "repo", "type": "git"}},
+		{"id": "get-version", "get": {"name": "version", "type": "semver"}}
+	]}},
+	{"id": "push", "on_success": {
+		"step": {"id": "put-image", "put": {"name": "push", "resource": "image", "type": "registry-image"}},
+		"on_success": {"id": "get-image", "get": {"name": "push", "resource": "image", "type": "registry-image"}}
+	}}
+]}`
+
+func TestCollectPlanResources(t *testing.T) {
+	var plan map[string]interface{}
+	if err := json.Unmarshal([]byte(buildPlan), &plan); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+
+	got := make(map[string]planResource)
+	for _, p := range collectPlanResources(plan, nil) {
+		got[p.id] = p
+	}
+	want := map[string]planResource{
+		"get-source":  {id: "get-source", step: "get", name: "source", resource: "repo", typ: "git"},
+		"get-version": {id: "get-version", step: "get", name: "version", resource: "version", typ: "semver"},
+		"put-image":   {id: "put-image", step: "put", name: "push", resource: "image", typ: "registry-image"},
+		"get-image":   {id: "get-image", step: "get", name: "push", resource: "image", typ: "registry-image"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectPlanResources() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if out := collectPlanResources(nil, nil); len(out) != 0 {
+		t.Errorf("collectPlanResources(nil) = %+v, want none", out)
+	}
+}
+
+func TestMapMetadata(t *testing.T) {
+	got := mapMetadata([]MetadataField{
+		{Name: "commit", Value: "abc123"},
+		{Name: "tag", Value: "v1"},
+		{Name: "tag", Value: "latest"},
+		{Name: "message", Value: ""},
+	})
+	want := map[string]string{"commit": "abc123", "tag": "v1\nlatest", "message": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapMetadata() = %q, want %q", got, want)
+	}
+	if got := mapMetadata(nil); len(got) != 0 {
+		t.Errorf("mapMetadata(nil) = %q, want empty", got)
+	}
+}
+
+// fakeBuild serves build 42 with its resources, plan and events
+type fakeBuild struct {
+	plan   string // Served with status 500 when empty
+	events []BuildEvent
+}
+
+func (f *fakeBuild) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/builds/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Build{ID: 42, Name: "7", Status: "succeeded"})
+	})
+	mux.HandleFunc("GET /api/v1/builds/42/resources", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(BuildResources{
+			Inputs: []BuildInput{
+				{Name: "source", Version: map[string]string{"ref": "abc123"}},
+				{Name: "version", Version: map[string]string{"number": "1.2.0"}},
+			},
+			Outputs: []BuildOutput{{Name: "image", Version: map[string]string{"digest": "sha256:f00"}}},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/builds/42/plan", func(w http.ResponseWriter, r *http.Request) {
+		if f.plan == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"schema": "exec.v2", "plan": %s}`, f.plan)
+	})
+	mux.HandleFunc("GET /api/v1/builds/42/events", func(w http.ResponseWriter, r *http.Request) {
+		for i, event := range f.events {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "id: %d\nevent: event\ndata: %s\n\n", i, data)
+		}
+		fmt.Fprint(w, "event: end\ndata\n\n")
+	})
+	return mux
+}
+
+// metadataEvent builds a finish event of a step reporting version metadata
+func metadataEvent(name, origin string, fields ...MetadataField) BuildEvent {
+	e := finished(name, origin, 100, 0)
+	e.Data.Metadata = fields
+	return e
+}
+
+func TestAdapter_RunResources(t *testing.T) {
+	f := &fakeBuild{
+		plan: buildPlan,
+		events: []BuildEvent{
+			metadataEvent("finish-get", "get-source",
+				MetadataField{Name: "author", Value: "alice"},
+				MetadataField{Name: "tag", Value: "v1"},
+				MetadataField{Name: "tag", Value: "release"}),
+			finished("finish-get", "get-version", 101, 0),
+			metadataEvent("finish-put", "put-image", MetadataField{Name: "repository", Value: "registry/app"}),
+			metadataEvent("finish-get", "get-image", MetadataField{Name: "repository", Value: "ignored"}),
+		},
+	}
+	a := newTestAdapter(t, f.handler())
+	ref := &ConcourseRunRef{Team: "main", Pipeline: "p", Job: "build", BuildID: 42}
+
+	got, err := a.RunResources(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("RunResources() error = %v", err)
+	}
+	want := &models.RunResources{
+		Inputs: []models.RunResource{
+			{Name: "source", Type: "git", Version: map[string]string{"ref": "abc123"},
+				Metadata: map[string]string{"author": "alice", "tag": "v1\nrelease"}},
+			{Name: "version", Type: "semver", Version: map[string]string{"number": "1.2.0"}},
+		},
+		Outputs: []models.RunResource{
+			{Name: "image", Type: "registry-image", Version: map[string]string{"digest": "sha256:f00"},
+				Metadata: map[string]string{"repository": "registry/app"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RunResources() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestAdapter_RunResources_WithoutPlan(t *testing.T) {
+	// Without a plan the versions are still reported, untyped
+	a := newTestAdapter(t, (&fakeBuild{}).handler())
+
+	got, err := a.RunResources(context.Background(), &ConcourseRunRef{Team: "main", BuildID: 42})
+	if err != nil {
+		t.Fatalf("RunResources() error = %v", err)
+	}
+	if len(got.Inputs) != 2 || got.Inputs[0].Type != "" || got.Inputs[0].Version["ref"] != "abc123" || got.Inputs[0].Metadata != nil {
+		t.Errorf("inputs = %+v, want untyped versions", got.Inputs)
+	}
+	if len(got.Outputs) != 1 || got.Outputs[0].Version["digest"] != "sha256:f00" {
+		t.Errorf("outputs = %+v, want the image version", got.Outputs)
+	}
+
+	if _, err := a.RunResources(context.Background(), &ConcourseRunRef{Team: "main", BuildID: 7}); err == nil {
+		t.Error("RunResources() of an unknown build succeeded, want error")
+	}
+}
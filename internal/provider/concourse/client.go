@@ -8,6 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lei/simple-ci/pkg/logger"
@@ -29,16 +33,42 @@ type Build struct {
 	StartTime  int64  `json:"start_time"`
 	EndTime    int64  `json:"end_time"`
 	CreateTime int64  `json:"create_time"`
+
+	TeamName     string `json:"team_name,omitempty"`
+	PipelineName string `json:"pipeline_name,omitempty"`
+	JobName      string `json:"job_name,omitempty"`
+	CreatedBy    string `json:"created_by,omitempty"` // User who triggered the build, if any
+
+	PipelineInstanceVars map[string]interface{} `json:"pipeline_instance_vars,omitempty"`
+}
+
+// BuildInput is a resource version a build used as input
+type BuildInput struct {
+	Name    string            `json:"name"`
+	Version map[string]string `json:"version"`
+}
+
+// BuildOutput is a resource version a build produced
+type BuildOutput struct {
+	Name    string            `json:"name"`
+	Version map[string]string `json:"version"`
+}
+
+// BuildResources lists the inputs and outputs of a build
+type BuildResources struct {
+	Inputs  []BuildInput  `json:"inputs"`
+	Outputs []BuildOutput `json:"outputs"`
 }
 
 // Pipeline represents a Concourse pipeline
 type Pipeline struct {
-	Name         string `json:"name"`
-	TeamName     string `json:"team_name"`
-	Paused       bool   `json:"paused"`
-	Public       bool   `json:"public"`
-	Archived     bool   `json:"archived"`
-	LastUpdated  int64  `json:"last_updated"`
+	Name         string                 `json:"name"`
+	TeamName     string                 `json:"team_name"`
+	Paused       bool                   `json:"paused"`
+	Public       bool                   `json:"public"`
+	Archived     bool                   `json:"archived"`
+	LastUpdated  int64                  `json:"last_updated"`
+	InstanceVars map[string]interface{} `json:"instance_vars,omitempty"`
 }
 
 // Job represents a Concourse job
@@ -138,20 +168,78 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body io.Rea
 	return resp, err
 }
 
-// CreateBuild triggers a new build for a job
-func (c *Client) CreateBuild(ctx context.Context, team, pipeline, job string, params map[string]interface{}) (*Build, error) {
-	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/builds", team, pipeline, job)
+// pipelineQuery returns the query string selecting an instanced pipeline ("" for regular pipelines)
+func pipelineQuery(instanceVars map[string]interface{}) (string, error) {
+	if len(instanceVars) == 0 {
+		return "", nil
+	}
+	payload, err := json.Marshal(instanceVars)
+	if err != nil {
+		return "", fmt.Errorf("marshal instance vars: %w", err)
+	}
+	return "?" + url.Values{"vars": []string{string(payload)}}.Encode(), nil
+}
 
-	var body io.Reader
-	if len(params) > 0 {
-		jsonBody, err := json.Marshal(params)
+// BuildWebURL returns the Concourse web page of a build
+func (c *Client) BuildWebURL(team, pipeline, job, build string, instanceVars map[string]interface{}) string {
+	page := fmt.Sprintf("%s/teams/%s/pipelines/%s/jobs/%s/builds/%s",
+		strings.TrimSuffix(c.baseURL, "/"),
+		url.PathEscape(team),
+		url.PathEscape(pipeline),
+		url.PathEscape(job),
+		url.PathEscape(build))
+	if len(instanceVars) == 0 {
+		return page
+	}
+
+	// The web UI takes instance vars as one vars.<name> parameter each
+	query := url.Values{}
+	for name, value := range instanceVars {
+		payload, err := json.Marshal(value)
 		if err != nil {
-			return nil, fmt.Errorf("marshal params: %w", err)
+			continue
 		}
-		body = bytes.NewReader(jsonBody)
+		query.Set("vars."+name, string(payload))
 	}
+	return page + "?" + query.Encode()
+}
 
-	resp, err := c.doRequest(ctx, "POST", path, body)
+// CreateBuild triggers a new build for a job. Non-empty instanceVars select an instanced pipeline.
+func (c *Client) CreateBuild(ctx context.Context, team, pipeline, job string, instanceVars map[string]interface{}) (*Build, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/builds%s", team, pipeline, job, query)
+
+	resp, err := c.doRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var build Build
+	if err := json.NewDecoder(resp.Body).Decode(&build); err != nil {
+		return nil, fmt.Errorf("decode build response: %w", err)
+	}
+
+	return &build, nil
+}
+
+// RerunBuild starts a new build of a job with the inputs of an earlier build.
+// Non-empty instanceVars select an instanced pipeline.
+func (c *Client) RerunBuild(ctx context.Context, team, pipeline, job, buildName string, instanceVars map[string]interface{}) (*Build, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/builds/%s%s", team, pipeline, job, url.PathEscape(buildName), query)
+
+	resp, err := c.doRequest(ctx, "POST", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -258,6 +346,96 @@ func (c *Client) StreamBuildEvents(ctx context.Context, buildID int, writer io.W
 	return scanner.Err()
 }
 
+// GetBuildResources retrieves the resource versions a build used and produced
+func (c *Client) GetBuildResources(ctx context.Context, buildID int) (*BuildResources, error) {
+	path := fmt.Sprintf("/api/v1/builds/%d/resources", buildID)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var resources BuildResources
+	if err := json.NewDecoder(resp.Body).Decode(&resources); err != nil {
+		return nil, fmt.Errorf("decode build resources: %w", err)
+	}
+
+	return &resources, nil
+}
+
+// BuildEvent is a decoded Concourse build event. Log events are not kept.
+type BuildEvent struct {
+	Event string         `json:"event"` // e.g. initialize-task, start-task, finish-task, error, status
+	Data  BuildEventData `json:"data"`
+}
+
+// BuildEventData holds the build event fields the gateway reads
+type BuildEventData struct {
+	Origin struct {
+		ID string `json:"id"`
+	} `json:"origin"`
+	Time       int64           `json:"time"`
+	ExitStatus *int            `json:"exit_status,omitempty"`
+	Succeeded  *bool           `json:"succeeded,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Metadata   []MetadataField `json:"metadata,omitempty"` // Resource metadata of finish-get and finish-put
+}
+
+// ReadBuildEvents reads the events of a build until the end of the stream.
+// Concourse keeps the stream open while the build runs, so for running
+// builds a wait > 0 bounds the read and the events seen so far are returned.
+func (c *Client) ReadBuildEvents(ctx context.Context, buildID int, wait time.Duration) ([]BuildEvent, error) {
+	path := fmt.Sprintf("/api/v1/builds/%d/events", buildID)
+
+	readCtx := ctx
+	if wait > 0 {
+		var cancel context.CancelFunc
+		readCtx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
+	}
+
+	resp, err := c.doRequest(readCtx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var events []BuildEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "event: end" {
+			return events, nil
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+
+		var event BuildEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil || event.Event == "log" {
+			continue // Skip malformed and log events
+		}
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil && (readCtx.Err() == nil || ctx.Err() != nil) {
+		return nil, err
+	}
+	return events, nil
+}
+
 // ListPipelines lists all pipelines for a team
 func (c *Client) ListPipelines(ctx context.Context, team string) ([]Pipeline, error) {
 	path := fmt.Sprintf("/api/v1/teams/%s/pipelines", team)
@@ -280,9 +458,89 @@ func (c *Client) ListPipelines(ctx context.Context, team string) ([]Pipeline, er
 	return pipelines, nil
 }
 
-// ListJobs lists all jobs in a pipeline
-func (c *Client) ListJobs(ctx context.Context, team, pipeline string) ([]Job, error) {
-	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs", team, pipeline)
+// GetPipeline retrieves a pipeline. Non-empty instanceVars select an instanced pipeline.
+func (c *Client) GetPipeline(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) (*Pipeline, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s%s", team, pipeline, query)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var p Pipeline
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("decode pipeline: %w", err)
+	}
+
+	return &p, nil
+}
+
+// SetPipelinePaused pauses or unpauses a pipeline. Non-empty instanceVars
+// select an instanced pipeline.
+func (c *Client) SetPipelinePaused(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, paused bool) error {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/%s%s", team, pipeline, pauseAction(paused), query)
+
+	resp, err := c.doRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return parseError(resp)
+	}
+	return nil
+}
+
+// SetJobPaused pauses or unpauses a job. Non-empty instanceVars select an
+// instanced pipeline.
+func (c *Client) SetJobPaused(ctx context.Context, team, pipeline, job string, instanceVars map[string]interface{}, paused bool) error {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/%s%s", team, pipeline, job, pauseAction(paused), query)
+
+	resp, err := c.doRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return parseError(resp)
+	}
+	return nil
+}
+
+// pauseAction returns the API path segment that pauses or unpauses
+func pauseAction(paused bool) string {
+	if paused {
+		return "pause"
+	}
+	return "unpause"
+}
+
+// ListJobs lists all jobs in a pipeline. Non-empty instanceVars select an instanced pipeline.
+func (c *Client) ListJobs(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]Job, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs%s", team, pipeline, query)
 
 	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
@@ -302,6 +560,33 @@ func (c *Client) ListJobs(ctx context.Context, team, pipeline string) ([]Job, er
 	return jobs, nil
 }
 
+// GetJob gets a job, including its finished and next builds. Non-empty
+// instanceVars select an instanced pipeline.
+func (c *Client) GetJob(ctx context.Context, team, pipeline, job string, instanceVars map[string]interface{}) (*Job, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s%s", team, pipeline, job, query)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var j Job
+	if err := json.NewDecoder(resp.Body).Decode(&j); err != nil {
+		return nil, fmt.Errorf("decode job: %w", err)
+	}
+
+	return &j, nil
+}
+
 // ListBuilds lists builds for a job
 func (c *Client) ListBuilds(ctx context.Context, team, pipeline, job string, limit int) ([]Build, error) {
 	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/builds", team, pipeline, job)
@@ -370,3 +655,339 @@ func (c *Client) ListTeams(ctx context.Context) ([]Team, error) {
 
 	return teams, nil
 }
+
+// ResourceVersion represents a version of a Concourse resource
+type ResourceVersion struct {
+	ID       int               `json:"id"`
+	Version  map[string]string `json:"version"`
+	Metadata []MetadataField   `json:"metadata,omitempty"`
+	Enabled  bool              `json:"enabled"`
+}
+
+// MetadataField is a name/value pair a resource reports for a version
+type MetadataField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Page selects a page of a paginated Concourse list. From and To are
+// inclusive IDs: From pages towards newer entries, To towards older ones.
+type Page struct {
+	Limit int `json:"limit,omitempty"`
+	From  int `json:"from,omitempty"`
+	To    int `json:"to,omitempty"`
+}
+
+// Pagination holds the pages around a list page; nil where there is none
+type Pagination struct {
+	Previous *Page `json:"previous,omitempty"` // Newer entries
+	Next     *Page `json:"next,omitempty"`     // Older entries
+}
+
+// linkRel matches one entry of a Link header, e.g. `<url>; rel="next"`
+var linkRel = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="([^"]*)"`)
+
+// parsePagination reads the previous and next pages from a Link header
+func parsePagination(header string) *Pagination {
+	var p Pagination
+	for _, m := range linkRel.FindAllStringSubmatch(header, -1) {
+		u, err := url.Parse(m[1])
+		if err != nil {
+			continue
+		}
+		page := &Page{}
+		page.Limit, _ = strconv.Atoi(u.Query().Get("limit"))
+		page.From, _ = strconv.Atoi(u.Query().Get("from"))
+		page.To, _ = strconv.Atoi(u.Query().Get("to"))
+		switch m[2] {
+		case "previous":
+			p.Previous = page
+		case "next":
+			p.Next = page
+		}
+	}
+	return &p
+}
+
+// ListResourceVersions lists versions of a resource, newest first. Non-empty
+// instanceVars select an instanced pipeline.
+func (c *Client) ListResourceVersions(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string, page Page) ([]ResourceVersion, *Pagination, error) {
+	query := url.Values{}
+	if len(instanceVars) > 0 {
+		payload, err := json.Marshal(instanceVars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal instance vars: %w", err)
+		}
+		query.Set("vars", string(payload))
+	}
+	for name, value := range map[string]int{"limit": page.Limit, "from": page.From, "to": page.To} {
+		if value > 0 {
+			query.Set(name, strconv.Itoa(value))
+		}
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/versions", team, pipeline, resource)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, parseError(resp)
+	}
+
+	var versions []ResourceVersion
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		return nil, nil, fmt.Errorf("decode resource versions: %w", err)
+	}
+
+	return versions, parsePagination(resp.Header.Get("Link")), nil
+}
+
+// FindResourceVersion looks up the version of a resource matching every given field.
+// Returns nil if the resource has no such version yet.
+func (c *Client) FindResourceVersion(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string, version map[string]string) (*ResourceVersion, error) {
+	query := url.Values{}
+	if len(instanceVars) > 0 {
+		payload, err := json.Marshal(instanceVars)
+		if err != nil {
+			return nil, fmt.Errorf("marshal instance vars: %w", err)
+		}
+		query.Set("vars", string(payload))
+	}
+	for field, value := range version {
+		query.Add("filter", field+":"+value)
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/versions?%s", team, pipeline, resource, query.Encode())
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var versions []ResourceVersion
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		return nil, fmt.Errorf("decode resource versions: %w", err)
+	}
+
+	// The filter is a hint; only accept versions matching every field exactly
+	for i, v := range versions {
+		match := true
+		for field, value := range version {
+			if v.Version[field] != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			return &versions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckResource asks Concourse to check a resource starting from the given
+// version (nil for the latest) and returns the check build
+func (c *Client) CheckResource(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string, from map[string]string) (*Build, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/check%s", team, pipeline, resource, query)
+
+	body, err := json.Marshal(map[string]interface{}{"from": from})
+	if err != nil {
+		return nil, fmt.Errorf("marshal check request: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, "POST", path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, parseError(resp)
+	}
+
+	var build Build
+	if err := json.NewDecoder(resp.Body).Decode(&build); err != nil {
+		return nil, fmt.Errorf("decode check build: %w", err)
+	}
+
+	return &build, nil
+}
+
+// PinResourceVersion pins a resource to the version with the given ID
+func (c *Client) PinResourceVersion(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string, versionID int) error {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/versions/%d/pin%s", team, pipeline, resource, versionID, query)
+
+	resp, err := c.doRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return parseError(resp)
+	}
+	return nil
+}
+
+// Resource represents a Concourse resource and its pin state
+type Resource struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	Icon           string            `json:"icon,omitempty"`
+	LastChecked    int64             `json:"last_checked,omitempty"`
+	PinnedVersion  map[string]string `json:"pinned_version,omitempty"`
+	PinnedInConfig bool              `json:"pinned_in_config,omitempty"`
+	PinComment     string            `json:"pin_comment,omitempty"`
+}
+
+// ResourceType represents a resource type defined by a pipeline
+type ResourceType struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Privileged bool     `json:"privileged,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	CheckEvery string   `json:"check_every,omitempty"`
+}
+
+// ListResources lists the resources of a pipeline. Non-empty instanceVars
+// select an instanced pipeline.
+func (c *Client) ListResources(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]Resource, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources%s", team, pipeline, query)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var resources []Resource
+	if err := json.NewDecoder(resp.Body).Decode(&resources); err != nil {
+		return nil, fmt.Errorf("decode resources: %w", err)
+	}
+
+	return resources, nil
+}
+
+// ListResourceTypes lists the resource types defined by a pipeline.
+// Non-empty instanceVars select an instanced pipeline.
+func (c *Client) ListResourceTypes(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}) ([]ResourceType, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resource-types%s", team, pipeline, query)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var types []ResourceType
+	if err := json.NewDecoder(resp.Body).Decode(&types); err != nil {
+		return nil, fmt.Errorf("decode resource types: %w", err)
+	}
+
+	return types, nil
+}
+
+// GetResource retrieves a resource of a pipeline
+func (c *Client) GetResource(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string) (*Resource, error) {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s%s", team, pipeline, resource, query)
+
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseError(resp)
+	}
+
+	var res Resource
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode resource: %w", err)
+	}
+
+	return &res, nil
+}
+
+// UnpinResource removes the pin of a resource
+func (c *Client) UnpinResource(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource string) error {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/unpin%s", team, pipeline, resource, query)
+
+	resp, err := c.doRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return parseError(resp)
+	}
+	return nil
+}
+
+// SetPinComment sets the comment shown on a pinned resource
+func (c *Client) SetPinComment(ctx context.Context, team, pipeline string, instanceVars map[string]interface{}, resource, comment string) error {
+	query, err := pipelineQuery(instanceVars)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/resources/%s/pin_comment%s", team, pipeline, resource, query)
+
+	body, err := json.Marshal(map[string]string{"pin_comment": comment})
+	if err != nil {
+		return fmt.Errorf("marshal pin comment: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, "PUT", path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return parseError(resp)
+	}
+	return nil
+}
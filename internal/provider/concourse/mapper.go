@@ -5,23 +5,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lei/simple-ci/internal/models"
 	"github.com/lei/simple-ci/internal/provider"
 )
 
-// mapBuildToRun converts a Concourse build to a generic Run
-func mapBuildToRun(build *Build, runRef *ConcourseRunRef) *models.Run {
+// mapBuildToRun converts a Concourse build to a generic Run. webURL is the
+// build's page in the Concourse UI.
+func mapBuildToRun(build *Build, runRef *ConcourseRunRef, webURL string) *models.Run {
 	run := &models.Run{
-		RunID:     runRef.ID(),
-		Status:    mapStatus(build.Status),
-		CreatedAt: time.Unix(build.CreateTime, 0),
+		RunID:       runRef.ID(),
+		Status:      mapStatus(build.Status),
+		Attempt:     buildAttempt(build.Name),
+		TriggeredBy: build.CreatedBy,
+		URL:         webURL,
+		CreatedAt:   time.Unix(build.CreateTime, 0),
 	}
 
 	if build.StartTime > 0 {
 		startedAt := time.Unix(build.StartTime, 0)
 		run.StartedAt = &startedAt
+		end := time.Now()
+		if build.EndTime > 0 {
+			end = time.Unix(build.EndTime, 0)
+		}
+		run.DurationSeconds = int64(end.Sub(startedAt).Seconds())
 	}
 
 	if build.EndTime > 0 {
@@ -32,6 +43,20 @@ func mapBuildToRun(build *Build, runRef *ConcourseRunRef) *models.Run {
 	return run
 }
 
+// buildAttempt derives the attempt number from a build name: reruns of
+// build 5 are named 5.1, 5.2, ... and count as attempts 2, 3, ...
+func buildAttempt(name string) int {
+	_, rerun, ok := strings.Cut(name, ".")
+	if !ok {
+		return 1
+	}
+	n, err := strconv.Atoi(rerun)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n + 1
+}
+
 // mapStatus converts Concourse build status to generic RunStatus
 func mapStatus(concourseStatus string) models.RunStatus {
 	switch concourseStatus {
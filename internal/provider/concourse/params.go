@@ -0,0 +1,224 @@
+package concourse
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// paramRef matches "${params.<name>}" in instance vars and pinned versions
+var paramRef = regexp.MustCompile(`\$\{params\.([A-Za-z0-9_-]+)\}`)
+
+// versionCheckAttempts bounds how long Trigger waits for a checked version to appear
+const versionCheckAttempts = 5
+
+// resolveParam substitutes trigger parameters into a templated value.
+// A value that is exactly one reference keeps the parameter's type.
+func resolveParam(value interface{}, params map[string]interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+
+	if m := paramRef.FindStringSubmatch(s); m != nil && m[0] == s {
+		v, ok := params[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("parameter %q is not set", m[1])
+		}
+		return v, nil
+	}
+
+	var missing string
+	out := paramRef.ReplaceAllStringFunc(s, func(match string) string {
+		name := paramRef.FindStringSubmatch(match)[1]
+		v, ok := params[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return fmt.Sprint(v)
+	})
+	if missing != "" {
+		return nil, fmt.Errorf("parameter %q is not set", missing)
+	}
+	return out, nil
+}
+
+// resolveInstanceVars resolves templated instance vars against trigger parameters
+func resolveInstanceVars(vars map[string]interface{}, params map[string]interface{}) (map[string]interface{}, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]interface{}, len(vars))
+	for name, value := range vars {
+		resolved, err := resolveParam(value, params)
+		if err != nil {
+			return nil, fmt.Errorf("instance var %s: %w", name, err)
+		}
+		out[name] = resolved
+	}
+	return out, nil
+}
+
+// resolvePins resolves templated resource versions against trigger parameters.
+// Concourse versions are string maps, so every field is rendered as a string.
+func resolvePins(pins map[string]map[string]interface{}, params map[string]interface{}) (map[string]map[string]string, error) {
+	if len(pins) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]map[string]string, len(pins))
+	for resource, fields := range pins {
+		version := make(map[string]string, len(fields))
+		for field, value := range fields {
+			resolved, err := resolveParam(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("pin %s.%s: %w", resource, field, err)
+			}
+			version[field] = fmt.Sprint(resolved)
+		}
+		out[resource] = version
+	}
+	return out, nil
+}
+
+// pinVersion pins a resource to a version, asking Concourse to check for it
+// first if it hasn't seen the version yet
+func (a *Adapter) pinVersion(ctx context.Context, ref *ConcourseJobRef, instanceVars map[string]interface{}, resource string, version map[string]string) error {
+	logger := a.getLogger(ctx)
+
+	found, err := a.client.FindResourceVersion(ctx, ref.Team, ref.Pipeline, instanceVars, resource, version)
+	if err != nil {
+		return fmt.Errorf("find version of %s: %w", resource, err)
+	}
+
+	if found == nil {
+		logger.Info("provider: version unknown, checking resource",
+			"pipeline", ref.Pipeline,
+			"resource", resource,
+			"version", version)
+		if _, err := a.client.CheckResource(ctx, ref.Team, ref.Pipeline, instanceVars, resource, version); err != nil {
+			return fmt.Errorf("check %s: %w", resource, err)
+		}
+
+		for attempt := 0; found == nil && attempt < versionCheckAttempts; attempt++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			if found, err = a.client.FindResourceVersion(ctx, ref.Team, ref.Pipeline, instanceVars, resource, version); err != nil {
+				return fmt.Errorf("find version of %s: %w", resource, err)
+			}
+		}
+		if found == nil {
+			return fmt.Errorf("%w: %v of resource %s", provider.ErrVersionNotFound, version, resource)
+		}
+	}
+
+	if err := a.client.PinResourceVersion(ctx, ref.Team, ref.Pipeline, instanceVars, resource, found.ID); err != nil {
+		return fmt.Errorf("pin %s: %w", resource, err)
+	}
+
+	logger.Info("provider: resource version pinned",
+		"pipeline", ref.Pipeline,
+		"resource", resource,
+		"version_id", found.ID)
+	return nil
+}
+
+// MapVersions implements provider.VersionMapper. The gateway pins the versions
+// of the job ref's pin mapping through PinVersions and restores the previous
+// pins once the build has started.
+func (a *Adapter) MapVersions(jobRef provider.JobRef, params map[string]interface{}) (map[string]map[string]string, error) {
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	return resolvePins(ref.Pins, params)
+}
+
+// PinVersions implements provider.VersionPinner
+func (a *Adapter) PinVersions(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams) ([]provider.ResourcePin, error) {
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	instanceVars, err := resolveInstanceVars(ref.InstanceVars, params.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("map parameters: %w", err)
+	}
+
+	resources := make([]string, 0, len(params.Versions))
+	for resource := range params.Versions {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	var previous []provider.ResourcePin
+	for _, resource := range resources {
+		current, err := a.client.GetResource(ctx, ref.Team, ref.Pipeline, instanceVars, resource)
+		if err != nil {
+			a.restore(ctx, ref, instanceVars, previous)
+			return nil, fmt.Errorf("get resource %s: %w", resource, err)
+		}
+		if current.PinnedInConfig {
+			a.restore(ctx, ref, instanceVars, previous)
+			return nil, fmt.Errorf("resource %s is pinned in the pipeline config", resource)
+		}
+
+		if err := a.pinVersion(ctx, ref, instanceVars, resource, params.Versions[resource]); err != nil {
+			a.restore(ctx, ref, instanceVars, previous)
+			return nil, err
+		}
+		previous = append(previous, provider.ResourcePin{Resource: resource, Version: current.PinnedVersion})
+	}
+	return previous, nil
+}
+
+// RestorePins implements provider.VersionPinner
+func (a *Adapter) RestorePins(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams, previous []provider.ResourcePin) error {
+	ref, ok := jobRef.(*ConcourseJobRef)
+	if !ok {
+		return fmt.Errorf("invalid job ref type: expected ConcourseJobRef")
+	}
+	instanceVars, err := resolveInstanceVars(ref.InstanceVars, params.Parameters)
+	if err != nil {
+		return fmt.Errorf("map parameters: %w", err)
+	}
+	return a.restore(ctx, ref, instanceVars, previous)
+}
+
+// restore unpins resources or pins them back to their previous version
+func (a *Adapter) restore(ctx context.Context, ref *ConcourseJobRef, instanceVars map[string]interface{}, previous []provider.ResourcePin) error {
+	logger := a.getLogger(ctx)
+
+	var firstErr error
+	for _, pin := range previous {
+		var err error
+		if pin.Version == nil {
+			err = a.client.UnpinResource(ctx, ref.Team, ref.Pipeline, instanceVars, pin.Resource)
+		} else {
+			err = a.pinVersion(ctx, ref, instanceVars, pin.Resource, pin.Version)
+		}
+		if err != nil {
+			logger.Error("provider: failed to restore resource pin",
+				"pipeline", ref.Pipeline,
+				"resource", pin.Resource,
+				"error", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("restore pin of %s: %w", pin.Resource, err)
+			}
+			continue
+		}
+		logger.Info("provider: resource pin restored",
+			"pipeline", ref.Pipeline,
+			"resource", pin.Resource,
+			"pinned", pin.Version != nil)
+	}
+	return firstErr
+}
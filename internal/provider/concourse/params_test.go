@@ -0,0 +1,109 @@
+package concourse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveParam(t *testing.T) {
+	params := map[string]interface{}{"env": "prod", "replicas": int64(3), "sha": "abc123"}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"non-string kept", 42, 42, false},
+		{"plain string", "main", "main", false},
+		{"whole reference keeps type", "${params.replicas}", int64(3), false},
+		{"embedded reference", "deploy-${params.env}", "deploy-prod", false},
+		{"several references", "${params.env}/${params.sha}", "prod/abc123", false},
+		{"embedded non-string", "n=${params.replicas}", "n=3", false},
+		{"missing whole reference", "${params.region}", nil, true},
+		{"missing embedded reference", "x-${params.region}", nil, true},
+		{"not a parameter reference", "${vars.env}", "${vars.env}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveParam(tt.value, params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveParam(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("resolveParam(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveInstanceVars(t *testing.T) {
+	params := map[string]interface{}{"env": "staging", "shard": int64(2)}
+
+	tests := []struct {
+		name    string
+		vars    map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"templated", map[string]interface{}{"env": "${params.env}", "shard": "${params.shard}", "team": "core"},
+			map[string]interface{}{"env": "staging", "shard": int64(2), "team": "core"}, false},
+		{"missing parameter", map[string]interface{}{"region": "${params.region}"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveInstanceVars(tt.vars, params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveInstanceVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveInstanceVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePins(t *testing.T) {
+	params := map[string]interface{}{"sha": "abc123", "build": int64(7)}
+
+	tests := []struct {
+		name    string
+		pins    map[string]map[string]interface{}
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"fields rendered as strings",
+			map[string]map[string]interface{}{"source-code": {"ref": "${params.sha}", "build": "${params.build}", "branch": "main"}},
+			map[string]map[string]string{"source-code": {"ref": "abc123", "build": "7", "branch": "main"}}, false},
+		{"missing parameter", map[string]map[string]interface{}{"source-code": {"ref": "${params.tag}"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePins(tt.pins, params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolvePins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapVersions(t *testing.T) {
+	a := &Adapter{}
+	ref := &ConcourseJobRef{
+		Team: "main", Pipeline: "deploy", Job: "deploy",
+		Pins: map[string]map[string]interface{}{"source-code": {"ref": "${params.sha}"}},
+	}
+
+	got, err := a.MapVersions(ref, map[string]interface{}{"sha": "abc123"})
+	if err != nil {
+		t.Fatalf("MapVersions() error = %v", err)
+	}
+	if got["source-code"]["ref"] != "abc123" {
+		t.Errorf("MapVersions() = %v, want source-code ref abc123", got)
+	}
+}
@@ -0,0 +1,138 @@
+package concourse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// resourceError maps the 404 Concourse returns for unknown pipelines and
+// resources to provider.ErrResourceNotFound
+func resourceError(err error, pipeline, resource string) error {
+	if !errors.Is(err, provider.ErrRunNotFound) {
+		return err
+	}
+	if resource == "" {
+		return fmt.Errorf("%w: pipeline %s", provider.ErrResourceNotFound, pipeline)
+	}
+	return fmt.Errorf("%w: %s/%s", provider.ErrResourceNotFound, pipeline, resource)
+}
+
+// ListResources lists the resources of a pipeline in the configured team
+func (a *Adapter) ListResources(ctx context.Context, pipeline string) ([]Resource, error) {
+	logger := a.getLogger(ctx)
+
+	logger.Debug("provider: listing resources", "team", a.config.Team, "pipeline", pipeline)
+
+	resources, err := a.client.ListResources(ctx, a.config.Team, pipeline, nil)
+	err = resourceError(err, pipeline, "")
+	if err != nil {
+		logger.Error("provider: failed to list resources", "pipeline", pipeline, "error", err)
+		return nil, fmt.Errorf("list resources: %w", err)
+	}
+
+	logger.Info("provider: resources listed", "pipeline", pipeline, "count", len(resources))
+	return resources, nil
+}
+
+// ListResourceTypes lists the resource types of a pipeline in the configured team
+func (a *Adapter) ListResourceTypes(ctx context.Context, pipeline string) ([]ResourceType, error) {
+	logger := a.getLogger(ctx)
+
+	logger.Debug("provider: listing resource types", "team", a.config.Team, "pipeline", pipeline)
+
+	types, err := a.client.ListResourceTypes(ctx, a.config.Team, pipeline, nil)
+	err = resourceError(err, pipeline, "")
+	if err != nil {
+		logger.Error("provider: failed to list resource types", "pipeline", pipeline, "error", err)
+		return nil, fmt.Errorf("list resource types: %w", err)
+	}
+
+	logger.Info("provider: resource types listed", "pipeline", pipeline, "count", len(types))
+	return types, nil
+}
+
+// ListResourceVersions lists a page of versions of a resource, newest first
+func (a *Adapter) ListResourceVersions(ctx context.Context, pipeline, resource string, page Page) ([]ResourceVersion, *Pagination, error) {
+	logger := a.getLogger(ctx)
+
+	logger.Debug("provider: listing resource versions",
+		"pipeline", pipeline,
+		"resource", resource,
+		"limit", page.Limit,
+		"from", page.From,
+		"to", page.To)
+
+	versions, pagination, err := a.client.ListResourceVersions(ctx, a.config.Team, pipeline, nil, resource, page)
+	err = resourceError(err, pipeline, resource)
+	if err != nil {
+		logger.Error("provider: failed to list resource versions", "pipeline", pipeline, "resource", resource, "error", err)
+		return nil, nil, fmt.Errorf("list resource versions: %w", err)
+	}
+
+	logger.Info("provider: resource versions listed", "pipeline", pipeline, "resource", resource, "count", len(versions))
+	return versions, pagination, nil
+}
+
+// CheckResource asks Concourse to check a resource for new versions, starting
+// from the given version if set, and returns the check build
+func (a *Adapter) CheckResource(ctx context.Context, pipeline, resource string, from map[string]string) (*Build, error) {
+	logger := a.getLogger(ctx)
+
+	build, err := a.client.CheckResource(ctx, a.config.Team, pipeline, nil, resource, from)
+	err = resourceError(err, pipeline, resource)
+	if err != nil {
+		logger.Error("provider: failed to check resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return nil, fmt.Errorf("check resource: %w", err)
+	}
+
+	logger.Info("provider: resource check started",
+		"pipeline", pipeline,
+		"resource", resource,
+		"build_id", build.ID)
+	return build, nil
+}
+
+// PinResource pins a resource to a version, given either by its fields or by
+// its Concourse ID. Versions Concourse hasn't seen yet are checked for first.
+// A non-empty comment is shown on the pin.
+func (a *Adapter) PinResource(ctx context.Context, pipeline, resource string, version map[string]string, versionID int, comment string) error {
+	logger := a.getLogger(ctx)
+
+	var err error
+	if versionID > 0 {
+		err = a.client.PinResourceVersion(ctx, a.config.Team, pipeline, nil, resource, versionID)
+	} else {
+		err = a.pinVersion(ctx, &ConcourseJobRef{Team: a.config.Team, Pipeline: pipeline}, nil, resource, version)
+	}
+	err = resourceError(err, pipeline, resource)
+	if err != nil {
+		logger.Error("provider: failed to pin resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return fmt.Errorf("pin resource: %w", err)
+	}
+
+	if comment != "" {
+		if err := resourceError(a.client.SetPinComment(ctx, a.config.Team, pipeline, nil, resource, comment), pipeline, resource); err != nil {
+			logger.Error("provider: failed to set pin comment", "pipeline", pipeline, "resource", resource, "error", err)
+			return fmt.Errorf("set pin comment: %w", err)
+		}
+	}
+
+	logger.Info("provider: resource pinned", "pipeline", pipeline, "resource", resource)
+	return nil
+}
+
+// UnpinResource removes the pin of a resource
+func (a *Adapter) UnpinResource(ctx context.Context, pipeline, resource string) error {
+	logger := a.getLogger(ctx)
+
+	if err := resourceError(a.client.UnpinResource(ctx, a.config.Team, pipeline, nil, resource), pipeline, resource); err != nil {
+		logger.Error("provider: failed to unpin resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return fmt.Errorf("unpin resource: %w", err)
+	}
+
+	logger.Info("provider: resource unpinned", "pipeline", pipeline, "resource", resource)
+	return nil
+}
@@ -0,0 +1,223 @@
+package concourse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+// fakeConcourse serves the resource endpoints of the Concourse API for one
+// pipeline "p" of team "main" and records the requests it received
+type fakeConcourse struct {
+	mu        sync.Mutex
+	versions  []ResourceVersion // Versions of resource "repo", newest first
+	checked   []map[string]string
+	pinned    int
+	comment   string
+	requests  []string
+	failCheck bool
+}
+
+func (f *fakeConcourse) handler() http.Handler {
+	const prefix = "/api/v1/teams/main/pipelines/p"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/resources", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Resource{{Name: "repo", Type: "git"}})
+	})
+	mux.HandleFunc("GET "+prefix+"/resources/repo/versions", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.URL.Query().Get("limit") == "1" {
+			w.Header().Set("Link", `<`+prefix+`/resources/repo/versions?to=1&limit=1>; rel="next", <`+prefix+`/resources/repo/versions?from=3&limit=1>; rel="previous"`)
+			json.NewEncoder(w).Encode(f.versions[:1])
+			return
+		}
+		json.NewEncoder(w).Encode(f.versions)
+	})
+	mux.HandleFunc("POST "+prefix+"/resources/repo/check", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.failCheck {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"checking failed"}`))
+			return
+		}
+		var body struct {
+			From map[string]string `json:"from"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.checked = append(f.checked, body.From)
+		if body.From != nil {
+			// The check finds the requested version
+			f.versions = append([]ResourceVersion{{ID: 9, Version: body.From}}, f.versions...)
+		}
+		json.NewEncoder(w).Encode(Build{ID: 77, Name: "check", Status: "started"})
+	})
+	mux.HandleFunc("PUT "+prefix+"/resources/repo/versions/{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, v := range f.versions {
+			if r.PathValue("id") == strconv.Itoa(v.ID) {
+				f.pinned = v.ID
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("PUT "+prefix+"/resources/repo/unpin", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.pinned = 0
+	})
+	mux.HandleFunc("PUT "+prefix+"/resources/repo/pin_comment", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var body struct {
+			PinComment string `json:"pin_comment"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.comment = body.PinComment
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+		f.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// newFakeConcourseAdapter starts a fake Concourse and returns an adapter for it
+func newFakeConcourseAdapter(t *testing.T, f *fakeConcourse) *Adapter {
+	t.Helper()
+	return newTestAdapter(t, f.handler())
+}
+
+// newTestAdapter returns an adapter for team "main" of a Concourse served by handler
+func newTestAdapter(t *testing.T, handler http.Handler) *Adapter {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	adapter, err := NewAdapter(&Config{URL: server.URL, Team: "main", BearerToken: "token"}, logger.New("error", "text"))
+	if err != nil {
+		t.Fatalf("NewAdapter() error = %v", err)
+	}
+	return adapter
+}
+
+func TestAdapter_ListResourceVersions(t *testing.T) {
+	f := &fakeConcourse{versions: []ResourceVersion{
+		{ID: 2, Version: map[string]string{"ref": "b"}, Metadata: []MetadataField{{Name: "author", Value: "alice"}}},
+		{ID: 1, Version: map[string]string{"ref": "a"}},
+	}}
+	a := newFakeConcourseAdapter(t, f)
+	ctx := context.Background()
+
+	versions, pagination, err := a.ListResourceVersions(ctx, "p", "repo", Page{Limit: 1, To: 2})
+	if err != nil {
+		t.Fatalf("ListResourceVersions() error = %v", err)
+	}
+	if len(versions) != 1 || versions[0].ID != 2 || versions[0].Metadata[0].Value != "alice" {
+		t.Errorf("versions = %+v, want version 2 with metadata", versions)
+	}
+	if got := f.requests[0]; got != "GET /api/v1/teams/main/pipelines/p/resources/repo/versions?limit=1&to=2" {
+		t.Errorf("request = %s", got)
+	}
+	if pagination.Next == nil || *pagination.Next != (Page{Limit: 1, To: 1}) ||
+		pagination.Previous == nil || *pagination.Previous != (Page{Limit: 1, From: 3}) {
+		t.Errorf("pagination = %+v, want next to=1 and previous from=3", pagination)
+	}
+
+	// Unpaged lists have no pages around them
+	if _, pagination, err = a.ListResourceVersions(ctx, "p", "repo", Page{}); err != nil || pagination.Next != nil || pagination.Previous != nil {
+		t.Errorf("unpaged pagination = %+v (error %v), want none", pagination, err)
+	}
+
+	_, _, err = a.ListResourceVersions(ctx, "p", "missing", Page{})
+	if !errors.Is(err, provider.ErrResourceNotFound) || !strings.Contains(err.Error(), "p/missing") {
+		t.Errorf("unknown resource error = %v, want ErrResourceNotFound naming it", err)
+	}
+}
+
+func TestAdapter_ListResources(t *testing.T) {
+	a := newFakeConcourseAdapter(t, &fakeConcourse{})
+	ctx := context.Background()
+
+	resources, err := a.ListResources(ctx, "p")
+	if err != nil || len(resources) != 1 || resources[0].Type != "git" {
+		t.Errorf("ListResources() = %+v, %v; want repo", resources, err)
+	}
+	if _, err := a.ListResources(ctx, "missing"); !errors.Is(err, provider.ErrResourceNotFound) {
+		t.Errorf("unknown pipeline error = %v, want ErrResourceNotFound", err)
+	}
+	if _, err := a.ListResourceTypes(ctx, "missing"); !errors.Is(err, provider.ErrResourceNotFound) {
+		t.Errorf("unknown pipeline types error = %v, want ErrResourceNotFound", err)
+	}
+}
+
+func TestAdapter_CheckResource(t *testing.T) {
+	f := &fakeConcourse{}
+	a := newFakeConcourseAdapter(t, f)
+	ctx := context.Background()
+
+	build, err := a.CheckResource(ctx, "p", "repo", map[string]string{"ref": "abc"})
+	if err != nil {
+		t.Fatalf("CheckResource() error = %v", err)
+	}
+	if build.ID != 77 || len(f.checked) != 1 || f.checked[0]["ref"] != "abc" {
+		t.Errorf("build = %+v, checked = %v; want check from ref abc", build, f.checked)
+	}
+
+	if _, err := a.CheckResource(ctx, "p", "missing", nil); !errors.Is(err, provider.ErrResourceNotFound) {
+		t.Errorf("unknown resource error = %v, want ErrResourceNotFound", err)
+	}
+
+	f.failCheck = true
+	_, err = a.CheckResource(ctx, "p", "repo", nil)
+	var providerErr *provider.ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Code != http.StatusInternalServerError || providerErr.Message != "checking failed" {
+		t.Errorf("failed check error = %v, want provider error with Concourse's message", err)
+	}
+}
+
+func TestAdapter_PinResource(t *testing.T) {
+	f := &fakeConcourse{versions: []ResourceVersion{{ID: 1, Version: map[string]string{"ref": "a"}}}}
+	a := newFakeConcourseAdapter(t, f)
+	ctx := context.Background()
+
+	// By ID, with a comment
+	if err := a.PinResource(ctx, "p", "repo", nil, 1, "hold for release"); err != nil {
+		t.Fatalf("PinResource() by ID error = %v", err)
+	}
+	if f.pinned != 1 || f.comment != "hold for release" {
+		t.Errorf("pinned = %d, comment = %q", f.pinned, f.comment)
+	}
+	if err := a.PinResource(ctx, "p", "repo", nil, 5, ""); !errors.Is(err, provider.ErrResourceNotFound) {
+		t.Errorf("unknown version ID error = %v, want ErrResourceNotFound", err)
+	}
+
+	// By fields; unknown versions are checked for first
+	if err := a.PinResource(ctx, "p", "repo", map[string]string{"ref": "new"}, 0, ""); err != nil {
+		t.Fatalf("PinResource() by version error = %v", err)
+	}
+	if f.pinned != 9 || len(f.checked) != 1 {
+		t.Errorf("pinned = %d after %d checks, want checked version 9", f.pinned, len(f.checked))
+	}
+
+	if err := a.UnpinResource(ctx, "p", "repo"); err != nil || f.pinned != 0 {
+		t.Errorf("UnpinResource() error = %v, pinned = %d", err, f.pinned)
+	}
+	if err := a.UnpinResource(ctx, "p", "missing"); !errors.Is(err, provider.ErrResourceNotFound) {
+		t.Errorf("unknown resource unpin error = %v, want ErrResourceNotFound", err)
+	}
+}
@@ -0,0 +1,282 @@
+package concourse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// runningEventsWait bounds how long the event stream of a running build is
+// read when building its step tree
+const runningEventsWait = 2 * time.Second
+
+// hookTypes maps the hook keys of a Concourse plan to step types
+var hookTypes = map[string]models.StepType{
+	"on_success": models.StepOnSuccess,
+	"on_failure": models.StepOnFailure,
+	"on_abort":   models.StepOnAbort,
+	"on_error":   models.StepOnError,
+	"ensure":     models.StepEnsure,
+}
+
+// Steps implements provider.StepReader. It parses the build plan into a step
+// tree and fills in status and timing from the build events.
+func (a *Adapter) Steps(ctx context.Context, runRef provider.RunRef) (*models.Step, error) {
+	logger := a.getLogger(ctx)
+
+	ref, ok := runRef.(*ConcourseRunRef)
+	if !ok {
+		logger.Error("provider: invalid run ref type for steps", "expected", "ConcourseRunRef")
+		return nil, fmt.Errorf("invalid run ref type: expected ConcourseRunRef")
+	}
+
+	build, err := a.client.GetBuild(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build", "build_id", ref.BuildID, "error", err)
+		return nil, err
+	}
+
+	plan, err := a.client.GetBuildPlan(ctx, ref.BuildID)
+	if err != nil {
+		logger.Error("provider: failed to get build plan", "build_id", ref.BuildID, "error", err)
+		return nil, fmt.Errorf("get build plan: %w", err)
+	}
+
+	running := !mapStatus(build.Status).IsTerminal()
+	events, err := a.buildEvents(ctx, build)
+	if err != nil {
+		return nil, err
+	}
+
+	root, _ := plan["plan"].(map[string]interface{})
+	step := parsePlan(root)
+	applyStepEvents(step, events)
+	summarizeStep(step, running)
+
+	logger.Debug("provider: build steps retrieved",
+		"build_id", ref.BuildID,
+		"events", len(events),
+		"status", step.Status)
+	return step, nil
+}
+
+// buildEvents reads the events of a build, as far as they go for running builds
+func (a *Adapter) buildEvents(ctx context.Context, build *Build) ([]BuildEvent, error) {
+	var wait time.Duration
+	if !mapStatus(build.Status).IsTerminal() {
+		wait = runningEventsWait
+	}
+	events, err := a.client.ReadBuildEvents(ctx, build.ID, wait)
+	if err != nil {
+		a.getLogger(ctx).Error("provider: failed to read build events", "build_id", build.ID, "error", err)
+		return nil, fmt.Errorf("read build events: %w", err)
+	}
+	return events, nil
+}
+
+// parsePlan converts a node of a Concourse build plan into a step tree
+func parsePlan(plan map[string]interface{}) *models.Step {
+	step := &models.Step{Type: models.StepUnknown, Status: models.StepPending}
+	if plan == nil {
+		return step
+	}
+	step.ID, _ = plan["id"].(string)
+
+	for key, value := range plan {
+		switch key {
+		case "get", "put", "task", "check", "set_pipeline", "load_var":
+			step.Type = models.StepType(key)
+			fields, _ := value.(map[string]interface{})
+			step.Name, _ = fields["name"].(string)
+			step.Resource, _ = fields["resource"].(string)
+		case "do":
+			step.Type = models.StepDo
+			step.Children = parsePlans(value)
+		case "aggregate":
+			// aggregate is the predecessor of in_parallel
+			step.Type = models.StepInParallel
+			step.Children = parsePlans(value)
+		case "retry":
+			step.Type = models.StepRetry
+			step.Children = parsePlans(value)
+		case "in_parallel":
+			step.Type = models.StepInParallel
+			fields, _ := value.(map[string]interface{})
+			step.Children = parsePlans(fields["steps"])
+		case "across":
+			step.Type = models.StepAcross
+			fields, _ := value.(map[string]interface{})
+			steps, _ := fields["steps"].([]interface{})
+			for _, s := range steps {
+				scoped, _ := s.(map[string]interface{})
+				child, _ := scoped["step"].(map[string]interface{})
+				step.Children = append(step.Children, parsePlan(child))
+			}
+		case "try", "timeout":
+			step.Type = models.StepType(key)
+			fields, _ := value.(map[string]interface{})
+			child, _ := fields["step"].(map[string]interface{})
+			step.Children = []*models.Step{parsePlan(child)}
+		default:
+			hookType, ok := hookTypes[key]
+			if !ok {
+				continue
+			}
+			step.Type = hookType
+			fields, _ := value.(map[string]interface{})
+			child, _ := fields["step"].(map[string]interface{})
+			step.Children = []*models.Step{parsePlan(child)}
+			// The hook step is under the hook key in public plans and under
+			// "next" in full plans
+			hook, ok := fields[key].(map[string]interface{})
+			if !ok {
+				hook, _ = fields["next"].(map[string]interface{})
+			}
+			step.Hook = parsePlan(hook)
+		}
+	}
+
+	return step
+}
+
+// parsePlans converts a list of plan nodes
+func parsePlans(value interface{}) []*models.Step {
+	plans, _ := value.([]interface{})
+	steps := make([]*models.Step, 0, len(plans))
+	for _, p := range plans {
+		plan, _ := p.(map[string]interface{})
+		steps = append(steps, parsePlan(plan))
+	}
+	return steps
+}
+
+// applyStepEvents sets the status and timing of leaf steps from the
+// initialize-*, start-*, finish-* and error events of the build
+func applyStepEvents(root *models.Step, events []BuildEvent) {
+	steps := make(map[string]*models.Step)
+	var index func(*models.Step)
+	index = func(s *models.Step) {
+		if s.ID != "" {
+			steps[s.ID] = s
+		}
+		for _, child := range s.Children {
+			index(child)
+		}
+		if s.Hook != nil {
+			index(s.Hook)
+		}
+	}
+	index(root)
+
+	for _, event := range events {
+		step, ok := steps[event.Data.Origin.ID]
+		if !ok {
+			continue
+		}
+		at := time.Unix(event.Data.Time, 0)
+		phase, _, _ := strings.Cut(event.Event, "-")
+
+		switch phase {
+		case "initialize", "start":
+			if step.StartedAt == nil && event.Data.Time > 0 {
+				step.StartedAt = &at
+			}
+			if !step.Status.IsDone() {
+				step.Status = models.StepRunning
+			}
+		case "finish":
+			if event.Data.Time > 0 {
+				step.FinishedAt = &at
+			}
+			step.ExitStatus = event.Data.ExitStatus
+			switch {
+			case event.Data.ExitStatus != nil && *event.Data.ExitStatus != 0,
+				event.Data.Succeeded != nil && !*event.Data.Succeeded:
+				step.Status = models.StepFailed
+			default:
+				step.Status = models.StepSucceeded
+			}
+		case "error":
+			step.Status = models.StepErrored
+			step.Error = event.Data.Message
+			if event.Data.Time > 0 {
+				step.FinishedAt = &at
+			}
+		}
+	}
+}
+
+// summarizeStep derives the status and timing of composite steps from their
+// children. Children that never started count as skipped once the build has
+// finished.
+func summarizeStep(step *models.Step, running bool) {
+	if len(step.Children) == 0 && step.Hook == nil {
+		return
+	}
+
+	parts := make([]*models.Step, 0, len(step.Children)+1)
+	for _, child := range step.Children {
+		summarizeStep(child, running)
+		parts = append(parts, child)
+	}
+	if step.Hook != nil {
+		summarizeStep(step.Hook, running)
+		// A hook that didn't run doesn't hold back its step
+		if step.Hook.Status != models.StepPending {
+			parts = append(parts, step.Hook)
+		}
+	}
+
+	var failed, errored, active, pending, done bool
+	for _, part := range parts {
+		switch part.Status {
+		case models.StepErrored:
+			errored = true
+		case models.StepFailed:
+			failed = true
+		case models.StepRunning:
+			active = true
+		case models.StepPending:
+			pending = true
+		default:
+			done = true
+		}
+		if part.StartedAt != nil && (step.StartedAt == nil || part.StartedAt.Before(*step.StartedAt)) {
+			step.StartedAt = part.StartedAt
+		}
+		if part.FinishedAt != nil && (step.FinishedAt == nil || part.FinishedAt.After(*step.FinishedAt)) {
+			step.FinishedAt = part.FinishedAt
+		}
+	}
+
+	switch {
+	case step.Type == models.StepTry && !active && (failed || errored):
+		// try swallows the failure of its step
+		step.Status = models.StepSucceeded
+	case step.Type == models.StepRetry:
+		// A retry ends with its last attempt
+		step.Status = models.StepPending
+		for _, attempt := range step.Children {
+			if attempt.Status != models.StepPending {
+				step.Status = attempt.Status
+			}
+		}
+	case errored:
+		step.Status = models.StepErrored
+	case failed:
+		step.Status = models.StepFailed
+	case active || (done && pending && running):
+		step.Status = models.StepRunning
+	case done:
+		step.Status = models.StepSucceeded
+	default:
+		step.Status = models.StepPending
+	}
+	if !step.Status.IsDone() {
+		step.FinishedAt = nil
+	}
+}
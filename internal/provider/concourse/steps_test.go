@@ -0,0 +1,285 @@
+package concourse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// testPlan is a public build plan using every kind of composite step
+const testPlan = `{"id": "root", "do": [
+	{"id": "get", "get": {"name": "repo", "resource": "source-code"}},
+	{"id": "par", "in_parallel": {"steps": [
+		{"id": "unit", "task": {"name": "unit"}},
+		{"id": "try", "try": {"step": {"id": "lint", "task": {"name": "lint"}}}}
+	]}},
+	{"id": "hook", "on_failure": {
+		"step": {"id": "deploy", "put": {"name": "deploy", "resource": "prod"}},
+		"on_failure": {"id": "notify", "task": {"name": "notify"}}
+	}},
+	{"id": "retry", "retry": [
+		{"id": "flaky1", "task": {"name": "flaky"}},
+		{"id": "flaky2", "task": {"name": "flaky"}}
+	]},
+	{"id": "across", "across": {"steps": [{"step": {"id": "matrix", "task": {"name": "matrix"}}}]}},
+	{"id": "timeout", "timeout": {"step": {"id": "slow", "task": {"name": "slow"}}}}
+]}`
+
+func decodePlan(t *testing.T, plan string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &out); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	return out
+}
+
+// event builds a build event of a step at the given second
+func event(name, origin string, at int64) BuildEvent {
+	e := BuildEvent{Event: name}
+	e.Data.Origin.ID = origin
+	e.Data.Time = at
+	return e
+}
+
+// finished builds a finish event with an exit status
+func finished(name, origin string, at int64, exit int) BuildEvent {
+	e := event(name, origin, at)
+	e.Data.ExitStatus = &exit
+	return e
+}
+
+// stepByID finds a step anywhere in a tree, including hooks
+func stepByID(root *models.Step, id string) *models.Step {
+	if root == nil {
+		return nil
+	}
+	if root.ID == id {
+		return root
+	}
+	for _, child := range root.Children {
+		if s := stepByID(child, id); s != nil {
+			return s
+		}
+	}
+	return stepByID(root.Hook, id)
+}
+
+func TestParsePlan(t *testing.T) {
+	root := parsePlan(decodePlan(t, testPlan))
+
+	if root.Type != models.StepDo || len(root.Children) != 6 {
+		t.Fatalf("root = %s with %d children, want do with 6", root.Type, len(root.Children))
+	}
+
+	tests := []struct {
+		id       string
+		typ      models.StepType
+		name     string
+		children int
+	}{
+		{"get", models.StepGet, "repo", 0},
+		{"par", models.StepInParallel, "", 2},
+		{"try", models.StepTry, "", 1},
+		{"lint", models.StepTask, "lint", 0},
+		{"hook", models.StepOnFailure, "", 1},
+		{"deploy", models.StepPut, "deploy", 0},
+		{"notify", models.StepTask, "notify", 0},
+		{"retry", models.StepRetry, "", 2},
+		{"across", models.StepAcross, "", 1},
+		{"matrix", models.StepTask, "matrix", 0},
+		{"timeout", models.StepTimeout, "", 1},
+		{"slow", models.StepTask, "slow", 0},
+	}
+	for _, tt := range tests {
+		step := stepByID(root, tt.id)
+		if step == nil {
+			t.Errorf("step %s missing", tt.id)
+			continue
+		}
+		if step.Type != tt.typ || step.Name != tt.name || len(step.Children) != tt.children || step.Status != models.StepPending {
+			t.Errorf("step %s = %s %q with %d children (%s), want pending %s %q with %d",
+				tt.id, step.Type, step.Name, len(step.Children), step.Status, tt.typ, tt.name, tt.children)
+		}
+	}
+
+	if get := stepByID(root, "get"); get.Resource != "source-code" {
+		t.Errorf("get resource = %q, want source-code", get.Resource)
+	}
+	if hook := stepByID(root, "hook"); hook.Hook == nil || hook.Hook.ID != "notify" {
+		t.Errorf("on_failure hook = %+v, want notify", hook.Hook)
+	}
+}
+
+func TestParsePlan_Variants(t *testing.T) {
+	// Full plans nest hooks under "next"; older ones use aggregate
+	root := parsePlan(decodePlan(t, `{"id": "e", "ensure": {
+		"step": {"id": "agg", "aggregate": [{"id": "a", "task": {"name": "a"}}]},
+		"next": {"id": "cleanup", "task": {"name": "cleanup"}}
+	}}`))
+	if root.Type != models.StepEnsure || root.Hook == nil || root.Hook.Name != "cleanup" {
+		t.Errorf("ensure = %+v, want hook cleanup from next", root)
+	}
+	if agg := stepByID(root, "agg"); agg == nil || agg.Type != models.StepInParallel || len(agg.Children) != 1 {
+		t.Errorf("aggregate = %+v, want in_parallel with one step", agg)
+	}
+
+	if step := parsePlan(decodePlan(t, `{"id": "x", "artifact_input": {}}`)); step.Type != models.StepUnknown || step.ID != "x" {
+		t.Errorf("unknown step = %+v, want unknown type", step)
+	}
+	if step := parsePlan(nil); step.Type != models.StepUnknown || step.Status != models.StepPending {
+		t.Errorf("nil plan = %+v, want pending unknown step", step)
+	}
+}
+
+func TestApplyStepEvents(t *testing.T) {
+	root := parsePlan(decodePlan(t, testPlan))
+	succeeded := false
+	failedPut := event("finish-put", "deploy", 140)
+	failedPut.Data.Succeeded = &succeeded
+	errored := event("error", "slow", 160)
+	errored.Data.Message = "timeout exceeded"
+
+	applyStepEvents(root, []BuildEvent{
+		event("initialize-get", "get", 100),
+		event("start-get", "get", 101),
+		finished("finish-get", "get", 105, 0),
+		event("start-task", "unit", 110),
+		finished("finish-task", "unit", 120, 1),
+		event("start-task", "lint", 110),
+		failedPut,
+		event("start-task", "matrix", 150),
+		errored,
+		event("start-task", "unknown-origin", 100),
+	})
+
+	tests := []struct {
+		id      string
+		status  models.StepStatus
+		started int64
+		ended   int64
+	}{
+		{"get", models.StepSucceeded, 100, 105},
+		{"unit", models.StepFailed, 110, 120},
+		{"lint", models.StepRunning, 110, 0},
+		{"deploy", models.StepFailed, 0, 140},
+		{"matrix", models.StepRunning, 150, 0},
+		{"slow", models.StepErrored, 0, 160},
+		{"notify", models.StepPending, 0, 0},
+	}
+	for _, tt := range tests {
+		step := stepByID(root, tt.id)
+		if step.Status != tt.status {
+			t.Errorf("step %s status = %s, want %s", tt.id, step.Status, tt.status)
+		}
+		if got := unixOrZero(step.StartedAt); got != tt.started {
+			t.Errorf("step %s started = %d, want %d", tt.id, got, tt.started)
+		}
+		if got := unixOrZero(step.FinishedAt); got != tt.ended {
+			t.Errorf("step %s finished = %d, want %d", tt.id, got, tt.ended)
+		}
+	}
+	if unit := stepByID(root, "unit"); unit.ExitStatus == nil || *unit.ExitStatus != 1 {
+		t.Errorf("unit exit status = %v, want 1", unit.ExitStatus)
+	}
+	if slow := stepByID(root, "slow"); slow.Error != "timeout exceeded" {
+		t.Errorf("slow error = %q, want the event message", slow.Error)
+	}
+}
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func TestSummarizeStep(t *testing.T) {
+	root := parsePlan(decodePlan(t, testPlan))
+	applyStepEvents(root, []BuildEvent{
+		event("start-get", "get", 100),
+		finished("finish-get", "get", 105, 0),
+		event("start-task", "unit", 110),
+		finished("finish-task", "unit", 120, 0),
+		event("start-task", "lint", 110),
+		finished("finish-task", "lint", 115, 1),
+		event("start-put", "deploy", 130),
+		finished("finish-put", "deploy", 140, 0),
+		event("start-task", "flaky1", 150),
+		finished("finish-task", "flaky1", 155, 1),
+		event("start-task", "flaky2", 156),
+		finished("finish-task", "flaky2", 160, 0),
+		event("start-task", "matrix", 170),
+		finished("finish-task", "matrix", 175, 0),
+		event("start-task", "slow", 180),
+		finished("finish-task", "slow", 190, 2),
+	})
+	summarizeStep(root, false)
+
+	tests := []struct {
+		id     string
+		status models.StepStatus
+	}{
+		{"try", models.StepSucceeded},   // try swallows the lint failure
+		{"par", models.StepSucceeded},   // so the parallel steps pass
+		{"hook", models.StepSucceeded},  // the hook didn't run
+		{"retry", models.StepSucceeded}, // the last attempt passed
+		{"timeout", models.StepFailed},
+		{"root", models.StepFailed},
+	}
+	for _, tt := range tests {
+		if step := stepByID(root, tt.id); step.Status != tt.status {
+			t.Errorf("step %s status = %s, want %s", tt.id, step.Status, tt.status)
+		}
+	}
+	if unixOrZero(root.StartedAt) != 100 || unixOrZero(root.FinishedAt) != 190 {
+		t.Errorf("root timing = %v to %v, want 100 to 190", root.StartedAt, root.FinishedAt)
+	}
+	if failing := root.Failing(); failing == nil || failing.Name != "slow" {
+		t.Errorf("Failing() = %+v, want the slow task", failing)
+	}
+}
+
+func TestSummarizeStep_Running(t *testing.T) {
+	plan := `{"id": "root", "do": [
+		{"id": "a", "task": {"name": "a"}},
+		{"id": "b", "task": {"name": "b"}}
+	]}`
+	events := []BuildEvent{
+		event("start-task", "a", 100),
+		finished("finish-task", "a", 110, 0),
+	}
+
+	// Between steps of a running build the sequence is still running
+	root := parsePlan(decodePlan(t, plan))
+	applyStepEvents(root, events)
+	summarizeStep(root, true)
+	if root.Status != models.StepRunning || root.FinishedAt != nil {
+		t.Errorf("running build root = %s finished %v, want running without finish time", root.Status, root.FinishedAt)
+	}
+
+	// Once the build is over, steps that never ran don't hold it back
+	root = parsePlan(decodePlan(t, plan))
+	applyStepEvents(root, events)
+	summarizeStep(root, false)
+	if root.Status != models.StepSucceeded || unixOrZero(root.FinishedAt) != 110 {
+		t.Errorf("finished build root = %s finished %v, want succeeded at 110", root.Status, root.FinishedAt)
+	}
+
+	// A failed attempt of a retry that is still retrying doesn't fail it yet
+	root = parsePlan(decodePlan(t, `{"id": "retry", "retry": [
+		{"id": "r1", "task": {"name": "flaky"}},
+		{"id": "r2", "task": {"name": "flaky"}}
+	]}`))
+	applyStepEvents(root, []BuildEvent{
+		event("start-task", "r1", 100),
+		finished("finish-task", "r1", 110, 1),
+		event("start-task", "r2", 111),
+	})
+	summarizeStep(root, true)
+	if root.Status != models.StepRunning {
+		t.Errorf("retry status = %s, want running with its second attempt", root.Status)
+	}
+}
@@ -17,6 +17,16 @@ var (
 
 	// ErrProviderUnavailable indicates the provider is temporarily unavailable
 	ErrProviderUnavailable = errors.New("provider temporarily unavailable")
+
+	// ErrResourceNotFound indicates the pipeline or resource doesn't exist in the provider
+	ErrResourceNotFound = errors.New("resource not found in provider")
+
+	// ErrVersionNotFound indicates a requested resource version doesn't exist
+	ErrVersionNotFound = errors.New("resource version not found")
+
+	// ErrJobRefUnresolved indicates a job reference depends on trigger
+	// parameters and can't be resolved outside a trigger
+	ErrJobRefUnresolved = errors.New("job reference depends on trigger parameters")
 )
 
 // ProviderError represents a provider-specific error
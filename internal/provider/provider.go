@@ -37,6 +37,84 @@ type RunRef interface {
 
 // TriggerParams contains parameters for triggering a run
 type TriggerParams struct {
-	Parameters     map[string]interface{} // User-provided params
-	IdempotencyKey string                 // Optional
+	Parameters     map[string]interface{}       // User-provided params
+	IdempotencyKey string                       // Optional
+	Versions       map[string]map[string]string // Optional input versions by resource name (see VersionPinner)
+}
+
+// VersionPinner is implemented by providers that can run a job at specific
+// input resource versions by pinning them around the trigger
+type VersionPinner interface {
+	// PinVersions pins params.Versions and returns the pins they replaced
+	PinVersions(ctx context.Context, jobRef JobRef, params TriggerParams) ([]ResourcePin, error)
+
+	// RestorePins puts back pins returned by PinVersions
+	RestorePins(ctx context.Context, jobRef JobRef, params TriggerParams, previous []ResourcePin) error
+}
+
+// VersionMapper is implemented by providers whose job references can derive
+// input versions from trigger parameters. The gateway pins mapped versions
+// through VersionPinner and restores them like requested versions.
+type VersionMapper interface {
+	// MapVersions returns the input versions the job reference maps params to
+	MapVersions(jobRef JobRef, params map[string]interface{}) (map[string]map[string]string, error)
+}
+
+// JobValidator is implemented by providers that can check a job reference
+// before it is registered through the API
+type JobValidator interface {
+	// ValidateJob returns an error wrapping ErrJobNotFound if the referenced
+	// job doesn't exist
+	ValidateJob(ctx context.Context, jobRef JobRef) error
+}
+
+// LatestRunsReader is implemented by providers that report a job's most
+// recent activity in a single call
+type LatestRunsReader interface {
+	// LatestRuns returns the job's most recent finished run and the run it is
+	// running or will run next; either may be nil
+	LatestRuns(ctx context.Context, jobRef JobRef) (finished, next *models.Run, err error)
+}
+
+// Rerunner is implemented by providers that can run a job again with the
+// exact inputs of an earlier run
+type Rerunner interface {
+	// Rerun starts a new run of runRef's job reusing its input versions
+	Rerun(ctx context.Context, runRef RunRef) (RunRef, error)
+}
+
+// Pauser is implemented by providers that can pause jobs, or the pipelines
+// they belong to, so that no new runs start until they are unpaused
+type Pauser interface {
+	// SetPaused pauses or unpauses the job or its pipeline
+	SetPaused(ctx context.Context, jobRef JobRef, target models.PauseTarget, paused bool) error
+
+	// PauseState reports whether the job and its pipeline are paused
+	PauseState(ctx context.Context, jobRef JobRef) (*models.JobPause, error)
+}
+
+// StepReader is implemented by providers that can report the steps of a run
+type StepReader interface {
+	// Steps returns the run's step tree with the status and timing of each step
+	Steps(ctx context.Context, runRef RunRef) (*models.Step, error)
+}
+
+// InputVersionReader is implemented by providers that report the input
+// versions a run resolved
+type InputVersionReader interface {
+	// InputVersions returns the version of each input of the run by name
+	InputVersions(ctx context.Context, runRef RunRef) (map[string]map[string]string, error)
+}
+
+// RunResourceReader is implemented by providers that report the resource
+// versions a run consumed and produced
+type RunResourceReader interface {
+	// RunResources returns the run's inputs and outputs
+	RunResources(ctx context.Context, runRef RunRef) (*models.RunResources, error)
+}
+
+// ResourcePin is the pin state of a resource. A nil Version means not pinned.
+type ResourcePin struct {
+	Resource string            `json:"resource"`
+	Version  map[string]string `json:"version,omitempty"`
 }
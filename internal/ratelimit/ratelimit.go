@@ -0,0 +1,365 @@
+// Package ratelimit implements token-bucket rate limiting for API callers and jobs.
+package ratelimit
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class separates request budgets so reads cannot starve triggers (and vice versa)
+type Class string
+
+const (
+	ClassTrigger Class = "trigger" // Mutating requests: trigger, cancel
+	ClassRead    Class = "read"    // Read-only requests
+)
+
+// Scope identifies what a bucket is keyed on
+type Scope string
+
+const (
+	ScopeKey Scope = "key" // Per API key name
+	ScopeJob Scope = "job" // Per job_id
+)
+
+// Rate is a token bucket budget: Limit requests refilled evenly over Per.
+// The burst size equals Limit. A zero Rate means unlimited.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// Unlimited reports whether the rate imposes no limit
+func (r Rate) Unlimited() bool {
+	return r.Limit <= 0 || r.Per <= 0
+}
+
+// String formats the rate in the same form accepted by ParseRate
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "unlimited"
+	}
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.Limit)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.Limit)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.Limit)
+	default:
+		return fmt.Sprintf("%d/%s", r.Limit, r.Per)
+	}
+}
+
+// ParseRate parses rates like "10/s", "60/m", "500/h" or "20/30s".
+// Empty string, "0" and "unlimited" yield an unlimited rate.
+func ParseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" || value == "unlimited" {
+		return Rate{}, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Rate{}, fmt.Errorf("invalid rate %q (expected <count>/<s|m|h|duration>)", value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 0 {
+		return Rate{}, fmt.Errorf("invalid rate count in %q", value)
+	}
+
+	var per time.Duration
+	switch unit := strings.TrimSpace(parts[1]); unit {
+	case "s", "sec", "second":
+		per = time.Second
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		per, err = time.ParseDuration(unit)
+		if err != nil || per <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate period in %q", value)
+		}
+	}
+
+	return Rate{Limit: limit, Per: per}, nil
+}
+
+// Limits holds per-class overrides. A nil field inherits the default budget.
+type Limits struct {
+	Trigger *Rate
+	Read    *Rate
+}
+
+// Config contains default budgets and per-subject overrides
+type Config struct {
+	KeyTrigger Rate
+	KeyRead    Rate
+	JobTrigger Rate
+	JobRead    Rate
+
+	KeyOverrides map[string]Limits // API key name -> limits
+	JobOverrides map[string]Limits // job_id -> limits
+
+	// Jobs holds the configured job IDs. Job targets for any other ID get
+	// no bucket, so unknown IDs in request URLs can't grow the limiter.
+	Jobs map[string]bool
+}
+
+// Enabled reports whether any budget is configured
+func (c Config) Enabled() bool {
+	if !c.KeyTrigger.Unlimited() || !c.KeyRead.Unlimited() || !c.JobTrigger.Unlimited() || !c.JobRead.Unlimited() {
+		return true
+	}
+	for _, overrides := range []map[string]Limits{c.KeyOverrides, c.JobOverrides} {
+		for _, l := range overrides {
+			if (l.Trigger != nil && !l.Trigger.Unlimited()) || (l.Read != nil && !l.Read.Unlimited()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Target identifies a single bucket to charge
+type Target struct {
+	Scope   Scope
+	Subject string
+	Class   Class
+}
+
+// Limiter tracks token buckets for API keys and jobs
+type Limiter struct {
+	mu      sync.Mutex
+	cfg     Config
+	buckets map[Target]*bucket
+	now     func() time.Time
+}
+
+// bucket is a single token bucket with its counters
+type bucket struct {
+	rate    Rate
+	tokens  float64
+	last    time.Time
+	allowed uint64
+	limited uint64
+}
+
+// New creates a limiter from the given configuration
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[Target]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetJobs replaces the configured jobs and their overrides (e.g. after
+// reloading jobs). Buckets of removed jobs are dropped.
+func (l *Limiter) SetJobs(jobs map[string]bool, overrides map[string]Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg.Jobs = jobs
+	l.cfg.JobOverrides = overrides
+	for target, b := range l.buckets {
+		if target.Scope != ScopeJob {
+			continue
+		}
+		rate := l.rateFor(target)
+		if rate.Unlimited() || !jobs[target.Subject] {
+			delete(l.buckets, target)
+			continue
+		}
+		b.rate = rate
+		b.tokens = math.Min(b.tokens, float64(rate.Limit))
+	}
+}
+
+// Allow charges one token from every target's bucket. Either all buckets are
+// charged or none are; when denied, retryAfter is the time until all targets
+// have a token available again.
+func (l *Limiter) Allow(targets ...Target) (bool, time.Duration) {
+	charges := make(map[Target]int, len(targets))
+	for _, t := range targets {
+		charges[t]++
+	}
+	return l.AllowEach(charges)
+}
+
+// AllowEach charges each target's bucket the given number of tokens, e.g. one
+// per run of a batch. Either all buckets are charged or none are. A charge
+// larger than a bucket's burst can never be allowed and is denied with a zero
+// retryAfter.
+func (l *Limiter) AllowEach(charges map[Target]int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	exceeded := false
+	active := make(map[*bucket]float64, len(charges))
+
+	for t, n := range charges {
+		b := l.bucketFor(t, now)
+		if b == nil || n <= 0 {
+			continue
+		}
+		b.refill(now)
+		if n > b.rate.Limit {
+			exceeded = true
+		} else if w := b.waitFor(float64(n)); w > wait {
+			wait = w
+		}
+		active[b] = float64(n)
+	}
+
+	if exceeded || wait > 0 {
+		for b := range active {
+			b.limited++
+		}
+		if exceeded {
+			return false, 0
+		}
+		return false, wait
+	}
+
+	for b, n := range active {
+		b.tokens -= n
+		b.allowed++
+	}
+	return true, 0
+}
+
+// bucketFor returns the bucket for a target, creating it on first use.
+// Returns nil when the target is unlimited or an unknown job.
+func (l *Limiter) bucketFor(t Target, now time.Time) *bucket {
+	if b, ok := l.buckets[t]; ok {
+		return b
+	}
+
+	if t.Scope == ScopeJob && !l.cfg.Jobs[t.Subject] {
+		return nil
+	}
+	rate := l.rateFor(t)
+	if rate.Unlimited() {
+		return nil
+	}
+	b := &bucket{rate: rate, tokens: float64(rate.Limit), last: now}
+	l.buckets[t] = b
+	return b
+}
+
+// rateFor resolves the effective rate for a target
+func (l *Limiter) rateFor(t Target) Rate {
+	var def Rate
+	var overrides map[string]Limits
+
+	switch t.Scope {
+	case ScopeKey:
+		overrides = l.cfg.KeyOverrides
+		def = l.cfg.KeyRead
+		if t.Class == ClassTrigger {
+			def = l.cfg.KeyTrigger
+		}
+	case ScopeJob:
+		overrides = l.cfg.JobOverrides
+		def = l.cfg.JobRead
+		if t.Class == ClassTrigger {
+			def = l.cfg.JobTrigger
+		}
+	}
+
+	if o, ok := overrides[t.Subject]; ok {
+		if t.Class == ClassTrigger && o.Trigger != nil {
+			return *o.Trigger
+		}
+		if t.Class == ClassRead && o.Read != nil {
+			return *o.Read
+		}
+	}
+	return def
+}
+
+// refill adds tokens accrued since the last refill
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.last = now
+	perToken := float64(b.rate.Per) / float64(b.rate.Limit)
+	b.tokens = math.Min(float64(b.rate.Limit), b.tokens+float64(elapsed)/perToken)
+}
+
+// waitFor returns how long until n tokens are available
+func (b *bucket) waitFor(n float64) time.Duration {
+	missing := n - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	perToken := float64(b.rate.Per) / float64(b.rate.Limit)
+	return time.Duration(math.Ceil(missing * perToken))
+}
+
+// WriteMetrics writes limiter state in Prometheus text exposition format
+func (l *Limiter) WriteMetrics(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	targets := make([]Target, 0, len(l.buckets))
+	for t := range l.buckets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Class < b.Class
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# HELP simpleci_ratelimit_requests_total Requests checked against a rate limit bucket.\n")
+	sb.WriteString("# TYPE simpleci_ratelimit_requests_total counter\n")
+	for _, t := range targets {
+		b := l.buckets[t]
+		fmt.Fprintf(&sb, "simpleci_ratelimit_requests_total{%s,result=\"allowed\"} %d\n", labels(t), b.allowed)
+		fmt.Fprintf(&sb, "simpleci_ratelimit_requests_total{%s,result=\"limited\"} %d\n", labels(t), b.limited)
+	}
+
+	sb.WriteString("# HELP simpleci_ratelimit_tokens Tokens currently available in a rate limit bucket.\n")
+	sb.WriteString("# TYPE simpleci_ratelimit_tokens gauge\n")
+	for _, t := range targets {
+		b := l.buckets[t]
+		b.refill(now)
+		fmt.Fprintf(&sb, "simpleci_ratelimit_tokens{%s} %g\n", labels(t), math.Floor(b.tokens*100)/100)
+	}
+
+	sb.WriteString("# HELP simpleci_ratelimit_capacity Configured burst size of a rate limit bucket.\n")
+	sb.WriteString("# TYPE simpleci_ratelimit_capacity gauge\n")
+	for _, t := range targets {
+		b := l.buckets[t]
+		fmt.Fprintf(&sb, "simpleci_ratelimit_capacity{%s} %d\n", labels(t), b.rate.Limit)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// labels formats a target as Prometheus labels
+func labels(t Target) string {
+	return fmt.Sprintf("scope=%q,subject=%q,class=%q", t.Scope, t.Subject, t.Class)
+}
@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Rate
+		wantErr bool
+	}{
+		{"empty", "", Rate{}, false},
+		{"unlimited", "unlimited", Rate{}, false},
+		{"per second", "10/s", Rate{Limit: 10, Per: time.Second}, false},
+		{"per minute", "60/m", Rate{Limit: 60, Per: time.Minute}, false},
+		{"per hour", "500/h", Rate{Limit: 500, Per: time.Hour}, false},
+		{"custom period", "20/30s", Rate{Limit: 20, Per: 30 * time.Second}, false},
+		{"missing period", "10", Rate{}, true},
+		{"bad count", "x/m", Rate{}, true},
+		{"bad period", "10/fortnight", Rate{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(Config{
+		KeyTrigger: Rate{Limit: 2, Per: time.Minute},
+		JobTrigger: Rate{Limit: 3, Per: time.Minute},
+		Jobs:       map[string]bool{"job_tests": true},
+	})
+	l.now = func() time.Time { return now }
+
+	key := Target{Scope: ScopeKey, Subject: "ci-bot", Class: ClassTrigger}
+	job := Target{Scope: ScopeJob, Subject: "job_tests", Class: ClassTrigger}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(key, job); !ok {
+			t.Fatalf("request %d denied, want allowed", i)
+		}
+	}
+
+	ok, retryAfter := l.Allow(key, job)
+	if ok {
+		t.Fatal("third request allowed, want denied by key budget")
+	}
+	if retryAfter != 30*time.Second {
+		t.Errorf("retryAfter = %v, want 30s", retryAfter)
+	}
+
+	// Denied requests must not consume the job bucket
+	other := Target{Scope: ScopeKey, Subject: "other", Class: ClassTrigger}
+	if ok, _ := l.Allow(other, job); !ok {
+		t.Fatal("job bucket was charged by a denied request")
+	}
+
+	now = now.Add(30 * time.Second)
+	if ok, _ := l.Allow(key); !ok {
+		t.Error("request after refill denied, want allowed")
+	}
+}
+
+func TestLimiter_Overrides(t *testing.T) {
+	unlimited := Rate{}
+	strict := Rate{Limit: 1, Per: time.Hour}
+	l := New(Config{
+		KeyRead:      Rate{Limit: 1, Per: time.Hour},
+		KeyOverrides: map[string]Limits{"dashboard": {Read: &unlimited}},
+		JobOverrides: map[string]Limits{"job_deploy": {Trigger: &strict}},
+		Jobs:         map[string]bool{"job_deploy": true},
+	})
+
+	dashboard := Target{Scope: ScopeKey, Subject: "dashboard", Class: ClassRead}
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.Allow(dashboard); !ok {
+			t.Fatalf("unlimited override denied request %d", i)
+		}
+	}
+
+	deploy := Target{Scope: ScopeJob, Subject: "job_deploy", Class: ClassTrigger}
+	l.Allow(deploy)
+	if ok, _ := l.Allow(deploy); ok {
+		t.Error("job override not applied")
+	}
+
+	l.SetJobs(map[string]bool{"job_deploy": true}, nil)
+	if ok, _ := l.Allow(deploy); !ok {
+		t.Error("removed job override still applied")
+	}
+}
+
+func TestLimiter_WriteMetrics(t *testing.T) {
+	l := New(Config{KeyTrigger: Rate{Limit: 1, Per: time.Minute}})
+	key := Target{Scope: ScopeKey, Subject: "ci-bot", Class: ClassTrigger}
+	l.Allow(key)
+	l.Allow(key)
+
+	var sb strings.Builder
+	if err := l.WriteMetrics(&sb); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`simpleci_ratelimit_requests_total{scope="key",subject="ci-bot",class="trigger",result="allowed"} 1`,
+		`simpleci_ratelimit_requests_total{scope="key",subject="ci-bot",class="trigger",result="limited"} 1`,
+		`simpleci_ratelimit_capacity{scope="key",subject="ci-bot",class="trigger"} 1`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("metrics missing %q\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestLimiter_UnknownJobs(t *testing.T) {
+	l := New(Config{
+		JobTrigger: Rate{Limit: 1, Per: time.Hour},
+		Jobs:       map[string]bool{"job_deploy": true},
+	})
+
+	for i := 0; i < 3; i++ {
+		l.Allow(Target{Scope: ScopeJob, Subject: fmt.Sprintf("random-%d", i), Class: ClassTrigger})
+	}
+	deploy := Target{Scope: ScopeJob, Subject: "job_deploy", Class: ClassTrigger}
+	l.Allow(deploy)
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d, want only the configured job's", len(l.buckets))
+	}
+
+	// Removing the job drops its bucket
+	l.SetJobs(map[string]bool{}, nil)
+	if len(l.buckets) != 0 {
+		t.Errorf("buckets = %d after removing the job, want 0", len(l.buckets))
+	}
+}
+
+func TestLimiter_AllowEach(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(Config{
+		KeyTrigger: Rate{Limit: 10, Per: time.Minute},
+		JobTrigger: Rate{Limit: 5, Per: time.Minute},
+		Jobs:       map[string]bool{"job_tests": true},
+	})
+	l.now = func() time.Time { return now }
+
+	key := Target{Scope: ScopeKey, Subject: "ci-bot", Class: ClassTrigger}
+	job := Target{Scope: ScopeJob, Subject: "job_tests", Class: ClassTrigger}
+
+	if ok, _ := l.AllowEach(map[Target]int{key: 4, job: 4}); !ok {
+		t.Fatal("batch of 4 denied, want allowed")
+	}
+
+	// Only one job token left: the whole batch is denied and nothing is charged
+	ok, retryAfter := l.AllowEach(map[Target]int{key: 2, job: 2})
+	if ok {
+		t.Fatal("batch of 2 allowed, want denied by job budget")
+	}
+	if retryAfter != 12*time.Second {
+		t.Errorf("retryAfter = %v, want 12s", retryAfter)
+	}
+	if ok, _ := l.Allow(key, job); !ok {
+		t.Error("denied batch consumed tokens")
+	}
+
+	// A batch larger than the burst can never pass
+	if ok, retryAfter := l.AllowEach(map[Target]int{key: 11}); ok || retryAfter != 0 {
+		t.Errorf("AllowEach(11) = %v, %v; want denied with zero retryAfter", ok, retryAfter)
+	}
+}
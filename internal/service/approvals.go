@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// defaultApprovalExpiry applies when a job's approval policy sets no expires_after
+const defaultApprovalExpiry = 24 * time.Hour
+
+var (
+	// ErrRunNotPendingApproval indicates the run isn't waiting for approvers
+	ErrRunNotPendingApproval = errors.New("run is not pending approval")
+	// ErrApproverScopeRequired indicates the caller may not approve runs
+	ErrApproverScopeRequired = errors.New("approver scope required")
+	// ErrSelfApproval indicates the caller triggered the run they tried to approve
+	ErrSelfApproval = errors.New("runs cannot be approved by the caller who triggered them")
+	// ErrAlreadyApproved indicates the caller already approved the run
+	ErrAlreadyApproved = errors.New("run already approved by this caller")
+)
+
+// approvalExpiry returns how long runs of a job wait for approvers
+func approvalExpiry(policy *models.JobApproval) time.Duration {
+	if d, err := time.ParseDuration(policy.ExpiresAfter); err == nil && d > 0 {
+		return d
+	}
+	return defaultApprovalExpiry
+}
+
+// requestApproval holds a run in the gateway until enough approvers sign off
+func (s *Service) requestApproval(ctx context.Context, job *models.Job, rec *runRecord) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+
+	expiresAt := rec.CreatedAt.Add(approvalExpiry(job.Approval))
+	if rec.ID == "" {
+		rec.ID = newGatewayRunID()
+	}
+	rec.GatewayStatus = models.GatewayStatusPendingApproval
+	rec.Status = models.StatusQueued
+	rec.ApprovalExpiresAt = &expiresAt
+	if err := s.runs.put(rec); err != nil {
+		return nil, fmt.Errorf("persist pending run: %w", err)
+	}
+
+	logger.Info("service: run waiting for approval",
+		"job_id", rec.JobID,
+		"run_id", rec.ID,
+		"required_approvals", job.Approval.RequiredApprovals,
+		"expires_at", expiresAt)
+
+	return rec.toRun(), nil
+}
+
+// ApproveRun records the caller's approval and dispatches the run once the
+// job's required number of distinct approvers has been reached
+func (s *Service) ApproveRun(ctx context.Context, runID, comment string) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+	caller := callerName(ctx)
+
+	s.approvalMu.Lock()
+	defer s.approvalMu.Unlock()
+
+	rec, err := s.pendingApproval(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	job, exists := s.job(rec.JobID)
+	if !exists {
+		if err := s.finishHeldAs(rec.ID, models.GatewayStatusDispatchFailed, models.StatusErrored, "job no longer configured"); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+		return nil, ErrJobNotFound
+	}
+	// Runs without a recorded trigger caller (e.g. with auth disabled) can't be self-approved
+	if rec.TriggeredBy != "" && caller == rec.TriggeredBy {
+		logger.Warn("service: self-approval refused", "run_id", runID, "caller", caller)
+		return nil, ErrSelfApproval
+	}
+	for _, a := range rec.Approvals {
+		if a.By == caller {
+			return nil, ErrAlreadyApproved
+		}
+	}
+
+	// The final approval dispatches the run, so freezes apply to the approver.
+	// A run approved during a freeze without an override is held until it ends.
+	var override *models.FreezeOverride
+	if job.Approval == nil || len(rec.Approvals)+1 >= job.Approval.RequiredApprovals {
+		override, _ = s.checkFreeze(ctx, job, time.Now())
+	}
+
+	approvals := append([]models.RunApproval(nil), rec.Approvals...)
+	rec.Approvals = append(approvals, models.RunApproval{By: caller, Comment: comment, At: time.Now()})
+	if _, err := s.runs.update(rec.ID, func(r *runRecord) { r.Approvals = rec.Approvals }); err != nil {
+		return nil, fmt.Errorf("persist approval: %w", err)
+	}
+
+	logger.Info("service: run approved",
+		"job_id", rec.JobID,
+		"run_id", rec.ID,
+		"approved_by", caller,
+		"approvals", len(rec.Approvals))
+
+	if job.Approval != nil && len(rec.Approvals) < job.Approval.RequiredApprovals {
+		return rec.toRun(), nil
+	}
+
+	// Fully approved - continue as if freshly triggered
+	rec.GatewayStatus = ""
+	rec.ApprovalExpiresAt = nil
+	if override != nil {
+		rec.FreezeOverride = override
+	}
+	run, err := s.release(ctx, job, rec)
+	if err != nil {
+		if rec.ProviderRunID == "" {
+			if err := s.finishHeldAs(rec.ID, models.GatewayStatusDispatchFailed, models.StatusErrored, err.Error()); err != nil {
+				logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+			}
+		}
+		return nil, err
+	}
+	if override != nil {
+		s.auditFreezeOverride(ctx, override, run.RunID)
+	}
+	return run, nil
+}
+
+// RejectRun drops a run that is waiting for approvers
+func (s *Service) RejectRun(ctx context.Context, runID, comment string) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+	caller := callerName(ctx)
+
+	s.approvalMu.Lock()
+	defer s.approvalMu.Unlock()
+
+	rec, err := s.pendingApproval(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "rejected by " + callerLabel(ctx)
+	if comment != "" {
+		reason += ": " + comment
+	}
+	if err := s.finishHeld(rec.ID, models.GatewayStatusRejected, reason); err != nil {
+		return nil, fmt.Errorf("persist rejection: %w", err)
+	}
+
+	logger.Info("service: run rejected",
+		"job_id", rec.JobID,
+		"run_id", rec.ID,
+		"rejected_by", caller)
+
+	rec, _ = s.runs.get(rec.ID)
+	return rec.toRun(), nil
+}
+
+// pendingApproval looks up a run the caller may decide on
+func (s *Service) pendingApproval(ctx context.Context, runID string) (*runRecord, error) {
+	rec, tracked := s.runs.get(runID)
+	if !tracked {
+		return nil, ErrRunNotFound
+	}
+	if !callerHasScope(ctx, models.ScopeApprover) {
+		s.getLogger(ctx).Warn("service: approval refused, caller lacks approver scope",
+			"run_id", runID,
+			"caller", callerName(ctx))
+		return nil, ErrApproverScopeRequired
+	}
+	if !rec.awaitingApproval() {
+		return nil, ErrRunNotPendingApproval
+	}
+	if rec.ApprovalExpiresAt != nil && !time.Now().Before(*rec.ApprovalExpiresAt) {
+		s.expireApproval(ctx, rec)
+		return nil, ErrRunNotPendingApproval
+	}
+	return rec, nil
+}
+
+// expireApprovals drops runs whose approval window has passed
+func (s *Service) expireApprovals(ctx context.Context, now time.Time) {
+	s.approvalMu.Lock()
+	defer s.approvalMu.Unlock()
+
+	expired := s.runs.list(func(r *runRecord) bool {
+		return r.awaitingApproval() && r.ApprovalExpiresAt != nil && !now.Before(*r.ApprovalExpiresAt)
+	})
+	for _, rec := range expired {
+		s.expireApproval(ctx, rec)
+	}
+}
+
+// expireApproval ends a single run whose approval window has passed
+func (s *Service) expireApproval(ctx context.Context, rec *runRecord) {
+	logger := s.getLogger(ctx)
+
+	if err := s.finishHeld(rec.ID, models.GatewayStatusExpired, "not approved in time"); err != nil {
+		logger.Error("service: failed to expire pending run", "run_id", rec.ID, "error", err)
+		return
+	}
+	logger.Info("service: pending approval expired",
+		"job_id", rec.JobID,
+		"run_id", rec.ID,
+		"approvals", len(rec.Approvals))
+}
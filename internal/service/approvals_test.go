@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// callerCtx returns a context authenticated as name with the given scopes
+func callerCtx(name string, scopes ...string) context.Context {
+	ctx := context.WithValue(context.Background(), "api_key_name", name)
+	return context.WithValue(ctx, "api_key_scopes", scopes)
+}
+
+func approvalJob(id string, required int) *models.Job {
+	job := testJob(id)
+	job.Environment = "prod"
+	job.Approval = &models.JobApproval{RequiredApprovals: required, ExpiresAfter: "1h"}
+	return job
+}
+
+func TestApproveRun(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, approvalJob("deploy", 2))
+
+	run, err := svc.TriggerRun(callerCtx("alice"), "deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusPendingApproval || run.ApprovalExpiresAt == nil {
+		t.Fatalf("gateway_status = %q, want pending_approval with expiry", run.GatewayStatus)
+	}
+	if len(prov.triggers) != 0 {
+		t.Fatalf("run dispatched before approval")
+	}
+
+	if _, err := svc.ApproveRun(callerCtx("bob"), run.RunID, ""); !errors.Is(err, ErrApproverScopeRequired) {
+		t.Errorf("approve without scope error = %v, want ErrApproverScopeRequired", err)
+	}
+	if _, err := svc.ApproveRun(callerCtx("alice", "approver"), run.RunID, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self approval error = %v, want ErrSelfApproval", err)
+	}
+
+	got, err := svc.ApproveRun(callerCtx("bob", "approver"), run.RunID, "lgtm")
+	if err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	if got.GatewayStatus != models.GatewayStatusPendingApproval || len(prov.triggers) != 0 {
+		t.Fatalf("dispatched after one of two approvals")
+	}
+	if _, err := svc.ApproveRun(callerCtx("bob", "approver"), run.RunID, ""); !errors.Is(err, ErrAlreadyApproved) {
+		t.Errorf("repeat approval error = %v, want ErrAlreadyApproved", err)
+	}
+
+	got, err = svc.ApproveRun(callerCtx("carol", "approver"), run.RunID, "")
+	if err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	if got.RunID != run.RunID || got.GatewayStatus != models.GatewayStatusDispatched || len(prov.triggers) != 1 {
+		t.Fatalf("run = %+v, want dispatched under its gateway ID", got)
+	}
+	if len(got.Approvals) != 2 || got.Approvals[0].By != "bob" || got.Approvals[0].Comment != "lgtm" {
+		t.Errorf("approvals = %+v", got.Approvals)
+	}
+}
+
+func TestApproveRun_UnnamedCallers(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, approvalJob("deploy", 1))
+
+	// Without names there is no way to tell callers apart, so nothing counts as self-approval
+	run, err := svc.TriggerRun(callerCtx(""), "deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	got, err := svc.ApproveRun(callerCtx("", "approver"), run.RunID, "")
+	if err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	if got.GatewayStatus != models.GatewayStatusDispatched || len(prov.triggers) != 1 {
+		t.Errorf("gateway_status = %q with %d triggers, want dispatched", got.GatewayStatus, len(prov.triggers))
+	}
+}
+
+func TestRejectAndExpireRun(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, approvalJob("deploy", 1))
+
+	rejected, _ := svc.TriggerRun(callerCtx("alice"), "deploy", nil, "")
+	got, err := svc.RejectRun(callerCtx("bob", "approver"), rejected.RunID, "freeze")
+	if err != nil {
+		t.Fatalf("RejectRun() error = %v", err)
+	}
+	if got.GatewayStatus != models.GatewayStatusRejected || got.CancelReason != models.CancelReasonRejected || got.Reason != "rejected by bob: freeze" {
+		t.Errorf("run = %+v, want rejected by bob", got)
+	}
+	if _, err := svc.ApproveRun(callerCtx("carol", "approver"), rejected.RunID, ""); !errors.Is(err, ErrRunNotPendingApproval) {
+		t.Errorf("approve rejected run error = %v, want ErrRunNotPendingApproval", err)
+	}
+
+	expiring, _ := svc.TriggerRun(callerCtx("alice"), "deploy", nil, "")
+	svc.expireApprovals(context.Background(), time.Now().Add(2*time.Hour))
+
+	got, err = svc.GetRun(context.Background(), expiring.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if got.GatewayStatus != models.GatewayStatusExpired || got.Status != models.StatusCanceled {
+		t.Errorf("gateway_status = %q, status = %q; want expired, canceled", got.GatewayStatus, got.Status)
+	}
+	if len(prov.triggers) != 0 {
+		t.Errorf("triggered %d builds, want none", len(prov.triggers))
+	}
+}
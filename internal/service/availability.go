@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// ErrJobsUnavailable indicates strict validation found jobs the provider doesn't know
+var ErrJobsUnavailable = errors.New("jobs unavailable in provider")
+
+// ValidationMode decides how jobs missing from the provider are handled
+type ValidationMode string
+
+const (
+	ValidationOff     ValidationMode = "off"     // Jobs are not checked against the provider
+	ValidationLenient ValidationMode = "lenient" // Missing jobs are marked unavailable in ListJobs
+	ValidationStrict  ValidationMode = "strict"  // Missing jobs fail startup and reloads
+)
+
+// ValidateJobs checks every job in effect against the provider and records
+// which ones are unavailable. In strict mode unavailable jobs are an error
+// wrapping ErrJobsUnavailable. Provider errors that prevent a check are
+// returned in any mode; jobs that couldn't be checked keep no availability.
+func (s *Service) ValidateJobs(ctx context.Context) (map[string]models.JobAvailability, error) {
+	results, err := s.checkJobs(ctx, s.definitions().jobs)
+	s.setAvailability(results)
+	if err != nil {
+		return results, err
+	}
+	if s.validation == ValidationStrict {
+		return results, unavailableError(results)
+	}
+	return results, nil
+}
+
+// checkJobs looks every job up in the provider. Jobs the provider doesn't
+// know are reported unavailable; the first other error is returned after all
+// jobs were tried. Providers that can't validate jobs skip the check.
+func (s *Service) checkJobs(ctx context.Context, jobs map[string]*models.Job) (map[string]models.JobAvailability, error) {
+	logger := s.getLogger(ctx)
+
+	validator, ok := s.provider.(provider.JobValidator)
+	if !ok {
+		logger.Debug("service: provider cannot validate job refs, skipping job validation")
+		return nil, nil
+	}
+
+	now := time.Now()
+	results := make(map[string]models.JobAvailability, len(jobs))
+	var firstErr error
+	for id, job := range jobs {
+		jobRef, err := s.buildJobRef(job)
+		if err == nil {
+			err = validator.ValidateJob(ctx, jobRef)
+			if err != nil && !errors.Is(err, provider.ErrJobNotFound) {
+				logger.Warn("service: could not validate job", "job_id", id, "error", err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("validate job %s: %w", id, err)
+				}
+				continue
+			}
+		}
+
+		avail := models.JobAvailability{Available: err == nil, CheckedAt: now}
+		if err != nil {
+			avail.Reason = err.Error()
+			logger.Warn("service: job unavailable in provider", "job_id", id, "reason", avail.Reason)
+		}
+		results[id] = avail
+	}
+
+	return results, firstErr
+}
+
+// unavailableError lists unavailable jobs, or returns nil if there are none
+func unavailableError(results map[string]models.JobAvailability) error {
+	var unavailable []string
+	for id, avail := range results {
+		if !avail.Available {
+			unavailable = append(unavailable, fmt.Sprintf("%s (%s)", id, avail.Reason))
+		}
+	}
+	if len(unavailable) == 0 {
+		return nil
+	}
+	sort.Strings(unavailable)
+	return fmt.Errorf("%w: %s", ErrJobsUnavailable, strings.Join(unavailable, ", "))
+}
+
+// setAvailability replaces the recorded job availability
+func (s *Service) setAvailability(results map[string]models.JobAvailability) {
+	s.availabilityMu.Lock()
+	defer s.availabilityMu.Unlock()
+
+	s.availability = make(map[string]models.JobAvailability, len(results))
+	for id, avail := range results {
+		s.availability[id] = avail
+	}
+}
+
+// mergeAvailability records results on top of the recorded availability,
+// keeping earlier results for jobs that couldn't be checked this time. Jobs
+// no longer configured are dropped.
+func (s *Service) mergeAvailability(results map[string]models.JobAvailability, jobs map[string]*models.Job) {
+	s.availabilityMu.Lock()
+	defer s.availabilityMu.Unlock()
+
+	merged := make(map[string]models.JobAvailability, len(jobs))
+	for id := range jobs {
+		if avail, ok := results[id]; ok {
+			merged[id] = avail
+		} else if avail, ok := s.availability[id]; ok {
+			merged[id] = avail
+		}
+	}
+	s.availability = merged
+}
+
+// markAvailable records a job that just passed validation
+func (s *Service) markAvailable(jobID string) {
+	s.availabilityMu.Lock()
+	defer s.availabilityMu.Unlock()
+
+	if s.availability == nil {
+		s.availability = make(map[string]models.JobAvailability)
+	}
+	s.availability[jobID] = models.JobAvailability{Available: true, CheckedAt: time.Now()}
+}
+
+// withProviderState returns a copy of the job carrying its recorded
+// availability and pause state
+func (s *Service) withProviderState(job *models.Job) *models.Job {
+	s.availabilityMu.Lock()
+	avail, hasAvail := s.availability[job.JobID]
+	s.availabilityMu.Unlock()
+
+	s.pauseMu.Lock()
+	pause, hasPause := s.pauses[job.JobID]
+	s.pauseMu.Unlock()
+
+	if !hasAvail && !hasPause {
+		return job
+	}
+	cp := *job
+	if hasAvail {
+		cp.Availability = &avail
+	}
+	if hasPause {
+		cp.Pause = &pause
+	}
+	return &cp
+}
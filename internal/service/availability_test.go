@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+func TestValidateJobs(t *testing.T) {
+	ctx := context.Background()
+	prov := &validatingProvider{fakeProvider: newFakeProvider(), jobs: map[string]bool{"build": true}}
+
+	svc, err := NewService([]*models.Job{testJob("build"), testJob("deploy")}, prov, logger.New("error", "text"), Options{
+		Validation: ValidationLenient,
+	})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	results, err := svc.ValidateJobs(ctx)
+	if err != nil {
+		t.Fatalf("ValidateJobs() lenient error = %v", err)
+	}
+	if !results["build"].Available || results["deploy"].Available || results["deploy"].Reason == "" {
+		t.Errorf("results = %+v, want build available and deploy unavailable with a reason", results)
+	}
+	for _, job := range svc.ListJobs(ctx) {
+		if job.Availability == nil || job.Availability.Available != (job.JobID == "build") {
+			t.Errorf("ListJobs() %s availability = %+v", job.JobID, job.Availability)
+		}
+	}
+
+	// Strict mode rejects a reload introducing an unknown job
+	svc.validation = ValidationStrict
+	err = svc.Reload(ctx, Definitions{Jobs: []*models.Job{testJob("build"), testJob("test")}, Hash: "v2"})
+	if !errors.Is(err, ErrJobsUnavailable) {
+		t.Fatalf("Reload() strict error = %v, want ErrJobsUnavailable", err)
+	}
+	if _, exists := svc.job("test"); exists {
+		t.Error("strict reload applied a configuration with unavailable jobs")
+	}
+
+	// A valid reload replaces the recorded availability
+	if err := svc.Reload(ctx, Definitions{Jobs: []*models.Job{testJob("build")}, Hash: "v3"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := svc.ValidateJobs(ctx); err != nil {
+		t.Errorf("ValidateJobs() strict error = %v, want nil", err)
+	}
+}
+
+func TestReload_LenientKeepsUncheckedAvailability(t *testing.T) {
+	ctx := context.Background()
+	prov := &validatingProvider{fakeProvider: newFakeProvider(), jobs: map[string]bool{"build": true}}
+	svc, err := NewService([]*models.Job{testJob("build"), testJob("deploy")}, prov, logger.New("error", "text"), Options{
+		Validation: ValidationLenient,
+	})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	if _, err := svc.ValidateJobs(ctx); err != nil {
+		t.Fatalf("ValidateJobs() error = %v", err)
+	}
+
+	// deploy can't be looked up this time; its earlier result stays
+	prov.jobs["test"] = true
+	prov.errs = map[string]error{"deploy": errors.New("connection refused")}
+	err = svc.Reload(ctx, Definitions{Jobs: []*models.Job{testJob("build"), testJob("deploy"), testJob("test")}, Hash: "v2"})
+	if err != nil {
+		t.Fatalf("Reload() lenient error = %v", err)
+	}
+	for _, job := range svc.ListJobs(ctx) {
+		if job.Availability == nil || job.Availability.Available != (job.JobID != "deploy") {
+			t.Errorf("ListJobs() %s availability = %+v", job.JobID, job.Availability)
+		}
+	}
+
+	// Removed jobs don't keep an entry
+	if err := svc.Reload(ctx, Definitions{Jobs: []*models.Job{testJob("build")}, Hash: "v3"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, ok := svc.availability["deploy"]; ok {
+		t.Error("availability kept for a removed job")
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// batchStore keeps batches in memory and persists them to the state directory
+type batchStore struct {
+	mu      sync.Mutex
+	file    *store.File
+	records map[string]*models.Batch
+
+	// redact masks sensitive child parameters before batches are persisted;
+	// the values are only kept in memory
+	redact func(jobID string, recorded []string, values map[string]interface{}) map[string]interface{}
+}
+
+// newBatchStore creates a batch store backed by batches.json in dir
+func newBatchStore(dir string) (*batchStore, error) {
+	bs := &batchStore{
+		file:    store.NewFile(dir, "batches.json"),
+		records: make(map[string]*models.Batch),
+	}
+
+	var records []*models.Batch
+	if err := bs.file.Load(&records); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		bs.records[rec.BatchID] = rec
+	}
+
+	return bs, nil
+}
+
+// newBatchID generates an ID for a batch
+func newBatchID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "batch-" + hex.EncodeToString(b)
+}
+
+// get returns a copy of the batch with the given ID
+func (bs *batchStore) get(id string) (*models.Batch, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	rec, ok := bs.records[id]
+	if !ok {
+		return nil, false
+	}
+	return copyBatch(rec), true
+}
+
+// list returns copies of matching batches ordered by creation time
+func (bs *batchStore) list(match func(*models.Batch) bool) []*models.Batch {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	var out []*models.Batch
+	for _, rec := range bs.records {
+		if match == nil || match(rec) {
+			out = append(out, copyBatch(rec))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// put inserts or replaces a batch and persists the store
+func (bs *batchStore) put(rec *models.Batch) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.records[rec.BatchID] = copyBatch(rec)
+	return bs.saveLocked()
+}
+
+// prune drops finished batches older than the cutoff
+func (bs *batchStore) prune(cutoff time.Time) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	removed := false
+	for id, rec := range bs.records {
+		if rec.FinishedAt != nil && rec.FinishedAt.Before(cutoff) {
+			delete(bs.records, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return bs.saveLocked()
+}
+
+// saveLocked persists all batches; caller must hold bs.mu
+func (bs *batchStore) saveLocked() error {
+	records := make([]*models.Batch, 0, len(bs.records))
+	for _, rec := range bs.records {
+		if bs.redact != nil {
+			rec = copyBatch(rec)
+			for i := range rec.Runs {
+				rec.Runs[i].Parameters = bs.redact(rec.JobID, rec.Sensitive, rec.Runs[i].Parameters)
+			}
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return bs.file.Save(records)
+}
+
+// copyBatch copies a batch including its child runs and counts
+func copyBatch(rec *models.Batch) *models.Batch {
+	cp := *rec
+	cp.Runs = append([]models.BatchRun(nil), rec.Runs...)
+	cp.Counts = make(map[models.RunStatus]int, len(rec.Counts))
+	for status, n := range rec.Counts {
+		cp.Counts[status] = n
+	}
+	return &cp
+}
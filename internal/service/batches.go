@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+)
+
+// maxBatchSize caps the number of child runs a single batch may create
+const maxBatchSize = 100
+
+var (
+	// ErrBatchNotFound indicates the requested batch doesn't exist
+	ErrBatchNotFound = errors.New("batch not found")
+	// ErrInvalidBatch indicates the batch request has no usable parameter sets
+	ErrInvalidBatch = errors.New("invalid batch")
+)
+
+// BatchRequest describes the parameter sets of a batch. Matrix combinations
+// and explicit parameter sets are merged over the common parameters.
+type BatchRequest struct {
+	Parameters    map[string]interface{}   // Common to every child run
+	Matrix        map[string][]interface{} // Cartesian product of the listed values
+	ParameterSets []map[string]interface{} // Explicit list of parameter sets
+	MaxParallel   int                      // Max children in flight; 0 means all at once
+}
+
+// expandBatch returns the parameter set for every child run
+func expandBatch(req BatchRequest) ([]map[string]interface{}, error) {
+	if len(req.Matrix) > 0 && len(req.ParameterSets) > 0 {
+		return nil, fmt.Errorf("%w: matrix and parameter_sets are mutually exclusive", ErrInvalidBatch)
+	}
+	if req.MaxParallel < 0 {
+		return nil, fmt.Errorf("%w: max_parallel must not be negative", ErrInvalidBatch)
+	}
+
+	sets := req.ParameterSets
+	if len(req.Matrix) > 0 {
+		keys := make([]string, 0, len(req.Matrix))
+		total := 1
+		for key, values := range req.Matrix {
+			if len(values) == 0 {
+				return nil, fmt.Errorf("%w: matrix entry %q has no values", ErrInvalidBatch, key)
+			}
+			keys = append(keys, key)
+			if total *= len(values); total > maxBatchSize {
+				return nil, fmt.Errorf("%w: more than %d combinations", ErrInvalidBatch, maxBatchSize)
+			}
+		}
+		sort.Strings(keys)
+
+		// The last key varies fastest so combinations come out in a stable order
+		sets = []map[string]interface{}{{}}
+		for _, key := range keys {
+			next := make([]map[string]interface{}, 0, len(sets)*len(req.Matrix[key]))
+			for _, set := range sets {
+				for _, value := range req.Matrix[key] {
+					combo := make(map[string]interface{}, len(set)+1)
+					for k, v := range set {
+						combo[k] = v
+					}
+					combo[key] = value
+					next = append(next, combo)
+				}
+			}
+			sets = next
+		}
+	}
+
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("%w: matrix or parameter_sets is required", ErrInvalidBatch)
+	}
+	if len(sets) > maxBatchSize {
+		return nil, fmt.Errorf("%w: more than %d parameter sets", ErrInvalidBatch, maxBatchSize)
+	}
+
+	out := make([]map[string]interface{}, len(sets))
+	for i, set := range sets {
+		params := make(map[string]interface{}, len(req.Parameters)+len(set))
+		for k, v := range req.Parameters {
+			params[k] = v
+		}
+		for k, v := range set {
+			params[k] = v
+		}
+		out[i] = params
+	}
+	return out, nil
+}
+
+// TriggerBatch creates a batch of runs for a job and triggers as many as
+// max_parallel allows; the rest are triggered as earlier children finish.
+func (s *Service) TriggerBatch(ctx context.Context, jobID string, req BatchRequest) (*models.Batch, error) {
+	logger := s.getLogger(ctx)
+
+	job, exists := s.job(jobID)
+	if !exists {
+		logger.Debug("service: job not found", "job_id", jobID)
+		return nil, ErrJobNotFound
+	}
+
+	sets, err := expandBatch(req)
+	if err != nil {
+		logger.Debug("service: invalid batch", "job_id", jobID, "error", err)
+		return nil, err
+	}
+
+	// Reject the whole batch up front rather than failing children one by one
+	for i, set := range sets {
+		if sets[i], err = validateParameters(job, set); err != nil {
+			logger.Debug("service: batch parameters rejected", "job_id", jobID, "index", i, "error", err)
+			return nil, prefixFields(err, fmt.Sprintf("runs[%d]", i))
+		}
+	}
+
+	// Every child run counts against the rate limits, not just the request.
+	// Children are charged as they are triggered; the first wave is charged
+	// here so an exhausted budget refuses the batch instead of stalling it.
+	wave := len(sets)
+	if req.MaxParallel > 0 && req.MaxParallel < wave {
+		wave = req.MaxParallel
+	}
+	jobIDs := make([]string, wave)
+	for i := range jobIDs {
+		jobIDs[i] = jobID
+	}
+	if err := s.chargeRuns(ctx, jobIDs); err != nil {
+		return nil, err
+	}
+
+	batch := &models.Batch{
+		BatchID:     newBatchID(),
+		JobID:       jobID,
+		Status:      models.BatchRunning,
+		MaxParallel: req.MaxParallel,
+		TriggeredBy: callerName(ctx),
+		Sensitive:   sensitiveParameters(job, nil),
+		CreatedAt:   time.Now(),
+	}
+	for i, params := range sets {
+		batch.Runs = append(batch.Runs, models.BatchRun{
+			Index:      i,
+			Parameters: params,
+			Status:     models.StatusQueued,
+		})
+	}
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	if err := s.batches.put(batch); err != nil {
+		return nil, fmt.Errorf("persist batch: %w", err)
+	}
+
+	logger.Info("service: batch created",
+		"job_id", jobID,
+		"batch_id", batch.BatchID,
+		"runs", len(sets),
+		"max_parallel", req.MaxParallel)
+
+	batch, err = s.advanceBatchLocked(ctx, batch.BatchID, wave)
+	if err != nil {
+		return nil, err
+	}
+	return s.redactBatch(batch), nil
+}
+
+// redactBatch masks sensitive child parameters in a batch returned to callers
+func (s *Service) redactBatch(batch *models.Batch) *models.Batch {
+	for i := range batch.Runs {
+		batch.Runs[i].Parameters = s.redactParameters(batch.JobID, batch.Sensitive, batch.Runs[i].Parameters)
+	}
+	return batch
+}
+
+// GetBatch returns a batch with the aggregated status of its child runs
+func (s *Service) GetBatch(ctx context.Context, batchID string) (*models.Batch, error) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	if _, ok := s.batches.get(batchID); !ok {
+		return nil, ErrBatchNotFound
+	}
+	batch, err := s.advanceBatchLocked(ctx, batchID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.redactBatch(batch), nil
+}
+
+// advanceBatches refreshes running batches and triggers waiting children
+func (s *Service) advanceBatches(ctx context.Context) {
+	running := s.batches.list(func(b *models.Batch) bool { return b.Status == models.BatchRunning })
+	if len(running) == 0 {
+		return
+	}
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	for _, batch := range running {
+		if _, err := s.advanceBatchLocked(ctx, batch.BatchID, 0); err != nil {
+			s.logger.Error("service: failed to advance batch", "batch_id", batch.BatchID, "error", err)
+		}
+	}
+}
+
+// advanceBatchLocked refreshes child statuses, triggers waiting children while
+// the parallelism limit and the caller's rate limits allow and recomputes the
+// aggregate. The first prepaid triggers were already charged. Caller must
+// hold s.batchMu.
+func (s *Service) advanceBatchLocked(ctx context.Context, batchID string, prepaid int) (*models.Batch, error) {
+	logger := s.getLogger(ctx)
+
+	batch, ok := s.batches.get(batchID)
+	if !ok {
+		return nil, ErrBatchNotFound
+	}
+	if batch.Status != models.BatchRunning {
+		return batch, nil
+	}
+
+	inFlight := 0
+	for i := range batch.Runs {
+		child := &batch.Runs[i]
+		if child.RunID == "" {
+			continue
+		}
+		if rec := s.latestAttempt(child.RunID); rec != nil {
+			child.Status = rec.Status
+			child.Reason = rec.Reason
+			if rec.Status.IsTerminal() && s.retryPending(rec) {
+				child.Status = models.StatusQueued
+			}
+		}
+		if !child.Status.IsTerminal() {
+			inFlight++
+		}
+	}
+
+	triggerCtx := context.WithValue(ctx, "api_key_name", batch.TriggeredBy)
+	limited := false
+	for i := range batch.Runs {
+		child := &batch.Runs[i]
+		if child.RunID != "" || child.Status.IsTerminal() {
+			continue
+		}
+		if batch.MaxParallel > 0 && inFlight >= batch.MaxParallel {
+			child.Reason = "waiting for a parallelism slot"
+			continue
+		}
+		if prepaid > 0 {
+			prepaid--
+		} else if limited || s.chargeRuns(triggerCtx, []string{batch.JobID}) != nil {
+			// Retried on the next advance once the budget has refilled
+			limited = true
+			child.Reason = "waiting for rate limit budget"
+			continue
+		}
+
+		if job, exists := s.job(batch.JobID); exists && params.HasRedacted(job.Parameters, child.Parameters) {
+			child.Status = models.StatusErrored
+			child.Reason = ErrParametersNotRetained.Error()
+			continue
+		}
+
+		// The idempotency key makes a trigger repeated after a crash return the original run
+		run, err := s.triggerOnce(triggerCtx, batch.JobID, child.Parameters, fmt.Sprintf("batch:%s:%d", batch.BatchID, child.Index))
+		if err != nil {
+			logger.Error("service: batch child failed to start",
+				"batch_id", batch.BatchID,
+				"index", child.Index,
+				"error", err)
+			child.Status = models.StatusErrored
+			child.Reason = err.Error()
+			continue
+		}
+
+		child.RunID = run.RunID
+		child.Status = run.Status
+		child.Reason = run.Reason
+		if !run.Status.IsTerminal() {
+			inFlight++
+		}
+	}
+
+	batch.Counts = make(map[models.RunStatus]int)
+	done, succeeded := true, true
+	for _, child := range batch.Runs {
+		batch.Counts[child.Status]++
+		if !child.Status.IsTerminal() {
+			done = false
+		}
+		if child.Status != models.StatusSucceeded {
+			succeeded = false
+		}
+	}
+	if done {
+		now := time.Now()
+		batch.FinishedAt = &now
+		batch.Status = models.BatchFailed
+		if succeeded {
+			batch.Status = models.BatchSucceeded
+		}
+		logger.Info("service: batch finished",
+			"batch_id", batch.BatchID,
+			"status", batch.Status)
+	}
+
+	if err := s.batches.put(batch); err != nil {
+		return nil, fmt.Errorf("persist batch: %w", err)
+	}
+	return batch, nil
+}
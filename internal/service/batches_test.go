@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestExpandBatch_Matrix(t *testing.T) {
+	sets, err := expandBatch(BatchRequest{
+		Parameters: map[string]interface{}{"ref": "main"},
+		Matrix: map[string][]interface{}{
+			"service": {"api", "web"},
+			"region":  {"eu", "us", "ap"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expandBatch() error = %v", err)
+	}
+	if len(sets) != 6 {
+		t.Fatalf("len(sets) = %d, want 6", len(sets))
+	}
+	// Keys are expanded in sorted order with the last key varying fastest
+	if sets[0]["region"] != "eu" || sets[0]["service"] != "api" || sets[1]["service"] != "web" || sets[0]["ref"] != "main" {
+		t.Errorf("sets[0:2] = %v, want region=eu with service api then web, plus common ref", sets[:2])
+	}
+}
+
+func TestExpandBatch_Invalid(t *testing.T) {
+	tests := []BatchRequest{
+		{},
+		{Matrix: map[string][]interface{}{"a": {}}},
+		{Matrix: map[string][]interface{}{"a": {1}}, ParameterSets: []map[string]interface{}{{}}},
+		{ParameterSets: []map[string]interface{}{{}}, MaxParallel: -1},
+	}
+	for i, req := range tests {
+		if _, err := expandBatch(req); !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("case %d: error = %v, want ErrInvalidBatch", i, err)
+		}
+	}
+}
+
+func TestTriggerBatch_MaxParallel(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("deploy"))
+
+	batch, err := svc.TriggerBatch(ctx, "deploy", BatchRequest{
+		ParameterSets: []map[string]interface{}{{"region": "eu"}, {"region": "us"}, {"region": "ap"}},
+		MaxParallel:   2,
+	})
+	if err != nil {
+		t.Fatalf("TriggerBatch() error = %v", err)
+	}
+	if len(prov.triggers) != 2 || batch.Runs[2].RunID != "" {
+		t.Fatalf("triggers = %d, want 2 with the third child waiting", len(prov.triggers))
+	}
+
+	prov.finish(1, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	svc.advanceBatches(ctx)
+	if len(prov.triggers) != 3 || prov.triggers[2].Parameters["region"] != "ap" {
+		t.Fatalf("triggers = %+v, want third child triggered", prov.triggers)
+	}
+
+	prov.finish(2, models.StatusSucceeded)
+	prov.finish(3, models.StatusFailed)
+	svc.refreshActive(ctx, "")
+	batch, err = svc.GetBatch(ctx, batch.BatchID)
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	if batch.Status != models.BatchFailed || batch.Counts[models.StatusSucceeded] != 2 || batch.Counts[models.StatusFailed] != 1 {
+		t.Errorf("status = %q, counts = %v; want failed with 2 succeeded, 1 failed", batch.Status, batch.Counts)
+	}
+}
+
+func TestTriggerBatch_RateLimited(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("deploy"))
+
+	var charged []string
+	svc.allowRuns = func(ctx context.Context, jobIDs []string) (bool, time.Duration) {
+		charged = jobIDs
+		return false, 30 * time.Second
+	}
+
+	_, err := svc.TriggerBatch(ctx, "deploy", BatchRequest{
+		ParameterSets: []map[string]interface{}{{"n": 1}, {"n": 2}, {"n": 3}},
+	})
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.RetryAfter != 30*time.Second || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("TriggerBatch() error = %v, want RateLimitError", err)
+	}
+	if len(charged) != 3 || charged[0] != "deploy" {
+		t.Errorf("charged job IDs = %v, want one per run", charged)
+	}
+	if len(prov.triggers) != 0 {
+		t.Errorf("provider triggers = %d, want none", len(prov.triggers))
+	}
+}
+
+func TestTriggerBatch_ChargedPerChild(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	job := testJob("deploy")
+	job.Parameters = []models.JobParameter{{Name: "region", Type: models.ParamString}}
+	svc := newTestService(t, prov, job)
+
+	// The budget covers the first wave only
+	var charged []string
+	budget := 2
+	svc.allowRuns = func(ctx context.Context, jobIDs []string) (bool, time.Duration) {
+		if len(jobIDs) > budget {
+			return false, time.Minute
+		}
+		budget -= len(jobIDs)
+		charged = append(charged, jobIDs...)
+		return true, 0
+	}
+
+	// Invalid parameters are rejected before anything is charged
+	if _, err := svc.TriggerBatch(ctx, "deploy", BatchRequest{
+		ParameterSets: []map[string]interface{}{{"region": 1}},
+	}); err == nil || len(charged) != 0 {
+		t.Fatalf("invalid batch error = %v, charged = %v; want rejection without a charge", err, charged)
+	}
+
+	batch, err := svc.TriggerBatch(ctx, "deploy", BatchRequest{
+		ParameterSets: []map[string]interface{}{{"region": "eu"}, {"region": "us"}, {"region": "ap"}},
+		MaxParallel:   2,
+	})
+	if err != nil {
+		t.Fatalf("TriggerBatch() error = %v", err)
+	}
+	if len(charged) != 2 || len(prov.triggers) != 2 {
+		t.Fatalf("charged = %v, triggers = %d; want the first two children", charged, len(prov.triggers))
+	}
+
+	// The third child waits for the budget, not for a slot
+	prov.finish(1, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	if batch, _ = svc.GetBatch(ctx, batch.BatchID); batch.Runs[2].RunID != "" || batch.Runs[2].Reason != "waiting for rate limit budget" {
+		t.Fatalf("third child = %+v, want waiting for the rate limit", batch.Runs[2])
+	}
+
+	budget = 1
+	svc.advanceBatches(ctx)
+	if len(charged) != 3 || len(prov.triggers) != 3 {
+		t.Errorf("charged = %v, triggers = %d; want the third child once the budget refilled", charged, len(prov.triggers))
+	}
+}
@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// triggerLimited applies the job's concurrency policy before dispatching.
+// Slots are counted per job and environment. Provider calls are made outside
+// concurrencyMu; a reserved slot keeps the count right while they run.
+func (s *Service) triggerLimited(ctx context.Context, job *models.Job, rec *runRecord) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+	limit := job.Concurrency
+	key := slotKey(job.JobID, rec.Environment)
+
+	s.refreshActive(ctx, job.JobID)
+
+	s.concurrencyMu.Lock()
+	active := s.runs.list(func(r *runRecord) bool { return r.inSlot(job.JobID, rec.Environment) && r.isActive() })
+	queued := s.runs.list(func(r *runRecord) bool { return r.inSlot(job.JobID, rec.Environment) && r.GatewayStatus.IsHeld() })
+	used := len(active) + s.reserved[key]
+	full := used >= limit.MaxConcurrent
+
+	logger.Debug("service: checking concurrency",
+		"job_id", job.JobID,
+		"environment", rec.Environment,
+		"policy", limit.Policy,
+		"max_concurrent", limit.MaxConcurrent,
+		"active", len(active),
+		"reserved", s.reserved[key],
+		"queued", len(queued))
+
+	var victims []*runRecord
+	switch limit.Policy {
+	case models.PolicyReject:
+		if full {
+			s.concurrencyMu.Unlock()
+			logger.Info("service: trigger rejected by concurrency policy",
+				"job_id", job.JobID,
+				"active", len(active))
+			return nil, ErrConcurrencyLimit
+		}
+
+	case models.PolicyCancelPrevious:
+		s.supersedeQueued(ctx, queued)
+		// Cancel the oldest active runs until a slot is free
+		if full {
+			n := used - limit.MaxConcurrent + 1
+			if n > len(active) {
+				n = len(active)
+			}
+			victims = active[:n]
+		}
+
+	case models.PolicySupersedePending:
+		if full || len(queued) > 0 {
+			s.supersedeQueued(ctx, queued)
+			defer s.concurrencyMu.Unlock()
+			return s.enqueue(ctx, rec)
+		}
+
+	default: // models.PolicyQueue
+		if full || len(queued) > 0 {
+			defer s.concurrencyMu.Unlock()
+			return s.enqueue(ctx, rec)
+		}
+	}
+	if len(victims) == 0 {
+		s.reserveSlot(key)
+	}
+	s.concurrencyMu.Unlock()
+
+	if len(victims) > 0 {
+		for _, prev := range victims {
+			if err := s.cancelForSlot(ctx, prev); err != nil {
+				// Dispatching anyway would exceed max_concurrent
+				logger.Warn("service: holding run, previous run could not be canceled",
+					"job_id", job.JobID,
+					"run_id", prev.ID)
+				return s.enqueue(ctx, rec)
+			}
+		}
+		if !s.reserveFreeSlot(job, rec.Environment) {
+			return s.enqueue(ctx, rec)
+		}
+	}
+	defer s.releaseSlot(key)
+
+	return s.dispatch(ctx, job, rec)
+}
+
+// slotKey identifies the concurrency slots of a job in an environment
+func slotKey(jobID, environment string) string {
+	return jobID + "/" + environment
+}
+
+// reserveSlot holds a slot for a dispatch in flight. The caller holds concurrencyMu.
+func (s *Service) reserveSlot(key string) {
+	if s.reserved == nil {
+		s.reserved = make(map[string]int)
+	}
+	s.reserved[key]++
+}
+
+// reserveFreeSlot reserves a slot if the job has one free in the environment
+func (s *Service) reserveFreeSlot(job *models.Job, environment string) bool {
+	s.concurrencyMu.Lock()
+	defer s.concurrencyMu.Unlock()
+
+	key := slotKey(job.JobID, environment)
+	active := s.runs.list(func(r *runRecord) bool { return r.inSlot(job.JobID, environment) && r.isActive() })
+	if len(active)+s.reserved[key] >= job.Concurrency.MaxConcurrent {
+		return false
+	}
+	s.reserveSlot(key)
+	return true
+}
+
+// releaseSlot frees a slot reserved for a dispatch once the run is recorded
+func (s *Service) releaseSlot(key string) {
+	s.concurrencyMu.Lock()
+	defer s.concurrencyMu.Unlock()
+
+	if s.reserved[key] <= 1 {
+		delete(s.reserved, key)
+		return
+	}
+	s.reserved[key]--
+}
+
+// supersedeQueued ends held runs replaced by a newer trigger
+func (s *Service) supersedeQueued(ctx context.Context, queued []*runRecord) {
+	for _, held := range queued {
+		if err := s.finishHeld(held.ID, models.GatewayStatusSuperseded, "superseded by a newer trigger"); err != nil {
+			s.getLogger(ctx).Error("service: failed to supersede queued run", "run_id", held.ID, "error", err)
+		}
+	}
+}
+
+// enqueue holds a run in the gateway until a slot frees up
+func (s *Service) enqueue(ctx context.Context, rec *runRecord) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+
+	if rec.ID == "" {
+		rec.ID = newGatewayRunID()
+	}
+	rec.GatewayStatus = models.GatewayStatusQueued
+	rec.Status = models.StatusQueued
+	if err := s.runs.put(rec); err != nil {
+		return nil, fmt.Errorf("persist queued run: %w", err)
+	}
+
+	run := rec.toRun()
+	run.QueuePosition = s.queuePosition(rec)
+
+	logger.Info("service: run queued by gateway",
+		"job_id", rec.JobID,
+		"run_id", rec.ID,
+		"queue_position", run.QueuePosition)
+
+	return run, nil
+}
+
+// cancelForSlot cancels an active run to make room for a newer one
+func (s *Service) cancelForSlot(ctx context.Context, rec *runRecord) error {
+	logger := s.getLogger(ctx)
+
+	runRef, err := s.parseRunRef(rec.ProviderRunID)
+	if err == nil {
+		err = s.provider.Cancel(ctx, runRef)
+	}
+	if err != nil {
+		logger.Error("service: failed to cancel previous run",
+			"job_id", rec.JobID,
+			"run_id", rec.ID,
+			"error", err)
+		return err
+	}
+
+	now := time.Now()
+	if _, err := s.runs.update(rec.ID, func(r *runRecord) {
+		r.Status = models.StatusCanceled
+		r.Reason = "canceled in favor of a newer trigger"
+		r.CancelReason = models.CancelReasonSuperseded
+		r.NoRetry = true
+		r.FinishedAt = &now
+	}); err != nil {
+		logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+	}
+
+	logger.Info("service: previous run canceled for new trigger",
+		"job_id", rec.JobID,
+		"run_id", rec.ID)
+	return nil
+}
+
+// finishHeld ends a held run without dispatching it
+func (s *Service) finishHeld(id string, status models.GatewayStatus, reason string) error {
+	return s.finishHeldAs(id, status, models.StatusCanceled, reason)
+}
+
+// queuePosition returns the 1-based position of a held run within its job's
+// queue for the run's environment
+func (s *Service) queuePosition(rec *runRecord) int {
+	queued := s.runs.list(func(r *runRecord) bool { return r.inSlot(rec.JobID, rec.Environment) && r.GatewayStatus.IsHeld() })
+	for i, r := range queued {
+		if r.ID == rec.ID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// trackRuns periodically reconciles gateway state with the provider
+func (s *Service) trackRuns(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	s.logger.Info("service: run tracker started", "poll_interval", s.pollInterval)
+
+	for {
+		s.reconcile(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("service: run tracker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile refreshes active runs, aborts timed-out runs, restores pins, expires
+// pending approvals, schedules retries, dispatches queued runs, advances workflows
+// and batches, refreshes pause state and prunes old records
+func (s *Service) reconcile(ctx context.Context) {
+	s.refreshActive(ctx, "")
+	s.enforceTimeouts(ctx, time.Now())
+	s.restorePins(ctx)
+	s.expireApprovals(ctx, time.Now())
+	s.scheduleRetries(ctx, time.Now())
+	s.dispatchQueued(ctx)
+	s.advanceWorkflows(ctx)
+	s.advanceBatches(ctx)
+	s.refreshPauses(ctx, time.Now())
+
+	if err := s.runs.prune(time.Now().Add(-runRetention)); err != nil {
+		s.logger.Error("service: failed to prune run records", "error", err)
+	}
+	if err := s.workflowRuns.prune(time.Now().Add(-runRetention)); err != nil {
+		s.logger.Error("service: failed to prune workflow runs", "error", err)
+	}
+	if err := s.batches.prune(time.Now().Add(-runRetention)); err != nil {
+		s.logger.Error("service: failed to prune batches", "error", err)
+	}
+	if err := s.freezes.prune(time.Now().Add(-runRetention), time.Now().Add(-overrideRetention)); err != nil {
+		s.logger.Error("service: failed to prune freezes", "error", err)
+	}
+	if err := s.pauseEvents.prune(time.Now().Add(-pauseRetention)); err != nil {
+		s.logger.Error("service: failed to prune pause events", "error", err)
+	}
+}
+
+// refreshActive updates active run records from the provider.
+// An empty jobID refreshes all jobs.
+func (s *Service) refreshActive(ctx context.Context, jobID string) {
+	logger := s.getLogger(ctx)
+
+	active := s.runs.list(func(r *runRecord) bool {
+		return r.isActive() && (jobID == "" || r.JobID == jobID)
+	})
+
+	for _, rec := range active {
+		runRef, err := s.parseRunRef(rec.ProviderRunID)
+		if err != nil {
+			logger.Warn("service: tracked run has invalid provider run_id",
+				"run_id", rec.ID,
+				"provider_run_id", rec.ProviderRunID)
+			continue
+		}
+
+		providerRun, err := s.provider.GetRun(ctx, runRef)
+		if err != nil {
+			logger.Warn("service: failed to refresh tracked run", "run_id", rec.ID, "error", err)
+			continue
+		}
+
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) { s.recordProviderRun(r, providerRun) }); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+	}
+}
+
+// dispatchQueued dispatches held runs for every job and environment with free slots
+func (s *Service) dispatchQueued(ctx context.Context) {
+	queued := s.runs.list(func(r *runRecord) bool { return r.GatewayStatus.IsHeld() })
+	seen := make(map[string]bool)
+
+	for _, rec := range queued {
+		key := slotKey(rec.JobID, rec.Environment)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s.dispatchJobQueue(ctx, rec.JobID, rec.Environment)
+	}
+}
+
+// dispatchJobQueue dispatches the oldest held runs of a job in an environment
+// while slots are free. Slots are reserved under concurrencyMu and the runs
+// dispatched outside it.
+func (s *Service) dispatchJobQueue(ctx context.Context, jobID, environment string) {
+	logger := s.getLogger(ctx)
+	key := slotKey(jobID, environment)
+
+	s.concurrencyMu.Lock()
+	queued := s.runs.list(func(r *runRecord) bool { return r.inSlot(jobID, environment) && r.GatewayStatus.IsHeld() })
+	if len(queued) == 0 {
+		s.concurrencyMu.Unlock()
+		return
+	}
+
+	job, exists := s.job(jobID)
+	if !exists {
+		s.concurrencyMu.Unlock()
+		for _, rec := range queued {
+			if err := s.finishHeld(rec.ID, models.GatewayStatusDispatchFailed, "job no longer configured"); err != nil {
+				logger.Error("service: failed to drop queued run", "run_id", rec.ID, "error", err)
+			}
+		}
+		return
+	}
+
+	// Retries wait for their backoff; they keep their place but don't block other runs
+	now := time.Now()
+	ready := queued[:0]
+	for _, rec := range queued {
+		if rec.isReady(now) {
+			ready = append(ready, rec)
+		}
+	}
+	queued = ready
+
+	free := len(queued)
+	if job.Concurrency != nil {
+		active := s.runs.list(func(r *runRecord) bool { return r.inSlot(jobID, environment) && r.isActive() })
+		free = job.Concurrency.MaxConcurrent - len(active) - s.reserved[key]
+	}
+	if free > len(queued) {
+		free = len(queued)
+	}
+	if free <= 0 {
+		s.concurrencyMu.Unlock()
+		return
+	}
+	queued = queued[:free]
+	for range queued {
+		s.reserveSlot(key)
+	}
+	s.concurrencyMu.Unlock()
+
+	for _, rec := range queued {
+		s.dispatchHeld(ctx, job, rec)
+		s.releaseSlot(key)
+	}
+}
+
+// dispatchHeld dispatches a held run, ending it when the provider refuses it
+func (s *Service) dispatchHeld(ctx context.Context, job *models.Job, rec *runRecord) {
+	logger := s.getLogger(ctx)
+	logger.Info("service: dispatching queued run", "job_id", job.JobID, "run_id", rec.ID)
+
+	if _, err := s.dispatch(ctx, job, rec); err != nil {
+		if errors.Is(err, ErrVersionPinBusy) {
+			// Stay queued until the earlier run has started and released its pins
+			logger.Debug("service: queued run waiting for pinned versions", "job_id", job.JobID, "run_id", rec.ID)
+			return
+		}
+		if errors.Is(err, ErrJobFrozen) {
+			// Stay queued until the freeze ends
+			logger.Debug("service: queued run waiting for freeze", "job_id", job.JobID, "run_id", rec.ID)
+			return
+		}
+		logger.Error("service: failed to dispatch queued run",
+			"job_id", job.JobID,
+			"run_id", rec.ID,
+			"error", err)
+		if rec.ProviderRunID == "" {
+			if err := s.finishHeldAs(rec.ID, models.GatewayStatusDispatchFailed, models.StatusErrored, err.Error()); err != nil {
+				logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+			}
+		}
+	}
+}
+
+// finishHeldAs ends a held run with an explicit run status
+func (s *Service) finishHeldAs(id string, gatewayStatus models.GatewayStatus, status models.RunStatus, reason string) error {
+	now := time.Now()
+	_, err := s.runs.update(id, func(r *runRecord) {
+		r.GatewayStatus = gatewayStatus
+		r.Status = status
+		r.Reason = reason
+		r.FinishedAt = &now
+		switch gatewayStatus {
+		case models.GatewayStatusCanceled:
+			r.CancelReason = models.CancelReasonUser
+		case models.GatewayStatusSuperseded:
+			r.CancelReason = models.CancelReasonSuperseded
+		case models.GatewayStatusRejected:
+			r.CancelReason = models.CancelReasonRejected
+		case models.GatewayStatusExpired:
+			r.CancelReason = models.CancelReasonExpired
+		}
+	})
+	return err
+}
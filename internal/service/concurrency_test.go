@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+func limitedJob(policy models.ConcurrencyPolicy) *models.Job {
+	job := testJob("job_deploy")
+	job.Concurrency = &models.JobConcurrency{MaxConcurrent: 1, Policy: policy}
+	return job
+}
+
+func TestTriggerRun_QueuePolicy(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("first trigger error = %v", err)
+	}
+	if first.GatewayStatus != models.GatewayStatusDispatched {
+		t.Fatalf("first gateway_status = %q, want dispatched", first.GatewayStatus)
+	}
+
+	second, err := svc.TriggerRun(ctx, "job_deploy", map[string]interface{}{"n": 2}, "")
+	if err != nil {
+		t.Fatalf("second trigger error = %v", err)
+	}
+	if second.GatewayStatus != models.GatewayStatusQueued || second.QueuePosition != 1 {
+		t.Fatalf("second run = %+v, want queued at position 1", second)
+	}
+
+	// Slot still taken: nothing dispatched
+	svc.reconcile(ctx)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("provider triggers = %d, want 1", len(prov.triggers))
+	}
+
+	prov.finish(1, models.StatusSucceeded)
+	svc.reconcile(ctx)
+	if len(prov.triggers) != 2 || prov.triggers[1].Parameters["n"] != 2 {
+		t.Fatalf("queued run not dispatched with its parameters: %+v", prov.triggers)
+	}
+
+	run, err := svc.GetRun(ctx, second.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.RunID != second.RunID || run.ProviderRunID != "main:p:job_deploy:2" {
+		t.Errorf("GetRun() = %+v, want gateway id linked to provider run", run)
+	}
+}
+
+func TestTriggerRun_RejectPolicy(t *testing.T) {
+	svc := newTestService(t, newFakeProvider(), limitedJob(models.PolicyReject))
+	ctx := context.Background()
+
+	if _, err := svc.TriggerRun(ctx, "job_deploy", nil, ""); err != nil {
+		t.Fatalf("first trigger error = %v", err)
+	}
+	if _, err := svc.TriggerRun(ctx, "job_deploy", nil, ""); !errors.Is(err, ErrConcurrencyLimit) {
+		t.Fatalf("second trigger error = %v, want ErrConcurrencyLimit", err)
+	}
+}
+
+func TestTriggerRun_CancelPreviousPolicy(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyCancelPrevious))
+	ctx := context.Background()
+
+	svc.TriggerRun(ctx, "job_deploy", nil, "")
+	second, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("second trigger error = %v", err)
+	}
+	if second.GatewayStatus != models.GatewayStatusDispatched {
+		t.Errorf("second gateway_status = %q, want dispatched", second.GatewayStatus)
+	}
+	if len(prov.canceled) != 1 || prov.canceled[0] != 1 {
+		t.Errorf("canceled builds = %v, want [1]", prov.canceled)
+	}
+}
+
+func TestTriggerRun_SupersedePendingPolicy(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicySupersedePending))
+	ctx := context.Background()
+
+	svc.TriggerRun(ctx, "job_deploy", nil, "")
+	pending, _ := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	newest, _ := svc.TriggerRun(ctx, "job_deploy", nil, "")
+
+	run, err := svc.GetRun(ctx, pending.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusSuperseded {
+		t.Errorf("pending gateway_status = %q, want superseded", run.GatewayStatus)
+	}
+	if newest.GatewayStatus != models.GatewayStatusQueued || newest.QueuePosition != 1 {
+		t.Errorf("newest run = %+v, want queued at position 1", newest)
+	}
+}
+
+func TestRunStore_PersistsQueue(t *testing.T) {
+	dir := t.TempDir()
+	prov := newFakeProvider()
+	ctx := context.Background()
+
+	job := limitedJob(models.PolicyQueue)
+	log := logger.New("error", "text")
+	svc, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	svc.TriggerRun(ctx, "job_deploy", nil, "")
+	queued, _ := svc.TriggerRun(ctx, "job_deploy", nil, "")
+
+	restarted, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	run, err := restarted.GetRun(ctx, queued.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() after restart error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusQueued {
+		t.Errorf("gateway_status after restart = %q, want queued", run.GatewayStatus)
+	}
+}
+
+func TestTriggerRun_CancelPreviousHoldsWhenCancelFails(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyCancelPrevious))
+	ctx := context.Background()
+
+	svc.TriggerRun(ctx, "job_deploy", nil, "")
+	prov.cancelErr = errors.New("concourse unavailable")
+
+	second, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("second trigger error = %v", err)
+	}
+	if second.GatewayStatus != models.GatewayStatusQueued {
+		t.Errorf("second gateway_status = %q, want queued", second.GatewayStatus)
+	}
+	if len(prov.triggers) != 1 {
+		t.Fatalf("provider triggers = %d, want 1", len(prov.triggers))
+	}
+
+	// The held run goes out once the previous run has finished
+	prov.finish(1, models.StatusSucceeded)
+	svc.reconcile(ctx)
+	if len(prov.triggers) != 2 {
+		t.Errorf("provider triggers after finish = %d, want 2", len(prov.triggers))
+	}
+}
+
+func TestTriggerRun_ConcurrencyPerEnvironment(t *testing.T) {
+	prov := newFakeProvider()
+	job := limitedJob(models.PolicyReject)
+	job.Environment = "staging"
+	svc := newTestService(t, prov, job)
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("first trigger error = %v", err)
+	}
+	// A run of the same job in another environment holds a separate slot
+	if _, err := svc.runs.update(first.RunID, func(r *runRecord) { r.Environment = "production" }); err != nil {
+		t.Fatalf("update record error = %v", err)
+	}
+
+	if _, err := svc.TriggerRun(ctx, "job_deploy", nil, ""); err != nil {
+		t.Fatalf("staging trigger error = %v", err)
+	}
+	if _, err := svc.TriggerRun(ctx, "job_deploy", nil, ""); !errors.Is(err, ErrConcurrencyLimit) {
+		t.Fatalf("second staging trigger error = %v, want ErrConcurrencyLimit", err)
+	}
+}
+
+// blockingProvider holds triggers of one job until released
+type blockingProvider struct {
+	*fakeProvider
+	job     string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) Trigger(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams) (provider.RunRef, error) {
+	if jobRef.(*concourse.ConcourseJobRef).Job == b.job {
+		close(b.entered)
+		<-b.release
+	}
+	return b.fakeProvider.Trigger(ctx, jobRef, params)
+}
+
+func TestTriggerRun_ConcurrencyDispatchOutsideLock(t *testing.T) {
+	prov := &blockingProvider{
+		fakeProvider: newFakeProvider(),
+		job:          "job_slow",
+		entered:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	slow := testJob("job_slow")
+	slow.Concurrency = &models.JobConcurrency{MaxConcurrent: 1, Policy: models.PolicyQueue}
+	svc := newTestService(t, prov, slow, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := svc.TriggerRun(ctx, "job_slow", nil, "")
+		slowDone <- err
+	}()
+	<-prov.entered
+
+	// A slow provider call for one job doesn't stall triggers of others
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("trigger error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("trigger blocked behind another job's dispatch")
+	}
+
+	// The slot of the slow dispatch stays reserved while it runs
+	queued, err := svc.TriggerRun(ctx, "job_slow", nil, "")
+	if err != nil {
+		t.Fatalf("second slow trigger error = %v", err)
+	}
+	if queued.GatewayStatus != models.GatewayStatusQueued {
+		t.Errorf("second slow gateway_status = %q, want queued", queued.GatewayStatus)
+	}
+
+	close(prov.release)
+	if err := <-slowDone; err != nil {
+		t.Fatalf("slow trigger error = %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lei/simple-ci/internal/discovery"
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// ErrInvalidImport indicates discovery import options are malformed
+var ErrInvalidImport = errors.New("invalid import")
+
+// ImportJobs walks the provider's teams, pipelines and jobs and compares them
+// with the jobs in effect. Unless dryRun is set, discovered jobs without a
+// definition are registered through the job management API, which requires
+// the job_admin scope; registration stops at the first failure.
+func (s *Service) ImportJobs(ctx context.Context, opts discovery.Options, dryRun bool) (*discovery.Result, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: importing jobs",
+		"teams", opts.Teams,
+		"pipelines", opts.Pipelines,
+		"dry_run", dryRun)
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return nil, fmt.Errorf("provider does not support job discovery")
+	}
+
+	if err := opts.Check(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if !dryRun && !callerHasScope(ctx, models.ScopeJobAdmin) {
+		return nil, ErrJobAdminScopeRequired
+	}
+
+	result, err := discovery.Import(ctx, adapter, s.ListJobs(ctx), opts)
+	if err != nil {
+		logger.Error("service: failed to discover jobs", "error", err)
+		return nil, fmt.Errorf("discover jobs: %w", err)
+	}
+
+	logger.Info("service: jobs discovered",
+		"added", len(result.Added),
+		"unchanged", len(result.Unchanged),
+		"stale", len(result.Stale),
+		"conflicts", len(result.Conflicts),
+		"dry_run", dryRun)
+
+	if dryRun {
+		return result, nil
+	}
+
+	for i, job := range result.Added {
+		saved, err := s.CreateJob(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("register %s after %d of %d jobs: %w", job.JobID, i, len(result.Added), err)
+		}
+		result.Added[i] = saved
+	}
+	return result, nil
+}
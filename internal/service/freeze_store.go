@@ -0,0 +1,143 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/freeze"
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// overrideRetention is how long freeze override audit entries are kept
+const overrideRetention = 90 * 24 * time.Hour
+
+// freezeState is the persisted form of the freeze store
+type freezeState struct {
+	Freezes   []*models.Freeze        `json:"freezes"`
+	Overrides []models.FreezeOverride `json:"overrides"`
+}
+
+// freezeStore keeps ad-hoc freezes and the override audit log, persisted to
+// freezes.json in the state directory
+type freezeStore struct {
+	mu        sync.Mutex
+	file      *store.File
+	state     freezeState
+	schedules []*freeze.Schedule // state.Freezes compiled, in the same order
+}
+
+// newFreezeStore creates a freeze store backed by freezes.json in dir
+func newFreezeStore(dir string) (*freezeStore, error) {
+	fs := &freezeStore{file: store.NewFile(dir, "freezes.json")}
+	if err := fs.file.Load(&fs.state); err != nil {
+		return nil, err
+	}
+	for _, f := range fs.state.Freezes {
+		sch, err := freeze.Compile(f)
+		if err != nil {
+			return nil, fmt.Errorf("freeze %s: %w", f.FreezeID, err)
+		}
+		fs.schedules = append(fs.schedules, sch)
+	}
+	return fs, nil
+}
+
+// newFreezeID generates an ID for an ad-hoc freeze
+func newFreezeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "freeze-" + hex.EncodeToString(b)
+}
+
+// list returns the compiled ad-hoc freezes in creation order. Callers must
+// copy a schedule's Freeze before changing it.
+func (fs *freezeStore) list() []*freeze.Schedule {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return append([]*freeze.Schedule(nil), fs.schedules...)
+}
+
+// add stores a new compiled freeze and persists the store
+func (fs *freezeStore) add(sch *freeze.Schedule) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	cp, f := *sch, *sch.Freeze
+	cp.Freeze = &f
+	fs.state.Freezes = append(fs.state.Freezes, &f)
+	fs.schedules = append(fs.schedules, &cp)
+	return fs.file.Save(fs.state)
+}
+
+// remove deletes a freeze. Returns false if it doesn't exist.
+func (fs *freezeStore) remove(id string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for i, f := range fs.state.Freezes {
+		if f.FreezeID == id {
+			fs.state.Freezes = append(fs.state.Freezes[:i], fs.state.Freezes[i+1:]...)
+			fs.schedules = append(fs.schedules[:i], fs.schedules[i+1:]...)
+			return true, fs.file.Save(fs.state)
+		}
+	}
+	return false, nil
+}
+
+// recordOverride appends an entry to the override audit log
+func (fs *freezeStore) recordOverride(o models.FreezeOverride) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.state.Overrides = append(fs.state.Overrides, o)
+	return fs.file.Save(fs.state)
+}
+
+// overrides returns the override audit log, oldest first
+func (fs *freezeStore) overrides() []models.FreezeOverride {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return append([]models.FreezeOverride(nil), fs.state.Overrides...)
+}
+
+// prune drops ad-hoc freezes that ended before freezeCutoff and audit
+// entries older than overrideCutoff
+func (fs *freezeStore) prune(freezeCutoff, overrideCutoff time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	removed := false
+	freezes := fs.state.Freezes[:0]
+	schedules := fs.schedules[:0]
+	for i, f := range fs.state.Freezes {
+		if f.End != nil && f.End.Before(freezeCutoff) {
+			removed = true
+			continue
+		}
+		freezes = append(freezes, f)
+		schedules = append(schedules, fs.schedules[i])
+	}
+	fs.state.Freezes = freezes
+	fs.schedules = schedules
+
+	overrides := fs.state.Overrides[:0]
+	for _, o := range fs.state.Overrides {
+		if o.At.Before(overrideCutoff) {
+			removed = true
+			continue
+		}
+		overrides = append(overrides, o)
+	}
+	fs.state.Overrides = overrides
+
+	if !removed {
+		return nil
+	}
+	return fs.file.Save(fs.state)
+}
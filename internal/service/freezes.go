@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lei/simple-ci/internal/freeze"
+	"github.com/lei/simple-ci/internal/models"
+)
+
+var (
+	// ErrJobFrozen indicates an active freeze blocks triggers of the job
+	ErrJobFrozen = errors.New("job is frozen")
+	// ErrFreezeNotFound indicates the requested freeze doesn't exist
+	ErrFreezeNotFound = errors.New("freeze not found")
+	// ErrFreezeReadOnly indicates the freeze comes from configuration and can't be lifted through the API
+	ErrFreezeReadOnly = errors.New("freeze is defined in the jobs configuration")
+	// ErrFreezeOverrideScopeRequired indicates the caller may not create or lift freezes
+	ErrFreezeOverrideScopeRequired = errors.New("freeze_override scope required")
+	// ErrInvalidFreeze indicates an ad-hoc freeze request is malformed
+	ErrInvalidFreeze = errors.New("invalid freeze")
+)
+
+// FreezeError reports the freeze that blocked a trigger
+type FreezeError struct {
+	JobID  string
+	Freeze *models.Freeze
+}
+
+func (e *FreezeError) Error() string {
+	msg := fmt.Sprintf("job %s is frozen by %s", e.JobID, e.Freeze.FreezeID)
+	if e.Freeze.ActiveUntil != nil {
+		msg += " until " + e.Freeze.ActiveUntil.Format(time.RFC3339)
+	}
+	if e.Freeze.Reason != "" {
+		msg += ": " + e.Freeze.Reason
+	}
+	return msg
+}
+
+func (e *FreezeError) Unwrap() error {
+	return ErrJobFrozen
+}
+
+// FreezeRequest describes an ad-hoc freeze created through the API
+type FreezeRequest struct {
+	Reason       string
+	Projects     []string
+	Environments []string
+	Start        *time.Time // Defaults to now
+	End          *time.Time // Open-ended until lifted when unset
+}
+
+// ListFreezes returns configured and ad-hoc freezes with their current state,
+// and the override audit log
+func (s *Service) ListFreezes(ctx context.Context) ([]*models.Freeze, []models.FreezeOverride) {
+	freezes := s.allFreezes(time.Now())
+	s.getLogger(ctx).Debug("service: listing freezes", "count", len(freezes))
+	return freezes, s.freezes.overrides()
+}
+
+// allFreezes returns copies of every freeze with Active/ActiveUntil evaluated at now
+func (s *Service) allFreezes(now time.Time) []*models.Freeze {
+	schedules := slices.Concat(s.definitions().freezes, s.freezes.list())
+	freezes := make([]*models.Freeze, 0, len(schedules))
+	for _, sch := range schedules {
+		cp := *sch.Freeze
+		cp.Active, cp.ActiveUntil = sch.Window(now)
+		freezes = append(freezes, &cp)
+	}
+	return freezes
+}
+
+// CreateFreeze adds an ad-hoc freeze
+func (s *Service) CreateFreeze(ctx context.Context, req FreezeRequest) (*models.Freeze, error) {
+	logger := s.getLogger(ctx)
+	now := time.Now()
+
+	if !callerHasScope(ctx, models.ScopeFreezeOverride) {
+		return nil, ErrFreezeOverrideScopeRequired
+	}
+
+	f := &models.Freeze{
+		FreezeID:     newFreezeID(),
+		Reason:       req.Reason,
+		Projects:     req.Projects,
+		Environments: req.Environments,
+		Start:        req.Start,
+		End:          req.End,
+		Source:       models.FreezeSourceAPI,
+		CreatedBy:    callerName(ctx),
+	}
+	if f.Start == nil {
+		f.Start = &now
+	}
+	sch, err := freeze.Compile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFreeze, err)
+	}
+	if freeze.Expired(f, now) {
+		return nil, fmt.Errorf("%w: end is in the past", ErrInvalidFreeze)
+	}
+
+	if err := s.freezes.add(sch); err != nil {
+		return nil, fmt.Errorf("persist freeze: %w", err)
+	}
+
+	f.Active, f.ActiveUntil = sch.Window(now)
+
+	logger.Info("service: freeze created",
+		"freeze_id", f.FreezeID,
+		"created_by", f.CreatedBy,
+		"projects", f.Projects,
+		"environments", f.Environments,
+		"reason", f.Reason)
+
+	return f, nil
+}
+
+// DeleteFreeze lifts an ad-hoc freeze
+func (s *Service) DeleteFreeze(ctx context.Context, freezeID string) error {
+	logger := s.getLogger(ctx)
+
+	for _, sch := range s.definitions().freezes {
+		if sch.Freeze.FreezeID == freezeID {
+			return ErrFreezeReadOnly
+		}
+	}
+	if !callerHasScope(ctx, models.ScopeFreezeOverride) {
+		return ErrFreezeOverrideScopeRequired
+	}
+
+	removed, err := s.freezes.remove(freezeID)
+	if err != nil {
+		return fmt.Errorf("persist freeze: %w", err)
+	}
+	if !removed {
+		return ErrFreezeNotFound
+	}
+
+	logger.Warn("service: freeze lifted", "freeze_id", freezeID, "lifted_by", callerName(ctx))
+	return nil
+}
+
+// checkFreeze returns a *FreezeError if an active freeze blocks triggers of
+// the job. Callers with the freeze_override scope get an override to record
+// on the run instead.
+func (s *Service) checkFreeze(ctx context.Context, job *models.Job, now time.Time) (*models.FreezeOverride, error) {
+	f := s.activeFreeze(job, now)
+	if f == nil {
+		return nil, nil
+	}
+
+	if !callerHasScope(ctx, models.ScopeFreezeOverride) {
+		s.getLogger(ctx).Info("service: trigger blocked by freeze",
+			"job_id", job.JobID,
+			"freeze_id", f.FreezeID,
+			"caller", callerName(ctx))
+		return nil, &FreezeError{JobID: job.JobID, Freeze: f}
+	}
+
+	return &models.FreezeOverride{
+		FreezeID: f.FreezeID,
+		JobID:    job.JobID,
+		By:       callerName(ctx),
+		At:       now,
+	}, nil
+}
+
+// activeFreeze returns the first active freeze matching the job, if any
+func (s *Service) activeFreeze(job *models.Job, now time.Time) *models.Freeze {
+	for _, f := range s.allFreezes(now) {
+		if f.Active && freeze.Matches(f, job) {
+			return f
+		}
+	}
+	return nil
+}
+
+// auditFreezeOverride records a trigger let through a freeze
+func (s *Service) auditFreezeOverride(ctx context.Context, override *models.FreezeOverride, runID string) {
+	logger := s.getLogger(ctx)
+
+	entry := *override
+	entry.RunID = runID
+	logger.Warn("service: freeze overridden",
+		"freeze_id", entry.FreezeID,
+		"job_id", entry.JobID,
+		"run_id", entry.RunID,
+		"overridden_by", entry.By)
+
+	if err := s.freezes.recordOverride(entry); err != nil {
+		logger.Error("service: failed to persist freeze override", "run_id", runID, "error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+func TestTriggerRun_Frozen(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	end := time.Now().Add(time.Hour)
+	holidays := &models.Freeze{
+		FreezeID:     "holidays",
+		Reason:       "year-end freeze",
+		Environments: []string{"prod"},
+		Start:        &start,
+		End:          &end,
+		Source:       models.FreezeSourceConfig,
+	}
+
+	prov := newFakeProvider()
+	prod := testJob("deploy-prod")
+	prod.Environment = "prod"
+	staging := testJob("deploy-staging")
+	staging.Environment = "staging"
+
+	svc, err := NewService([]*models.Job{prod, staging}, prov, logger.New("error", "text"), Options{
+		StateDir: t.TempDir(),
+		Freezes:  []*models.Freeze{holidays},
+	})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	_, err = svc.TriggerRun(callerCtx("alice"), "deploy-prod", nil, "")
+	var freezeErr *FreezeError
+	if !errors.As(err, &freezeErr) || freezeErr.Freeze.FreezeID != "holidays" || !errors.Is(err, ErrJobFrozen) {
+		t.Fatalf("TriggerRun() error = %v, want FreezeError for holidays", err)
+	}
+
+	if _, err := svc.TriggerRun(callerCtx("alice"), "deploy-staging", nil, ""); err != nil {
+		t.Fatalf("TriggerRun() for unfrozen environment error = %v", err)
+	}
+
+	run, err := svc.TriggerRun(callerCtx("oncall", "freeze_override"), "deploy-prod", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() with override error = %v", err)
+	}
+	if run.FreezeOverride == nil || run.FreezeOverride.FreezeID != "holidays" || run.FreezeOverride.By != "oncall" {
+		t.Errorf("freeze_override = %+v, want holidays by oncall", run.FreezeOverride)
+	}
+
+	_, overrides := svc.ListFreezes(context.Background())
+	if len(overrides) != 1 || overrides[0].RunID != run.RunID || overrides[0].JobID != "deploy-prod" {
+		t.Errorf("overrides = %+v, want one entry for %s", overrides, run.RunID)
+	}
+
+	if err := svc.DeleteFreeze(callerCtx("oncall", "freeze_override"), "holidays"); !errors.Is(err, ErrFreezeReadOnly) {
+		t.Errorf("DeleteFreeze() on config freeze error = %v, want ErrFreezeReadOnly", err)
+	}
+}
+
+func TestAdHocFreeze(t *testing.T) {
+	prov := newFakeProvider()
+	job := testJob("deploy")
+	job.Project = "shop"
+	svc := newTestService(t, prov, job)
+
+	if _, err := svc.CreateFreeze(callerCtx("bob"), FreezeRequest{Reason: "incident 42"}); !errors.Is(err, ErrFreezeOverrideScopeRequired) {
+		t.Fatalf("CreateFreeze() without scope error = %v, want ErrFreezeOverrideScopeRequired", err)
+	}
+	incident, err := svc.CreateFreeze(callerCtx("alice", "freeze_override"), FreezeRequest{Reason: "incident 42", Projects: []string{"shop"}})
+	if err != nil {
+		t.Fatalf("CreateFreeze() error = %v", err)
+	}
+	if !incident.Active || incident.ActiveUntil != nil || incident.CreatedBy != "alice" {
+		t.Errorf("freeze = %+v, want active open-ended freeze by alice", incident)
+	}
+
+	if _, err := svc.TriggerRun(callerCtx("bob"), "deploy", nil, ""); !errors.Is(err, ErrJobFrozen) {
+		t.Fatalf("TriggerRun() error = %v, want ErrJobFrozen", err)
+	}
+
+	if err := svc.DeleteFreeze(callerCtx("bob"), incident.FreezeID); !errors.Is(err, ErrFreezeOverrideScopeRequired) {
+		t.Errorf("DeleteFreeze() without scope error = %v", err)
+	}
+	if err := svc.DeleteFreeze(callerCtx("alice", "freeze_override"), incident.FreezeID); err != nil {
+		t.Fatalf("DeleteFreeze() error = %v", err)
+	}
+	if _, err := svc.TriggerRun(callerCtx("bob"), "deploy", nil, ""); err != nil {
+		t.Errorf("TriggerRun() after lifting error = %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, err := svc.CreateFreeze(callerCtx("alice", "freeze_override"), FreezeRequest{End: &past}); !errors.Is(err, ErrInvalidFreeze) {
+		t.Errorf("CreateFreeze() ending in the past error = %v, want ErrInvalidFreeze", err)
+	}
+}
+
+func TestDispatchQueued_HeldDuringFreeze(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	if _, err := svc.TriggerRun(ctx, "job_deploy", nil, ""); err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	queued, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil || queued.GatewayStatus != models.GatewayStatusQueued {
+		t.Fatalf("TriggerRun() = %+v, %v; want queued", queued, err)
+	}
+	incident, err := svc.CreateFreeze(callerCtx("ops", "freeze_override"), FreezeRequest{Reason: "incident"})
+	if err != nil {
+		t.Fatalf("CreateFreeze() error = %v", err)
+	}
+
+	prov.finish(1, models.StatusSucceeded)
+	svc.reconcile(ctx)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("queued run dispatched during a freeze")
+	}
+	if run, _ := svc.GetRun(ctx, queued.RunID); run.GatewayStatus != models.GatewayStatusQueued {
+		t.Fatalf("gateway_status = %q, want queued", run.GatewayStatus)
+	}
+
+	if err := svc.DeleteFreeze(callerCtx("ops", "freeze_override"), incident.FreezeID); err != nil {
+		t.Fatalf("DeleteFreeze() error = %v", err)
+	}
+	svc.reconcile(ctx)
+	if run, _ := svc.GetRun(ctx, queued.RunID); run.GatewayStatus != models.GatewayStatusDispatched || len(prov.triggers) != 2 {
+		t.Errorf("gateway_status = %q with %d triggers, want dispatched after the freeze", run.GatewayStatus, len(prov.triggers))
+	}
+}
+
+func TestApproveRun_HeldDuringFreeze(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, approvalJob("deploy", 1))
+	ctx := context.Background()
+
+	pending, err := svc.TriggerRun(callerCtx("alice"), "deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	incident, err := svc.CreateFreeze(callerCtx("ops", "freeze_override"), FreezeRequest{Reason: "incident"})
+	if err != nil {
+		t.Fatalf("CreateFreeze() error = %v", err)
+	}
+
+	run, err := svc.ApproveRun(callerCtx("bob", "approver"), pending.RunID, "")
+	if err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusQueued || len(prov.triggers) != 0 {
+		t.Fatalf("gateway_status = %q with %d triggers, want held during the freeze", run.GatewayStatus, len(prov.triggers))
+	}
+
+	if err := svc.DeleteFreeze(callerCtx("ops", "freeze_override"), incident.FreezeID); err != nil {
+		t.Fatalf("DeleteFreeze() error = %v", err)
+	}
+	svc.reconcile(ctx)
+	if run, _ := svc.GetRun(ctx, pending.RunID); run.GatewayStatus != models.GatewayStatusDispatched {
+		t.Errorf("gateway_status = %q, want dispatched after the freeze", run.GatewayStatus)
+	}
+}
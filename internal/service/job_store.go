@@ -0,0 +1,112 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// jobStoreState is the persisted form of the job store
+type jobStoreState struct {
+	Jobs      []*models.Job        `json:"jobs"`
+	Revisions []models.JobRevision `json:"revisions"`
+}
+
+// jobStore keeps jobs registered through the API and their change history,
+// persisted to jobs.json in the state directory
+type jobStore struct {
+	mu    sync.Mutex
+	file  *store.File
+	state jobStoreState
+}
+
+// newJobStore creates a job store backed by jobs.json in dir
+func newJobStore(dir string) (*jobStore, error) {
+	js := &jobStore{file: store.NewFile(dir, "jobs.json")}
+	if err := js.file.Load(&js.state); err != nil {
+		return nil, err
+	}
+	return js, nil
+}
+
+// list returns all API-managed jobs in registration order
+func (js *jobStore) list() []*models.Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	return append([]*models.Job(nil), js.state.Jobs...)
+}
+
+// get looks up an API-managed job
+func (js *jobStore) get(jobID string) (*models.Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	for _, j := range js.state.Jobs {
+		if j.JobID == jobID {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+// nextRevision returns the revision number for the next change of a job.
+// Numbering continues across deletions.
+func (js *jobStore) nextRevision(jobID string) int {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	next := 1
+	for _, r := range js.state.Revisions {
+		if r.JobID == jobID && r.Revision >= next {
+			next = r.Revision + 1
+		}
+	}
+	return next
+}
+
+// apply records a revision, storing or removing the job it describes, and
+// persists the store
+func (js *jobStore) apply(rev models.JobRevision) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.state.Jobs = applyRevision(js.state.Jobs, rev)
+	js.state.Revisions = append(js.state.Revisions, rev)
+	return js.file.Save(js.state)
+}
+
+// revisions returns the change history of a job, oldest first
+func (js *jobStore) revisions(jobID string) []models.JobRevision {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	var out []models.JobRevision
+	for _, r := range js.state.Revisions {
+		if r.JobID == jobID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// applyRevision returns jobs with the job described by rev stored or removed
+func applyRevision(jobs []*models.Job, rev models.JobRevision) []*models.Job {
+	out := make([]*models.Job, 0, len(jobs)+1)
+	replaced := false
+	for _, j := range jobs {
+		if j.JobID != rev.JobID {
+			out = append(out, j)
+			continue
+		}
+		if rev.Job != nil {
+			out = append(out, rev.Job)
+			replaced = true
+		}
+	}
+	if rev.Job != nil && !replaced {
+		out = append(out, rev.Job)
+	}
+	return out
+}
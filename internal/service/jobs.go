@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+var (
+	// ErrJobExists indicates a job with the same ID is already defined
+	ErrJobExists = errors.New("job already exists")
+	// ErrJobReadOnly indicates the job comes from configuration and can't be deleted through the API
+	ErrJobReadOnly = errors.New("job is defined in the jobs configuration")
+	// ErrJobInUse indicates a workflow still references the job
+	ErrJobInUse = errors.New("job is used by a workflow")
+	// ErrInvalidJob indicates a job definition or its provider ref is invalid
+	ErrInvalidJob = errors.New("invalid job definition")
+	// ErrJobAdminScopeRequired indicates the caller may not manage jobs
+	ErrJobAdminScopeRequired = errors.New("job_admin scope required")
+)
+
+// CreateJob registers a new job through the API. It fails with ErrJobExists
+// if a job with the same ID is already defined, in jobs.yaml or through the API.
+func (s *Service) CreateJob(ctx context.Context, job *models.Job) (*models.Job, error) {
+	saved, _, err := s.saveJob(ctx, job, true)
+	return saved, err
+}
+
+// PutJob creates or replaces an API-managed job. A job defined in jobs.yaml
+// is overridden until the API definition is deleted. Reports whether the job
+// didn't exist before.
+func (s *Service) PutJob(ctx context.Context, job *models.Job) (*models.Job, bool, error) {
+	return s.saveJob(ctx, job, false)
+}
+
+// saveJob validates a job against the provider and stores a new revision
+func (s *Service) saveJob(ctx context.Context, job *models.Job, createOnly bool) (*models.Job, bool, error) {
+	logger := s.getLogger(ctx)
+
+	if !callerHasScope(ctx, models.ScopeJobAdmin) {
+		return nil, false, ErrJobAdminScopeRequired
+	}
+	if err := s.validateJobRef(ctx, job); err != nil {
+		return nil, false, err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	_, exists := s.job(job.JobID)
+	if createOnly && exists {
+		return nil, false, ErrJobExists
+	}
+
+	saved := *job
+	saved.Source = models.JobSourceAPI
+	saved.Revision = s.managedJobs.nextRevision(job.JobID)
+
+	action := models.JobCreated
+	if _, managed := s.managedJobs.get(job.JobID); managed {
+		action = models.JobUpdated
+	}
+
+	rev := models.JobRevision{
+		JobID:    saved.JobID,
+		Revision: saved.Revision,
+		Action:   action,
+		Job:      &saved,
+		By:       callerName(ctx),
+		At:       time.Now(),
+	}
+
+	current := s.definitions()
+	defs, err := newDefinitions(current.source, applyRevision(s.managedJobs.list(), rev))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	if err := s.commitJobRevision(defs, rev); err != nil {
+		return nil, false, err
+	}
+	if _, ok := s.provider.(provider.JobValidator); ok {
+		s.markAvailable(saved.JobID)
+	}
+
+	logger.Info("service: job saved",
+		"job_id", saved.JobID,
+		"revision", saved.Revision,
+		"action", action,
+		"by", rev.By)
+
+	effective, _ := s.job(saved.JobID)
+	return effective, !exists, nil
+}
+
+// DeleteJob removes an API-managed job. A jobs.yaml definition it overrode
+// takes effect again. Runs already started keep being tracked.
+func (s *Service) DeleteJob(ctx context.Context, jobID string) error {
+	logger := s.getLogger(ctx)
+
+	if !callerHasScope(ctx, models.ScopeJobAdmin) {
+		return ErrJobAdminScopeRequired
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if _, managed := s.managedJobs.get(jobID); !managed {
+		if _, exists := s.job(jobID); exists {
+			return ErrJobReadOnly
+		}
+		return ErrJobNotFound
+	}
+
+	rev := models.JobRevision{
+		JobID:    jobID,
+		Revision: s.managedJobs.nextRevision(jobID),
+		Action:   models.JobDeleted,
+		By:       callerName(ctx),
+		At:       time.Now(),
+	}
+
+	defs, err := newDefinitions(s.definitions().source, applyRevision(s.managedJobs.list(), rev))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrJobInUse, err)
+	}
+	if err := s.commitJobRevision(defs, rev); err != nil {
+		return err
+	}
+
+	_, restored := defs.jobs[jobID]
+	logger.Info("service: job deleted",
+		"job_id", jobID,
+		"revision", rev.Revision,
+		"config_restored", restored,
+		"by", rev.By)
+	return nil
+}
+
+// ListJobRevisions returns the change history of an API-managed job, oldest
+// first. Jobs only defined in jobs.yaml have no revisions.
+func (s *Service) ListJobRevisions(ctx context.Context, jobID string) ([]models.JobRevision, error) {
+	revisions := s.managedJobs.revisions(jobID)
+	if _, exists := s.job(jobID); !exists && len(revisions) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	s.getLogger(ctx).Debug("service: listing job revisions", "job_id", jobID, "count", len(revisions))
+	return revisions, nil
+}
+
+// commitJobRevision persists a job change and swaps in the configuration it
+// produces; callers hold reloadMu. A pending jobs.yaml reload error is kept.
+func (s *Service) commitJobRevision(defs *definitions, rev models.JobRevision) error {
+	if err := s.managedJobs.apply(rev); err != nil {
+		return fmt.Errorf("persist job: %w", err)
+	}
+
+	current := s.definitions()
+	defs.reloadError = current.reloadError
+	defs.reloadErrorAt = current.reloadErrorAt
+	s.storeDefinitions(defs)
+	return nil
+}
+
+// validateJobRef checks that a job's provider ref is well formed and, when the
+// provider supports it, that the referenced job exists
+func (s *Service) validateJobRef(ctx context.Context, job *models.Job) error {
+	logger := s.getLogger(ctx)
+
+	jobRef, err := s.buildJobRef(job)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+
+	validator, ok := s.provider.(provider.JobValidator)
+	if !ok {
+		logger.Debug("service: provider cannot validate job refs", "job_id", job.JobID)
+		return nil
+	}
+
+	if err := validator.ValidateJob(ctx, jobRef); err != nil {
+		if errors.Is(err, provider.ErrJobNotFound) {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		return fmt.Errorf("validate provider ref: %w", err)
+	}
+	return nil
+}
+
+// withApprovalPolicy applies the environment's default approval policy to an
+// API-managed job that doesn't set its own
+func withApprovalPolicy(job *models.Job, approvals map[string]*models.JobApproval) *models.Job {
+	if job.Approval != nil || job.Environment == "" {
+		return job
+	}
+	policy := approvals[job.Environment]
+	if policy == nil {
+		return job
+	}
+
+	cp := *job
+	cp.Approval = policy
+	return &cp
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+// validatingProvider is a fakeProvider that knows which Concourse jobs exist
+type validatingProvider struct {
+	*fakeProvider
+	jobs map[string]bool
+	errs map[string]error // Lookup failures by job name
+}
+
+func (p *validatingProvider) ValidateJob(ctx context.Context, jobRef provider.JobRef) error {
+	ref := jobRef.(*concourse.ConcourseJobRef)
+	if err := p.errs[ref.Job]; err != nil {
+		return err
+	}
+	if !p.jobs[ref.Job] {
+		return fmt.Errorf("%w: pipeline %s has no job %s", provider.ErrJobNotFound, ref.Pipeline, ref.Job)
+	}
+	return nil
+}
+
+func TestManagedJobs(t *testing.T) {
+	prov := &validatingProvider{
+		fakeProvider: newFakeProvider(),
+		jobs:         map[string]bool{"build": true, "deploy": true, "deploy-v2": true},
+	}
+	stateDir := t.TempDir()
+	configured := testJob("build")
+	configured.Source = models.JobSourceConfig
+
+	var changed []*models.Job
+	svc, err := NewService([]*models.Job{configured}, prov, logger.New("error", "text"), Options{
+		StateDir:    stateDir,
+		Approvals:   map[string]*models.JobApproval{"prod": {RequiredApprovals: 1, ExpiresAfter: "24h"}},
+		JobsChanged: func(jobs []*models.Job) { changed = jobs },
+	})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	admin := callerCtx("platform", "job_admin")
+	deploy := testJob("deploy")
+	deploy.Environment = "prod"
+
+	if _, err := svc.CreateJob(callerCtx("bob"), deploy); !errors.Is(err, ErrJobAdminScopeRequired) {
+		t.Fatalf("CreateJob() without scope error = %v, want ErrJobAdminScopeRequired", err)
+	}
+	if _, err := svc.CreateJob(admin, testJob("missing")); !errors.Is(err, ErrInvalidJob) {
+		t.Fatalf("CreateJob() for unknown provider job error = %v, want ErrInvalidJob", err)
+	}
+
+	created, err := svc.CreateJob(admin, deploy)
+	if err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+	if created.Source != models.JobSourceAPI || created.Revision != 1 || created.Approval == nil {
+		t.Errorf("job = %+v, want API revision 1 with the prod approval policy", created)
+	}
+	if len(changed) != 2 {
+		t.Errorf("JobsChanged got %d jobs, want 2", len(changed))
+	}
+	if _, err := svc.CreateJob(admin, testJob("build")); !errors.Is(err, ErrJobExists) {
+		t.Errorf("CreateJob() for configured job error = %v, want ErrJobExists", err)
+	}
+
+	// PUT overrides a configured job until the API definition is deleted
+	override := testJob("build")
+	override.Provider.Ref["job"] = "deploy-v2"
+	saved, created2, err := svc.PutJob(admin, override)
+	if err != nil || created2 {
+		t.Fatalf("PutJob() = created %v, error %v; want override of existing job", created2, err)
+	}
+	if saved.Provider.Ref["job"] != "deploy-v2" {
+		t.Errorf("effective ref = %v, want override", saved.Provider.Ref)
+	}
+
+	if err := svc.DeleteJob(admin, "build"); err != nil {
+		t.Fatalf("DeleteJob() error = %v", err)
+	}
+	if job, _ := svc.job("build"); job.Source != models.JobSourceConfig {
+		t.Errorf("after delete source = %q, want jobs.yaml definition restored", job.Source)
+	}
+	if err := svc.DeleteJob(admin, "build"); !errors.Is(err, ErrJobReadOnly) {
+		t.Errorf("DeleteJob() of configured job error = %v, want ErrJobReadOnly", err)
+	}
+
+	revisions, err := svc.ListJobRevisions(context.Background(), "build")
+	if err != nil {
+		t.Fatalf("ListJobRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Action != models.JobCreated || revisions[1].Action != models.JobDeleted ||
+		revisions[1].Revision != 2 || revisions[1].By != "platform" {
+		t.Errorf("revisions = %+v, want created then deleted by platform", revisions)
+	}
+
+	// API-managed jobs survive restarts and reloads of jobs.yaml
+	restarted, err := NewService([]*models.Job{configured}, prov, logger.New("error", "text"), Options{StateDir: stateDir})
+	if err != nil {
+		t.Fatalf("NewService() after restart error = %v", err)
+	}
+	if err := restarted.Reload(context.Background(), Definitions{Jobs: []*models.Job{configured}, Hash: "v2"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := restarted.TriggerRun(context.Background(), "deploy", nil, ""); err != nil {
+		t.Errorf("TriggerRun() for API-managed job after restart error = %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// latestRunsConcurrency bounds provider lookups when filling latest runs for
+// a list of jobs
+const latestRunsConcurrency = 8
+
+// GetJob returns a configured job with its latest runs
+func (s *Service) GetJob(ctx context.Context, jobID string) (*models.Job, error) {
+	logger := s.getLogger(ctx)
+
+	job, exists := s.job(jobID)
+	if !exists {
+		logger.Debug("service: job not found", "job_id", jobID)
+		return nil, ErrJobNotFound
+	}
+
+	runs, err := s.latestRuns(ctx, job)
+	if err != nil {
+		logger.Error("service: failed to get latest runs", "job_id", jobID, "error", err)
+		return nil, err
+	}
+
+	cp := *s.withProviderState(job)
+	cp.LatestRun = runs
+	return &cp, nil
+}
+
+// WithLatestRuns returns copies of jobs with their latest runs filled in.
+// Jobs whose runs can't be looked up are returned without them.
+func (s *Service) WithLatestRuns(ctx context.Context, jobs []*models.Job) []*models.Job {
+	logger := s.getLogger(ctx)
+
+	out := make([]*models.Job, len(jobs))
+	sem := make(chan struct{}, latestRunsConcurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			cp := *job
+			runs, err := s.latestRuns(ctx, job)
+			if err != nil {
+				logger.Warn("service: failed to get latest runs", "job_id", job.JobID, "error", err)
+			}
+			cp.LatestRun = runs
+			out[i] = &cp
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// latestRuns combines the job's latest finished and next run in the provider
+// with runs the gateway is holding back. A run pending in the provider is
+// queued ahead of runs held by the gateway. Jobs missing from the provider
+// only report held runs.
+func (s *Service) latestRuns(ctx context.Context, job *models.Job) (*models.JobRuns, error) {
+	runs := &models.JobRuns{}
+
+	if reader, ok := s.provider.(provider.LatestRunsReader); ok {
+		jobRef, err := s.buildJobRef(job)
+		if err != nil {
+			return nil, err
+		}
+		finished, next, err := reader.LatestRuns(ctx, jobRef)
+		if err != nil && !errors.Is(err, provider.ErrJobNotFound) {
+			return nil, err
+		}
+
+		runs.Finished = s.gatewayRun(job.JobID, finished)
+		if next = s.gatewayRun(job.JobID, next); next != nil {
+			if next.Status == models.StatusQueued {
+				runs.Queued = next
+			} else {
+				runs.Running = next
+			}
+		}
+	}
+
+	if runs.Queued == nil {
+		held := s.runs.list(func(r *runRecord) bool {
+			return r.JobID == job.JobID && (r.GatewayStatus.IsHeld() || r.awaitingApproval())
+		})
+		if len(held) > 0 {
+			runs.Queued = held[0].toRun()
+			if held[0].GatewayStatus.IsHeld() {
+				runs.Queued.QueuePosition = s.queuePosition(held[0])
+			}
+		}
+	}
+	return runs, nil
+}
+
+// gatewayRun presents a provider run the way GetRun would: runs the gateway
+// dispatched carry the gateway's run_id and state
+func (s *Service) gatewayRun(jobID string, run *models.Run) *models.Run {
+	if run == nil {
+		return nil
+	}
+	if rec, tracked := s.runs.get(run.RunID); tracked {
+		return mergeRun(rec, run)
+	}
+	run.JobID = jobID
+	return run
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestGetJob_LatestRuns(t *testing.T) {
+	prov := newCapableProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("first trigger error = %v", err)
+	}
+	second, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("second trigger error = %v", err)
+	}
+
+	job, err := svc.GetJob(ctx, "job_deploy")
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	runs := job.LatestRun
+	if runs == nil || runs.Finished != nil {
+		t.Fatalf("latest_run = %+v, want no finished run", runs)
+	}
+	if runs.Running == nil || runs.Running.RunID != first.RunID {
+		t.Errorf("running = %+v, want gateway run %s", runs.Running, first.RunID)
+	}
+	if runs.Queued == nil || runs.Queued.RunID != second.RunID || runs.Queued.QueuePosition != 1 {
+		t.Errorf("queued = %+v, want held run %s at position 1", runs.Queued, second.RunID)
+	}
+
+	prov.finish(1, models.StatusSucceeded)
+	svc.reconcile(ctx)
+
+	jobs := svc.WithLatestRuns(ctx, svc.ListJobs(ctx))
+	runs = jobs[0].LatestRun
+	if runs.Finished == nil || runs.Finished.RunID != first.RunID || runs.Finished.Status != models.StatusSucceeded {
+		t.Errorf("finished = %+v, want succeeded run %s", runs.Finished, first.RunID)
+	}
+	if runs.Running == nil || runs.Running.RunID != second.RunID {
+		t.Errorf("running = %+v, want dispatched run %s", runs.Running, second.RunID)
+	}
+	if runs.Queued != nil {
+		t.Errorf("queued = %+v, want none", runs.Queued)
+	}
+}
+
+func TestGetJob_NotFound(t *testing.T) {
+	svc := newTestService(t, newFakeProvider(), testJob("job_a"))
+
+	if _, err := svc.GetJob(context.Background(), "job_missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("GetJob() error = %v, want ErrJobNotFound", err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// pauseRetention is how long pause audit entries are kept
+const pauseRetention = 90 * 24 * time.Hour
+
+// pauseStore keeps the pause audit log, persisted to pauses.json in the state
+// directory
+type pauseStore struct {
+	mu     sync.Mutex
+	file   *store.File
+	events []models.PauseEvent
+}
+
+// newPauseStore creates a pause store backed by pauses.json in dir
+func newPauseStore(dir string) (*pauseStore, error) {
+	ps := &pauseStore{file: store.NewFile(dir, "pauses.json")}
+	if err := ps.file.Load(&ps.events); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// record appends an entry to the audit log
+func (ps *pauseStore) record(e models.PauseEvent) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.events = append(ps.events, e)
+	return ps.file.Save(ps.events)
+}
+
+// list returns the audit log, oldest first
+func (ps *pauseStore) list() []models.PauseEvent {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return append([]models.PauseEvent(nil), ps.events...)
+}
+
+// prune drops audit entries older than cutoff
+func (ps *pauseStore) prune(cutoff time.Time) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	kept := ps.events[:0]
+	for _, e := range ps.events {
+		if !e.At.Before(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(ps.events) {
+		return nil
+	}
+	ps.events = kept
+	return ps.file.Save(ps.events)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+var (
+	// ErrOperatorScopeRequired indicates the caller lacks the operator scope
+	ErrOperatorScopeRequired = errors.New("operator scope required")
+	// ErrPauseUnsupported indicates the provider can't pause jobs or pipelines
+	ErrPauseUnsupported = errors.New("provider does not support pausing jobs")
+	// ErrInvalidPauseTarget indicates a pause target other than job or pipeline
+	ErrInvalidPauseTarget = errors.New("invalid pause target")
+)
+
+// pauseRefreshInterval is how often the run tracker re-reads pause state
+// from the provider, picking up pauses made outside the gateway
+const pauseRefreshInterval = time.Minute
+
+// pauseRefreshConcurrency bounds provider lookups when refreshing pause state
+const pauseRefreshConcurrency = 8
+
+// SetPaused pauses or unpauses a job, or the whole pipeline it belongs to, in
+// the provider. It requires the operator scope and is recorded in the pause
+// audit log. Returns the job with its new pause state.
+func (s *Service) SetPaused(ctx context.Context, jobID string, target models.PauseTarget, paused bool, reason string) (*models.Job, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: setting pause state",
+		"job_id", jobID,
+		"target", target,
+		"paused", paused)
+
+	if !callerHasScope(ctx, models.ScopeOperator) {
+		return nil, ErrOperatorScopeRequired
+	}
+	switch target {
+	case "":
+		target = models.PauseTargetJob
+	case models.PauseTargetJob, models.PauseTargetPipeline:
+	default:
+		return nil, fmt.Errorf("%w: %q (expected job or pipeline)", ErrInvalidPauseTarget, target)
+	}
+
+	job, exists := s.job(jobID)
+	if !exists {
+		logger.Debug("service: job not found", "job_id", jobID)
+		return nil, ErrJobNotFound
+	}
+
+	pauser, ok := s.provider.(provider.Pauser)
+	if !ok {
+		return nil, ErrPauseUnsupported
+	}
+	jobRef, err := s.buildJobRef(job)
+	if err != nil {
+		return nil, fmt.Errorf("build job ref: %w", err)
+	}
+
+	if err := pauser.SetPaused(ctx, jobRef, target, paused); err != nil {
+		logger.Error("service: failed to set pause state", "job_id", jobID, "target", target, "error", err)
+		return nil, err
+	}
+
+	event := models.PauseEvent{
+		JobID:  jobID,
+		Target: target,
+		Paused: paused,
+		Reason: reason,
+		By:     callerName(ctx),
+		At:     time.Now(),
+	}
+	logger.Warn("service: pause state changed",
+		"job_id", jobID,
+		"target", target,
+		"paused", paused,
+		"by", event.By,
+		"reason", reason)
+	if err := s.pauseEvents.record(event); err != nil {
+		logger.Error("service: failed to persist pause event", "job_id", jobID, "error", err)
+	}
+
+	// A pipeline pause also changes the other jobs of the pipeline
+	affected := map[string]*models.Job{jobID: job}
+	if target == models.PauseTargetPipeline {
+		affected = s.pipelineJobs(job, jobRef)
+	}
+	s.readPauses(ctx, pauser, affected)
+
+	return s.withProviderState(job), nil
+}
+
+// pipelineJobs returns the configured jobs in the same pipeline as job,
+// including job itself
+func (s *Service) pipelineJobs(job *models.Job, jobRef provider.JobRef) map[string]*models.Job {
+	jobs := map[string]*models.Job{job.JobID: job}
+	ref, ok := jobRef.(*concourse.ConcourseJobRef)
+	if !ok {
+		return jobs
+	}
+	for id, other := range s.definitions().jobs {
+		otherRef, err := s.buildJobRef(other)
+		if err != nil {
+			continue
+		}
+		if o, ok := otherRef.(*concourse.ConcourseJobRef); ok && o.Team == ref.Team && o.Pipeline == ref.Pipeline &&
+			reflect.DeepEqual(o.InstanceVars, ref.InstanceVars) {
+			jobs[id] = other
+		}
+	}
+	return jobs
+}
+
+// ListPauses returns the jobs that are currently paused, as of the last
+// refresh, and the pause audit log
+func (s *Service) ListPauses(ctx context.Context) ([]*models.Job, []models.PauseEvent) {
+	var paused []*models.Job
+	for _, job := range s.ListJobs(ctx) {
+		if job.Pause != nil && job.Pause.Paused() {
+			paused = append(paused, job)
+		}
+	}
+	return paused, s.pauseEvents.list()
+}
+
+// refreshPauses re-reads the pause state of every job from the provider once
+// pauseRefreshInterval has passed since the last refresh
+func (s *Service) refreshPauses(ctx context.Context, now time.Time) {
+	pauser, ok := s.provider.(provider.Pauser)
+	if !ok {
+		return
+	}
+	s.pauseMu.Lock()
+	due := now.Sub(s.pausesCheckedAt) >= pauseRefreshInterval
+	if due {
+		s.pausesCheckedAt = now
+	}
+	s.pauseMu.Unlock()
+	if !due {
+		return
+	}
+
+	s.readPauses(ctx, pauser, s.definitions().jobs)
+}
+
+// readPauses reads the pause state of the given jobs from the provider and
+// records it. Jobs whose state can't be read keep their previous state.
+func (s *Service) readPauses(ctx context.Context, pauser provider.Pauser, jobs map[string]*models.Job) {
+	logger := s.getLogger(ctx)
+
+	states := make(map[string]*models.JobPause, len(jobs))
+	var mu sync.Mutex
+	sem := make(chan struct{}, pauseRefreshConcurrency)
+	var wg sync.WaitGroup
+	for id, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			jobRef, err := s.buildJobRef(job)
+			if err != nil {
+				return
+			}
+			state, err := pauser.PauseState(ctx, jobRef)
+			if err != nil && !errors.Is(err, provider.ErrJobNotFound) {
+				logger.Warn("service: failed to read pause state", "job_id", id, "error", err)
+				return
+			}
+
+			mu.Lock()
+			states[id] = state
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for id, state := range states {
+		s.setPause(id, state)
+	}
+}
+
+// setPause records a job's pause state; nil forgets it
+func (s *Service) setPause(jobID string, state *models.JobPause) {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	if state == nil {
+		delete(s.pauses, jobID)
+		return
+	}
+	if s.pauses == nil {
+		s.pauses = make(map[string]models.JobPause)
+	}
+	s.pauses[jobID] = *state
+}
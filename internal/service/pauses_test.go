@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestSetPaused(t *testing.T) {
+	prov := newCapableProvider()
+	other := testJob("job_c")
+	other.Provider.Ref["pipeline"] = "q"
+	svc := newTestService(t, prov, testJob("job_a"), testJob("job_b"), other)
+	ctx := callerCtx("oncall", string(models.ScopeOperator))
+
+	if _, err := svc.SetPaused(callerCtx("dev"), "job_a", "", true, ""); !errors.Is(err, ErrOperatorScopeRequired) {
+		t.Fatalf("SetPaused() without scope error = %v, want ErrOperatorScopeRequired", err)
+	}
+	if _, err := svc.SetPaused(ctx, "job_a", "team", true, ""); !errors.Is(err, ErrInvalidPauseTarget) {
+		t.Fatalf("SetPaused() with bad target error = %v, want ErrInvalidPauseTarget", err)
+	}
+
+	job, err := svc.SetPaused(ctx, "job_a", "", true, "incident 42")
+	if err != nil {
+		t.Fatalf("SetPaused() error = %v", err)
+	}
+	if job.Pause == nil || !job.Pause.Job || job.Pause.Pipeline {
+		t.Errorf("pause = %+v, want job paused", job.Pause)
+	}
+
+	// Pausing the pipeline updates the other jobs of the pipeline right away;
+	// job_c is in another pipeline
+	if _, err := svc.SetPaused(ctx, "job_a", models.PauseTargetPipeline, true, ""); err != nil {
+		t.Fatalf("SetPaused(pipeline) error = %v", err)
+	}
+
+	paused, events := svc.ListPauses(context.Background())
+	if len(paused) != 2 || paused[1].JobID != "job_b" || !paused[1].Pause.Pipeline {
+		t.Errorf("paused jobs = %+v, want only job_a and job_b", paused)
+	}
+	if len(events) != 2 || events[0].By != "oncall" || events[0].Reason != "incident 42" || events[1].Target != models.PauseTargetPipeline {
+		t.Errorf("pause events = %+v, want job and pipeline pause by oncall", events)
+	}
+
+	job, err = svc.SetPaused(ctx, "job_a", models.PauseTargetPipeline, false, "")
+	if err != nil {
+		t.Fatalf("SetPaused(unpause) error = %v", err)
+	}
+	if job.Pause.Pipeline || !job.Pause.Job {
+		t.Errorf("pause = %+v, want only the job paused", job.Pause)
+	}
+	if job, _ := svc.GetJob(context.Background(), "job_b"); job.Pause == nil || job.Pause.Pipeline {
+		t.Errorf("job_b pause = %+v, want the pipeline unpaused", job.Pause)
+	}
+}
+
+func TestSetPaused_Unsupported(t *testing.T) {
+	svc := newTestService(t, newFakeProvider(), testJob("job_a"))
+	ctx := callerCtx("oncall", string(models.ScopeOperator))
+
+	if _, err := svc.SetPaused(ctx, "job_a", "", true, ""); !errors.Is(err, ErrPauseUnsupported) {
+		t.Errorf("SetPaused() error = %v, want ErrPauseUnsupported", err)
+	}
+	if _, err := svc.SetPaused(ctx, "job_missing", "", true, ""); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("SetPaused() error = %v, want ErrJobNotFound", err)
+	}
+}
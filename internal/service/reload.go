@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/freeze"
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// Definitions is a complete jobs configuration: jobs, workflows and freezes
+type Definitions struct {
+	Jobs      []*models.Job
+	Workflows []*models.Workflow
+	Freezes   []*models.Freeze
+	Approvals map[string]*models.JobApproval // Default approval policy by environment for API-managed jobs
+	Hash      string                         // Identifies the configuration source, e.g. a hash of jobs.yaml
+}
+
+// definitions is the validated configuration in effect. It is never modified
+// after being stored; reloads swap in a new value.
+type definitions struct {
+	jobs      map[string]*models.Job
+	workflows map[string]*models.Workflow
+	freezes   []*freeze.Schedule
+	source    Definitions // Configuration before API-managed jobs were merged in
+
+	hash     string
+	version  int // Incremented by every successful reload or job change
+	loadedAt time.Time
+
+	reloadError   string // Last failed reload, cleared by the next successful one
+	reloadErrorAt *time.Time
+}
+
+// newDefinitions merges API-managed jobs into a configuration, validates the
+// result and indexes it. Managed jobs replace configured jobs with the same ID.
+func newDefinitions(d Definitions, managed []*models.Job) (*definitions, error) {
+	defs := &definitions{
+		jobs:      make(map[string]*models.Job, len(d.Jobs)+len(managed)),
+		workflows: make(map[string]*models.Workflow, len(d.Workflows)),
+		source:    d,
+		hash:      d.Hash,
+		version:   1,
+		loadedAt:  time.Now(),
+	}
+
+	for _, j := range d.Jobs {
+		if _, dup := defs.jobs[j.JobID]; dup {
+			return nil, fmt.Errorf("duplicate job %s", j.JobID)
+		}
+		defs.jobs[j.JobID] = j
+	}
+	for _, j := range managed {
+		defs.jobs[j.JobID] = withApprovalPolicy(j, d.Approvals)
+	}
+
+	for _, wf := range d.Workflows {
+		if _, dup := defs.workflows[wf.WorkflowID]; dup {
+			return nil, fmt.Errorf("duplicate workflow %s", wf.WorkflowID)
+		}
+		if err := validateWorkflow(wf, defs.jobs); err != nil {
+			return nil, err
+		}
+		defs.workflows[wf.WorkflowID] = wf
+	}
+
+	for _, f := range d.Freezes {
+		sch, err := freeze.Compile(f)
+		if err != nil {
+			return nil, fmt.Errorf("freeze %s: %w", f.FreezeID, err)
+		}
+		defs.freezes = append(defs.freezes, sch)
+	}
+
+	return defs, nil
+}
+
+// definitions returns the configuration currently in effect
+func (s *Service) definitions() *definitions {
+	return s.defs.Load()
+}
+
+// job looks up a configured job
+func (s *Service) job(jobID string) (*models.Job, bool) {
+	job, ok := s.definitions().jobs[jobID]
+	return job, ok
+}
+
+// workflow looks up a configured workflow
+func (s *Service) workflow(workflowID string) (*models.Workflow, bool) {
+	wf, ok := s.definitions().workflows[workflowID]
+	return wf, ok
+}
+
+// Reload validates a new configuration and swaps it in atomically. On error
+// the current configuration stays in effect and the error is reported in
+// detailed health checks. Jobs are checked against the provider according to
+// the validation mode. Runs of removed jobs keep being tracked.
+func (s *Service) Reload(ctx context.Context, d Definitions) error {
+	logger := s.getLogger(ctx)
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	defs, err := newDefinitions(d, s.managedJobs.list())
+	if err != nil {
+		s.reloadFailed(ctx, err)
+		return err
+	}
+
+	if s.validation != ValidationOff {
+		results, err := s.checkJobs(ctx, defs.jobs)
+		if err == nil && s.validation == ValidationStrict {
+			err = unavailableError(results)
+		}
+		if err != nil && s.validation == ValidationStrict {
+			s.reloadFailed(ctx, err)
+			return err
+		}
+		if err != nil {
+			// Provider errors leave some jobs unchecked; keep what is known about them
+			s.mergeAvailability(results, defs.jobs)
+		} else {
+			s.setAvailability(results)
+		}
+	}
+	s.storeDefinitions(defs)
+
+	logger.Info("service: configuration reloaded",
+		"version", defs.version,
+		"hash", defs.hash,
+		"jobs", len(defs.jobs),
+		"workflows", len(defs.workflows),
+		"freezes", len(defs.freezes))
+
+	return nil
+}
+
+// ReloadFailed records a configuration that could not be loaded; the current
+// configuration stays in effect
+func (s *Service) ReloadFailed(ctx context.Context, err error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.reloadFailed(ctx, err)
+}
+
+// reloadFailed implements ReloadFailed; callers hold reloadMu
+func (s *Service) reloadFailed(ctx context.Context, err error) {
+	now := time.Now()
+	defs := *s.definitions()
+	defs.reloadError = err.Error()
+	defs.reloadErrorAt = &now
+	s.defs.Store(&defs)
+
+	s.getLogger(ctx).Error("service: configuration reload failed, keeping current configuration",
+		"version", defs.version,
+		"hash", defs.hash,
+		"error", err)
+}
+
+// storeDefinitions swaps in a new configuration and notifies JobsChanged;
+// callers hold reloadMu
+func (s *Service) storeDefinitions(defs *definitions) {
+	defs.version = s.definitions().version + 1
+	s.defs.Store(defs)
+
+	if s.jobsChanged != nil {
+		jobs := make([]*models.Job, 0, len(defs.jobs))
+		for _, j := range defs.jobs {
+			jobs = append(jobs, j)
+		}
+		s.jobsChanged(jobs)
+	}
+}
+
+// ConfigHash returns the hash of the configuration in effect
+func (s *Service) ConfigHash() string {
+	return s.definitions().hash
+}
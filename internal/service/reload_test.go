@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("build"))
+
+	if err := svc.Reload(ctx, Definitions{
+		Jobs: []*models.Job{testJob("build"), testJob("deploy")},
+		Hash: "v2",
+	}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := svc.TriggerRun(ctx, "deploy", nil, ""); err != nil {
+		t.Fatalf("TriggerRun() for added job error = %v", err)
+	}
+
+	// A workflow referencing an unknown job invalidates the whole configuration
+	err := svc.Reload(ctx, Definitions{
+		Jobs: []*models.Job{testJob("build")},
+		Workflows: []*models.Workflow{{
+			WorkflowID: "release",
+			Nodes:      []models.WorkflowNode{{ID: "ship", JobID: "missing", When: models.ConditionSuccess}},
+		}},
+		Hash: "v3",
+	})
+	if err == nil {
+		t.Fatal("Reload() error = nil, want invalid workflow")
+	}
+
+	defs := svc.definitions()
+	if defs.hash != "v2" || defs.version != 2 || defs.reloadError == "" {
+		t.Errorf("hash = %q, version = %d, reload_error = %q; want v2, 2 and the error", defs.hash, defs.version, defs.reloadError)
+	}
+	if _, exists := svc.job("deploy"); !exists {
+		t.Error("failed reload dropped the previous configuration")
+	}
+
+	if err := svc.Reload(ctx, Definitions{Jobs: []*models.Job{testJob("build")}, Hash: "v4"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := svc.TriggerRun(ctx, "deploy", nil, ""); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("TriggerRun() for removed job error = %v, want ErrJobNotFound", err)
+	}
+	if defs := svc.definitions(); defs.reloadError != "" || defs.version != 3 {
+		t.Errorf("reload_error = %q, version = %d; want cleared, 3", defs.reloadError, defs.version)
+	}
+}
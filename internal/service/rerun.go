@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// ErrRerunUnsupported indicates a run the gateway has no record of can't be
+// rerun because the provider can't reuse its inputs
+var ErrRerunUnsupported = errors.New("run was not triggered through the gateway and the provider cannot rerun it")
+
+// RerunRun starts a new run of a run's job with the same parameters and
+// input versions. Runs triggered through the gateway are re-triggered with
+// their recorded parameters; once the original reached the provider,
+// providers implementing provider.Rerunner reuse its exact inputs instead.
+// Runs triggered outside the gateway can only be rerun by such providers.
+// The rerun is subject to the job's freezes, approvals and concurrency
+// limits like any trigger and links back to the original through rerun_of.
+func (s *Service) RerunRun(ctx context.Context, runID string) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: rerunning run", "run_id", runID)
+
+	origin, tracked := s.runs.get(runID)
+	if !tracked {
+		var err error
+		if origin, err = s.untrackedRun(ctx, runID); err != nil {
+			logger.Debug("service: run can't be rerun", "run_id", runID, "error", err)
+			return nil, err
+		}
+	}
+
+	job, exists := s.job(origin.JobID)
+	if !exists {
+		logger.Debug("service: job not found", "job_id", origin.JobID)
+		return nil, ErrJobNotFound
+	}
+
+	if params.HasRedacted(job.Parameters, origin.Parameters) {
+		logger.Debug("service: recorded parameters not retained", "run_id", runID)
+		return nil, ErrParametersNotRetained
+	}
+
+	// The job's parameter schema may have changed since the original run
+	values := origin.Parameters
+	if tracked {
+		var err error
+		if values, err = validateParameters(job, origin.Parameters); err != nil {
+			logger.Debug("service: recorded parameters rejected", "run_id", runID, "error", err)
+			return nil, err
+		}
+	}
+
+	run, err := s.submit(ctx, job, &runRecord{
+		JobID:       job.JobID,
+		Parameters:  values,
+		Sensitive:   origin.Sensitive,
+		Versions:    origin.Versions,
+		TriggeredBy: callerName(ctx),
+		RerunOf:     origin.ID,
+		Attempt:     1,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("service: run rerun",
+		"job_id", job.JobID,
+		"run_id", run.RunID,
+		"rerun_of", origin.ID)
+	return run, nil
+}
+
+// untrackedRun describes a provider run the gateway didn't trigger. It is
+// attributed to the first configured job (by job_id) referencing the run's
+// provider job.
+func (s *Service) untrackedRun(ctx context.Context, runID string) (*runRecord, error) {
+	runRef, err := s.parseRunRef(runID)
+	if err != nil {
+		return nil, ErrRunNotFound
+	}
+	if _, ok := s.provider.(provider.Rerunner); !ok {
+		return nil, ErrRerunUnsupported
+	}
+	if _, err := s.provider.GetRun(ctx, runRef); err != nil {
+		if errors.Is(err, provider.ErrRunNotFound) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+
+	ref := runRef.(*concourse.ConcourseRunRef)
+	for _, job := range s.ListJobs(ctx) {
+		jobRef, err := s.buildJobRef(job)
+		if err != nil {
+			continue
+		}
+		if r, ok := jobRef.(*concourse.ConcourseJobRef); ok && r.Team == ref.Team && r.Pipeline == ref.Pipeline && r.Job == ref.Job {
+			return &runRecord{ID: runID, JobID: job.JobID}, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// rerunRef returns the provider run whose inputs a rerun reuses, or nil when
+// the run is triggered afresh: it isn't a rerun, the provider can't rerun or
+// the original never reached the provider
+func (s *Service) rerunRef(rec *runRecord) provider.RunRef {
+	if rec.RerunOf == "" {
+		return nil
+	}
+	if _, ok := s.provider.(provider.Rerunner); !ok {
+		return nil
+	}
+	providerRunID, err := s.resolveProviderRunID(rec.RerunOf)
+	if err != nil {
+		return nil
+	}
+	runRef, err := s.parseRunRef(providerRunID)
+	if err != nil {
+		return nil
+	}
+	return runRef
+}
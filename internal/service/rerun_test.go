@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestRerunRun_Parameters(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("job_a"))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_a", map[string]interface{}{"branch": "main"}, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+
+	run, err := svc.RerunRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("RerunRun() error = %v", err)
+	}
+	if run.RerunOf != first.RunID || run.RunID == first.RunID {
+		t.Errorf("rerun = %+v, want new run linked to %s", run, first.RunID)
+	}
+	if len(prov.triggers) != 2 || prov.triggers[1].Parameters["branch"] != "main" {
+		t.Errorf("provider triggers = %+v, want original parameters re-triggered", prov.triggers)
+	}
+
+	got, err := svc.GetRun(ctx, run.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if got.RerunOf != first.RunID {
+		t.Errorf("GetRun().RerunOf = %q, want %q", got.RerunOf, first.RunID)
+	}
+}
+
+func TestRerunRun_ProviderRerun(t *testing.T) {
+	prov := newCapableProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	prov.finish(1, models.StatusFailed)
+
+	run, err := svc.RerunRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("RerunRun() error = %v", err)
+	}
+	if len(prov.reruns) != 1 || prov.reruns[0] != 1 || len(prov.triggers) != 1 {
+		t.Errorf("reruns = %v, triggers = %d; want build 1 rerun by the provider", prov.reruns, len(prov.triggers))
+	}
+	if run.RerunOf != first.RunID {
+		t.Errorf("RerunOf = %q, want %q", run.RerunOf, first.RunID)
+	}
+
+	// A rerun waits for a free slot like any trigger and reuses the inputs once dispatched
+	held, err := svc.RerunRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("second RerunRun() error = %v", err)
+	}
+	if held.GatewayStatus != models.GatewayStatusQueued {
+		t.Fatalf("second rerun gateway_status = %q, want queued", held.GatewayStatus)
+	}
+	prov.finish(2, models.StatusSucceeded)
+	svc.reconcile(ctx)
+	if len(prov.reruns) != 2 || prov.reruns[1] != 1 {
+		t.Errorf("reruns = %v, want build 1 rerun again after dispatch", prov.reruns)
+	}
+}
+
+func TestRerunRun_Untracked(t *testing.T) {
+	ctx := context.Background()
+
+	prov := newCapableProvider()
+	prov.statuses[7] = models.StatusSucceeded
+	prov.nextID = 7
+	svc := newTestService(t, prov, testJob("job_a"))
+
+	run, err := svc.RerunRun(ctx, "main:p:job_a:7")
+	if err != nil {
+		t.Fatalf("RerunRun() error = %v", err)
+	}
+	if run.JobID != "job_a" || run.RerunOf != "main:p:job_a:7" || len(prov.reruns) != 1 {
+		t.Errorf("rerun = %+v, reruns = %v; want job_a rerun from build 7", run, prov.reruns)
+	}
+
+	if _, err := svc.RerunRun(ctx, "main:p:job_unknown:7"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("RerunRun() for unconfigured job error = %v, want ErrJobNotFound", err)
+	}
+	if _, err := svc.RerunRun(ctx, "main:p:job_a:99"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("RerunRun() for missing build error = %v, want ErrRunNotFound", err)
+	}
+
+	plain := newFakeProvider()
+	plain.statuses[7] = models.StatusSucceeded
+	svc = newTestService(t, plain, testJob("job_a"))
+	if _, err := svc.RerunRun(ctx, "main:p:job_a:7"); !errors.Is(err, ErrRerunUnsupported) {
+		t.Errorf("RerunRun() without provider support error = %v, want ErrRerunUnsupported", err)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// ErrInvalidPin indicates a pin request names no version or more than one
+var ErrInvalidPin = errors.New("invalid pin")
+
+// PinRequest selects the version to pin a resource to: either by its version
+// fields or by its provider version ID
+type PinRequest struct {
+	Version   map[string]string
+	VersionID int
+	Comment   string // Shown on the pin in Concourse
+}
+
+// ListPipelineResources lists the resources of a pipeline from the provider
+func (s *Service) ListPipelineResources(ctx context.Context, pipeline string) ([]concourse.Resource, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: listing resources", "pipeline", pipeline)
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return nil, fmt.Errorf("provider does not support resource listing")
+	}
+
+	resources, err := adapter.ListResources(ctx, pipeline)
+	if err != nil {
+		logger.Error("service: failed to list resources", "pipeline", pipeline, "error", err)
+		return nil, err
+	}
+
+	logger.Info("service: resources listed", "pipeline", pipeline, "count", len(resources))
+	return resources, nil
+}
+
+// ListPipelineResourceTypes lists the resource types of a pipeline from the provider
+func (s *Service) ListPipelineResourceTypes(ctx context.Context, pipeline string) ([]concourse.ResourceType, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: listing resource types", "pipeline", pipeline)
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return nil, fmt.Errorf("provider does not support resource type listing")
+	}
+
+	types, err := adapter.ListResourceTypes(ctx, pipeline)
+	if err != nil {
+		logger.Error("service: failed to list resource types", "pipeline", pipeline, "error", err)
+		return nil, err
+	}
+
+	logger.Info("service: resource types listed", "pipeline", pipeline, "count", len(types))
+	return types, nil
+}
+
+// ListResourceVersions lists a page of versions of a resource, newest first
+func (s *Service) ListResourceVersions(ctx context.Context, pipeline, resource string, page concourse.Page) ([]concourse.ResourceVersion, *concourse.Pagination, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: listing resource versions", "pipeline", pipeline, "resource", resource)
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return nil, nil, fmt.Errorf("provider does not support resource version listing")
+	}
+
+	versions, pagination, err := adapter.ListResourceVersions(ctx, pipeline, resource, page)
+	if err != nil {
+		logger.Error("service: failed to list resource versions", "pipeline", pipeline, "resource", resource, "error", err)
+		return nil, nil, err
+	}
+
+	logger.Info("service: resource versions listed", "pipeline", pipeline, "resource", resource, "count", len(versions))
+	return versions, pagination, nil
+}
+
+// CheckResource asks the provider to check a resource for new versions,
+// starting from the given version if set
+func (s *Service) CheckResource(ctx context.Context, pipeline, resource string, from map[string]string) (*concourse.Build, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: checking resource", "pipeline", pipeline, "resource", resource)
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return nil, fmt.Errorf("provider does not support resource checks")
+	}
+
+	build, err := adapter.CheckResource(ctx, pipeline, resource, from)
+	if err != nil {
+		logger.Error("service: failed to check resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return nil, err
+	}
+
+	logger.Info("service: resource check started",
+		"pipeline", pipeline,
+		"resource", resource,
+		"build_id", build.ID,
+		"caller", callerName(ctx))
+	return build, nil
+}
+
+// PinResource pins a resource to a version. It requires the operator scope.
+func (s *Service) PinResource(ctx context.Context, pipeline, resource string, req PinRequest) error {
+	logger := s.getLogger(ctx)
+
+	if !callerHasScope(ctx, models.ScopeOperator) {
+		return ErrOperatorScopeRequired
+	}
+	if (len(req.Version) == 0) == (req.VersionID == 0) {
+		return fmt.Errorf("%w: set exactly one of version or version_id", ErrInvalidPin)
+	}
+	if req.VersionID < 0 {
+		return fmt.Errorf("%w: version_id must be positive", ErrInvalidPin)
+	}
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return fmt.Errorf("provider does not support resource pinning")
+	}
+
+	if err := adapter.PinResource(ctx, pipeline, resource, req.Version, req.VersionID, req.Comment); err != nil {
+		logger.Error("service: failed to pin resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return err
+	}
+
+	logger.Warn("service: resource pinned",
+		"pipeline", pipeline,
+		"resource", resource,
+		"version", req.Version,
+		"version_id", req.VersionID,
+		"pinned_by", callerName(ctx))
+	return nil
+}
+
+// UnpinResource removes the pin of a resource. It requires the operator scope.
+func (s *Service) UnpinResource(ctx context.Context, pipeline, resource string) error {
+	logger := s.getLogger(ctx)
+
+	if !callerHasScope(ctx, models.ScopeOperator) {
+		return ErrOperatorScopeRequired
+	}
+
+	adapter, ok := s.provider.(*concourse.Adapter)
+	if !ok {
+		logger.Error("service: provider is not concourse adapter")
+		return fmt.Errorf("provider does not support resource pinning")
+	}
+
+	if err := adapter.UnpinResource(ctx, pipeline, resource); err != nil {
+		logger.Error("service: failed to unpin resource", "pipeline", pipeline, "resource", resource, "error", err)
+		return err
+	}
+
+	logger.Warn("service: resource unpinned",
+		"pipeline", pipeline,
+		"resource", resource,
+		"unpinned_by", callerName(ctx))
+	return nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// scheduleRetries creates the next attempt for runs flagged for retry when
+// they finished. The attempt is submitted like a trigger by the original
+// caller: approvals apply, and it is held by the gateway until its backoff
+// has elapsed and no freeze blocks it.
+func (s *Service) scheduleRetries(ctx context.Context, now time.Time) {
+	logger := s.getLogger(ctx)
+
+	finished := s.runs.list(func(r *runRecord) bool {
+		return r.GatewayStatus == models.GatewayStatusDispatched &&
+			r.Status.IsTerminal() && r.RetryEligible && r.NextRunID == "" && !r.NoRetry
+	})
+
+	for _, prev := range finished {
+		job, exists := s.job(prev.JobID)
+		if !exists || job.Retry == nil {
+			continue
+		}
+		policy := job.Retry
+		if prev.attempt() >= policy.MaxAttempts {
+			continue
+		}
+
+		attempt := prev.attempt() + 1
+		notBefore := now.Add(retryDelay(policy, prev.attempt()))
+		next := &runRecord{
+			ID:          newGatewayRunID(),
+			JobID:       prev.JobID,
+			Parameters:  prev.Parameters,
+			Sensitive:   prev.Sensitive,
+			Versions:    prev.Versions,
+			TriggeredBy: prev.TriggeredBy,
+			Reason:      fmt.Sprintf("retry %d of %d after %s", attempt, policy.MaxAttempts, prev.Status),
+			Attempt:     attempt,
+			OriginRunID: prev.originID(),
+			NotBefore:   &notBefore,
+			CreatedAt:   now,
+		}
+
+		// Link first so a crash between the two writes can't schedule a second retry
+		if _, err := s.runs.update(prev.ID, func(r *runRecord) { r.NextRunID = next.ID }); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", prev.ID, "error", err)
+			continue
+		}
+
+		retryCtx := context.WithValue(ctx, "api_key_name", prev.TriggeredBy)
+		if _, err := s.submit(retryCtx, job, next); err != nil {
+			logger.Warn("service: retry not submitted",
+				"job_id", prev.JobID,
+				"run_id", next.ID,
+				"previous_run_id", prev.ID,
+				"error", err)
+			// Keep the attempt in the chain so the reason shows up on the run
+			next.GatewayStatus = models.GatewayStatusRejected
+			next.Status = models.StatusCanceled
+			next.CancelReason = models.CancelReasonRejected
+			next.Reason = err.Error()
+			next.FinishedAt = &now
+			if err := s.runs.put(next); err != nil {
+				logger.Error("service: failed to persist retry", "run_id", next.ID, "error", err)
+			}
+			continue
+		}
+
+		logger.Info("service: retry scheduled",
+			"job_id", prev.JobID,
+			"run_id", next.ID,
+			"previous_run_id", prev.ID,
+			"attempt", attempt,
+			"not_before", notBefore)
+	}
+}
+
+// retryDelay returns the backoff before the attempt following the given one
+func retryDelay(policy *models.JobRetry, attempt int) time.Duration {
+	backoff, _ := time.ParseDuration(policy.Backoff)
+	if backoff <= 0 {
+		return 0
+	}
+
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := time.Duration(float64(backoff) * math.Pow(multiplier, float64(attempt-1)))
+
+	if maxBackoff, _ := time.ParseDuration(policy.MaxBackoff); maxBackoff > 0 && (delay > maxBackoff || delay <= 0) {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// stopRetries prevents further attempts in a chain and cancels a pending retry
+func (s *Service) stopRetries(ctx context.Context, originID string) {
+	logger := s.getLogger(ctx)
+
+	chain := s.runs.list(func(r *runRecord) bool { return r.originID() == originID })
+	for _, rec := range chain {
+		if rec.GatewayStatus.IsHeld() && rec.attempt() > 1 {
+			if err := s.finishHeld(rec.ID, models.GatewayStatusCanceled, "retry canceled by "+callerLabel(ctx)); err != nil {
+				logger.Error("service: failed to cancel pending retry", "run_id", rec.ID, "error", err)
+			}
+		}
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) { r.NoRetry = true }); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+	}
+}
+
+// fillAttempts adds the retry chain and its final outcome to a run
+func (s *Service) fillAttempts(run *models.Run, rec *runRecord) {
+	originID := rec.originID()
+	chain := s.runs.list(func(r *runRecord) bool { return r.originID() == originID })
+	if len(chain) < 2 {
+		if job, exists := s.job(rec.JobID); !exists || job.Retry == nil {
+			return
+		}
+	}
+
+	slices.SortFunc(chain, func(a, b *runRecord) int { return a.attempt() - b.attempt() })
+	for _, r := range chain {
+		status := r.Status
+		if r.ID == rec.ID {
+			status = run.Status // Freshest view of the requested attempt
+		}
+		run.Attempts = append(run.Attempts, models.RunAttempt{
+			Attempt:       r.attempt(),
+			RunID:         r.ID,
+			Status:        status,
+			GatewayStatus: r.GatewayStatus,
+			CreatedAt:     r.CreatedAt,
+			FinishedAt:    r.FinishedAt,
+		})
+	}
+
+	// The chain has an outcome once its last attempt finished without a retry pending
+	last := chain[len(chain)-1]
+	if last.ID == rec.ID {
+		last.Status = run.Status
+	}
+	if last.Status.IsTerminal() && !s.retryPending(last) {
+		run.FinalStatus = last.Status
+	}
+}
+
+// retryPending reports whether the reconciler will still schedule a retry after rec
+func (s *Service) retryPending(rec *runRecord) bool {
+	if !rec.RetryEligible || rec.NoRetry || rec.GatewayStatus != models.GatewayStatusDispatched {
+		return false
+	}
+	job, exists := s.job(rec.JobID)
+	return exists && job.Retry != nil && rec.attempt() < job.Retry.MaxAttempts
+}
+
+// retryCovers reports whether the job's retry policy allows another attempt
+// after rec in its current status
+func (s *Service) retryCovers(rec *runRecord) bool {
+	job, exists := s.job(rec.JobID)
+	if !exists || job.Retry == nil {
+		return false
+	}
+	return rec.attempt() < job.Retry.MaxAttempts && slices.Contains(job.Retry.On, rec.Status)
+}
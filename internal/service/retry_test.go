@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func retryJob() *models.Job {
+	job := testJob("flaky")
+	job.Retry = &models.JobRetry{
+		MaxAttempts: 3,
+		On:          []models.RunStatus{models.StatusErrored},
+		Backoff:     "1m",
+		MaxBackoff:  "90s",
+		Multiplier:  2,
+	}
+	return job
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := retryJob().Retry
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 90 * time.Second}, // 2m capped by max_backoff
+		{5, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(policy, tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(attempt %d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleRetries_AttemptChain(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, retryJob())
+
+	first, err := svc.TriggerRun(ctx, "flaky", map[string]interface{}{"ref": "main"}, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+
+	prov.finish(1, models.StatusErrored)
+	svc.refreshActive(ctx, "")
+	now := time.Now()
+	svc.scheduleRetries(ctx, now)
+
+	run, err := svc.GetRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if len(run.Attempts) != 2 || run.FinalStatus != "" {
+		t.Fatalf("attempts = %+v, final = %q; want 2 attempts and no final status", run.Attempts, run.FinalStatus)
+	}
+	retryID := run.Attempts[1].RunID
+
+	// The retry waits for its backoff
+	svc.dispatchQueued(ctx)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("retry dispatched before backoff elapsed")
+	}
+
+	svc.runs.update(retryID, func(r *runRecord) { r.NotBefore = &now })
+	svc.dispatchQueued(ctx)
+	if len(prov.triggers) != 2 || prov.triggers[1].Parameters["ref"] != "main" {
+		t.Fatalf("triggers = %+v, want retry with original parameters", prov.triggers)
+	}
+
+	prov.finish(2, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	svc.scheduleRetries(ctx, time.Now())
+
+	run, err = svc.GetRun(ctx, retryID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.Attempt != 2 || run.FinalStatus != models.StatusSucceeded {
+		t.Errorf("attempt = %d, final = %q; want 2, succeeded", run.Attempt, run.FinalStatus)
+	}
+}
+
+func TestScheduleRetries_StatusNotRetried(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, retryJob())
+
+	first, _ := svc.TriggerRun(ctx, "flaky", nil, "")
+	prov.finish(1, models.StatusFailed)
+	svc.refreshActive(ctx, "")
+	svc.scheduleRetries(ctx, time.Now())
+
+	run, err := svc.GetRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if len(run.Attempts) != 1 || run.FinalStatus != models.StatusFailed {
+		t.Errorf("attempts = %+v, final = %q; want 1 attempt, failed", run.Attempts, run.FinalStatus)
+	}
+}
+
+func TestScheduleRetries_PolicyAddedAfterFinish(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	job := testJob("flaky")
+	svc := newTestService(t, prov, job)
+
+	first, _ := svc.TriggerRun(ctx, "flaky", nil, "")
+	prov.finish(1, models.StatusErrored)
+	svc.refreshActive(ctx, "")
+
+	// Runs that finished before the policy existed are not retried
+	job.Retry = retryJob().Retry
+	svc.scheduleRetries(ctx, time.Now())
+
+	run, err := svc.GetRun(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if len(run.Attempts) != 1 || run.FinalStatus != models.StatusErrored {
+		t.Errorf("attempts = %+v, final = %q; want 1 attempt, errored", run.Attempts, run.FinalStatus)
+	}
+}
+
+func TestScheduleRetries_RequiresApproval(t *testing.T) {
+	ctx := callerCtx("alice")
+	prov := newFakeProvider()
+	job := retryJob()
+	job.Approval = &models.JobApproval{RequiredApprovals: 1, ExpiresAfter: "1h"}
+	job.Retry.Backoff = ""
+	svc := newTestService(t, prov, job)
+
+	first, _ := svc.TriggerRun(ctx, "flaky", nil, "")
+	if _, err := svc.ApproveRun(callerCtx("bob", "approver"), first.RunID, ""); err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	prov.finish(1, models.StatusErrored)
+	svc.refreshActive(ctx, "")
+	svc.scheduleRetries(ctx, time.Now())
+
+	run, _ := svc.GetRun(ctx, first.RunID)
+	if len(run.Attempts) != 2 || run.Attempts[1].GatewayStatus != models.GatewayStatusPendingApproval {
+		t.Fatalf("attempts = %+v, want retry pending approval", run.Attempts)
+	}
+	svc.dispatchQueued(ctx)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("retry dispatched without approval")
+	}
+
+	retry, err := svc.ApproveRun(callerCtx("bob", "approver"), run.Attempts[1].RunID, "")
+	if err != nil {
+		t.Fatalf("ApproveRun() for retry error = %v", err)
+	}
+	if retry.GatewayStatus != models.GatewayStatusDispatched || len(prov.triggers) != 2 {
+		t.Errorf("approved retry gateway_status = %q with %d triggers, want dispatched", retry.GatewayStatus, len(prov.triggers))
+	}
+}
+
+func TestScheduleRetries_Frozen(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, retryJob())
+
+	first, _ := svc.TriggerRun(callerCtx("alice"), "flaky", nil, "")
+	incident, err := svc.CreateFreeze(callerCtx("ops", "freeze_override"), FreezeRequest{Reason: "incident"})
+	if err != nil {
+		t.Fatalf("CreateFreeze() error = %v", err)
+	}
+	prov.finish(1, models.StatusErrored)
+	svc.refreshActive(ctx, "")
+	// Scheduled in the past so only the freeze holds the retry back
+	svc.scheduleRetries(ctx, time.Now().Add(-2*time.Minute))
+
+	run, _ := svc.GetRun(ctx, first.RunID)
+	if len(run.Attempts) != 2 || run.Attempts[1].GatewayStatus != models.GatewayStatusQueued {
+		t.Fatalf("attempts = %+v, want retry held during the freeze", run.Attempts)
+	}
+	svc.dispatchQueued(ctx)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("retry dispatched during a freeze")
+	}
+
+	if err := svc.DeleteFreeze(callerCtx("ops", "freeze_override"), incident.FreezeID); err != nil {
+		t.Fatalf("DeleteFreeze() error = %v", err)
+	}
+	svc.dispatchQueued(ctx)
+	if len(prov.triggers) != 2 {
+		t.Errorf("retry not dispatched after the freeze ended")
+	}
+}
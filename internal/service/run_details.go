@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// fillRunDetails adds the resolved input versions and, for failed runs, the
+// failing step from providers that report them. Both are fixed once known,
+// so they are kept on the run record (nil for runs the gateway doesn't
+// track) and fetched only once. Lookup failures leave the fields empty.
+func (s *Service) fillRunDetails(ctx context.Context, run *models.Run, runRef provider.RunRef, rec *runRecord) {
+	logger := s.getLogger(ctx)
+
+	var inputs map[string]map[string]string
+	if reader, ok := s.provider.(provider.InputVersionReader); ok && run.InputVersions == nil && run.StartedAt != nil {
+		versions, err := reader.InputVersions(ctx, runRef)
+		if err != nil {
+			logger.Warn("service: failed to read input versions", "run_id", run.RunID, "error", err)
+		} else if len(versions) > 0 {
+			inputs = versions
+			run.InputVersions = versions
+		}
+	}
+
+	var failing string
+	failed := run.Status == models.StatusFailed || run.Status == models.StatusErrored
+	if reader, ok := s.provider.(provider.StepReader); ok && failed && run.FailingStep == "" {
+		steps, err := reader.Steps(ctx, runRef)
+		if err != nil {
+			logger.Warn("service: failed to read run steps", "run_id", run.RunID, "error", err)
+		} else if step := steps.Failing(); step != nil {
+			failing = step.Name
+			if failing == "" {
+				failing = string(step.Type)
+			}
+			run.FailingStep = failing
+		}
+	}
+
+	if rec == nil || (inputs == nil && failing == "") {
+		return
+	}
+	if _, err := s.runs.update(rec.ID, func(r *runRecord) {
+		if inputs != nil {
+			r.InputVersions = inputs
+		}
+		if failing != "" {
+			r.FailingStep = failing
+		}
+	}); err != nil {
+		logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestGetRun_Details(t *testing.T) {
+	prov := newCapableProvider()
+	job := testJob("job_a")
+	job.Parameters = []models.JobParameter{
+		{Name: "branch", Type: models.ParamString},
+		{Name: "token", Type: models.ParamString, Sensitive: true},
+	}
+	svc := newTestService(t, prov, job)
+
+	triggered, err := svc.TriggerRun(callerCtx("alice"), "job_a", map[string]interface{}{"branch": "main", "token": "s3cret"}, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	prov.finish(1, models.StatusFailed)
+
+	for range 2 {
+		run, err := svc.GetRun(context.Background(), triggered.RunID)
+		if err != nil {
+			t.Fatalf("GetRun() error = %v", err)
+		}
+		if run.TriggeredBy != "alice" {
+			t.Errorf("TriggeredBy = %q, want alice", run.TriggeredBy)
+		}
+		if run.Parameters["branch"] != "main" || run.Parameters["token"] != "***" {
+			t.Errorf("Parameters = %v, want token redacted", run.Parameters)
+		}
+		if run.InputVersions["repo"]["ref"] != "abc123" || run.FailingStep != "test" {
+			t.Errorf("InputVersions = %v, FailingStep = %q; want repo abc123 and test", run.InputVersions, run.FailingStep)
+		}
+		if run.DurationSeconds < 60 {
+			t.Errorf("DurationSeconds = %d, want at least 60", run.DurationSeconds)
+		}
+	}
+	if prov.inputReads != 1 || prov.stepReads != 1 {
+		t.Errorf("provider reads = %d inputs, %d steps; want details fetched once", prov.inputReads, prov.stepReads)
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// ErrRunResourcesUnsupported indicates the provider can't report the
+// resources of a run
+var ErrRunResourcesUnsupported = errors.New("provider does not support run resources")
+
+// GetRunResources returns the resource versions a dispatched run consumed and
+// produced, e.g. the exact git commit a deploy used
+func (s *Service) GetRunResources(ctx context.Context, runID string) (*models.RunResources, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: getting run resources", "run_id", runID)
+
+	reader, ok := s.provider.(provider.RunResourceReader)
+	if !ok {
+		return nil, ErrRunResourcesUnsupported
+	}
+
+	providerRunID, err := s.resolveProviderRunID(runID)
+	if err != nil {
+		logger.Debug("service: run has no resources yet", "run_id", runID, "error", err)
+		return nil, err
+	}
+
+	runRef, err := s.parseRunRef(providerRunID)
+	if err != nil {
+		logger.Debug("service: failed to parse run_id for resources", "run_id", runID, "error", err)
+		return nil, ErrRunNotFound
+	}
+
+	resources, err := reader.RunResources(ctx, runRef)
+	if err != nil {
+		if errors.Is(err, provider.ErrRunNotFound) {
+			return nil, ErrRunNotFound
+		}
+		logger.Error("service: failed to get run resources", "run_id", runID, "error", err)
+		return nil, err
+	}
+
+	logger.Debug("service: run resources retrieved",
+		"run_id", runID,
+		"inputs", len(resources.Inputs),
+		"outputs", len(resources.Outputs))
+	return resources, nil
+}
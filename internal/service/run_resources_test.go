@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestGetRunResources(t *testing.T) {
+	prov := newCapableProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	held, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+
+	resources, err := svc.GetRunResources(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("GetRunResources() error = %v", err)
+	}
+	if len(resources.Inputs) != 1 || resources.Inputs[0].Version["ref"] != "abc123" || len(resources.Outputs) != 1 {
+		t.Errorf("resources = %+v, want repo input at abc123 and one output", resources)
+	}
+
+	if _, err := svc.GetRunResources(ctx, held.RunID); !errors.Is(err, ErrRunNotDispatched) {
+		t.Errorf("GetRunResources(held) error = %v, want ErrRunNotDispatched", err)
+	}
+	if _, err := svc.GetRunResources(ctx, "main:p:job_deploy:99"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("GetRunResources(unknown) error = %v, want ErrRunNotFound", err)
+	}
+}
+
+func TestGetRunResources_Unsupported(t *testing.T) {
+	svc := newTestService(t, newFakeProvider(), testJob("job_a"))
+
+	if _, err := svc.GetRunResources(context.Background(), "main:p:job_a:1"); !errors.Is(err, ErrRunResourcesUnsupported) {
+		t.Errorf("GetRunResources() error = %v, want ErrRunResourcesUnsupported", err)
+	}
+}
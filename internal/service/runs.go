@@ -0,0 +1,282 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// runRetention is how long finished run records are kept
+const runRetention = 7 * 24 * time.Hour
+
+// runRecord is the gateway's record of a run it dispatched or is holding back.
+// Runs dispatched straight to the provider use the provider run_id as ID;
+// runs held by the gateway get a gateway ID that stays valid after dispatch.
+type runRecord struct {
+	ID                string                       `json:"id"`
+	JobID             string                       `json:"job_id"`
+	Environment       string                       `json:"environment,omitempty"` // Job environment when the run was created
+	Parameters        map[string]interface{}       `json:"parameters,omitempty"`
+	Sensitive         []string                     `json:"sensitive_parameters"` // Parameters masked in state; empty, not nil, when there are none
+	IdempotencyKey    string                       `json:"idempotency_key,omitempty"`
+	TriggeredBy       string                       `json:"triggered_by,omitempty"`
+	GatewayStatus     models.GatewayStatus         `json:"gateway_status"`
+	ProviderRunID     string                       `json:"provider_run_id,omitempty"`
+	Status            models.RunStatus             `json:"status"`
+	Reason            string                       `json:"reason,omitempty"`
+	CancelReason      models.CancelReason          `json:"cancel_reason,omitempty"`
+	Attempt           int                          `json:"attempt,omitempty"`
+	OriginRunID       string                       `json:"origin_run_id,omitempty"`  // First attempt of a retry chain
+	NextRunID         string                       `json:"next_run_id,omitempty"`    // Retry scheduled after this attempt
+	NoRetry           bool                         `json:"no_retry,omitempty"`       // Set when a caller canceled the chain
+	RetryEligible     bool                         `json:"retry_eligible,omitempty"` // Finished under a retry policy covering its status
+	NotBefore         *time.Time                   `json:"not_before,omitempty"`     // Earliest dispatch time for held retries
+	RerunOf           string                       `json:"rerun_of,omitempty"`       // Run this one was rerun from
+	Versions          map[string]map[string]string `json:"versions,omitempty"`       // Requested input versions by resource
+	PreviousPins      []provider.ResourcePin       `json:"previous_pins,omitempty"`  // Pins replaced for Versions
+	PinsPending       bool                         `json:"pins_pending,omitempty"`   // PreviousPins not yet restored
+	PinKeys           []string                     `json:"pin_keys,omitempty"`       // Pinned resources as team/pipeline/resource
+	InputVersions     map[string]map[string]string `json:"input_versions,omitempty"` // Input versions the provider resolved
+	FailingStep       string                       `json:"failing_step,omitempty"`   // Step that failed or errored the run
+	Approvals         []models.RunApproval         `json:"approvals,omitempty"`
+	ApprovalExpiresAt *time.Time                   `json:"approval_expires_at,omitempty"`
+	FreezeOverride    *models.FreezeOverride       `json:"freeze_override,omitempty"` // Freeze the trigger was let through
+	CreatedAt         time.Time                    `json:"created_at"`
+	DispatchedAt      *time.Time                   `json:"dispatched_at,omitempty"`
+	StartedAt         *time.Time                   `json:"started_at,omitempty"`
+	FinishedAt        *time.Time                   `json:"finished_at,omitempty"`
+}
+
+// isActive reports whether the record occupies a concurrency slot
+func (r *runRecord) isActive() bool {
+	return r.GatewayStatus == models.GatewayStatusDispatched && !r.Status.IsTerminal()
+}
+
+// inSlot reports whether the record counts against the concurrency limit of a
+// job in an environment. Records without an environment count against all of
+// their job's environments.
+func (r *runRecord) inSlot(jobID, environment string) bool {
+	return r.JobID == jobID && (r.Environment == environment || r.Environment == "" || environment == "")
+}
+
+// awaitingApproval reports whether the run is waiting for approvers
+func (r *runRecord) awaitingApproval() bool {
+	return r.GatewayStatus == models.GatewayStatusPendingApproval
+}
+
+// isReady reports whether a held record may be dispatched at now
+func (r *runRecord) isReady(now time.Time) bool {
+	return r.NotBefore == nil || !now.Before(*r.NotBefore)
+}
+
+// attempt returns the record's attempt number (records from before retries count as 1)
+func (r *runRecord) attempt() int {
+	if r.Attempt == 0 {
+		return 1
+	}
+	return r.Attempt
+}
+
+// originID returns the ID of the first attempt in the record's retry chain
+func (r *runRecord) originID() string {
+	if r.OriginRunID != "" {
+		return r.OriginRunID
+	}
+	return r.ID
+}
+
+// toRun converts the record to the API model
+func (r *runRecord) toRun() *models.Run {
+	run := &models.Run{
+		RunID:           r.ID,
+		JobID:           r.JobID,
+		Status:          r.Status,
+		GatewayStatus:   r.GatewayStatus,
+		Reason:          r.Reason,
+		CancelReason:    r.CancelReason,
+		Attempt:         r.attempt(),
+		RerunOf:         r.RerunOf,
+		TriggeredBy:     r.TriggeredBy,
+		NotBefore:       r.NotBefore,
+		Versions:        r.Versions,
+		InputVersions:   r.InputVersions,
+		FailingStep:     r.FailingStep,
+		Approvals:       r.Approvals,
+		FreezeOverride:  r.FreezeOverride,
+		CreatedAt:       r.CreatedAt,
+		StartedAt:       r.StartedAt,
+		FinishedAt:      r.FinishedAt,
+		DurationSeconds: runDuration(r.StartedAt, r.FinishedAt),
+	}
+	if r.ProviderRunID != r.ID {
+		run.ProviderRunID = r.ProviderRunID
+	}
+	if r.awaitingApproval() {
+		run.ApprovalExpiresAt = r.ApprovalExpiresAt
+	}
+	return run
+}
+
+// runDuration returns the seconds between start and finish, or since start
+// while the run is still going. Zero if the run hasn't started.
+func runDuration(startedAt, finishedAt *time.Time) int64 {
+	if startedAt == nil {
+		return 0
+	}
+	end := time.Now()
+	if finishedAt != nil {
+		end = *finishedAt
+	}
+	return int64(end.Sub(*startedAt).Seconds())
+}
+
+// runStore keeps run records in memory and persists them to the state directory
+type runStore struct {
+	mu         sync.Mutex
+	file       *store.File
+	records    map[string]*runRecord
+	byProvider map[string]string // provider run_id -> record ID
+
+	// redact masks sensitive parameters before records are persisted; the
+	// values are only kept in memory
+	redact func(jobID string, recorded []string, values map[string]interface{}) map[string]interface{}
+}
+
+// newRunStore creates a run store backed by runs.json in dir (memory-only if dir is empty)
+func newRunStore(dir string) (*runStore, error) {
+	rs := &runStore{
+		file:       store.NewFile(dir, "runs.json"),
+		records:    make(map[string]*runRecord),
+		byProvider: make(map[string]string),
+	}
+
+	var records []*runRecord
+	if err := rs.file.Load(&records); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		rs.records[rec.ID] = rec
+		if rec.ProviderRunID != "" {
+			rs.byProvider[rec.ProviderRunID] = rec.ID
+		}
+	}
+
+	return rs, nil
+}
+
+// newGatewayRunID generates an ID for a run held by the gateway
+func newGatewayRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "gw-" + hex.EncodeToString(b)
+}
+
+// get returns a copy of the record with the given ID, also matching provider run IDs
+func (rs *runStore) get(id string) (*runRecord, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rec, ok := rs.records[id]
+	if !ok {
+		recID, found := rs.byProvider[id]
+		if !found {
+			return nil, false
+		}
+		rec = rs.records[recID]
+	}
+	cp := *rec
+	return &cp, true
+}
+
+// list returns copies of matching records ordered by creation time
+func (rs *runStore) list(match func(*runRecord) bool) []*runRecord {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	var out []*runRecord
+	for _, rec := range rs.records {
+		if match == nil || match(rec) {
+			cp := *rec
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// put inserts or replaces a record and persists the store
+func (rs *runStore) put(rec *runRecord) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	cp := *rec
+	rs.records[rec.ID] = &cp
+	if rec.ProviderRunID != "" {
+		rs.byProvider[rec.ProviderRunID] = rec.ID
+	}
+	return rs.saveLocked()
+}
+
+// update applies fn to the stored record and persists the store.
+// Returns false if the record doesn't exist.
+func (rs *runStore) update(id string, fn func(*runRecord)) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rec, ok := rs.records[id]
+	if !ok {
+		return false, nil
+	}
+	fn(rec)
+	if rec.ProviderRunID != "" {
+		rs.byProvider[rec.ProviderRunID] = rec.ID
+	}
+	return true, rs.saveLocked()
+}
+
+// prune drops finished records older than the cutoff
+func (rs *runStore) prune(cutoff time.Time) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	removed := false
+	for id, rec := range rs.records {
+		if rec.FinishedAt != nil && rec.FinishedAt.Before(cutoff) {
+			delete(rs.records, id)
+			delete(rs.byProvider, rec.ProviderRunID)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return rs.saveLocked()
+}
+
+// saveLocked persists all records; caller must hold rs.mu
+func (rs *runStore) saveLocked() error {
+	records := make([]*runRecord, 0, len(rs.records))
+	for _, rec := range rs.records {
+		if rs.redact != nil && len(rec.Parameters) > 0 {
+			cp := *rec
+			cp.Parameters = rs.redact(rec.JobID, rec.Sensitive, rec.Parameters)
+			rec = &cp
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return rs.file.Save(records)
+}
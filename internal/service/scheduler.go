@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/cron"
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// schedulerCaller is the caller identity recorded on scheduled runs
+const schedulerCaller = "scheduler"
+
+// scheduleState is the persisted state of a job schedule
+type scheduleState struct {
+	LastSlot    *time.Time `json:"last_slot,omitempty"` // Scheduled time of the last fire
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+	LastRunID   string     `json:"last_run_id,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// scheduleEntry is the in-memory schedule for a job
+type scheduleEntry struct {
+	spec     models.JobSchedule
+	schedule *cron.Schedule
+	loc      *time.Location
+	jitter   time.Duration
+	nextSlot time.Time // Next scheduled time
+	fireAt   time.Time // nextSlot plus jitter
+}
+
+// scheduledFire is a schedule slot that is due
+type scheduledFire struct {
+	jobID      string
+	slot       time.Time
+	parameters map[string]interface{}
+}
+
+// scheduler tracks cron schedules. Fired slots are persisted before the
+// trigger so a restart never fires the same slot twice.
+type scheduler struct {
+	mu      sync.Mutex
+	file    *store.File
+	state   map[string]*scheduleState
+	entries map[string]*scheduleEntry
+}
+
+// newScheduler creates a scheduler backed by schedules.json in dir
+func newScheduler(dir string) (*scheduler, error) {
+	sc := &scheduler{
+		file:    store.NewFile(dir, "schedules.json"),
+		state:   make(map[string]*scheduleState),
+		entries: make(map[string]*scheduleEntry),
+	}
+	if err := sc.file.Load(&sc.state); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// sync reconciles schedule entries with the job definitions and returns due slots.
+// Due slots are marked as fired and persisted before returning.
+func (sc *scheduler) sync(jobs map[string]*models.Job, now time.Time) ([]scheduledFire, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := sc.syncEntriesLocked(jobs, now); err != nil {
+		return nil, err
+	}
+
+	var due []scheduledFire
+	for jobID, entry := range sc.entries {
+		if entry.nextSlot.IsZero() || now.Before(entry.fireAt) {
+			continue
+		}
+
+		slot := entry.nextSlot
+		state := sc.stateFor(jobID)
+		state.LastSlot = &slot
+		due = append(due, scheduledFire{jobID: jobID, slot: slot, parameters: entry.spec.Parameters})
+
+		// Advance from now so slots missed while the gateway was busy or asleep are skipped
+		entry.advance(now)
+	}
+
+	if len(due) > 0 {
+		if err := sc.file.Save(sc.state); err != nil {
+			return nil, fmt.Errorf("persist schedule state: %w", err)
+		}
+	}
+	return due, nil
+}
+
+// refresh reconciles schedule entries with the job definitions without firing
+func (sc *scheduler) refresh(jobs map[string]*models.Job, now time.Time) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.syncEntriesLocked(jobs, now)
+}
+
+// syncEntriesLocked adds, replaces and removes entries to match the jobs; caller must hold sc.mu
+func (sc *scheduler) syncEntriesLocked(jobs map[string]*models.Job, now time.Time) error {
+	for jobID := range sc.entries {
+		if job, ok := jobs[jobID]; !ok || job.Schedule == nil {
+			delete(sc.entries, jobID)
+		}
+	}
+
+	for jobID, job := range jobs {
+		if job.Schedule == nil {
+			continue
+		}
+		if entry, ok := sc.entries[jobID]; ok && sameSchedule(entry.spec, *job.Schedule) {
+			entry.spec.Parameters = job.Schedule.Parameters
+			continue
+		}
+
+		entry, err := sc.newEntry(jobID, *job.Schedule, now)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", jobID, err)
+		}
+		sc.entries[jobID] = entry
+	}
+	return nil
+}
+
+// newEntry builds an entry whose next slot follows the last fired slot (or now)
+func (sc *scheduler) newEntry(jobID string, spec models.JobSchedule, now time.Time) (*scheduleEntry, error) {
+	schedule, err := cron.Parse(spec.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if spec.Timezone != "" {
+		if loc, err = time.LoadLocation(spec.Timezone); err != nil {
+			return nil, fmt.Errorf("load timezone: %w", err)
+		}
+	}
+
+	var jitter time.Duration
+	if spec.Jitter != "" {
+		if jitter, err = time.ParseDuration(spec.Jitter); err != nil {
+			return nil, fmt.Errorf("parse jitter: %w", err)
+		}
+	}
+
+	entry := &scheduleEntry{spec: spec, schedule: schedule, loc: loc, jitter: jitter}
+
+	// Never fire a slot at or before the last one that already fired
+	base := now
+	if state, ok := sc.state[jobID]; ok && state.LastSlot != nil && state.LastSlot.After(base) {
+		base = *state.LastSlot
+	}
+	entry.advance(base)
+	return entry, nil
+}
+
+// advance moves the entry to the first slot after base
+func (e *scheduleEntry) advance(base time.Time) {
+	e.nextSlot = e.schedule.Next(base.In(e.loc))
+	e.fireAt = e.nextSlot
+	if e.jitter > 0 && !e.nextSlot.IsZero() {
+		e.fireAt = e.nextSlot.Add(rand.N(e.jitter))
+	}
+}
+
+// record stores the outcome of a fired slot
+func (sc *scheduler) record(jobID string, firedAt time.Time, runID string, fireErr error) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	state := sc.stateFor(jobID)
+	state.LastFiredAt = &firedAt
+	state.LastRunID = runID
+	state.LastError = ""
+	if fireErr != nil {
+		state.LastError = fireErr.Error()
+	}
+	return sc.file.Save(sc.state)
+}
+
+// stateFor returns the state for a job, creating it if needed; caller must hold sc.mu
+func (sc *scheduler) stateFor(jobID string) *scheduleState {
+	state, ok := sc.state[jobID]
+	if !ok {
+		state = &scheduleState{}
+		sc.state[jobID] = state
+	}
+	return state
+}
+
+// list returns the current schedules sorted by job_id
+func (sc *scheduler) list() []models.Schedule {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	schedules := make([]models.Schedule, 0, len(sc.entries))
+	for jobID, entry := range sc.entries {
+		sched := models.Schedule{
+			JobID:    jobID,
+			Cron:     entry.spec.Cron,
+			Timezone: entry.spec.Timezone,
+			Jitter:   entry.spec.Jitter,
+		}
+		if sched.Timezone == "" {
+			sched.Timezone = "UTC"
+		}
+		if !entry.nextSlot.IsZero() {
+			next := entry.nextSlot
+			sched.NextFireAt = &next
+		}
+		if state, ok := sc.state[jobID]; ok {
+			sched.LastFiredAt = state.LastFiredAt
+			sched.LastRunID = state.LastRunID
+			sched.LastError = state.LastError
+		}
+		schedules = append(schedules, sched)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].JobID < schedules[j].JobID })
+	return schedules
+}
+
+// sameSchedule reports whether two schedule specs produce the same fire times
+func sameSchedule(a, b models.JobSchedule) bool {
+	return a.Cron == b.Cron && a.Timezone == b.Timezone && a.Jitter == b.Jitter
+}
+
+// runScheduler fires due schedules until ctx is canceled
+func (s *Service) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	s.logger.Info("service: scheduler started")
+
+	for {
+		s.fireDueSchedules(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("service: scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fireDueSchedules triggers every schedule whose next fire time has passed
+func (s *Service) fireDueSchedules(ctx context.Context, now time.Time) {
+	due, err := s.schedules.sync(s.definitions().jobs, now)
+	if err != nil {
+		s.logger.Error("service: failed to sync schedules", "error", err)
+		return
+	}
+
+	for _, fire := range due {
+		s.fireSchedule(ctx, fire)
+	}
+}
+
+// fireSchedule triggers a scheduled run as the scheduler caller
+func (s *Service) fireSchedule(ctx context.Context, fire scheduledFire) {
+	ctx = context.WithValue(ctx, "api_key_name", schedulerCaller)
+	idempotencyKey := fmt.Sprintf("schedule:%s:%s", fire.jobID, fire.slot.UTC().Format(time.RFC3339))
+
+	params := make(map[string]interface{}, len(fire.parameters))
+	for k, v := range fire.parameters {
+		params[k] = v
+	}
+
+	s.logger.Info("service: firing scheduled run",
+		"job_id", fire.jobID,
+		"slot", fire.slot)
+
+	var runID string
+	run, err := s.triggerOnce(ctx, fire.jobID, params, idempotencyKey)
+	if err != nil {
+		s.logger.Error("service: scheduled run failed",
+			"job_id", fire.jobID,
+			"slot", fire.slot,
+			"error", err)
+	} else {
+		runID = run.RunID
+	}
+
+	if err := s.schedules.record(fire.jobID, time.Now(), runID, err); err != nil {
+		s.logger.Error("service: failed to persist schedule state", "job_id", fire.jobID, "error", err)
+	}
+}
+
+// ListSchedules returns configured job schedules with their next fire times
+func (s *Service) ListSchedules(ctx context.Context) ([]models.Schedule, error) {
+	logger := s.getLogger(ctx)
+
+	// Refresh without firing so newly configured schedules show up immediately
+	if err := s.schedules.refresh(s.definitions().jobs, time.Now()); err != nil {
+		logger.Error("service: failed to sync schedules", "error", err)
+		return nil, fmt.Errorf("sync schedules: %w", err)
+	}
+
+	schedules := s.schedules.list()
+	logger.Debug("service: schedules listed", "count", len(schedules))
+	return schedules, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+func TestScheduler_FiresOncePerSlot(t *testing.T) {
+	dir := t.TempDir()
+	prov := newFakeProvider()
+	ctx := context.Background()
+	log := logger.New("error", "text")
+
+	job := testJob("job_nightly")
+	job.Schedule = &models.JobSchedule{
+		Cron:       "0 2 * * *",
+		Parameters: map[string]interface{}{"suite": "full"},
+	}
+
+	svc, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	start := time.Date(2026, 1, 8, 1, 59, 0, 0, time.UTC)
+	svc.fireDueSchedules(ctx, start)
+	if len(prov.triggers) != 0 {
+		t.Fatalf("fired before slot: %d triggers", len(prov.triggers))
+	}
+
+	schedules := svc.schedules.list()
+	if len(schedules) != 1 || !schedules[0].NextFireAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("schedules = %+v, want next fire at 02:00", schedules)
+	}
+
+	slot := time.Date(2026, 1, 8, 2, 0, 30, 0, time.UTC)
+	svc.fireDueSchedules(ctx, slot)
+	svc.fireDueSchedules(ctx, slot)
+	if len(prov.triggers) != 1 {
+		t.Fatalf("triggers = %d, want 1", len(prov.triggers))
+	}
+	if prov.triggers[0].Parameters["suite"] != "full" {
+		t.Errorf("scheduled parameters = %v, want default parameters", prov.triggers[0].Parameters)
+	}
+
+	rec, ok := svc.runs.get(svc.schedules.list()[0].LastRunID)
+	if !ok || rec.TriggeredBy != schedulerCaller {
+		t.Errorf("scheduled run record = %+v, want triggered by scheduler", rec)
+	}
+
+	// A restart (even with the clock behind the fired slot) must not fire it again
+	restarted, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	restarted.fireDueSchedules(ctx, start.Add(30*time.Second))
+	restarted.fireDueSchedules(ctx, slot)
+	if len(prov.triggers) != 1 {
+		t.Errorf("triggers after restart = %d, want 1", len(prov.triggers))
+	}
+}
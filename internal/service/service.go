@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
 	"github.com/lei/simple-ci/internal/provider"
 	"github.com/lei/simple-ci/internal/provider/concourse"
 	"github.com/lei/simple-ci/pkg/logger"
@@ -18,27 +22,196 @@ var (
 	ErrJobNotFound = errors.New("job not found")
 	// ErrRunNotFound indicates the requested run doesn't exist
 	ErrRunNotFound = errors.New("run not found")
+	// ErrConcurrencyLimit indicates the job has no free slot and its policy rejects new runs
+	ErrConcurrencyLimit = errors.New("job concurrency limit reached")
+	// ErrRunNotDispatched indicates the run is still held by the gateway
+	ErrRunNotDispatched = errors.New("run has not been dispatched to the provider yet")
+	// ErrVersionsUnsupported indicates the provider can't trigger at specific versions
+	ErrVersionsUnsupported = errors.New("provider does not support triggering at specific versions")
+	// ErrVersionPinBusy indicates resources to pin are still pinned for a run waiting to start
+	ErrVersionPinBusy = errors.New("resources are pinned for another run waiting to start")
+	// ErrParametersNotRetained indicates a run's sensitive parameters were
+	// masked in the state directory and lost with a restart
+	ErrParametersNotRetained = errors.New("sensitive parameters of this run are not retained across restarts")
+	// ErrRateLimited indicates the runs of a batch or workflow exceed the caller's trigger budget
+	ErrRateLimited = errors.New("rate limit exceeded")
 )
 
+// RateLimitError reports runs of a batch or workflow refused by the rate limiter
+type RateLimitError struct {
+	Runs       int
+	RetryAfter time.Duration // Zero when the runs exceed the budget's burst
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter == 0 {
+		return fmt.Sprintf("rate limit exceeded: %d runs exceed the trigger budget", e.Runs)
+	}
+	return fmt.Sprintf("rate limit exceeded: not enough trigger budget for %d runs", e.Runs)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// Options contains optional service settings
+type Options struct {
+	// StateDir is where gateway state (queued runs etc.) is persisted.
+	// Empty keeps state in memory only.
+	StateDir string
+
+	// PollInterval is how often active runs are refreshed from the provider
+	// and queued runs are dispatched. Defaults to 10s.
+	PollInterval time.Duration
+
+	// Workflows chain configured jobs into DAGs run by the gateway
+	Workflows []*models.Workflow
+
+	// Freezes are configured freeze windows; ad-hoc freezes are added through the API
+	Freezes []*models.Freeze
+
+	// ConfigHash identifies the loaded jobs configuration in detailed health checks
+	ConfigHash string
+
+	// Approvals are the default approval policies by environment, applied to
+	// API-managed jobs that don't set their own
+	Approvals map[string]*models.JobApproval
+
+	// Validation decides how jobs the provider doesn't know are handled on
+	// reload and by ValidateJobs. Defaults to ValidationOff.
+	Validation ValidationMode
+
+	// JobsChanged is called with all jobs after a reload or a change through
+	// the job management API, e.g. to update per-job rate limits
+	JobsChanged func(jobs []*models.Job)
+
+	// AllowRuns charges the runs a batch or workflow triggers against the
+	// caller's rate limits, with one job ID per run. Nil allows all runs.
+	AllowRuns func(ctx context.Context, jobIDs []string) (bool, time.Duration)
+}
+
 // Service coordinates business logic between API and provider layers
 type Service struct {
-	jobs     map[string]*models.Job
+	defs     atomic.Pointer[definitions] // Jobs, workflows and freezes from configuration
 	provider provider.Provider
 	logger   *logger.Logger
+
+	runs          *runStore
+	schedules     *scheduler
+	pollInterval  time.Duration
+	concurrencyMu sync.Mutex     // Serializes slot accounting for concurrency-limited jobs
+	reserved      map[string]int // Slot key -> dispatches in flight; guarded by concurrencyMu
+	pinMu         sync.Mutex     // Serializes version pinning around triggers
+	approvalMu    sync.Mutex     // Serializes approval decisions
+	reloadMu      sync.Mutex     // Serializes configuration swaps
+
+	workflowRuns *workflowStore
+	workflowMu   sync.Mutex // Serializes workflow run updates
+
+	batches *batchStore
+	batchMu sync.Mutex // Serializes batch updates
+
+	freezes *freezeStore // Ad-hoc freezes and the override audit log
+
+	managedJobs *jobStore // Jobs registered through the API
+	jobsChanged func(jobs []*models.Job)
+	allowRuns   func(ctx context.Context, jobIDs []string) (bool, time.Duration)
+
+	validation     ValidationMode
+	availabilityMu sync.Mutex
+	availability   map[string]models.JobAvailability // Job ID -> result of the last provider check
+
+	pauseMu         sync.Mutex
+	pauses          map[string]models.JobPause // Job ID -> pause state last read from the provider
+	pausesCheckedAt time.Time                  // Last pause refresh; zero forces the next one
+	pauseEvents     *pauseStore                // Pause audit log
 }
 
 // NewService creates a new service instance
-func NewService(jobs []*models.Job, prov provider.Provider, log *logger.Logger) *Service {
-	jobMap := make(map[string]*models.Job)
-	for _, j := range jobs {
-		jobMap[j.JobID] = j
+func NewService(jobs []*models.Job, prov provider.Provider, log *logger.Logger, opts Options) (*Service, error) {
+	managedJobs, err := newJobStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load managed jobs: %w", err)
+	}
+
+	defs, err := newDefinitions(Definitions{
+		Jobs:      jobs,
+		Workflows: opts.Workflows,
+		Freezes:   opts.Freezes,
+		Approvals: opts.Approvals,
+		Hash:      opts.ConfigHash,
+	}, managedJobs.list())
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := newRunStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load run store: %w", err)
+	}
+
+	schedules, err := newScheduler(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load schedule state: %w", err)
+	}
+
+	workflowRuns, err := newWorkflowStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load workflow runs: %w", err)
+	}
+
+	batches, err := newBatchStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load batches: %w", err)
+	}
+
+	freezes, err := newFreezeStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load freezes: %w", err)
+	}
+
+	pauseEvents, err := newPauseStore(opts.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load pause events: %w", err)
+	}
+
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 10 * time.Second
 	}
 
-	return &Service{
-		jobs:     jobMap,
-		provider: prov,
-		logger:   log,
+	s := &Service{
+		provider:     prov,
+		logger:       log,
+		runs:         runs,
+		schedules:    schedules,
+		pollInterval: pollInterval,
+		workflowRuns: workflowRuns,
+		batches:      batches,
+		freezes:      freezes,
+		managedJobs:  managedJobs,
+		jobsChanged:  opts.JobsChanged,
+		allowRuns:    opts.AllowRuns,
+		validation:   opts.Validation,
+		pauseEvents:  pauseEvents,
 	}
+	if s.validation == "" {
+		s.validation = ValidationOff
+	}
+	s.defs.Store(defs)
+
+	// Sensitive parameters never reach the state directory
+	runs.redact = s.redactParameters
+	batches.redact = s.redactParameters
+	workflowRuns.redact = s.redactWorkflowParameters
+	return s, nil
+}
+
+// Start launches background processing (run tracking, queue dispatch and
+// scheduled triggers). It returns immediately; processing stops when ctx is canceled.
+func (s *Service) Start(ctx context.Context) {
+	go s.trackRuns(ctx)
+	go s.runScheduler(ctx)
 }
 
 // getLogger retrieves logger from context or falls back to service logger
@@ -52,30 +225,191 @@ func (s *Service) getLogger(ctx context.Context) *logger.Logger {
 	return s.logger
 }
 
-// ListJobs returns all configured jobs
+// callerHasScope reports whether the caller's API key was granted scope
+func callerHasScope(ctx context.Context, scope models.Scope) bool {
+	scopes, _ := ctx.Value("api_key_scopes").([]string)
+	for _, s := range scopes {
+		if models.Scope(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// callerName retrieves the authenticated caller identity from context
+func callerName(ctx context.Context) string {
+	// Using plain string key for cross-package compatibility
+	if name, ok := ctx.Value("api_key_name").(string); ok {
+		return name
+	}
+	return ""
+}
+
+// ListJobs returns all configured jobs ordered by job_id
 func (s *Service) ListJobs(ctx context.Context) []*models.Job {
-	jobs := make([]*models.Job, 0, len(s.jobs))
-	for _, j := range s.jobs {
-		jobs = append(jobs, j)
+	defs := s.definitions()
+	jobs := make([]*models.Job, 0, len(defs.jobs))
+	for _, j := range defs.jobs {
+		jobs = append(jobs, s.withProviderState(j))
 	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].JobID < jobs[j].JobID })
 	return jobs
 }
 
 // TriggerRun triggers a new run for the specified job
 func (s *Service) TriggerRun(ctx context.Context, jobID string, params map[string]interface{}, idempotencyKey string) (*models.Run, error) {
+	return s.TriggerRunAt(ctx, jobID, params, idempotencyKey, nil)
+}
+
+// TriggerRunAt triggers a new run with the given input resources pinned to
+// specific versions (e.g. {"source-code": {"ref": "abc123"}}). Previous pins are
+// restored once the run has started.
+func (s *Service) TriggerRunAt(ctx context.Context, jobID string, params map[string]interface{}, idempotencyKey string, versions map[string]map[string]string) (*models.Run, error) {
 	logger := s.getLogger(ctx)
 
 	logger.Debug("service: triggering run",
 		"job_id", jobID,
 		"param_count", len(params),
+		"version_count", len(versions),
 		"has_idempotency_key", idempotencyKey != "")
 
-	job, exists := s.jobs[jobID]
+	job, exists := s.job(jobID)
 	if !exists {
 		logger.Debug("service: job not found", "job_id", jobID)
 		return nil, ErrJobNotFound
 	}
 
+	params, err := validateParameters(job, params)
+	if err != nil {
+		logger.Debug("service: parameters rejected", "job_id", jobID, "error", err)
+		return nil, err
+	}
+	if err := validateVersions(versions); err != nil {
+		logger.Debug("service: versions rejected", "job_id", jobID, "error", err)
+		return nil, err
+	}
+
+	// Versions the job ref maps from parameters are pinned and restored like
+	// requested ones
+	mapped, err := s.mapVersions(job, params)
+	if err != nil {
+		logger.Debug("service: versions not mapped", "job_id", jobID, "error", err)
+		return nil, err
+	}
+	versions = mergeVersions(mapped, versions)
+
+	return s.submit(ctx, job, &runRecord{
+		JobID:          jobID,
+		Parameters:     params,
+		IdempotencyKey: idempotencyKey,
+		Versions:       versions,
+		TriggeredBy:    callerName(ctx),
+		Attempt:        1,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// triggerOnce triggers a run for a gateway-internal caller (a schedule, workflow
+// or batch) and returns the original run when a trigger with the same key is
+// already recorded, e.g. when the caller repeats it after a crash. Keys of
+// API triggers are only passed on to the provider.
+func (s *Service) triggerOnce(ctx context.Context, jobID string, params map[string]interface{}, key string) (*models.Run, error) {
+	if existing := s.runs.list(func(r *runRecord) bool {
+		return r.JobID == jobID && r.IdempotencyKey == key
+	}); len(existing) > 0 {
+		s.getLogger(ctx).Info("service: repeated trigger replayed",
+			"job_id", jobID,
+			"run_id", existing[0].ID,
+			"key", key)
+		if run, err := s.GetRun(ctx, existing[0].ID); err == nil {
+			return run, nil
+		}
+		return existing[0].toRun(), nil
+	}
+	return s.TriggerRun(ctx, jobID, params, key)
+}
+
+// chargeRuns checks the runs of a batch or workflow against the rate limiter
+func (s *Service) chargeRuns(ctx context.Context, jobIDs []string) error {
+	if s.allowRuns == nil {
+		return nil
+	}
+	if ok, retryAfter := s.allowRuns(ctx, jobIDs); !ok {
+		s.getLogger(ctx).Info("service: runs refused by rate limit",
+			"caller", callerName(ctx),
+			"runs", len(jobIDs),
+			"retry_after", retryAfter)
+		return &RateLimitError{Runs: len(jobIDs), RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// submit checks freeze windows for a new run and hands it to the job's
+// approval gate or concurrency policy
+func (s *Service) submit(ctx context.Context, job *models.Job, rec *runRecord) (*models.Run, error) {
+	rec.Environment = job.Environment
+	rec.Sensitive = sensitiveParameters(job, rec.Sensitive)
+	override, err := s.checkFreeze(ctx, job, time.Now())
+	if err != nil && rec.attempt() == 1 {
+		return nil, err
+	}
+	// Retries are held until the freeze ends
+	rec.FreezeOverride = override
+
+	var run *models.Run
+	if job.Approval != nil {
+		run, err = s.requestApproval(ctx, job, rec)
+	} else {
+		run, err = s.release(ctx, job, rec)
+	}
+	if err == nil && override != nil {
+		s.auditFreezeOverride(ctx, override, run.RunID)
+	}
+	return run, err
+}
+
+// release hands a run to the job's concurrency policy, or dispatches it
+// directly. Retries still in their backoff are held, as are existing runs
+// blocked by pinned versions or a freeze.
+func (s *Service) release(ctx context.Context, job *models.Job, rec *runRecord) (*models.Run, error) {
+	if !rec.isReady(time.Now()) {
+		return s.enqueue(ctx, rec)
+	}
+	var run *models.Run
+	var err error
+	if job.Concurrency != nil {
+		run, err = s.triggerLimited(ctx, job, rec)
+	} else {
+		run, err = s.dispatch(ctx, job, rec)
+	}
+	if (errors.Is(err, ErrVersionPinBusy) || errors.Is(err, ErrJobFrozen)) && rec.ID != "" {
+		// Approved runs and retries already exist; they wait for the pins or
+		// the end of the freeze like held runs instead of failing
+		return s.enqueue(ctx, rec)
+	}
+	return run, err
+}
+
+// dispatch hands a run to the provider and records it.
+// Records without an ID take the provider run_id as their ID.
+func (s *Service) dispatch(ctx context.Context, job *models.Job, rec *runRecord) (*models.Run, error) {
+	logger := s.getLogger(ctx)
+	jobID := job.JobID
+
+	if params.HasRedacted(job.Parameters, rec.Parameters) {
+		logger.Warn("service: run lost its sensitive parameters", "job_id", jobID, "run_id", rec.ID)
+		return nil, ErrParametersNotRetained
+	}
+
+	// Held runs can reach this long after their trigger; runs let through a
+	// freeze by an override keep going
+	if rec.FreezeOverride == nil {
+		if f := s.activeFreeze(job, time.Now()); f != nil {
+			logger.Info("service: dispatch blocked by freeze", "job_id", jobID, "run_id", rec.ID, "freeze_id", f.FreezeID)
+			return nil, &FreezeError{JobID: jobID, Freeze: f}
+		}
+	}
+
 	// Convert job to provider-specific JobRef
 	logger.Debug("service: building job ref",
 		"job_id", jobID,
@@ -88,19 +422,62 @@ func (s *Service) TriggerRun(ctx context.Context, jobID string, params map[strin
 		return nil, fmt.Errorf("build job ref: %w", err)
 	}
 
+	triggerParams := provider.TriggerParams{
+		Parameters:     rec.Parameters,
+		IdempotencyKey: rec.IdempotencyKey,
+		Versions:       rec.Versions,
+	}
+
+	// Reruns reuse the inputs of the provider run they were rerun from, so
+	// versions don't need pinning
+	rerunRef := s.rerunRef(rec)
+	pin := len(rec.Versions) > 0 && rerunRef == nil
+
+	var previousPins []provider.ResourcePin
+	var keys []string
+	if pin {
+		s.pinMu.Lock()
+		defer s.pinMu.Unlock()
+
+		keys = pinKeys(jobRef, rec.Versions)
+		if previousPins, err = s.pinVersions(ctx, job, jobRef, triggerParams, keys); err != nil {
+			return nil, err
+		}
+	}
+
 	// Trigger via provider
-	logger.Debug("service: calling provider trigger", "job_id", jobID)
-	runRef, err := s.provider.Trigger(ctx, jobRef, provider.TriggerParams{
-		Parameters:     params,
-		IdempotencyKey: idempotencyKey,
-	})
+	var runRef provider.RunRef
+	if rerunRef != nil {
+		logger.Debug("service: calling provider rerun", "job_id", jobID, "rerun_of", rerunRef.ID())
+		runRef, err = s.provider.(provider.Rerunner).Rerun(ctx, rerunRef)
+	} else {
+		logger.Debug("service: calling provider trigger", "job_id", jobID)
+		runRef, err = s.provider.Trigger(ctx, jobRef, triggerParams)
+	}
 	if err != nil {
 		logger.Error("service: provider trigger failed",
 			"job_id", jobID,
 			"error", err)
+		if pin {
+			s.restorePinsNow(ctx, jobRef, triggerParams, previousPins)
+		}
 		return nil, fmt.Errorf("trigger run: %w", err)
 	}
 
+	now := time.Now()
+	if pin {
+		rec.PreviousPins = previousPins
+		rec.PinsPending = true
+		rec.PinKeys = keys
+	}
+	rec.ProviderRunID = runRef.ID()
+	rec.GatewayStatus = models.GatewayStatusDispatched
+	rec.DispatchedAt = &now
+	rec.Status = models.StatusQueued
+	if rec.ID == "" {
+		rec.ID = rec.ProviderRunID
+	}
+
 	// Get initial status
 	logger.Debug("service: fetching initial run status", "job_id", jobID)
 	providerRun, err := s.provider.GetRun(ctx, runRef)
@@ -108,18 +485,78 @@ func (s *Service) TriggerRun(ctx context.Context, jobID string, params map[strin
 		logger.Error("service: failed to get run status",
 			"job_id", jobID,
 			"error", err)
+		if putErr := s.runs.put(rec); putErr != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", putErr)
+		}
 		return nil, fmt.Errorf("get run status: %w", err)
 	}
 
-	// Add job_id to the run
-	providerRun.JobID = jobID
+	s.recordProviderRun(rec, providerRun)
+	if err := s.runs.put(rec); err != nil {
+		logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+	}
+
+	run := mergeRun(rec, providerRun)
 
 	logger.Info("service: run triggered successfully",
 		"job_id", jobID,
-		"run_id", providerRun.RunID,
-		"status", providerRun.Status)
+		"run_id", run.RunID,
+		"provider_run_id", rec.ProviderRunID,
+		"status", run.Status)
 
-	return providerRun, nil
+	return run, nil
+}
+
+// recordProviderRun applies a provider run to a record. A run seen finishing
+// is flagged for retry if the job's retry policy covers it at that moment.
+func (s *Service) recordProviderRun(rec *runRecord, run *models.Run) {
+	wasTerminal := rec.Status.IsTerminal()
+	applyProviderRun(rec, run)
+	if !wasTerminal && rec.Status.IsTerminal() {
+		rec.RetryEligible = s.retryCovers(rec)
+	}
+}
+
+// applyProviderRun copies provider status and timestamps onto a record
+func applyProviderRun(rec *runRecord, run *models.Run) {
+	rec.Status = run.Status
+	rec.StartedAt = run.StartedAt
+	rec.FinishedAt = run.FinishedAt
+	if rec.Status.IsTerminal() && rec.FinishedAt == nil {
+		now := time.Now()
+		rec.FinishedAt = &now
+	}
+}
+
+// mergeRun combines the provider's view of a run with the gateway record
+func mergeRun(rec *runRecord, providerRun *models.Run) *models.Run {
+	run := *providerRun
+	run.RunID = rec.ID
+	run.JobID = rec.JobID
+	run.GatewayStatus = rec.GatewayStatus
+	run.Reason = rec.Reason
+	run.CancelReason = rec.CancelReason
+	run.Attempt = rec.attempt()
+	run.RerunOf = rec.RerunOf
+	if rec.TriggeredBy != "" {
+		run.TriggeredBy = rec.TriggeredBy
+	}
+	run.Versions = rec.Versions
+	if rec.InputVersions != nil {
+		run.InputVersions = rec.InputVersions
+	}
+	if rec.FailingStep != "" {
+		run.FailingStep = rec.FailingStep
+	}
+	run.Approvals = rec.Approvals
+	run.FreezeOverride = rec.FreezeOverride
+	if run.DurationSeconds == 0 {
+		run.DurationSeconds = runDuration(run.StartedAt, run.FinishedAt)
+	}
+	if rec.ProviderRunID != rec.ID {
+		run.ProviderRunID = rec.ProviderRunID
+	}
+	return &run
 }
 
 // GetRun retrieves the status of a run
@@ -128,8 +565,28 @@ func (s *Service) GetRun(ctx context.Context, runID string) (*models.Run, error)
 
 	logger.Debug("service: getting run status", "run_id", runID)
 
+	// Runs held by the gateway are answered from the run store
+	rec, tracked := s.runs.get(runID)
+	if tracked && rec.GatewayStatus != models.GatewayStatusDispatched {
+		run := rec.toRun()
+		if rec.GatewayStatus.IsHeld() {
+			run.QueuePosition = s.queuePosition(rec)
+		}
+		s.fillAttempts(run, rec)
+		run.Parameters = s.redactParameters(rec.JobID, rec.Sensitive, rec.Parameters)
+		logger.Debug("service: run held by gateway",
+			"run_id", runID,
+			"gateway_status", rec.GatewayStatus)
+		return run, nil
+	}
+
+	providerRunID := runID
+	if tracked {
+		providerRunID = rec.ProviderRunID
+	}
+
 	// Parse run_id to provider-specific RunRef
-	runRef, err := s.parseRunRef(runID)
+	runRef, err := s.parseRunRef(providerRunID)
 	if err != nil {
 		logger.Debug("service: failed to parse run_id", "run_id", runID, "error", err)
 		return nil, ErrRunNotFound
@@ -145,6 +602,19 @@ func (s *Service) GetRun(ctx context.Context, runID string) (*models.Run, error)
 		return nil, err
 	}
 
+	if tracked {
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) {
+			s.recordProviderRun(r, providerRun)
+			rec.RetryEligible = r.RetryEligible
+		}); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+		providerRun = mergeRun(rec, providerRun)
+		s.fillAttempts(providerRun, rec)
+		providerRun.Parameters = s.redactParameters(rec.JobID, rec.Sensitive, rec.Parameters)
+	}
+	s.fillRunDetails(ctx, providerRun, runRef, rec)
+
 	logger.Debug("service: run status retrieved",
 		"run_id", runID,
 		"status", providerRun.Status)
@@ -152,13 +622,31 @@ func (s *Service) GetRun(ctx context.Context, runID string) (*models.Run, error)
 	return providerRun, nil
 }
 
+// resolveProviderRunID maps a gateway run_id to the provider run_id
+func (s *Service) resolveProviderRunID(runID string) (string, error) {
+	rec, tracked := s.runs.get(runID)
+	if !tracked {
+		return runID, nil
+	}
+	if rec.GatewayStatus != models.GatewayStatusDispatched {
+		return "", ErrRunNotDispatched
+	}
+	return rec.ProviderRunID, nil
+}
+
 // StreamRunEvents streams events for a run
 func (s *Service) StreamRunEvents(ctx context.Context, runID string, writer io.Writer) error {
 	logger := s.getLogger(ctx)
 
 	logger.Info("service: starting event stream", "run_id", runID)
 
-	runRef, err := s.parseRunRef(runID)
+	providerRunID, err := s.resolveProviderRunID(runID)
+	if err != nil {
+		logger.Debug("service: run not streamable", "run_id", runID, "error", err)
+		return err
+	}
+
+	runRef, err := s.parseRunRef(providerRunID)
 	if err != nil {
 		logger.Debug("service: failed to parse run_id for streaming", "run_id", runID, "error", err)
 		return ErrRunNotFound
@@ -180,7 +668,23 @@ func (s *Service) CancelRun(ctx context.Context, runID string) error {
 
 	logger.Info("service: canceling run", "run_id", runID)
 
-	runRef, err := s.parseRunRef(runID)
+	// Runs still held by the gateway are canceled without calling the provider
+	if rec, tracked := s.runs.get(runID); tracked && (rec.GatewayStatus.IsHeld() || rec.awaitingApproval()) {
+		if err := s.finishHeld(rec.ID, models.GatewayStatusCanceled, "canceled by "+callerLabel(ctx)); err != nil {
+			logger.Error("service: failed to cancel held run", "run_id", runID, "error", err)
+			return err
+		}
+		s.stopRetries(ctx, rec.originID())
+		logger.Info("service: held run canceled", "run_id", runID)
+		return nil
+	}
+
+	providerRunID, err := s.resolveProviderRunID(runID)
+	if err != nil {
+		return err
+	}
+
+	runRef, err := s.parseRunRef(providerRunID)
 	if err != nil {
 		logger.Debug("service: failed to parse run_id for cancel", "run_id", runID, "error", err)
 		return ErrRunNotFound
@@ -192,10 +696,29 @@ func (s *Service) CancelRun(ctx context.Context, runID string) error {
 		return err
 	}
 
+	if rec, tracked := s.runs.get(runID); tracked {
+		reason := "canceled by " + callerLabel(ctx)
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) {
+			r.Reason = reason
+			r.CancelReason = models.CancelReasonUser
+		}); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+		s.stopRetries(ctx, rec.originID())
+	}
+
 	logger.Info("service: run canceled successfully", "run_id", runID)
 	return nil
 }
 
+// callerLabel returns the caller identity for human-facing messages
+func callerLabel(ctx context.Context) string {
+	if name := callerName(ctx); name != "" {
+		return name
+	}
+	return "gateway"
+}
+
 // buildJobRef converts a Job to a provider-specific JobRef
 func (s *Service) buildJobRef(job *models.Job) (provider.JobRef, error) {
 	switch job.Provider.Kind {
@@ -214,11 +737,36 @@ func (s *Service) buildJobRef(job *models.Job) (provider.JobRef, error) {
 			return nil, fmt.Errorf("missing or invalid 'job' in concourse job ref")
 		}
 
-		return &concourse.ConcourseJobRef{
+		jobRef := &concourse.ConcourseJobRef{
 			Team:     team,
 			Pipeline: pipeline,
 			Job:      jobName,
-		}, nil
+		}
+
+		if raw, ok := job.Provider.Ref["instance_vars"]; ok {
+			vars, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid 'instance_vars' in concourse job ref: expected a map")
+			}
+			jobRef.InstanceVars = vars
+		}
+
+		if raw, ok := job.Provider.Ref["pin"]; ok {
+			pins, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid 'pin' in concourse job ref: expected a map of resources")
+			}
+			jobRef.Pins = make(map[string]map[string]interface{}, len(pins))
+			for resource, version := range pins {
+				fields, ok := version.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid 'pin.%s' in concourse job ref: expected a version map", resource)
+				}
+				jobRef.Pins[resource] = fields
+			}
+		}
+
+		return jobRef, nil
 	default:
 		return nil, fmt.Errorf("unsupported provider kind: %s", job.Provider.Kind)
 	}
@@ -379,10 +927,32 @@ func (s *Service) HealthCheck(ctx context.Context) map[string]interface{} {
 	checks := health["checks"].(map[string]interface{})
 
 	// Check job configuration
-	checks["job_config"] = map[string]interface{}{
-		"status": "healthy",
-		"count":  len(s.jobs),
+	defs := s.definitions()
+	jobConfig := map[string]interface{}{
+		"status":    "healthy",
+		"count":     len(defs.jobs),
+		"version":   defs.version,
+		"loaded_at": defs.loadedAt,
+	}
+	if defs.hash != "" {
+		jobConfig["hash"] = defs.hash
+	}
+	unavailable := 0
+	for _, j := range s.ListJobs(ctx) {
+		if j.Availability != nil && !j.Availability.Available {
+			unavailable++
+		}
+	}
+	if unavailable > 0 {
+		jobConfig["status"] = "degraded"
+		jobConfig["unavailable"] = unavailable
+	}
+	if defs.reloadError != "" {
+		// The previous configuration stays in effect
+		jobConfig["last_reload_error"] = defs.reloadError
+		jobConfig["last_reload_error_at"] = defs.reloadErrorAt
 	}
+	checks["job_config"] = jobConfig
 
 	// Check provider connectivity
 	adapter, ok := s.provider.(*concourse.Adapter)
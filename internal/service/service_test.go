@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+// fakeProvider records triggers and serves run statuses from memory
+type fakeProvider struct {
+	mu       sync.Mutex
+	nextID   int
+	statuses map[int]models.RunStatus
+	triggers []provider.TriggerParams
+	canceled []int
+
+	cancelErr error // Returned by Cancel when set
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{statuses: make(map[int]models.RunStatus)}
+}
+
+func (f *fakeProvider) Trigger(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams) (provider.RunRef, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ref := jobRef.(*concourse.ConcourseJobRef)
+	f.nextID++
+	f.statuses[f.nextID] = models.StatusRunning
+	f.triggers = append(f.triggers, params)
+	return &concourse.ConcourseRunRef{Team: ref.Team, Pipeline: ref.Pipeline, Job: ref.Job, BuildID: f.nextID}, nil
+}
+
+func (f *fakeProvider) GetRun(ctx context.Context, runRef provider.RunRef) (*models.Run, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ref := runRef.(*concourse.ConcourseRunRef)
+	status, ok := f.statuses[ref.BuildID]
+	if !ok {
+		return nil, provider.ErrRunNotFound
+	}
+	return &models.Run{RunID: ref.ID(), Status: status, CreatedAt: time.Now()}, nil
+}
+
+func (f *fakeProvider) StreamEvents(ctx context.Context, runRef provider.RunRef, writer io.Writer) error {
+	return nil
+}
+
+func (f *fakeProvider) Cancel(ctx context.Context, runRef provider.RunRef) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cancelErr != nil {
+		return f.cancelErr
+	}
+	ref := runRef.(*concourse.ConcourseRunRef)
+	f.statuses[ref.BuildID] = models.StatusCanceled
+	f.canceled = append(f.canceled, ref.BuildID)
+	return nil
+}
+
+// finish marks a build as finished with the given status
+func (f *fakeProvider) finish(buildID int, status models.RunStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[buildID] = status
+}
+
+// capableProvider is a fakeProvider with every optional provider capability.
+// Its runs started a minute ago, reruns and pause state are kept in memory,
+// and every known build reports the same steps, inputs and outputs.
+type capableProvider struct {
+	*fakeProvider
+	reruns   []int           // Builds rerun, in order
+	jobs     map[string]bool // Job name -> paused
+	pipeline bool            // Pipeline paused
+
+	inputReads, stepReads int
+}
+
+func newCapableProvider() *capableProvider {
+	return &capableProvider{fakeProvider: newFakeProvider(), jobs: make(map[string]bool)}
+}
+
+func (p *capableProvider) GetRun(ctx context.Context, runRef provider.RunRef) (*models.Run, error) {
+	run, err := p.fakeProvider.GetRun(ctx, runRef)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now().Add(-time.Minute)
+	run.StartedAt = &started
+	run.TriggeredBy = "concourse-bot"
+	return run, nil
+}
+
+// LatestRuns reports the highest finished and the lowest unfinished build
+func (p *capableProvider) LatestRuns(ctx context.Context, jobRef provider.JobRef) (*models.Run, *models.Run, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ref := jobRef.(*concourse.ConcourseJobRef)
+	var finished, next *models.Run
+	for id := 1; id <= p.nextID; id++ {
+		runRef := &concourse.ConcourseRunRef{Team: ref.Team, Pipeline: ref.Pipeline, Job: ref.Job, BuildID: id}
+		run := &models.Run{RunID: runRef.ID(), Status: p.statuses[id], CreatedAt: time.Now()}
+		switch {
+		case run.Status.IsTerminal():
+			finished = run
+		case next == nil:
+			next = run
+		}
+	}
+	return finished, next, nil
+}
+
+func (p *capableProvider) Rerun(ctx context.Context, runRef provider.RunRef) (provider.RunRef, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ref := runRef.(*concourse.ConcourseRunRef)
+	if _, ok := p.statuses[ref.BuildID]; !ok {
+		return nil, provider.ErrRunNotFound
+	}
+	p.nextID++
+	p.statuses[p.nextID] = models.StatusRunning
+	p.reruns = append(p.reruns, ref.BuildID)
+	return &concourse.ConcourseRunRef{Team: ref.Team, Pipeline: ref.Pipeline, Job: ref.Job, BuildID: p.nextID}, nil
+}
+
+func (p *capableProvider) SetPaused(ctx context.Context, jobRef provider.JobRef, target models.PauseTarget, paused bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if target == models.PauseTargetPipeline {
+		p.pipeline = paused
+	} else {
+		p.jobs[jobRef.(*concourse.ConcourseJobRef).Job] = paused
+	}
+	return nil
+}
+
+func (p *capableProvider) PauseState(ctx context.Context, jobRef provider.JobRef) (*models.JobPause, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return &models.JobPause{Job: p.jobs[jobRef.(*concourse.ConcourseJobRef).Job], Pipeline: p.pipeline, CheckedAt: time.Now()}, nil
+}
+
+// Steps reports a get, a try around a failing lint task and a failing test task
+func (p *capableProvider) Steps(ctx context.Context, runRef provider.RunRef) (*models.Step, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.statuses[runRef.(*concourse.ConcourseRunRef).BuildID]; !ok {
+		return nil, provider.ErrRunNotFound
+	}
+	p.stepReads++
+	exit := 1
+	return &models.Step{ID: "root", Type: models.StepDo, Status: models.StepFailed, Children: []*models.Step{
+		{ID: "a", Type: models.StepGet, Name: "repo", Status: models.StepSucceeded},
+		{ID: "b", Type: models.StepTry, Status: models.StepSucceeded, Children: []*models.Step{
+			{ID: "c", Type: models.StepTask, Name: "lint", Status: models.StepFailed, ExitStatus: &exit},
+		}},
+		{ID: "d", Type: models.StepTask, Name: "test", Status: models.StepFailed, ExitStatus: &exit},
+	}}, nil
+}
+
+func (p *capableProvider) InputVersions(ctx context.Context, runRef provider.RunRef) (map[string]map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inputReads++
+	return map[string]map[string]string{"repo": {"ref": "abc123"}}, nil
+}
+
+// RunResources reports a git input and an image output
+func (p *capableProvider) RunResources(ctx context.Context, runRef provider.RunRef) (*models.RunResources, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.statuses[runRef.(*concourse.ConcourseRunRef).BuildID]; !ok {
+		return nil, provider.ErrRunNotFound
+	}
+	return &models.RunResources{
+		Inputs: []models.RunResource{
+			{Name: "repo", Type: "git", Version: map[string]string{"ref": "abc123"}, Metadata: map[string]string{"author": "alice"}},
+		},
+		Outputs: []models.RunResource{
+			{Name: "image", Type: "registry-image", Version: map[string]string{"digest": "sha256:f00"}},
+		},
+	}, nil
+}
+
+var (
+	_ provider.LatestRunsReader   = (*capableProvider)(nil)
+	_ provider.Rerunner           = (*capableProvider)(nil)
+	_ provider.Pauser             = (*capableProvider)(nil)
+	_ provider.StepReader         = (*capableProvider)(nil)
+	_ provider.InputVersionReader = (*capableProvider)(nil)
+	_ provider.RunResourceReader  = (*capableProvider)(nil)
+)
+
+func newTestService(t *testing.T, prov provider.Provider, jobs ...*models.Job) *Service {
+	t.Helper()
+	svc, err := NewService(jobs, prov, logger.New("error", "text"), Options{StateDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return svc
+}
+
+func testJob(id string) *models.Job {
+	return &models.Job{
+		JobID: id,
+		Provider: models.JobProviderConfig{
+			Kind: "concourse",
+			Ref:  map[string]interface{}{"team": "main", "pipeline": "p", "job": id},
+		},
+	}
+}
+
+func TestTriggerOnce_ReplaysRecordedRun(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("job_a"), testJob("job_b"))
+	ctx := context.Background()
+
+	first, err := svc.triggerOnce(ctx, "job_a", nil, "batch:b1:0")
+	if err != nil {
+		t.Fatalf("triggerOnce() error = %v", err)
+	}
+	repeated, err := svc.triggerOnce(ctx, "job_a", nil, "batch:b1:0")
+	if err != nil {
+		t.Fatalf("repeated triggerOnce() error = %v", err)
+	}
+	if repeated.RunID != first.RunID || len(prov.triggers) != 1 {
+		t.Errorf("repeated trigger = %s with %d provider triggers, want %s with 1", repeated.RunID, len(prov.triggers), first.RunID)
+	}
+
+	// Keys are scoped to the job
+	if _, err := svc.triggerOnce(ctx, "job_b", nil, "batch:b1:0"); err != nil {
+		t.Fatalf("triggerOnce() for other job error = %v", err)
+	}
+	if len(prov.triggers) != 2 {
+		t.Errorf("provider triggers = %d, want 2", len(prov.triggers))
+	}
+}
+
+func TestTriggerRun_IdempotencyKeyPassedToProvider(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("job_a"))
+	ctx := context.Background()
+
+	first, _ := svc.TriggerRun(ctx, "job_a", nil, "client-key")
+	second, err := svc.TriggerRun(ctx, "job_a", nil, "client-key")
+	if err != nil {
+		t.Fatalf("second TriggerRun() error = %v", err)
+	}
+	if second.RunID == first.RunID || len(prov.triggers) != 2 {
+		t.Fatalf("API triggers with the same key replayed by the gateway: %d provider triggers", len(prov.triggers))
+	}
+	if prov.triggers[1].IdempotencyKey != "client-key" {
+		t.Errorf("provider idempotency key = %q, want client-key", prov.triggers[1].IdempotencyKey)
+	}
+}
+
+func TestSensitiveParameters_NotPersisted(t *testing.T) {
+	dir := t.TempDir()
+	prov := newFakeProvider()
+	ctx := context.Background()
+	log := logger.New("error", "text")
+
+	job := limitedJob(models.PolicyQueue)
+	job.Parameters = []models.JobParameter{
+		{Name: "ref", Type: models.ParamString},
+		{Name: "token", Type: models.ParamString, Sensitive: true},
+	}
+	svc, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	first, _ := svc.TriggerRun(ctx, "job_deploy", map[string]interface{}{"ref": "main", "token": "s3cret"}, "")
+	queued, _ := svc.TriggerRun(ctx, "job_deploy", map[string]interface{}{"ref": "main", "token": "s3cret"}, "")
+	if _, err := svc.TriggerBatch(ctx, "job_deploy", BatchRequest{
+		ParameterSets: []map[string]interface{}{{"token": "s3cret"}},
+	}); err != nil {
+		t.Fatalf("TriggerBatch() error = %v", err)
+	}
+	if prov.triggers[0].Parameters["token"] != "s3cret" {
+		t.Fatalf("provider token = %v, want the real value", prov.triggers[0].Parameters["token"])
+	}
+
+	for _, name := range []string{"runs.json", "batches.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if strings.Contains(string(data), "s3cret") {
+			t.Errorf("%s contains a sensitive value", name)
+		}
+	}
+
+	// After a restart the values are gone: held runs and reruns can't use them
+	restarted, err := NewService([]*models.Job{job}, prov, log, Options{StateDir: dir})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	if _, err := restarted.RerunRun(ctx, first.RunID); !errors.Is(err, ErrParametersNotRetained) {
+		t.Errorf("RerunRun() error = %v, want ErrParametersNotRetained", err)
+	}
+	prov.finish(1, models.StatusSucceeded)
+	restarted.reconcile(ctx)
+	run, _ := restarted.GetRun(ctx, queued.RunID)
+	if run.GatewayStatus != models.GatewayStatusDispatchFailed || len(prov.triggers) != 1 {
+		t.Errorf("held run gateway_status = %q with %d triggers, want dispatch_failed without a trigger", run.GatewayStatus, len(prov.triggers))
+	}
+}
+
+func TestSensitiveParameters_MaskedAfterJobRemoved(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	job := testJob("deploy")
+	job.Parameters = []models.JobParameter{
+		{Name: "ref", Type: models.ParamString},
+		{Name: "token", Type: models.ParamString, Sensitive: true},
+	}
+	svc := newTestService(t, prov, job)
+
+	run, err := svc.TriggerRun(ctx, "deploy", map[string]interface{}{"ref": "main", "token": "s3cret"}, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	if err := svc.Reload(ctx, Definitions{}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// The names recorded at trigger time still apply without the job
+	got, err := svc.GetRun(ctx, run.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if got.Parameters["token"] != params.Redacted || got.Parameters["ref"] != "main" {
+		t.Errorf("parameters = %v, want only token masked", got.Parameters)
+	}
+
+	// Without recorded names or a schema, every value is masked
+	masked := svc.redactParameters("deploy", nil, map[string]interface{}{"ref": "main"})
+	if masked["ref"] != params.Redacted {
+		t.Errorf("unrecorded parameters = %v, want every value masked", masked)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// ErrStepsUnsupported indicates the provider can't report the steps of a run
+var ErrStepsUnsupported = errors.New("provider does not support run steps")
+
+// GetRunSteps returns the step tree of a dispatched run with the status and
+// timing of each step
+func (s *Service) GetRunSteps(ctx context.Context, runID string) (*models.Step, error) {
+	logger := s.getLogger(ctx)
+
+	logger.Debug("service: getting run steps", "run_id", runID)
+
+	reader, ok := s.provider.(provider.StepReader)
+	if !ok {
+		return nil, ErrStepsUnsupported
+	}
+
+	providerRunID, err := s.resolveProviderRunID(runID)
+	if err != nil {
+		logger.Debug("service: run has no steps yet", "run_id", runID, "error", err)
+		return nil, err
+	}
+
+	runRef, err := s.parseRunRef(providerRunID)
+	if err != nil {
+		logger.Debug("service: failed to parse run_id for steps", "run_id", runID, "error", err)
+		return nil, ErrRunNotFound
+	}
+
+	steps, err := reader.Steps(ctx, runRef)
+	if err != nil {
+		if errors.Is(err, provider.ErrRunNotFound) {
+			return nil, ErrRunNotFound
+		}
+		logger.Error("service: failed to get run steps", "run_id", runID, "error", err)
+		return nil, err
+	}
+
+	logger.Debug("service: run steps retrieved", "run_id", runID, "status", steps.Status)
+	return steps, nil
+}
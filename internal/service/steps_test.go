@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestGetRunSteps(t *testing.T) {
+	prov := newCapableProvider()
+	svc := newTestService(t, prov, limitedJob(models.PolicyQueue))
+	ctx := context.Background()
+
+	first, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	held, err := svc.TriggerRun(ctx, "job_deploy", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+
+	steps, err := svc.GetRunSteps(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("GetRunSteps() error = %v", err)
+	}
+	if failing := steps.Failing(); failing == nil || failing.Name != "test" {
+		t.Errorf("Failing() = %+v, want the test task", failing)
+	}
+
+	if _, err := svc.GetRunSteps(ctx, held.RunID); !errors.Is(err, ErrRunNotDispatched) {
+		t.Errorf("GetRunSteps(held) error = %v, want ErrRunNotDispatched", err)
+	}
+	if _, err := svc.GetRunSteps(ctx, "main:p:job_deploy:99"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("GetRunSteps(unknown) error = %v, want ErrRunNotFound", err)
+	}
+}
+
+func TestGetRunSteps_Unsupported(t *testing.T) {
+	svc := newTestService(t, newFakeProvider(), testJob("job_a"))
+
+	if _, err := svc.GetRunSteps(context.Background(), "main:p:job_a:1"); !errors.Is(err, ErrStepsUnsupported) {
+		t.Errorf("GetRunSteps() error = %v, want ErrStepsUnsupported", err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+// enforceTimeouts aborts dispatched runs that have been active longer than
+// their job's timeout. The timeout is measured from dispatch, so runs stuck
+// pending in the provider (e.g. waiting on a lock) are caught as well.
+func (s *Service) enforceTimeouts(ctx context.Context, now time.Time) {
+	logger := s.getLogger(ctx)
+
+	active := s.runs.list(func(r *runRecord) bool { return r.isActive() })
+	for _, rec := range active {
+		job, exists := s.job(rec.JobID)
+		if !exists || job.Timeout == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(job.Timeout)
+		if err != nil || timeout <= 0 {
+			continue
+		}
+
+		since := rec.CreatedAt
+		if rec.DispatchedAt != nil {
+			since = *rec.DispatchedAt
+		}
+		if now.Sub(since) < timeout {
+			continue
+		}
+
+		runRef, err := s.parseRunRef(rec.ProviderRunID)
+		if err == nil {
+			err = s.provider.Cancel(ctx, runRef)
+		}
+		if err != nil {
+			logger.Error("service: failed to abort timed out run",
+				"job_id", rec.JobID,
+				"run_id", rec.ID,
+				"error", err)
+			continue
+		}
+
+		finished := now
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) {
+			r.Status = models.StatusCanceled
+			r.Reason = fmt.Sprintf("timed out after %s", job.Timeout)
+			r.CancelReason = models.CancelReasonTimeout
+			r.FinishedAt = &finished
+			r.NoRetry = true // Retrying would likely time out again
+		}); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+
+		logger.Warn("service: run aborted after timeout",
+			"job_id", rec.JobID,
+			"run_id", rec.ID,
+			"timeout", job.Timeout)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+)
+
+func TestEnforceTimeouts(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	job := testJob("hangs")
+	job.Timeout = "1h"
+	job.Retry = &models.JobRetry{MaxAttempts: 2, On: []models.RunStatus{models.StatusCanceled}}
+	svc := newTestService(t, prov, job)
+
+	run, err := svc.TriggerRun(ctx, "hangs", nil, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+
+	svc.enforceTimeouts(ctx, time.Now().Add(30*time.Minute))
+	if len(prov.canceled) != 0 {
+		t.Fatalf("run aborted before its timeout")
+	}
+
+	svc.enforceTimeouts(ctx, time.Now().Add(2*time.Hour))
+	if len(prov.canceled) != 1 {
+		t.Fatalf("canceled = %v, want the timed out build", prov.canceled)
+	}
+
+	got, err := svc.GetRun(ctx, run.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if got.Status != models.StatusCanceled || got.CancelReason != models.CancelReasonTimeout {
+		t.Errorf("status = %q, cancel_reason = %q; want canceled, timeout", got.Status, got.CancelReason)
+	}
+
+	// Timed out runs aren't retried, even when the policy covers canceled runs
+	svc.scheduleRetries(ctx, time.Now())
+	got, _ = svc.GetRun(ctx, run.RunID)
+	if len(got.Attempts) != 1 || got.FinalStatus != models.StatusCanceled {
+		t.Errorf("attempts = %+v, final = %q; want no retry", got.Attempts, got.FinalStatus)
+	}
+}
+
+func TestCancelRun_UserReason(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "api_key_name", "alice")
+	prov := newFakeProvider()
+	svc := newTestService(t, prov, testJob("deploy"))
+
+	run, _ := svc.TriggerRun(ctx, "deploy", nil, "")
+	if err := svc.CancelRun(ctx, run.RunID); err != nil {
+		t.Fatalf("CancelRun() error = %v", err)
+	}
+
+	got, err := svc.GetRun(ctx, run.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if got.CancelReason != models.CancelReasonUser || got.Reason != "canceled by alice" {
+		t.Errorf("cancel_reason = %q, reason = %q; want user, canceled by alice", got.CancelReason, got.Reason)
+	}
+}
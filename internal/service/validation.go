@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+)
+
+// ValidationError reports trigger parameters rejected by a job's schema
+type ValidationError struct {
+	Fields []params.FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid parameters: " + strings.Join(msgs, "; ")
+}
+
+// validateParameters checks values against the job's schema and applies defaults
+func validateParameters(job *models.Job, values map[string]interface{}) (map[string]interface{}, error) {
+	validated, fieldErrs := params.Validate(job.Parameters, values)
+	if len(fieldErrs) > 0 {
+		return nil, &ValidationError{Fields: fieldErrs}
+	}
+	return validated, nil
+}
+
+// prefixFields qualifies field names of a validation error, e.g. for batch children
+func prefixFields(err error, prefix string) error {
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		return err
+	}
+	fields := make([]params.FieldError, len(verr.Fields))
+	for i, f := range verr.Fields {
+		fields[i] = params.FieldError{Field: fmt.Sprintf("%s.%s", prefix, f.Field), Message: f.Message}
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// sensitiveParameters returns the names of the job's sensitive parameters
+// together with names already recorded, e.g. on an earlier attempt. The
+// result is never nil so records can tell "none" from "not recorded".
+func sensitiveParameters(job *models.Job, recorded []string) []string {
+	names := append([]string{}, recorded...)
+	for _, p := range job.Parameters {
+		if p.Sensitive && !slices.Contains(names, p.Name) {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactParameters masks sensitive parameters for responses and the state
+// directory. The names recorded with a run or batch when it was triggered
+// are masked along with those of the job's current schema, so values stay
+// masked after the job is removed. Records from before names were recorded
+// fall back to the schema; if the job is gone too, every value is masked.
+func (s *Service) redactParameters(jobID string, recorded []string, values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	job, exists := s.job(jobID)
+	switch {
+	case exists:
+		return maskParameters(sensitiveParameters(job, recorded), values)
+	case recorded != nil:
+		return maskParameters(recorded, values)
+	}
+	return maskAll(values)
+}
+
+// maskParameters returns a copy of values with the named parameters masked
+func maskParameters(names []string, values map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		masked[name] = value
+	}
+	for _, name := range names {
+		if _, ok := masked[name]; ok {
+			masked[name] = params.Redacted
+		}
+	}
+	return masked
+}
+
+// maskAll returns a copy of values with every parameter masked
+func maskAll(values map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(values))
+	for name := range values {
+		masked[name] = params.Redacted
+	}
+	return masked
+}
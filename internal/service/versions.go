@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+	"github.com/lei/simple-ci/internal/provider"
+	"github.com/lei/simple-ci/internal/provider/concourse"
+)
+
+// validateVersions checks that every requested resource names at least one version field
+func validateVersions(versions map[string]map[string]string) error {
+	var fields []params.FieldError
+	for resource, version := range versions {
+		if resource == "" {
+			fields = append(fields, params.FieldError{Field: "versions", Message: "resource name must not be empty"})
+			continue
+		}
+		if len(version) == 0 {
+			fields = append(fields, params.FieldError{Field: "versions." + resource, Message: "must name at least one version field"})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return &ValidationError{Fields: fields}
+}
+
+// mapVersions returns the input versions the job's provider reference derives
+// from trigger parameters, if the provider maps any
+func (s *Service) mapVersions(job *models.Job, values map[string]interface{}) (map[string]map[string]string, error) {
+	mapper, ok := s.provider.(provider.VersionMapper)
+	if !ok {
+		return nil, nil
+	}
+	jobRef, err := s.buildJobRef(job)
+	if err != nil {
+		return nil, fmt.Errorf("build job ref: %w", err)
+	}
+	mapped, err := mapper.MapVersions(jobRef, values)
+	if err != nil {
+		return nil, fmt.Errorf("map parameters: %w", err)
+	}
+	return mapped, nil
+}
+
+// mergeVersions combines mapped and requested versions; requested ones win
+func mergeVersions(mapped, requested map[string]map[string]string) map[string]map[string]string {
+	if len(mapped) == 0 {
+		return requested
+	}
+	out := make(map[string]map[string]string, len(mapped)+len(requested))
+	for resource, version := range mapped {
+		out[resource] = version
+	}
+	for resource, version := range requested {
+		out[resource] = version
+	}
+	return out
+}
+
+// pinKeys names the resources a run pins as team/pipeline/resource
+func pinKeys(jobRef provider.JobRef, versions map[string]map[string]string) []string {
+	scope := jobRef.Kind()
+	if ref, ok := jobRef.(*concourse.ConcourseJobRef); ok {
+		scope = ref.Team + "/" + ref.Pipeline
+	}
+	keys := make([]string, 0, len(versions))
+	for resource := range versions {
+		keys = append(keys, scope+"/"+resource)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pinConflicts returns the given resource keys a run holding pins still
+// pins. Records from before pin keys were kept block every key of their own job.
+func (r *runRecord) pinConflicts(jobID string, keys []string) []string {
+	if !r.PinsPending {
+		return nil
+	}
+	if len(r.PinKeys) == 0 {
+		if r.JobID == jobID {
+			return keys
+		}
+		return nil
+	}
+	var conflicts []string
+	for _, key := range r.PinKeys {
+		if slices.Contains(keys, key) {
+			conflicts = append(conflicts, key)
+		}
+	}
+	return conflicts
+}
+
+// pinVersions pins the requested versions for a trigger. Only one run at a
+// time may hold pins on a resource; caller must hold s.pinMu.
+func (s *Service) pinVersions(ctx context.Context, job *models.Job, jobRef provider.JobRef, triggerParams provider.TriggerParams, keys []string) ([]provider.ResourcePin, error) {
+	logger := s.getLogger(ctx)
+
+	pinner, ok := s.provider.(provider.VersionPinner)
+	if !ok {
+		return nil, ErrVersionsUnsupported
+	}
+
+	var conflicts []string
+	busy := s.runs.list(func(r *runRecord) bool {
+		found := r.pinConflicts(job.JobID, keys)
+		conflicts = append(conflicts, found...)
+		return len(found) > 0
+	})
+	if len(busy) > 0 {
+		sort.Strings(conflicts)
+		conflicts = slices.Compact(conflicts)
+		logger.Info("service: versions still pinned for an earlier run",
+			"job_id", job.JobID,
+			"run_id", busy[0].ID,
+			"resources", conflicts)
+		return nil, fmt.Errorf("%w: %s", ErrVersionPinBusy, strings.Join(conflicts, ", "))
+	}
+
+	previous, err := pinner.PinVersions(ctx, jobRef, triggerParams)
+	if err != nil {
+		logger.Error("service: failed to pin versions", "job_id", job.JobID, "error", err)
+		return nil, err
+	}
+	return previous, nil
+}
+
+// restorePinsNow puts back pins after a trigger that did not create a run
+func (s *Service) restorePinsNow(ctx context.Context, jobRef provider.JobRef, triggerParams provider.TriggerParams, previous []provider.ResourcePin) {
+	pinner, ok := s.provider.(provider.VersionPinner)
+	if !ok {
+		return
+	}
+	if err := pinner.RestorePins(ctx, jobRef, triggerParams, previous); err != nil {
+		s.getLogger(ctx).Error("service: failed to restore pins", "error", err)
+	}
+}
+
+// restorePins puts back the previous pins of runs that have started, i.e.
+// whose inputs have been chosen by the provider
+func (s *Service) restorePins(ctx context.Context) {
+	logger := s.getLogger(ctx)
+
+	pending := s.runs.list(func(r *runRecord) bool {
+		return r.PinsPending && r.Status != models.StatusQueued
+	})
+
+	pinner, ok := s.provider.(provider.VersionPinner)
+	for _, rec := range pending {
+		var err error
+		if job, exists := s.job(rec.JobID); exists && ok {
+			var jobRef provider.JobRef
+			if jobRef, err = s.buildJobRef(job); err == nil {
+				err = pinner.RestorePins(ctx, jobRef, provider.TriggerParams{
+					Parameters: rec.Parameters,
+					Versions:   rec.Versions,
+				}, rec.PreviousPins)
+			}
+		}
+		if err != nil {
+			// Try again on the next pass
+			logger.Error("service: failed to restore pins", "run_id", rec.ID, "error", err)
+			continue
+		}
+
+		if _, err := s.runs.update(rec.ID, func(r *runRecord) { r.PinsPending = false }); err != nil {
+			logger.Error("service: failed to persist run record", "run_id", rec.ID, "error", err)
+		}
+		logger.Info("service: previous pins restored", "job_id", rec.JobID, "run_id", rec.ID)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/provider"
+)
+
+// pinningProvider is a fakeProvider that also pins resource versions and
+// maps the "sha" parameter to a source-code version when mapSHA is set
+type pinningProvider struct {
+	*fakeProvider
+	pinned   map[string]map[string]string
+	restored int
+	mapSHA   bool
+}
+
+func (p *pinningProvider) MapVersions(jobRef provider.JobRef, params map[string]interface{}) (map[string]map[string]string, error) {
+	sha, ok := params["sha"].(string)
+	if !p.mapSHA || !ok {
+		return nil, nil
+	}
+	return map[string]map[string]string{"source-code": {"ref": sha}}, nil
+}
+
+func (p *pinningProvider) PinVersions(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams) ([]provider.ResourcePin, error) {
+	var previous []provider.ResourcePin
+	for resource, version := range params.Versions {
+		if version["ref"] == "missing" {
+			return nil, provider.ErrVersionNotFound
+		}
+		previous = append(previous, provider.ResourcePin{Resource: resource, Version: p.pinned[resource]})
+		p.pinned[resource] = version
+	}
+	return previous, nil
+}
+
+func (p *pinningProvider) RestorePins(ctx context.Context, jobRef provider.JobRef, params provider.TriggerParams, previous []provider.ResourcePin) error {
+	for _, pin := range previous {
+		if pin.Version == nil {
+			delete(p.pinned, pin.Resource)
+		} else {
+			p.pinned[pin.Resource] = pin.Version
+		}
+	}
+	p.restored++
+	return nil
+}
+
+func TestTriggerRunAt_PinsAndRestores(t *testing.T) {
+	ctx := context.Background()
+	prov := &pinningProvider{
+		fakeProvider: newFakeProvider(),
+		pinned:       map[string]map[string]string{"source-code": {"ref": "old"}},
+	}
+	svc := newTestService(t, prov, testJob("build"))
+
+	versions := map[string]map[string]string{"source-code": {"ref": "abc123"}}
+	run, err := svc.TriggerRunAt(ctx, "build", nil, "", versions)
+	if err != nil {
+		t.Fatalf("TriggerRunAt() error = %v", err)
+	}
+	if run.Versions["source-code"]["ref"] != "abc123" {
+		t.Errorf("run versions = %v, want source-code ref abc123", run.Versions)
+	}
+	if got := prov.triggers[0].Versions; got["source-code"]["ref"] != "abc123" {
+		t.Errorf("trigger versions = %v", got)
+	}
+
+	// The first run still holds its pins
+	if _, err := svc.TriggerRunAt(ctx, "build", nil, "", versions); !errors.Is(err, ErrVersionPinBusy) {
+		t.Fatalf("second TriggerRunAt() error = %v, want ErrVersionPinBusy", err)
+	}
+
+	svc.restorePins(ctx)
+	if prov.restored != 1 || prov.pinned["source-code"]["ref"] != "old" {
+		t.Fatalf("restored = %d, pinned = %v; want previous pin back", prov.restored, prov.pinned)
+	}
+
+	if _, err := svc.TriggerRunAt(ctx, "build", nil, "", versions); err != nil {
+		t.Fatalf("TriggerRunAt() after restore error = %v", err)
+	}
+}
+
+func TestTriggerRunAt_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	svc := newTestService(t, newFakeProvider(), testJob("build"))
+	_, err := svc.TriggerRunAt(ctx, "build", nil, "", map[string]map[string]string{"repo": {"ref": "x"}})
+	if !errors.Is(err, ErrVersionsUnsupported) {
+		t.Errorf("error = %v, want ErrVersionsUnsupported", err)
+	}
+
+	prov := &pinningProvider{fakeProvider: newFakeProvider(), pinned: map[string]map[string]string{}}
+	svc = newTestService(t, prov, testJob("build"))
+	_, err = svc.TriggerRunAt(ctx, "build", nil, "", map[string]map[string]string{"repo": {"ref": "missing"}})
+	if !errors.Is(err, provider.ErrVersionNotFound) {
+		t.Errorf("error = %v, want ErrVersionNotFound", err)
+	}
+	if len(prov.triggers) != 0 {
+		t.Errorf("triggered %d builds, want none", len(prov.triggers))
+	}
+
+	var verr *ValidationError
+	_, err = svc.TriggerRunAt(ctx, "build", nil, "", map[string]map[string]string{"repo": {}})
+	if !errors.As(err, &verr) || verr.Fields[0].Field != "versions.repo" {
+		t.Errorf("error = %v, want validation error on versions.repo", err)
+	}
+}
+
+func TestTriggerRun_MappedVersionsRestored(t *testing.T) {
+	ctx := context.Background()
+	prov := &pinningProvider{
+		fakeProvider: newFakeProvider(),
+		pinned:       map[string]map[string]string{},
+		mapSHA:       true,
+	}
+	svc := newTestService(t, prov, testJob("build"))
+
+	run, err := svc.TriggerRun(ctx, "build", map[string]interface{}{"sha": "abc123"}, "")
+	if err != nil {
+		t.Fatalf("TriggerRun() error = %v", err)
+	}
+	if run.Versions["source-code"]["ref"] != "abc123" || prov.pinned["source-code"]["ref"] != "abc123" {
+		t.Fatalf("versions = %v, pinned = %v; want mapped version pinned", run.Versions, prov.pinned)
+	}
+
+	// The mapped pin is put back like a requested one
+	svc.restorePins(ctx)
+	if _, pinned := prov.pinned["source-code"]; pinned || prov.restored != 1 {
+		t.Errorf("pinned = %v after restore, want source-code unpinned", prov.pinned)
+	}
+
+	// Requested versions override the mapping
+	if _, err := svc.TriggerRunAt(ctx, "build", map[string]interface{}{"sha": "abc123"}, "", map[string]map[string]string{"source-code": {"ref": "def456"}}); err != nil {
+		t.Fatalf("TriggerRunAt() error = %v", err)
+	}
+	if prov.pinned["source-code"]["ref"] != "def456" {
+		t.Errorf("pinned = %v, want requested version", prov.pinned)
+	}
+}
+
+func TestTriggerRunAt_PinsBusyPerResource(t *testing.T) {
+	ctx := context.Background()
+	prov := &pinningProvider{fakeProvider: newFakeProvider(), pinned: map[string]map[string]string{}}
+	other := testJob("other")
+	other.Provider.Ref["pipeline"] = "q"
+	svc := newTestService(t, prov, testJob("build"), testJob("test"), other)
+
+	source := map[string]map[string]string{"source-code": {"ref": "abc123"}}
+	if _, err := svc.TriggerRunAt(ctx, "build", nil, "", source); err != nil {
+		t.Fatalf("TriggerRunAt() error = %v", err)
+	}
+
+	// Another job of the pipeline can't repin the resource
+	_, err := svc.TriggerRunAt(ctx, "test", nil, "", source)
+	if !errors.Is(err, ErrVersionPinBusy) || !strings.HasSuffix(err.Error(), ": main/p/source-code") {
+		t.Errorf("same resource error = %v, want ErrVersionPinBusy naming main/p/source-code", err)
+	}
+	// but can pin other resources, and resources of other pipelines are separate
+	if _, err := svc.TriggerRunAt(ctx, "test", nil, "", map[string]map[string]string{"config": {"ref": "v1"}}); err != nil {
+		t.Errorf("other resource error = %v", err)
+	}
+	if _, err := svc.TriggerRunAt(ctx, "other", nil, "", source); err != nil {
+		t.Errorf("other pipeline error = %v", err)
+	}
+}
+
+func TestApproveRun_WaitsForPinnedVersions(t *testing.T) {
+	prov := &pinningProvider{fakeProvider: newFakeProvider(), pinned: map[string]map[string]string{}}
+	svc := newTestService(t, prov, testJob("build"), approvalJob("deploy", 1))
+	ctx := context.Background()
+
+	source := map[string]map[string]string{"source-code": {"ref": "abc123"}}
+	held, err := svc.TriggerRunAt(callerCtx("alice"), "deploy", nil, "", source)
+	if err != nil {
+		t.Fatalf("TriggerRunAt() error = %v", err)
+	}
+	if _, err := svc.TriggerRunAt(ctx, "build", nil, "", source); err != nil {
+		t.Fatalf("TriggerRunAt() error = %v", err)
+	}
+
+	run, err := svc.ApproveRun(callerCtx("bob", "approver"), held.RunID, "")
+	if err != nil {
+		t.Fatalf("ApproveRun() error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusQueued {
+		t.Fatalf("gateway_status = %q, want queued while pins are busy", run.GatewayStatus)
+	}
+
+	// Once the earlier run has started and its pins are back, the run goes out
+	svc.reconcile(ctx)
+	run, err = svc.GetRun(ctx, held.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.GatewayStatus != models.GatewayStatusDispatched || len(prov.triggers) != 2 {
+		t.Errorf("gateway_status = %q, triggers = %d; want dispatched", run.GatewayStatus, len(prov.triggers))
+	}
+}
+
+var (
+	_ provider.VersionPinner = (*pinningProvider)(nil)
+	_ provider.VersionMapper = (*pinningProvider)(nil)
+)
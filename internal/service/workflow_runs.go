@@ -0,0 +1,123 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/store"
+)
+
+// workflowStore keeps workflow runs in memory and persists them to the state directory
+type workflowStore struct {
+	mu      sync.Mutex
+	file    *store.File
+	records map[string]*models.WorkflowRun
+
+	// redact masks workflow parameters that feed sensitive node parameters
+	// before runs are persisted; the values are only kept in memory
+	redact func(workflowID string, recorded []string, values map[string]interface{}) map[string]interface{}
+}
+
+// newWorkflowStore creates a workflow store backed by workflow_runs.json in dir
+func newWorkflowStore(dir string) (*workflowStore, error) {
+	ws := &workflowStore{
+		file:    store.NewFile(dir, "workflow_runs.json"),
+		records: make(map[string]*models.WorkflowRun),
+	}
+
+	var records []*models.WorkflowRun
+	if err := ws.file.Load(&records); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		ws.records[rec.WorkflowRunID] = rec
+	}
+
+	return ws, nil
+}
+
+// newWorkflowRunID generates an ID for a workflow run
+func newWorkflowRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "wf-" + hex.EncodeToString(b)
+}
+
+// get returns a copy of the workflow run with the given ID
+func (ws *workflowStore) get(id string) (*models.WorkflowRun, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	rec, ok := ws.records[id]
+	if !ok {
+		return nil, false
+	}
+	return copyWorkflowRun(rec), true
+}
+
+// list returns copies of matching workflow runs ordered by creation time
+func (ws *workflowStore) list(match func(*models.WorkflowRun) bool) []*models.WorkflowRun {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var out []*models.WorkflowRun
+	for _, rec := range ws.records {
+		if match == nil || match(rec) {
+			out = append(out, copyWorkflowRun(rec))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// put inserts or replaces a workflow run and persists the store
+func (ws *workflowStore) put(rec *models.WorkflowRun) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.records[rec.WorkflowRunID] = copyWorkflowRun(rec)
+	return ws.saveLocked()
+}
+
+// prune drops finished workflow runs older than the cutoff
+func (ws *workflowStore) prune(cutoff time.Time) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	removed := false
+	for id, rec := range ws.records {
+		if rec.FinishedAt != nil && rec.FinishedAt.Before(cutoff) {
+			delete(ws.records, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return ws.saveLocked()
+}
+
+// saveLocked persists all workflow runs; caller must hold ws.mu
+func (ws *workflowStore) saveLocked() error {
+	records := make([]*models.WorkflowRun, 0, len(ws.records))
+	for _, rec := range ws.records {
+		if ws.redact != nil && len(rec.Parameters) > 0 {
+			rec = copyWorkflowRun(rec)
+			rec.Parameters = ws.redact(rec.WorkflowID, rec.Sensitive, rec.Parameters)
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return ws.file.Save(records)
+}
+
+// copyWorkflowRun copies a workflow run including its node slice
+func copyWorkflowRun(rec *models.WorkflowRun) *models.WorkflowRun {
+	cp := *rec
+	cp.Nodes = append([]models.WorkflowNodeRun(nil), rec.Nodes...)
+	return &cp
+}
@@ -0,0 +1,571 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+)
+
+var (
+	// ErrWorkflowNotFound indicates the requested workflow doesn't exist
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	// ErrWorkflowRunNotFound indicates the requested workflow run doesn't exist
+	ErrWorkflowRunNotFound = errors.New("workflow run not found")
+	// ErrWorkflowRunFinished indicates the workflow run can no longer be changed
+	ErrWorkflowRunFinished = errors.New("workflow run already finished")
+)
+
+// workflowRef matches "${params.<name>}" and "${nodes.<id>.run_id|status}" in node parameters
+var workflowRef = regexp.MustCompile(`\$\{(params|nodes)\.([A-Za-z0-9_-]+)(?:\.(run_id|status))?\}`)
+
+// validateWorkflow checks node IDs, job references, dependencies and parameter references
+func validateWorkflow(wf *models.Workflow, jobs map[string]*models.Job) error {
+	if len(wf.Nodes) == 0 {
+		return fmt.Errorf("workflow %s has no nodes", wf.WorkflowID)
+	}
+
+	nodes := make(map[string]models.WorkflowNode, len(wf.Nodes))
+	for _, node := range wf.Nodes {
+		if node.ID == "" {
+			return fmt.Errorf("workflow %s: node id is required", wf.WorkflowID)
+		}
+		if _, dup := nodes[node.ID]; dup {
+			return fmt.Errorf("workflow %s: duplicate node %s", wf.WorkflowID, node.ID)
+		}
+		if _, ok := jobs[node.JobID]; !ok {
+			return fmt.Errorf("workflow %s node %s: unknown job %q", wf.WorkflowID, node.ID, node.JobID)
+		}
+		nodes[node.ID] = node
+	}
+
+	for _, node := range wf.Nodes {
+		for _, dep := range node.DependsOn {
+			if _, ok := nodes[dep]; !ok {
+				return fmt.Errorf("workflow %s node %s: unknown dependency %q", wf.WorkflowID, node.ID, dep)
+			}
+		}
+	}
+
+	// Depth-first search for cycles, collecting each node's ancestors on the way
+	ancestors := make(map[string]map[string]bool, len(nodes))
+	visiting := make(map[string]bool)
+	var visit func(id string) error
+	visit = func(id string) error {
+		if _, done := ancestors[id]; done {
+			return nil
+		}
+		if visiting[id] {
+			return fmt.Errorf("workflow %s: dependency cycle through node %s", wf.WorkflowID, id)
+		}
+		visiting[id] = true
+
+		set := make(map[string]bool)
+		for _, dep := range nodes[id].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+			set[dep] = true
+			for a := range ancestors[dep] {
+				set[a] = true
+			}
+		}
+
+		visiting[id] = false
+		ancestors[id] = set
+		return nil
+	}
+
+	for _, node := range wf.Nodes {
+		if err := visit(node.ID); err != nil {
+			return err
+		}
+	}
+
+	// Node outputs may only be referenced by nodes that run after them
+	for _, node := range wf.Nodes {
+		for _, ref := range collectRefs(node.Parameters) {
+			if ref[1] == "nodes" && !ancestors[node.ID][ref[2]] {
+				return fmt.Errorf("workflow %s node %s: parameter references %s, which is not an upstream node", wf.WorkflowID, node.ID, ref[2])
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectRefs returns every template reference in a parameter tree
+func collectRefs(value interface{}) [][]string {
+	switch v := value.(type) {
+	case string:
+		return workflowRef.FindAllStringSubmatch(v, -1)
+	case map[string]interface{}:
+		var refs [][]string
+		for _, item := range v {
+			refs = append(refs, collectRefs(item)...)
+		}
+		return refs
+	case []interface{}:
+		var refs [][]string
+		for _, item := range v {
+			refs = append(refs, collectRefs(item)...)
+		}
+		return refs
+	}
+	return nil
+}
+
+// ListWorkflows returns all configured workflows sorted by workflow_id
+func (s *Service) ListWorkflows(ctx context.Context) []*models.Workflow {
+	defs := s.definitions()
+	workflows := make([]*models.Workflow, 0, len(defs.workflows))
+	for _, wf := range defs.workflows {
+		workflows = append(workflows, wf)
+	}
+	sort.Slice(workflows, func(i, j int) bool { return workflows[i].WorkflowID < workflows[j].WorkflowID })
+	return workflows
+}
+
+// StartWorkflow creates a workflow run and triggers its root nodes
+func (s *Service) StartWorkflow(ctx context.Context, workflowID string, params map[string]interface{}) (*models.WorkflowRun, error) {
+	logger := s.getLogger(ctx)
+
+	wf, exists := s.workflow(workflowID)
+	if !exists {
+		logger.Debug("service: workflow not found", "workflow_id", workflowID)
+		return nil, ErrWorkflowNotFound
+	}
+
+	// Nodes count against the rate limits when they are triggered, so nodes a
+	// condition skips cost nothing. Root nodes are charged here so an
+	// exhausted budget refuses the workflow instead of stalling it.
+	prepaid := make(map[string]bool)
+	var jobIDs []string
+	for _, node := range wf.Nodes {
+		if len(node.DependsOn) == 0 && node.When != models.ConditionFailure {
+			prepaid[node.ID] = true
+			jobIDs = append(jobIDs, node.JobID)
+		}
+	}
+	if err := s.chargeRuns(ctx, jobIDs); err != nil {
+		return nil, err
+	}
+
+	wr := &models.WorkflowRun{
+		WorkflowRunID: newWorkflowRunID(),
+		WorkflowID:    workflowID,
+		Status:        models.WorkflowRunning,
+		Parameters:    params,
+		Sensitive:     s.workflowSensitive(wf),
+		TriggeredBy:   callerName(ctx),
+		CreatedAt:     time.Now(),
+	}
+	for _, node := range wf.Nodes {
+		wr.Nodes = append(wr.Nodes, models.WorkflowNodeRun{
+			NodeID: node.ID,
+			JobID:  node.JobID,
+			Status: models.NodePending,
+		})
+	}
+
+	s.workflowMu.Lock()
+	defer s.workflowMu.Unlock()
+
+	if err := s.workflowRuns.put(wr); err != nil {
+		return nil, fmt.Errorf("persist workflow run: %w", err)
+	}
+
+	logger.Info("service: workflow started",
+		"workflow_id", workflowID,
+		"workflow_run_id", wr.WorkflowRunID)
+
+	return s.redactWorkflowRun(s.advanceWorkflowLocked(ctx, wr.WorkflowRunID, prepaid))
+}
+
+// GetWorkflowRun returns a workflow run with per-node status
+func (s *Service) GetWorkflowRun(ctx context.Context, workflowRunID string) (*models.WorkflowRun, error) {
+	s.workflowMu.Lock()
+	defer s.workflowMu.Unlock()
+
+	if _, ok := s.workflowRuns.get(workflowRunID); !ok {
+		return nil, ErrWorkflowRunNotFound
+	}
+	return s.redactWorkflowRun(s.advanceWorkflowLocked(ctx, workflowRunID, nil))
+}
+
+// CancelWorkflowRun cancels in-flight node runs and every node that hasn't started
+func (s *Service) CancelWorkflowRun(ctx context.Context, workflowRunID string) (*models.WorkflowRun, error) {
+	logger := s.getLogger(ctx)
+
+	s.workflowMu.Lock()
+	defer s.workflowMu.Unlock()
+
+	wr, ok := s.workflowRuns.get(workflowRunID)
+	if !ok {
+		return nil, ErrWorkflowRunNotFound
+	}
+	if wr.Status != models.WorkflowRunning {
+		return nil, ErrWorkflowRunFinished
+	}
+
+	now := time.Now()
+	reason := "workflow canceled by " + callerLabel(ctx)
+	for i := range wr.Nodes {
+		node := &wr.Nodes[i]
+		switch node.Status {
+		case models.NodeRunning:
+			if err := s.CancelRun(ctx, s.latestAttempt(node.RunID).ID); err != nil {
+				logger.Error("service: failed to cancel workflow node run",
+					"workflow_run_id", workflowRunID,
+					"node_id", node.NodeID,
+					"run_id", node.RunID,
+					"error", err)
+			}
+		case models.NodePending:
+		default:
+			continue
+		}
+		node.Status = models.NodeCanceled
+		node.Reason = reason
+		node.FinishedAt = &now
+	}
+
+	wr.Status = models.WorkflowCanceled
+	wr.FinishedAt = &now
+	if err := s.workflowRuns.put(wr); err != nil {
+		return nil, fmt.Errorf("persist workflow run: %w", err)
+	}
+
+	logger.Info("service: workflow canceled", "workflow_run_id", workflowRunID)
+	return s.redactWorkflowRun(wr, nil)
+}
+
+// workflowSensitive returns the workflow parameters referenced by a
+// sensitive parameter of a node's job
+func (s *Service) workflowSensitive(wf *models.Workflow) []string {
+	names := []string{}
+	for _, node := range wf.Nodes {
+		job, exists := s.job(node.JobID)
+		if !exists {
+			continue
+		}
+		for _, p := range job.Parameters {
+			if !p.Sensitive {
+				continue
+			}
+			for _, ref := range collectRefs(node.Parameters[p.Name]) {
+				if ref[1] == "params" && !slices.Contains(names, ref[2]) {
+					names = append(names, ref[2])
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactWorkflowParameters masks the workflow parameters recorded as
+// sensitive when the run started. Runs from before names were recorded
+// fall back to the workflow's current definition; if it is gone, every
+// value is masked.
+func (s *Service) redactWorkflowParameters(workflowID string, recorded []string, values map[string]interface{}) map[string]interface{} {
+	if recorded == nil {
+		wf, exists := s.workflow(workflowID)
+		if !exists {
+			return maskAll(values)
+		}
+		recorded = s.workflowSensitive(wf)
+	}
+	return maskParameters(recorded, values)
+}
+
+// redactWorkflowRun masks sensitive workflow parameters in a run returned to callers
+func (s *Service) redactWorkflowRun(wr *models.WorkflowRun, err error) (*models.WorkflowRun, error) {
+	if wr != nil && wr.Parameters != nil {
+		wr.Parameters = s.redactWorkflowParameters(wr.WorkflowID, wr.Sensitive, wr.Parameters)
+	}
+	return wr, err
+}
+
+// advanceWorkflows moves every running workflow forward
+func (s *Service) advanceWorkflows(ctx context.Context) {
+	running := s.workflowRuns.list(func(wr *models.WorkflowRun) bool { return wr.Status == models.WorkflowRunning })
+	if len(running) == 0 {
+		return
+	}
+
+	s.workflowMu.Lock()
+	defer s.workflowMu.Unlock()
+
+	for _, wr := range running {
+		if _, err := s.advanceWorkflowLocked(ctx, wr.WorkflowRunID, nil); err != nil {
+			s.logger.Error("service: failed to advance workflow",
+				"workflow_run_id", wr.WorkflowRunID,
+				"error", err)
+		}
+	}
+}
+
+// advanceWorkflowLocked refreshes node statuses from their runs, triggers or
+// skips nodes whose dependencies are done and derives the workflow status.
+// Prepaid nodes were already charged against the rate limits. Caller must
+// hold s.workflowMu.
+func (s *Service) advanceWorkflowLocked(ctx context.Context, workflowRunID string, prepaid map[string]bool) (*models.WorkflowRun, error) {
+	logger := s.getLogger(ctx)
+
+	wr, ok := s.workflowRuns.get(workflowRunID)
+	if !ok {
+		return nil, ErrWorkflowRunNotFound
+	}
+	if wr.Status != models.WorkflowRunning {
+		return wr, nil
+	}
+
+	wf, exists := s.workflow(wr.WorkflowID)
+	if !exists {
+		now := time.Now()
+		wr.Status = models.WorkflowFailed
+		wr.FinishedAt = &now
+		return wr, s.workflowRuns.put(wr)
+	}
+
+	index := make(map[string]int, len(wr.Nodes))
+	for i, node := range wr.Nodes {
+		index[node.NodeID] = i
+	}
+
+	// Triggering, failing or skipping a node can unblock others, so repeat until stable
+	for changed := true; changed; {
+		changed = false
+		for _, def := range wf.Nodes {
+			i, ok := index[def.ID]
+			if !ok {
+				continue
+			}
+			node := &wr.Nodes[i]
+
+			switch node.Status {
+			case models.NodeRunning:
+				changed = s.refreshNode(node) || changed
+			case models.NodePending:
+				if s.startNode(ctx, wr, def, node, index, prepaid[def.ID]) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	done, failed := true, false
+	for _, node := range wr.Nodes {
+		if !node.Status.IsDone() {
+			done = false
+		}
+		if node.Status == models.NodeFailed || node.Status == models.NodeCanceled {
+			failed = true
+		}
+	}
+	if done {
+		now := time.Now()
+		wr.FinishedAt = &now
+		wr.Status = models.WorkflowSucceeded
+		if failed {
+			wr.Status = models.WorkflowFailed
+		}
+		logger.Info("service: workflow finished",
+			"workflow_run_id", wr.WorkflowRunID,
+			"status", wr.Status)
+	}
+
+	if err := s.workflowRuns.put(wr); err != nil {
+		return nil, fmt.Errorf("persist workflow run: %w", err)
+	}
+	return wr, nil
+}
+
+// refreshNode updates a running node from its latest run attempt
+func (s *Service) refreshNode(node *models.WorkflowNodeRun) bool {
+	rec := s.latestAttempt(node.RunID)
+	if rec == nil || !rec.Status.IsTerminal() || s.retryPending(rec) {
+		return false
+	}
+
+	switch rec.Status {
+	case models.StatusSucceeded:
+		node.Status = models.NodeSucceeded
+	case models.StatusCanceled:
+		node.Status = models.NodeCanceled
+	default:
+		node.Status = models.NodeFailed
+	}
+	node.Reason = rec.Reason
+	node.FinishedAt = rec.FinishedAt
+	if node.FinishedAt == nil {
+		now := time.Now()
+		node.FinishedAt = &now
+	}
+	return true
+}
+
+// latestAttempt follows a run's retry chain to its most recent attempt
+func (s *Service) latestAttempt(runID string) *runRecord {
+	rec, ok := s.runs.get(runID)
+	for ok && rec.NextRunID != "" {
+		next, found := s.runs.get(rec.NextRunID)
+		if !found {
+			break
+		}
+		rec = next
+	}
+	if !ok {
+		return nil
+	}
+	return rec
+}
+
+// startNode triggers or skips a pending node once all its dependencies are
+// done. Nodes the caller's rate limits can't cover yet stay pending.
+func (s *Service) startNode(ctx context.Context, wr *models.WorkflowRun, def models.WorkflowNode, node *models.WorkflowNodeRun, index map[string]int, prepaid bool) bool {
+	logger := s.getLogger(ctx)
+
+	allSucceeded, anyFailed := true, false
+	for _, dep := range def.DependsOn {
+		status := wr.Nodes[index[dep]].Status
+		if !status.IsDone() {
+			return false
+		}
+		if status != models.NodeSucceeded {
+			allSucceeded = false
+		}
+		if status == models.NodeFailed || status == models.NodeCanceled {
+			anyFailed = true
+		}
+	}
+
+	now := time.Now()
+	var proceed bool
+	switch def.When {
+	case models.ConditionFailure:
+		proceed = anyFailed
+	case models.ConditionAlways:
+		proceed = true
+	default:
+		proceed = allSucceeded
+	}
+	if !proceed {
+		node.Status = models.NodeSkipped
+		node.Reason = fmt.Sprintf("condition %q not met", def.When)
+		node.FinishedAt = &now
+		return true
+	}
+
+	triggerCtx := context.WithValue(ctx, "api_key_name", wr.TriggeredBy)
+	if !prepaid && s.chargeRuns(triggerCtx, []string{def.JobID}) != nil {
+		node.Reason = "waiting for rate limit budget"
+		return false
+	}
+
+	params, err := resolveNodeParams(def.Parameters, wr, index)
+	if err == nil {
+		// The idempotency key makes a trigger repeated after a crash return the original run
+		var run *models.Run
+		run, err = s.triggerOnce(triggerCtx, def.JobID, params, fmt.Sprintf("workflow:%s:%s", wr.WorkflowRunID, def.ID))
+		if err == nil {
+			node.Status = models.NodeRunning
+			node.RunID = run.RunID
+			node.Reason = ""
+			node.StartedAt = &now
+			logger.Info("service: workflow node triggered",
+				"workflow_run_id", wr.WorkflowRunID,
+				"node_id", def.ID,
+				"run_id", run.RunID)
+			return true
+		}
+	}
+
+	logger.Error("service: workflow node failed to start",
+		"workflow_run_id", wr.WorkflowRunID,
+		"node_id", def.ID,
+		"error", err)
+	node.Status = models.NodeFailed
+	node.Reason = err.Error()
+	node.FinishedAt = &now
+	return true
+}
+
+// resolveNodeParams substitutes workflow parameters and upstream node outputs.
+// A value that is exactly one reference keeps the referenced value's type.
+func resolveNodeParams(values map[string]interface{}, wr *models.WorkflowRun, index map[string]int) (map[string]interface{}, error) {
+	var resolve func(value interface{}) (interface{}, error)
+	lookup := func(ref []string) (interface{}, error) {
+		if ref[1] == "params" {
+			v, ok := wr.Parameters[ref[2]]
+			if !ok {
+				return nil, fmt.Errorf("missing workflow parameter %q", ref[2])
+			}
+			// Sensitive values are masked in state and lost across restarts
+			if v == params.Redacted && slices.Contains(wr.Sensitive, ref[2]) {
+				return nil, ErrParametersNotRetained
+			}
+			return v, nil
+		}
+		i, ok := index[ref[2]]
+		if !ok {
+			return nil, fmt.Errorf("unknown node %q", ref[2])
+		}
+		if ref[3] == "status" {
+			return string(wr.Nodes[i].Status), nil
+		}
+		return wr.Nodes[i].RunID, nil
+	}
+
+	resolve = func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case string:
+			if m := workflowRef.FindStringSubmatch(v); m != nil && m[0] == v {
+				return lookup(m)
+			}
+			var firstErr error
+			out := workflowRef.ReplaceAllStringFunc(v, func(match string) string {
+				val, err := lookup(workflowRef.FindStringSubmatch(match))
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				return fmt.Sprint(val)
+			})
+			return out, firstErr
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(v))
+			for k, item := range v {
+				resolved, err := resolve(item)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = resolved
+			}
+			return out, nil
+		case []interface{}:
+			out := make([]interface{}, len(v))
+			for i, item := range v {
+				resolved, err := resolve(item)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = resolved
+			}
+			return out, nil
+		}
+		return value, nil
+	}
+
+	resolved, err := resolve(values)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/params"
+	"github.com/lei/simple-ci/pkg/logger"
+)
+
+func releaseWorkflow() *models.Workflow {
+	return &models.Workflow{
+		WorkflowID: "release",
+		Nodes: []models.WorkflowNode{
+			{ID: "build", JobID: "build", When: models.ConditionSuccess,
+				Parameters: map[string]interface{}{"ref": "${params.ref}"}},
+			{ID: "test", JobID: "test", DependsOn: []string{"build"}, When: models.ConditionSuccess,
+				Parameters: map[string]interface{}{"artifact": "build-${nodes.build.run_id}"}},
+			{ID: "notify", JobID: "notify", DependsOn: []string{"test"}, When: models.ConditionFailure},
+		},
+	}
+}
+
+func newWorkflowService(t *testing.T, prov *fakeProvider) *Service {
+	t.Helper()
+	svc, err := NewService(
+		[]*models.Job{testJob("build"), testJob("test"), testJob("notify")},
+		prov, logger.New("error", "text"),
+		Options{StateDir: t.TempDir(), Workflows: []*models.Workflow{releaseWorkflow()}})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return svc
+}
+
+func nodeStatuses(wr *models.WorkflowRun) map[string]models.NodeStatus {
+	out := make(map[string]models.NodeStatus)
+	for _, node := range wr.Nodes {
+		out[node.NodeID] = node.Status
+	}
+	return out
+}
+
+func TestWorkflow_RunsInDependencyOrder(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newWorkflowService(t, prov)
+
+	wr, err := svc.StartWorkflow(ctx, "release", map[string]interface{}{"ref": "v1.2"})
+	if err != nil {
+		t.Fatalf("StartWorkflow() error = %v", err)
+	}
+	if got := nodeStatuses(wr); got["build"] != models.NodeRunning || got["test"] != models.NodePending {
+		t.Fatalf("statuses = %v, want build running, test pending", got)
+	}
+	if prov.triggers[0].Parameters["ref"] != "v1.2" {
+		t.Errorf("build parameters = %v, want ref from workflow parameters", prov.triggers[0].Parameters)
+	}
+
+	prov.finish(1, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	svc.advanceWorkflows(ctx)
+
+	if len(prov.triggers) != 2 || prov.triggers[1].Parameters["artifact"] != "build-main:p:build:1" {
+		t.Fatalf("triggers = %+v, want test triggered with build run_id", prov.triggers)
+	}
+
+	prov.finish(2, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	wr, err = svc.GetWorkflowRun(ctx, wr.WorkflowRunID)
+	if err != nil {
+		t.Fatalf("GetWorkflowRun() error = %v", err)
+	}
+	if got := nodeStatuses(wr); got["notify"] != models.NodeSkipped || wr.Status != models.WorkflowSucceeded {
+		t.Errorf("statuses = %v, workflow = %q; want notify skipped, succeeded", got, wr.Status)
+	}
+}
+
+func TestWorkflow_FailureBranch(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newWorkflowService(t, prov)
+
+	wr, _ := svc.StartWorkflow(ctx, "release", map[string]interface{}{"ref": "main"})
+	prov.finish(1, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	svc.advanceWorkflows(ctx)
+	prov.finish(2, models.StatusFailed)
+	svc.refreshActive(ctx, "")
+	svc.advanceWorkflows(ctx)
+
+	wr, _ = svc.GetWorkflowRun(ctx, wr.WorkflowRunID)
+	if got := nodeStatuses(wr); got["notify"] != models.NodeRunning {
+		t.Fatalf("statuses = %v, want notify running after test failed", got)
+	}
+
+	prov.finish(3, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	wr, _ = svc.GetWorkflowRun(ctx, wr.WorkflowRunID)
+	if wr.Status != models.WorkflowFailed {
+		t.Errorf("workflow status = %q, want failed", wr.Status)
+	}
+}
+
+func TestWorkflow_CancelPropagates(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newWorkflowService(t, prov)
+
+	wr, _ := svc.StartWorkflow(ctx, "release", map[string]interface{}{"ref": "main"})
+	wr, err := svc.CancelWorkflowRun(ctx, wr.WorkflowRunID)
+	if err != nil {
+		t.Fatalf("CancelWorkflowRun() error = %v", err)
+	}
+
+	if len(prov.canceled) != 1 || prov.canceled[0] != 1 {
+		t.Errorf("canceled = %v, want the in-flight build", prov.canceled)
+	}
+	for _, node := range wr.Nodes {
+		if node.Status != models.NodeCanceled {
+			t.Errorf("node %s status = %q, want canceled", node.NodeID, node.Status)
+		}
+	}
+	if _, err := svc.CancelWorkflowRun(ctx, wr.WorkflowRunID); err != ErrWorkflowRunFinished {
+		t.Errorf("second cancel error = %v, want ErrWorkflowRunFinished", err)
+	}
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	jobs := map[string]*models.Job{"a": testJob("a"), "b": testJob("b")}
+	tests := []struct {
+		name  string
+		nodes []models.WorkflowNode
+		want  string
+	}{
+		{"cycle", []models.WorkflowNode{
+			{ID: "x", JobID: "a", DependsOn: []string{"y"}},
+			{ID: "y", JobID: "b", DependsOn: []string{"x"}},
+		}, "cycle"},
+		{"unknown job", []models.WorkflowNode{{ID: "x", JobID: "missing"}}, "unknown job"},
+		{"unknown dependency", []models.WorkflowNode{{ID: "x", JobID: "a", DependsOn: []string{"z"}}}, "unknown dependency"},
+		{"downstream reference", []models.WorkflowNode{
+			{ID: "x", JobID: "a", Parameters: map[string]interface{}{"id": "${nodes.y.run_id}"}},
+			{ID: "y", JobID: "b", DependsOn: []string{"x"}},
+		}, "not an upstream node"},
+	}
+	for _, tt := range tests {
+		err := validateWorkflow(&models.Workflow{WorkflowID: "wf", Nodes: tt.nodes}, jobs)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestStartWorkflow_RateLimited(t *testing.T) {
+	prov := newFakeProvider()
+	svc := newWorkflowService(t, prov)
+
+	var charged []string
+	svc.allowRuns = func(ctx context.Context, jobIDs []string) (bool, time.Duration) {
+		charged = jobIDs
+		return false, 0
+	}
+
+	if _, err := svc.StartWorkflow(context.Background(), "release", nil); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("StartWorkflow() error = %v, want ErrRateLimited", err)
+	}
+	if strings.Join(charged, ",") != "build" {
+		t.Errorf("charged job IDs = %v, want the root node's job", charged)
+	}
+	if len(prov.triggers) != 0 {
+		t.Errorf("provider triggers = %d, want none", len(prov.triggers))
+	}
+}
+
+func TestWorkflow_ChargedPerNode(t *testing.T) {
+	ctx := context.Background()
+	prov := newFakeProvider()
+	svc := newWorkflowService(t, prov)
+
+	var charged []string
+	allow := true
+	svc.allowRuns = func(ctx context.Context, jobIDs []string) (bool, time.Duration) {
+		if !allow {
+			return false, time.Minute
+		}
+		charged = append(charged, jobIDs...)
+		return true, 0
+	}
+
+	wr, err := svc.StartWorkflow(ctx, "release", map[string]interface{}{"ref": "main"})
+	if err != nil {
+		t.Fatalf("StartWorkflow() error = %v", err)
+	}
+
+	// Without budget, test waits instead of failing
+	allow = false
+	prov.finish(1, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	svc.advanceWorkflows(ctx)
+	wr, _ = svc.GetWorkflowRun(ctx, wr.WorkflowRunID)
+	if wr.Nodes[1].Status != models.NodePending || wr.Nodes[1].Reason != "waiting for rate limit budget" {
+		t.Fatalf("test node = %+v, want pending on the rate limit", wr.Nodes[1])
+	}
+
+	allow = true
+	svc.advanceWorkflows(ctx)
+	prov.finish(2, models.StatusSucceeded)
+	svc.refreshActive(ctx, "")
+	wr, _ = svc.GetWorkflowRun(ctx, wr.WorkflowRunID)
+
+	// notify only runs on failure; skipped, it costs nothing
+	if got := nodeStatuses(wr); got["notify"] != models.NodeSkipped || wr.Status != models.WorkflowSucceeded {
+		t.Fatalf("statuses = %v (%s), want notify skipped and the workflow succeeded", got, wr.Status)
+	}
+	if strings.Join(charged, ",") != "build,test" {
+		t.Errorf("charged job IDs = %v, want build and test only", charged)
+	}
+}
+
+func TestWorkflow_SensitiveParametersNotPersisted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	prov := newFakeProvider()
+	log := logger.New("error", "text")
+
+	build, test := testJob("build"), testJob("test")
+	for _, job := range []*models.Job{build, test} {
+		job.Parameters = []models.JobParameter{{Name: "token", Type: models.ParamString, Sensitive: true}}
+	}
+	wf := &models.Workflow{
+		WorkflowID: "release",
+		Nodes: []models.WorkflowNode{
+			{ID: "build", JobID: "build", When: models.ConditionSuccess,
+				Parameters: map[string]interface{}{"token": "${params.token}"}},
+			{ID: "test", JobID: "test", DependsOn: []string{"build"}, When: models.ConditionSuccess,
+				Parameters: map[string]interface{}{"token": "${params.token}"}},
+		},
+	}
+	opts := Options{StateDir: dir, Workflows: []*models.Workflow{wf}}
+	svc, err := NewService([]*models.Job{build, test}, prov, log, opts)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	wr, err := svc.StartWorkflow(ctx, "release", map[string]interface{}{"ref": "v1", "token": "s3cret"})
+	if err != nil {
+		t.Fatalf("StartWorkflow() error = %v", err)
+	}
+	if prov.triggers[0].Parameters["token"] != "s3cret" {
+		t.Fatalf("build token = %v, want the real value", prov.triggers[0].Parameters["token"])
+	}
+	if wr.Parameters["token"] != params.Redacted || wr.Parameters["ref"] != "v1" {
+		t.Errorf("parameters = %v, want only token masked", wr.Parameters)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "workflow_runs.json"))
+	if err != nil {
+		t.Fatalf("read workflow_runs.json: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Error("workflow_runs.json contains a sensitive value")
+	}
+
+	// After a restart the value is gone: nodes that still need it fail
+	restarted, err := NewService([]*models.Job{build, test}, prov, log, opts)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	prov.finish(1, models.StatusSucceeded)
+	restarted.refreshActive(ctx, "")
+	wr, _ = restarted.GetWorkflowRun(ctx, wr.WorkflowRunID)
+	if wr.Nodes[1].Status != models.NodeFailed || wr.Nodes[1].Reason != ErrParametersNotRetained.Error() || len(prov.triggers) != 1 {
+		t.Errorf("test node = %+v with %d triggers, want failed without a trigger", wr.Nodes[1], len(prov.triggers))
+	}
+}
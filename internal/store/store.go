@@ -0,0 +1,93 @@
+// Package store provides simple JSON file persistence for gateway state.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File persists a single JSON document. Writes are atomic (temp file + rename).
+// A File created with an empty directory is memory-only: Load finds nothing
+// and Save is a no-op.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile creates a file store for name inside dir
+func NewFile(dir, name string) *File {
+	if dir == "" {
+		return &File{}
+	}
+	return &File{path: filepath.Join(dir, name)}
+}
+
+// Path returns the backing file path, or "" for memory-only stores
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load decodes the stored document into v. A missing file leaves v untouched.
+func (f *File) Load(v interface{}) error {
+	if f.path == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", f.path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Save encodes v and atomically replaces the stored document
+func (f *File) Save(v interface{}) error {
+	if f.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("replace %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/lei/simple-ci/internal/api"
@@ -13,6 +14,7 @@ import (
 	"github.com/lei/simple-ci/internal/models"
 	"github.com/lei/simple-ci/internal/provider"
 	"github.com/lei/simple-ci/internal/provider/concourse"
+	"github.com/lei/simple-ci/internal/ratelimit"
 	"github.com/lei/simple-ci/internal/service"
 	"github.com/lei/simple-ci/pkg/logger"
 )
@@ -24,6 +26,9 @@ type Gateway struct {
 	router  http.Handler
 	server  *http.Server
 	logger  *logger.Logger
+	limiter *ratelimit.Limiter
+
+	reloadMu sync.Mutex // Serializes jobs file reloads
 }
 
 // Config holds the configuration for the Gateway
@@ -40,8 +45,47 @@ type Config struct {
 	// Jobs configuration
 	Jobs []*models.Job
 
+	// Workflows chaining the configured jobs (optional)
+	Workflows []*models.Workflow
+
+	// Freezes block triggers of matching jobs during their windows (optional)
+	Freezes []*models.Freeze
+
+	// Approvals are default approval policies by environment, applied to jobs
+	// registered through the API that don't set their own (optional)
+	Approvals map[string]*models.JobApproval
+
+	// JobsFile is the jobs.yaml that Jobs, Workflows and Freezes came from (optional).
+	// When set, Reload re-reads it and the file is watched for changes.
+	JobsFile string
+
+	// JobsReloadInterval is how often JobsFile is checked for changes (0 disables watching)
+	JobsReloadInterval time.Duration
+
+	// ConfigHash identifies the jobs configuration in detailed health checks (optional)
+	ConfigHash string
+
+	// JobsValidation checks jobs against the provider at startup and on reload:
+	// "off" (default), "lenient" marks missing jobs unavailable, "strict" fails
+	JobsValidation string
+
 	// Logger configuration
 	Logging LoggingConfig
+
+	// Rate limit configuration (optional, unlimited when empty)
+	RateLimit RateLimitConfig
+
+	// State configuration for runs held by the gateway (optional, in-memory when empty)
+	State StateConfig
+}
+
+// StateConfig holds settings for gateway-managed state such as queued runs
+type StateConfig struct {
+	// Dir is where state is persisted across restarts. Empty keeps state in memory only.
+	Dir string
+
+	// PollInterval is how often tracked runs are refreshed and queues dispatched (default: 10s)
+	PollInterval time.Duration
 }
 
 // ServerConfig holds HTTP server configuration
@@ -61,6 +105,9 @@ type AuthConfig struct {
 type APIKey struct {
 	Name string
 	Key  string
+
+	// Scopes grants extra permissions, e.g. "approver" to approve runs
+	Scopes []string
 }
 
 // ProviderConfig holds CI provider configuration
@@ -87,6 +134,24 @@ type LoggingConfig struct {
 	Format string // json or text
 }
 
+// RateLimitConfig holds token bucket budgets such as "60/m" or "10/s".
+// Empty values mean unlimited. Per-job overrides come from each job's RateLimit.
+type RateLimitConfig struct {
+	KeyTrigger string // Per API key, mutating requests (trigger, cancel)
+	KeyRead    string // Per API key, read requests
+	JobTrigger string // Per job, mutating requests
+	JobRead    string // Per job, read requests
+
+	// KeyOverrides replaces the default budgets for specific API key names
+	KeyOverrides map[string]RateLimits
+}
+
+// RateLimits holds trigger and read budgets. Empty values inherit the default.
+type RateLimits struct {
+	Trigger string
+	Read    string
+}
+
 // New creates a new Gateway instance with the provided configuration
 func New(cfg *Config) (*Gateway, error) {
 	if cfg == nil {
@@ -123,8 +188,30 @@ func New(cfg *Config) (*Gateway, error) {
 		return nil, fmt.Errorf("unsupported provider kind: %s", cfg.Provider.Kind)
 	}
 
+	gw := &Gateway{
+		config: cfg,
+		logger: appLogger,
+	}
+
 	// Initialize service layer
-	svc := service.NewService(cfg.Jobs, prov, appLogger)
+	svc, err := service.NewService(cfg.Jobs, prov, appLogger, service.Options{
+		StateDir:     cfg.State.Dir,
+		PollInterval: cfg.State.PollInterval,
+		Workflows:    cfg.Workflows,
+		Freezes:      cfg.Freezes,
+		ConfigHash:   cfg.ConfigHash,
+		Approvals:    cfg.Approvals,
+		JobsChanged:  gw.applyJobRateLimits,
+		AllowRuns:    gw.allowRuns,
+		Validation:   service.ValidationMode(cfg.JobsValidation),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initialize service: %w", err)
+	}
+
+	if err := validateJobs(svc, cfg.JobsValidation, appLogger); err != nil {
+		return nil, err
+	}
 
 	// Initialize API layer
 	handlers := api.NewHandlers(svc)
@@ -133,13 +220,32 @@ func New(cfg *Config) (*Gateway, error) {
 	configAPIKeys := make([]config.APIKey, len(cfg.Auth.APIKeys))
 	for i, key := range cfg.Auth.APIKeys {
 		configAPIKeys[i] = config.APIKey{
-			Name: key.Name,
-			Key:  key.Key,
+			Name:   key.Name,
+			Key:    key.Key,
+			Scopes: key.Scopes,
 		}
 	}
 	authMiddleware := api.NewAuthMiddleware(configAPIKeys)
 	loggingMiddleware := api.NewLoggingMiddleware(appLogger)
-	router := api.NewRouter(handlers, authMiddleware, loggingMiddleware)
+
+	// Initialize rate limiter, including overrides of jobs registered through the API
+	rateLimitCfg, err := buildRateLimitConfig(cfg.RateLimit, svc.ListJobs(context.Background()))
+	if err != nil {
+		return nil, fmt.Errorf("rate limit config: %w", err)
+	}
+	// The limiter is built even without budgets so that per-job limits added
+	// by a later reload take effect
+	limiter := ratelimit.New(rateLimitCfg)
+	if rateLimitCfg.Enabled() {
+		appLogger.Info("rate limiting enabled",
+			"key_trigger", rateLimitCfg.KeyTrigger.String(),
+			"key_read", rateLimitCfg.KeyRead.String(),
+			"job_trigger", rateLimitCfg.JobTrigger.String(),
+			"job_read", rateLimitCfg.JobRead.String())
+	}
+	rateLimitMiddleware := api.NewRateLimitMiddleware(limiter)
+
+	router := api.NewRouter(handlers, authMiddleware, loggingMiddleware, rateLimitMiddleware)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -149,18 +255,19 @@ func New(cfg *Config) (*Gateway, error) {
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
-	return &Gateway{
-		config:  cfg,
-		service: svc,
-		router:  router,
-		server:  srv,
-		logger:  appLogger,
-	}, nil
+	gw.service = svc
+	gw.router = router
+	gw.server = srv
+	gw.limiter = limiter
+	return gw, nil
 }
 
 // Start starts the HTTP server
 // This is a blocking call that will run until the context is canceled or an error occurs
 func (g *Gateway) Start(ctx context.Context) error {
+	// Start background processing (run tracking, queue dispatch)
+	g.StartBackground(ctx)
+
 	serverErrors := make(chan error, 1)
 
 	// Start server in goroutine
@@ -194,6 +301,16 @@ func (g *Gateway) Start(ctx context.Context) error {
 	}
 }
 
+// StartBackground starts background processing (run tracking, queue dispatch)
+// without starting the HTTP server. Call this when serving Handler() from your
+// own server. It returns immediately; processing stops when ctx is canceled.
+func (g *Gateway) StartBackground(ctx context.Context) {
+	g.service.Start(ctx)
+	if g.config.JobsFile != "" && g.config.JobsReloadInterval > 0 {
+		go g.watchJobsFile(ctx)
+	}
+}
+
 // Handler returns the http.Handler for the gateway
 // Use this if you want to integrate the gateway into an existing HTTP server
 func (g *Gateway) Handler() http.Handler {
@@ -207,16 +324,21 @@ func (g *Gateway) Service() *service.Service {
 }
 
 // NewFromEnv creates a Gateway instance from environment variables and config files
-// This is a convenience function that mirrors the behavior of the standalone gateway
+// This is a convenience function that mirrors the behavior of the standalone gateway.
+// Settings are read from CONFIG_FILE, if set, with environment variables taking
+// precedence. An empty jobsFile uses the jobs file from the configuration.
 func NewFromEnv(jobsFile string) (*Gateway, error) {
-	// Load gateway configuration from environment variables
+	// Load gateway configuration from CONFIG_FILE and environment variables
 	cfg, err := config.Load()
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
+	if jobsFile == "" {
+		jobsFile = cfg.JobsFile
+	}
 
-	// Load job definitions
-	jobs, err := config.LoadJobs(jobsFile)
+	// Load job, workflow and freeze definitions
+	jobsConfig, err := config.LoadJobsFile(jobsFile)
 	if err != nil {
 		return nil, fmt.Errorf("load jobs: %w", err)
 	}
@@ -226,8 +348,9 @@ func NewFromEnv(jobsFile string) (*Gateway, error) {
 	gwAPIKeys := make([]APIKey, len(cfg.Auth.APIKeys))
 	for i, key := range cfg.Auth.APIKeys {
 		gwAPIKeys[i] = APIKey{
-			Name: key.Name,
-			Key:  key.Key,
+			Name:   key.Name,
+			Key:    key.Key,
+			Scopes: key.Scopes,
 		}
 	}
 
@@ -251,12 +374,149 @@ func NewFromEnv(jobsFile string) (*Gateway, error) {
 				TokenRefreshMargin: cfg.Concourse.TokenRefreshMargin,
 			},
 		},
-		Jobs: jobs,
+		Jobs:               jobsConfig.Jobs,
+		Workflows:          jobsConfig.Workflows,
+		Freezes:            jobsConfig.Freezes,
+		Approvals:          jobsConfig.Approvals,
+		JobsFile:           jobsFile,
+		JobsReloadInterval: cfg.JobsReloadInterval,
+		JobsValidation:     cfg.JobsValidation,
+		ConfigHash:         jobsConfig.Hash,
 		Logging: LoggingConfig{
 			Level:  cfg.Logging.Level,
 			Format: cfg.Logging.Format,
 		},
+		RateLimit: RateLimitConfig{
+			KeyTrigger: cfg.RateLimit.KeyTrigger,
+			KeyRead:    cfg.RateLimit.KeyRead,
+			JobTrigger: cfg.RateLimit.JobTrigger,
+			JobRead:    cfg.RateLimit.JobRead,
+		},
+		State: StateConfig{
+			Dir:          cfg.State.Dir,
+			PollInterval: cfg.State.PollInterval,
+		},
+	}
+
+	if len(cfg.RateLimit.KeyOverrides) > 0 {
+		gwConfig.RateLimit.KeyOverrides = make(map[string]RateLimits, len(cfg.RateLimit.KeyOverrides))
+		for name, limits := range cfg.RateLimit.KeyOverrides {
+			gwConfig.RateLimit.KeyOverrides[name] = RateLimits{
+				Trigger: limits.Trigger,
+				Read:    limits.Read,
+			}
+		}
 	}
 
 	return New(gwConfig)
 }
+
+// validateJobs checks jobs against the provider at startup. Strict mode fails
+// on unavailable jobs or when the provider can't be reached.
+func validateJobs(svc *service.Service, mode string, log *logger.Logger) error {
+	switch service.ValidationMode(mode) {
+	case "", service.ValidationOff:
+		return nil
+	case service.ValidationLenient, service.ValidationStrict:
+	default:
+		return fmt.Errorf("unknown jobs validation mode %q", mode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results, err := svc.ValidateJobs(ctx)
+	if err != nil && service.ValidationMode(mode) == service.ValidationStrict {
+		return fmt.Errorf("validate jobs: %w", err)
+	}
+	if err != nil {
+		log.Warn("jobs could not be validated against the provider", "error", err)
+	}
+
+	unavailable := 0
+	for _, avail := range results {
+		if !avail.Available {
+			unavailable++
+		}
+	}
+	log.Info("jobs validated against provider", "mode", mode, "checked", len(results), "unavailable", unavailable)
+	return nil
+}
+
+// allowRuns charges each run of a batch or workflow against the caller's
+// trigger budget and the trigger budget of its job
+func (g *Gateway) allowRuns(ctx context.Context, jobIDs []string) (bool, time.Duration) {
+	if g.limiter == nil {
+		return true, 0
+	}
+	key := ratelimit.Target{Scope: ratelimit.ScopeKey, Subject: api.GetAPIKeyName(ctx), Class: ratelimit.ClassTrigger}
+	charges := map[ratelimit.Target]int{key: len(jobIDs)}
+	for _, jobID := range jobIDs {
+		charges[ratelimit.Target{Scope: ratelimit.ScopeJob, Subject: jobID, Class: ratelimit.ClassTrigger}]++
+	}
+	return g.limiter.AllowEach(charges)
+}
+
+// buildRateLimitConfig parses rate limit budgets and collects per-job overrides
+func buildRateLimitConfig(cfg RateLimitConfig, jobs []*models.Job) (ratelimit.Config, error) {
+	var rlCfg ratelimit.Config
+	var err error
+
+	if rlCfg.KeyTrigger, err = ratelimit.ParseRate(cfg.KeyTrigger); err != nil {
+		return rlCfg, fmt.Errorf("key trigger: %w", err)
+	}
+	if rlCfg.KeyRead, err = ratelimit.ParseRate(cfg.KeyRead); err != nil {
+		return rlCfg, fmt.Errorf("key read: %w", err)
+	}
+	if rlCfg.JobTrigger, err = ratelimit.ParseRate(cfg.JobTrigger); err != nil {
+		return rlCfg, fmt.Errorf("job trigger: %w", err)
+	}
+	if rlCfg.JobRead, err = ratelimit.ParseRate(cfg.JobRead); err != nil {
+		return rlCfg, fmt.Errorf("job read: %w", err)
+	}
+
+	rlCfg.KeyOverrides = make(map[string]ratelimit.Limits)
+	for name, limits := range cfg.KeyOverrides {
+		parsed, err := parseRateLimits(limits.Trigger, limits.Read)
+		if err != nil {
+			return rlCfg, fmt.Errorf("api key %s: %w", name, err)
+		}
+		rlCfg.KeyOverrides[name] = parsed
+	}
+
+	rlCfg.JobOverrides = make(map[string]ratelimit.Limits)
+	rlCfg.Jobs = make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		rlCfg.Jobs[job.JobID] = true
+		if job.RateLimit == nil {
+			continue
+		}
+		parsed, err := parseRateLimits(job.RateLimit.Trigger, job.RateLimit.Read)
+		if err != nil {
+			return rlCfg, fmt.Errorf("job %s: %w", job.JobID, err)
+		}
+		rlCfg.JobOverrides[job.JobID] = parsed
+	}
+
+	return rlCfg, nil
+}
+
+// parseRateLimits parses an override pair, leaving empty values to inherit defaults
+func parseRateLimits(trigger, read string) (ratelimit.Limits, error) {
+	var limits ratelimit.Limits
+	if trigger != "" {
+		rate, err := ratelimit.ParseRate(trigger)
+		if err != nil {
+			return limits, fmt.Errorf("trigger: %w", err)
+		}
+		limits.Trigger = &rate
+	}
+	if read != "" {
+		rate, err := ratelimit.ParseRate(read)
+		if err != nil {
+			return limits, fmt.Errorf("read: %w", err)
+		}
+		limits.Read = &rate
+	}
+	return limits, nil
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lei/simple-ci/internal/config"
+	"github.com/lei/simple-ci/internal/models"
+	"github.com/lei/simple-ci/internal/service"
+)
+
+// Reload re-reads JobsFile and swaps the new jobs, workflows and freezes into
+// the running gateway without dropping connections. If the file is invalid the
+// current configuration stays in effect; the error is returned, logged and
+// reported in /health?detailed=true.
+func (g *Gateway) Reload(ctx context.Context) error {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+
+	if g.config.JobsFile == "" {
+		return fmt.Errorf("no jobs file configured")
+	}
+
+	g.logger.Info("reloading jobs file", "path", g.config.JobsFile)
+
+	jobsConfig, err := config.LoadJobsFile(g.config.JobsFile)
+	if err != nil {
+		g.service.ReloadFailed(ctx, err)
+		return err
+	}
+
+	// Per-job rate limits are applied by applyJobRateLimits once the service
+	// has merged in API-managed jobs
+	if _, err := buildRateLimitConfig(g.config.RateLimit, jobsConfig.Jobs); err != nil {
+		err = fmt.Errorf("rate limit config: %w", err)
+		g.service.ReloadFailed(ctx, err)
+		return err
+	}
+
+	if err := g.service.Reload(ctx, service.Definitions{
+		Jobs:      jobsConfig.Jobs,
+		Workflows: jobsConfig.Workflows,
+		Freezes:   jobsConfig.Freezes,
+		Approvals: jobsConfig.Approvals,
+		Hash:      jobsConfig.Hash,
+	}); err != nil {
+		return err
+	}
+
+	g.config.Jobs = jobsConfig.Jobs
+	g.config.Workflows = jobsConfig.Workflows
+	g.config.Freezes = jobsConfig.Freezes
+	g.config.Approvals = jobsConfig.Approvals
+	g.config.ConfigHash = jobsConfig.Hash
+	return nil
+}
+
+// applyJobRateLimits replaces per-job rate limit overrides whenever the
+// service's jobs change through a reload or the job management API
+func (g *Gateway) applyJobRateLimits(jobs []*models.Job) {
+	rateLimitCfg, err := buildRateLimitConfig(g.config.RateLimit, jobs)
+	if err != nil {
+		g.logger.Error("per-job rate limits not updated", "error", err)
+		return
+	}
+
+	g.limiter.SetJobs(rateLimitCfg.Jobs, rateLimitCfg.JobOverrides)
+}
+
+// watchJobsFile reloads the jobs file whenever its contents or the contents
+// of a file it includes change
+func (g *Gateway) watchJobsFile(ctx context.Context) {
+	ticker := time.NewTicker(g.config.JobsReloadInterval)
+	defer ticker.Stop()
+
+	g.logger.Info("watching jobs file",
+		"path", g.config.JobsFile,
+		"interval", g.config.JobsReloadInterval)
+
+	seen := g.service.ConfigHash()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		hash, err := config.JobsFileHash(g.config.JobsFile)
+		if err != nil {
+			// Editors may briefly remove the file while saving
+			g.logger.Debug("jobs file not readable", "path", g.config.JobsFile, "error", err)
+			continue
+		}
+		if hash == seen {
+			continue
+		}
+		seen = hash
+
+		// Errors are logged and reported by the service
+		_ = g.Reload(ctx)
+	}
+}